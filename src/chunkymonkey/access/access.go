@@ -0,0 +1,80 @@
+// Package access decides which players may log in, with bans of player names
+// and IP addresses, and a whitelist.
+package access
+
+import (
+	"os"
+	"strconv"
+)
+
+const msgNotWhitelisted = "You are not on the whitelist of this server."
+
+// Lists holds the bans and the whitelist of a server.
+type Lists struct {
+	Bans      *BanList // Bans of player names.
+	IpBans    *BanList // Bans of IP addresses.
+	Whitelist *Whitelist
+}
+
+// LoadListsFromFiles loads the bans and the whitelist from their files. Files
+// that do not exist are created when the lists are first changed.
+func LoadListsFromFiles(bansFile, ipBansFile, whitelistFile string) (lists *Lists, err os.Error) {
+	lists = new(Lists)
+
+	if lists.Bans, err = LoadBanListFromFile(bansFile); err != nil {
+		return nil, err
+	}
+	if lists.IpBans, err = LoadBanListFromFile(ipBansFile); err != nil {
+		return nil, err
+	}
+	if lists.Whitelist, err = LoadWhitelistFromFile(whitelistFile); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Check returns ok=true if the named player, connecting from the IP address,
+// may log in. Otherwise it returns the reason to give them.
+func (lists *Lists) Check(name, ip string) (refusal string, ok bool) {
+	if ban, banned := lists.Bans.Banned(name); banned {
+		return ban.Message(), false
+	}
+	if ban, banned := lists.IpBans.Banned(ip); banned {
+		return ban.Message(), false
+	}
+	if !lists.Whitelist.Allows(name) {
+		return msgNotWhitelisted, false
+	}
+
+	return "", true
+}
+
+// durationUnits are the units of durations given to ParseDuration, in seconds.
+var durationUnits = map[byte]int64{
+	's': 1,
+	'm': 60,
+	'h': 60 * 60,
+	'd': 24 * 60 * 60,
+	'w': 7 * 24 * 60 * 60,
+}
+
+// ParseDuration parses a positive number of seconds, minutes, hours, days or
+// weeks such as "30m" or "7d", returning the number of seconds.
+func ParseDuration(s string) (seconds int64, err os.Error) {
+	if len(s) < 2 {
+		return 0, os.NewError("bad duration: " + s)
+	}
+
+	unit, ok := durationUnits[s[len(s)-1]]
+	if !ok {
+		return 0, os.NewError("bad duration unit: " + s)
+	}
+
+	n, err := strconv.Atoi64(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, os.NewError("bad duration: " + s)
+	}
+
+	return n * unit, nil
+}
@@ -0,0 +1,110 @@
+package access
+
+import (
+	"testing"
+	"time"
+)
+
+func testLists() *Lists {
+	return &Lists{
+		Bans:      NewBanList(),
+		IpBans:    NewBanList(),
+		Whitelist: NewWhitelist(),
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	type Test struct {
+		input    string
+		expected int64
+		ok       bool
+	}
+
+	tests := []Test{
+		{"30s", 30, true},
+		{"5m", 5 * 60, true},
+		{"2h", 2 * 60 * 60, true},
+		{"7d", 7 * 24 * 60 * 60, true},
+		{"1w", 7 * 24 * 60 * 60, true},
+		{"", 0, false},
+		{"d", 0, false},
+		{"10", 0, false},
+		{"0d", 0, false},
+		{"-1d", 0, false},
+		{"xd", 0, false},
+	}
+
+	for _, test := range tests {
+		result, err := ParseDuration(test.input)
+		if test.ok != (err == nil) || result != test.expected {
+			t.Errorf("ParseDuration(%q) = %d, %v, expected %d, ok=%t", test.input, result, err, test.expected, test.ok)
+		}
+	}
+}
+
+func TestLists_Check(t *testing.T) {
+	lists := testLists()
+	now := time.Seconds()
+
+	lists.Bans.Add("Griefy", "griefing", "huin", 0)
+	lists.Bans.Add("expired", "spam", "huin", now-1)
+	lists.Bans.Add("later", "spam", "huin", now+60)
+	lists.IpBans.Add("10.0.0.1", "", "huin", 0)
+
+	type Test struct {
+		name, ip string
+		ok       bool
+	}
+
+	tests := []Test{
+		{"newbie", "10.0.0.2", true},
+		{"griefy", "10.0.0.2", false},
+		{"expired", "10.0.0.2", true},
+		{"later", "10.0.0.2", false},
+		{"newbie", "10.0.0.1", false},
+	}
+
+	for _, test := range tests {
+		if refusal, ok := lists.Check(test.name, test.ip); ok != test.ok {
+			t.Errorf("Check(%q, %q) = %q, %t, expected ok=%t", test.name, test.ip, refusal, ok, test.ok)
+		}
+	}
+
+	if refusal, _ := lists.Check("griefy", "10.0.0.2"); refusal != "You are banned forever: griefing" {
+		t.Errorf("Unexpected refusal %q", refusal)
+	}
+
+	if ok, _ := lists.Bans.Remove("GRIEFY"); !ok {
+		t.Errorf("Expected to unban griefy")
+	}
+	if _, ok := lists.Check("griefy", "10.0.0.2"); !ok {
+		t.Errorf("Expected griefy to be unbanned")
+	}
+	if ok, _ := lists.Bans.Remove("expired"); ok {
+		t.Errorf("Expected an expired ban not to be removed")
+	}
+}
+
+func TestWhitelist(t *testing.T) {
+	lists := testLists()
+
+	lists.Whitelist.Add("Huin")
+	if _, ok := lists.Check("newbie", "10.0.0.2"); !ok {
+		t.Errorf("Expected a disabled whitelist to allow anyone")
+	}
+
+	lists.Whitelist.SetEnabled(true)
+	if _, ok := lists.Check("newbie", "10.0.0.2"); ok {
+		t.Errorf("Expected the whitelist to refuse newbie")
+	}
+	if _, ok := lists.Check("huin", "10.0.0.2"); !ok {
+		t.Errorf("Expected the whitelist to allow huin")
+	}
+
+	if ok, _ := lists.Whitelist.Remove("huin"); !ok {
+		t.Errorf("Expected to remove huin from the whitelist")
+	}
+	if lists.Whitelist.Allows("huin") {
+		t.Errorf("Expected the whitelist to refuse huin once removed")
+	}
+}
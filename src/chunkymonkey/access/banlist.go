@@ -0,0 +1,183 @@
+package access
+
+import (
+	"io/ioutil"
+	"json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chunkymonkey/util"
+)
+
+// Ban records why and until when a player or address is banned.
+type Ban struct {
+	Reason  string
+	By      string // The name of whoever made the ban.
+	Created int64  // Seconds since the Unix epoch.
+	Expires int64  // Seconds since the Unix epoch, or zero if the ban is permanent.
+}
+
+// Expired returns true if the ban has expired at the given time, in seconds
+// since the Unix epoch.
+func (ban *Ban) Expired(now int64) bool {
+	return ban.Expires != 0 && ban.Expires <= now
+}
+
+// Until describes when the ban expires.
+func (ban *Ban) Until() string {
+	if ban.Expires == 0 {
+		return "forever"
+	}
+	return "until " + time.SecondsToUTC(ban.Expires).Format("2006-01-02 15:04 MST")
+}
+
+// Message is what a banned player is told when they are kicked or refused.
+func (ban *Ban) Message() string {
+	msg := "You are banned " + ban.Until()
+	if ban.Reason != "" {
+		msg += ": " + ban.Reason
+	}
+	return msg
+}
+
+// BanList is a set of bans of player names or IP addresses, stored in a JSON
+// file. Names are not case sensitive. It is safe to use from multiple
+// goroutines.
+type BanList struct {
+	lock     sync.Mutex
+	filename string // Empty if the bans are not saved.
+	bans     map[string]*Ban
+}
+
+// LoadBanListFromFile loads the bans in filename. If the file does not exist
+// then the list starts empty. Changes to the bans are saved back to the file.
+func LoadBanListFromFile(filename string) (banList *BanList, err os.Error) {
+	banList = NewBanList()
+	banList.filename = filename
+
+	var bans map[string]*Ban
+	if err = loadJsonFile(filename, &bans); err != nil {
+		return nil, err
+	}
+	for key, ban := range bans {
+		banList.bans[strings.ToLower(key)] = ban
+	}
+
+	return
+}
+
+// NewBanList creates an empty list of bans that is not saved.
+func NewBanList() *BanList {
+	return &BanList{
+		bans: make(map[string]*Ban),
+	}
+}
+
+// save writes the bans to their file, leaving out those that have expired. It
+// must be called with the lock held.
+func (banList *BanList) save() os.Error {
+	now := time.Seconds()
+	for key, ban := range banList.bans {
+		if ban.Expired(now) {
+			banList.bans[key] = nil, false
+		}
+	}
+
+	return saveJsonFile(banList.filename, banList.bans)
+}
+
+// Add bans the name or address until expires, in seconds since the Unix
+// epoch. Zero bans it permanently. Any earlier ban of it is replaced.
+func (banList *BanList) Add(key string, reason string, by string, expires int64) os.Error {
+	banList.lock.Lock()
+	defer banList.lock.Unlock()
+
+	banList.bans[strings.ToLower(key)] = &Ban{
+		Reason:  reason,
+		By:      by,
+		Created: time.Seconds(),
+		Expires: expires,
+	}
+
+	return banList.save()
+}
+
+// Remove lifts the ban of the name or address. It returns false if it was not
+// banned.
+func (banList *BanList) Remove(key string) (ok bool, err os.Error) {
+	banList.lock.Lock()
+	defer banList.lock.Unlock()
+
+	key = strings.ToLower(key)
+	ban, ok := banList.bans[key]
+	if !ok || ban.Expired(time.Seconds()) {
+		return false, nil
+	}
+	banList.bans[key] = nil, false
+
+	return true, banList.save()
+}
+
+// Banned returns the ban of the name or address, if it is banned.
+func (banList *BanList) Banned(key string) (ban Ban, ok bool) {
+	banList.lock.Lock()
+	defer banList.lock.Unlock()
+
+	b, ok := banList.bans[strings.ToLower(key)]
+	if !ok || b.Expired(time.Seconds()) {
+		return ban, false
+	}
+
+	return *b, true
+}
+
+// Keys returns the banned names or addresses in order.
+func (banList *BanList) Keys() []string {
+	banList.lock.Lock()
+	defer banList.lock.Unlock()
+
+	now := time.Seconds()
+	keys := make([]string, 0, len(banList.bans))
+	for key, ban := range banList.bans {
+		if !ban.Expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// loadJsonFile decodes the JSON in filename into v. It does nothing if the
+// file does not exist.
+func loadJsonFile(filename string, v interface{}) os.Error {
+	file, err := os.Open(filename)
+	if err != nil {
+		if errno, ok := util.Errno(err); ok && errno == os.ENOENT {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	return decoder.Decode(v)
+}
+
+// saveJsonFile writes v to filename as JSON. It does nothing if filename is
+// empty.
+func saveJsonFile(filename string, v interface{}) os.Error {
+	if filename == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, 0644)
+}
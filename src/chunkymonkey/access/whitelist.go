@@ -0,0 +1,127 @@
+package access
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// whitelistFile is how a Whitelist is stored.
+type whitelistFile struct {
+	Enabled bool
+	Names   []string
+}
+
+// Whitelist is the set of players that may log in while it is enabled, stored
+// in a JSON file. Names are not case sensitive. It is safe to use from
+// multiple goroutines.
+type Whitelist struct {
+	lock     sync.Mutex
+	filename string // Empty if the whitelist is not saved.
+	enabled  bool
+	names    map[string]bool
+}
+
+// LoadWhitelistFromFile loads the whitelist in filename. If the file does not
+// exist then the whitelist starts empty and disabled. Changes to the whitelist
+// are saved back to the file.
+func LoadWhitelistFromFile(filename string) (whitelist *Whitelist, err os.Error) {
+	whitelist = NewWhitelist()
+	whitelist.filename = filename
+
+	var data whitelistFile
+	if err = loadJsonFile(filename, &data); err != nil {
+		return nil, err
+	}
+
+	whitelist.enabled = data.Enabled
+	for _, name := range data.Names {
+		whitelist.names[strings.ToLower(name)] = true
+	}
+
+	return
+}
+
+// NewWhitelist creates an empty, disabled whitelist that is not saved.
+func NewWhitelist() *Whitelist {
+	return &Whitelist{
+		names: make(map[string]bool),
+	}
+}
+
+// save writes the whitelist to its file. It must be called with the lock held.
+func (whitelist *Whitelist) save() os.Error {
+	return saveJsonFile(whitelist.filename, &whitelistFile{
+		Enabled: whitelist.enabled,
+		Names:   whitelist.sortedNames(),
+	})
+}
+
+// Allows returns true if the whitelist is disabled or has the player on it.
+func (whitelist *Whitelist) Allows(name string) bool {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	return !whitelist.enabled || whitelist.names[strings.ToLower(name)]
+}
+
+func (whitelist *Whitelist) Enabled() bool {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	return whitelist.enabled
+}
+
+// SetEnabled turns the whitelist on or off.
+func (whitelist *Whitelist) SetEnabled(enabled bool) os.Error {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	whitelist.enabled = enabled
+	return whitelist.save()
+}
+
+// Add puts the player on the whitelist.
+func (whitelist *Whitelist) Add(name string) os.Error {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	whitelist.names[strings.ToLower(name)] = true
+	return whitelist.save()
+}
+
+// Remove takes the player off the whitelist. It returns false if they were not
+// on it.
+func (whitelist *Whitelist) Remove(name string) (ok bool, err os.Error) {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	name = strings.ToLower(name)
+	if !whitelist.names[name] {
+		return false, nil
+	}
+	whitelist.names[name] = false, false
+
+	return true, whitelist.save()
+}
+
+// Names returns the names on the whitelist in order.
+func (whitelist *Whitelist) Names() []string {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	return whitelist.sortedNames()
+}
+
+// sortedNames returns the names on the whitelist in order. It must be called
+// with the lock held.
+func (whitelist *Whitelist) sortedNames() []string {
+	names := make([]string, 0, len(whitelist.names))
+	for name := range whitelist.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -19,19 +19,27 @@ type IChunkStoreForeground interface {
 	WriteChunk(writer IChunkWriter) os.Error
 }
 
+// iFlusher is implemented by an IChunkStoreForeground that writes chunks
+// through another IChunkStore, which must be flushed in turn.
+type iFlusher interface {
+	Flush()
+}
+
 // ChunkService adapts an IChunkStoreForeground (which can only be accessed
 // from one goroutine) to an IChunkStore.
 type ChunkService struct {
-	store  IChunkStoreForeground
-	reads  chan readRequest
-	writes chan IChunkWriter
+	store   IChunkStoreForeground
+	reads   chan readRequest
+	writes  chan IChunkWriter
+	flushes chan chan bool
 }
 
 func NewChunkService(store IChunkStoreForeground) (s *ChunkService) {
 	return &ChunkService{
-		store:  store,
-		reads:  make(chan readRequest),
-		writes: make(chan IChunkWriter),
+		store:   store,
+		reads:   make(chan readRequest),
+		writes:  make(chan IChunkWriter),
+		flushes: make(chan chan bool),
 	}
 }
 
@@ -45,6 +53,13 @@ func (s *ChunkService) Serve() {
 			if err := s.store.WriteChunk(writer); err != nil {
 				log.Printf("Could not write chunk at %#v: %v", writer.ChunkLoc(), err)
 			}
+		case done := <-s.flushes:
+			// Writes are performed in order, so all earlier writes to this store
+			// are complete.
+			if flusher, ok := s.store.(iFlusher); ok {
+				flusher.Flush()
+			}
+			done <- true
 		}
 	}
 }
@@ -71,3 +86,9 @@ func (s *ChunkService) Writer() IChunkWriter {
 func (s *ChunkService) WriteChunk(writer IChunkWriter) {
 	s.writes <- writer
 }
+
+func (s *ChunkService) Flush() {
+	done := make(chan bool)
+	s.flushes <- done
+	<-done
+}
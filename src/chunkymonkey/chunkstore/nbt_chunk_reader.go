@@ -56,6 +56,11 @@ func (r *nbtChunkReader) HeightMap() []byte {
 	return r.chunkTag.Lookup("Level/HeightMap").(*nbt.ByteArray).Value
 }
 
+func (r *nbtChunkReader) TerrainPopulated() bool {
+	populated, ok := r.chunkTag.Lookup("Level/TerrainPopulated").(*nbt.Byte)
+	return ok && populated.Value != 0
+}
+
 func (r *nbtChunkReader) Entities() (entities []gamerules.INonPlayerEntity) {
 	entityListTag, ok := r.chunkTag.Lookup("Level/Entities").(*nbt.List)
 	if !ok {
@@ -85,6 +90,53 @@ func (r *nbtChunkReader) Entities() (entities []gamerules.INonPlayerEntity) {
 	return
 }
 
+func (r *nbtChunkReader) TileEntities() (tileEntities []gamerules.ITileEntity) {
+	tileEntityListTag, ok := r.chunkTag.Lookup("Level/TileEntities").(*nbt.List)
+	if !ok {
+		return
+	}
+
+	tileEntities = make([]gamerules.ITileEntity, 0, len(tileEntityListTag.Value))
+
+	for _, tileEntityTag := range tileEntityListTag.Value {
+		tileEntityId, ok := tileEntityTag.Lookup("id").(*nbt.String)
+
+		if !ok {
+			log.Printf("missing or bad tile entity type ID in NBT: %s", tileEntityId)
+		} else {
+			if tileEntity := gamerules.NewTileEntityByTypeName(tileEntityId.Value); tileEntity == nil {
+				log.Printf("Found unhandled tile entity type: %s", tileEntityId.Value)
+			} else {
+				if err := tileEntity.ReadNbt(tileEntityTag); err != nil {
+					log.Printf("Error reading tile entity NBT: %s", err)
+				} else if !r.blockHasTileEntity(tileEntity, tileEntityId.Value) {
+					log.Printf("Dropping %s tile entity at %v, which is not on a block of its type", tileEntityId.Value, tileEntity.Block())
+				} else {
+					tileEntities = append(tileEntities, tileEntity)
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// blockHasTileEntity returns true if the block that the tile entity is at keeps
+// its state in tile entities of the named type. Tile entities can be left
+// behind on other blocks by world editors.
+func (r *nbtChunkReader) blockHasTileEntity(tileEntity gamerules.ITileEntity, typeName string) bool {
+	blockLoc := tileEntity.Block()
+	_, subLoc := blockLoc.ToChunkLocal()
+	index, ok := subLoc.BlockIndex()
+	blocks := r.Blocks()
+	if !ok || int(index) >= len(blocks) {
+		return false
+	}
+
+	blockType, ok := gamerules.Blocks.Get(BlockId(blocks[index]))
+	return ok && blockType.HasTileEntity(typeName)
+}
+
 func (r *nbtChunkReader) RootTag() nbt.ITag {
 	return r.chunkTag
 }
@@ -24,14 +24,14 @@ func newNbtChunkWriter() *nbtChunkWriter {
 		chunkTag: &nbt.Compound{map[string]nbt.ITag{
 			"Level": &nbt.Compound{map[string]nbt.ITag{
 				"Entities":         &nbt.List{nbt.TagCompound, nil},
-				"TileEntities":     &nbt.List{nbt.TagCompound, nil}, // TODO
+				"TileEntities":     &nbt.List{nbt.TagCompound, nil},
 				"Blocks":           &nbt.ByteArray{},
 				"Data":             &nbt.ByteArray{},
 				"HeightMap":        &nbt.ByteArray{},
 				"SkyLight":         &nbt.ByteArray{},
 				"BlockLight":       &nbt.ByteArray{},
 				"LastUpdate":       &nbt.Long{0}, // TODO
-				"TerrainPopulated": &nbt.Byte{1}, // TODO
+				"TerrainPopulated": &nbt.Byte{0},
 				"xPos":             &nbt.Int{0},
 				"zPos":             &nbt.Int{0},
 			}},
@@ -69,6 +69,14 @@ func (w *nbtChunkWriter) SetHeightMap(heightMap []byte) {
 	w.chunkTag.Lookup("Level/HeightMap").(*nbt.ByteArray).Value = cloneByteArray(heightMap)
 }
 
+func (w *nbtChunkWriter) SetTerrainPopulated(populated bool) {
+	var value int8
+	if populated {
+		value = 1
+	}
+	w.chunkTag.Lookup("Level/TerrainPopulated").(*nbt.Byte).Value = value
+}
+
 func (w *nbtChunkWriter) SetEntities(entities map[EntityId]gamerules.INonPlayerEntity) {
 	entitiesNbt := make([]nbt.ITag, 0, len(entities))
 	for _, entity := range entities {
@@ -80,6 +88,17 @@ func (w *nbtChunkWriter) SetEntities(entities map[EntityId]gamerules.INonPlayerE
 	w.chunkTag.Lookup("Level/Entities").(*nbt.List).Value = entitiesNbt
 }
 
+func (w *nbtChunkWriter) SetTileEntities(tileEntities []gamerules.ITileEntity) {
+	tileEntitiesNbt := make([]nbt.ITag, 0, len(tileEntities))
+	for _, tileEntity := range tileEntities {
+		nbtData := tileEntity.WriteNbt()
+		if nbtData != nil {
+			tileEntitiesNbt = append(tileEntitiesNbt, nbtData)
+		}
+	}
+	w.chunkTag.Lookup("Level/TileEntities").(*nbt.List).Value = tileEntitiesNbt
+}
+
 func (w *nbtChunkWriter) RootTag() nbt.ITag {
 	return w.chunkTag
 }
@@ -25,6 +25,10 @@ type IChunkStore interface {
 	// Submits the set chunk data for writing. The chunk writer must not be
 	// altered any further after calling this.
 	WriteChunk(writer IChunkWriter)
+
+	// Flush returns once all chunks submitted to WriteChunk before it was
+	// called have been written.
+	Flush()
 }
 
 type IChunkReader interface {
@@ -46,9 +50,17 @@ type IChunkReader interface {
 	// Returns the height map data in the chunk.
 	HeightMap() []byte
 
+	// Returns true if the chunk has been decorated by the generator's
+	// population pass (trees, ores, etc.).
+	TerrainPopulated() bool
+
 	// Return a list of the entities (items, mobs) within the chunk.
 	Entities() []gamerules.INonPlayerEntity
 
+	// Returns a list of the tile entities (chest contents, etc.) within the
+	// chunk.
+	TileEntities() []gamerules.ITileEntity
+
 	// For low-level NBT access. Not for regular use. It's possible that this
 	// might return nil if the underlying system doesn't use NBT.
 	RootTag() nbt.ITag
@@ -81,8 +93,34 @@ type IChunkWriter interface {
 	// Sets the height map data in the chunk.
 	SetHeightMap(heightMap []byte)
 
+	// Sets whether the chunk has been decorated by the generator's population
+	// pass.
+	SetTerrainPopulated(populated bool)
+
 	// Sets a list of the entities (items, mobs) within the chunk.
 	SetEntities(entities map[EntityId]gamerules.INonPlayerEntity)
+
+	// Sets a list of the tile entities (chest contents, etc.) within the chunk.
+	SetTileEntities(tileEntities []gamerules.ITileEntity)
+}
+
+// IPopulator is implemented by generators whose chunks are decorated by a
+// population pass once the chunks around them exist. The pass for a chunk
+// places lakes, dungeons, trees and other plants, which can spread into the
+// neighbouring chunks at +X, +Z and +X+Z from it.
+type IPopulator interface {
+	// Populate runs the population pass for the chunk at loc, and returns the
+	// changes that it makes to the blocks. blocks and blockData are those of
+	// the chunk and its neighbours, with the chunk at loc+(dx, dz) at index
+	// dx*2+dz. They are not altered. It is safe to call from any goroutine.
+	Populate(loc ChunkXz, blocks, blockData [4][]byte) []BlockChange
+}
+
+// BlockChange is a change made to a block by a population pass.
+type BlockChange struct {
+	gamerules.BlockState
+	// TileEntity is the tile entity of the block, or nil for none.
+	TileEntity gamerules.ITileEntity
 }
 
 // Given the NamedTag for a level.dat, returns an appropriate
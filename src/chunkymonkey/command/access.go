@@ -0,0 +1,200 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"chunkymonkey/access"
+	"chunkymonkey/gamerules"
+)
+
+const msgNotConnected = "'%s' is not connected."
+
+// /kick <player> [<reason>]
+const kickCmd = "kick"
+const kickUsage = "kick <player> [<reason>]"
+const kickDesc = "Disconnects a player from the server."
+const kickPermission = "admin.commands.kick"
+const msgKicked = "Kicked by an operator."
+
+func cmdKick(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	if len(args) < 2 {
+		player.EchoMessage(kickUsage)
+		return
+	}
+
+	reason := msgKicked
+	if len(args) > 2 {
+		reason = strings.Join(args[2:], " ")
+	}
+
+	if !cmdHandler.KickPlayer(args[1], reason) {
+		player.EchoMessage(fmt.Sprintf(msgNotConnected, args[1]))
+		return
+	}
+	cmdHandler.BroadcastMessage(fmt.Sprintf("%s was kicked: %s", args[1], reason))
+}
+
+// /ban <player> [<duration>] [<reason>]
+const banCmd = "ban"
+const banUsage = "ban <player> [<duration>] [<reason>]"
+const banDesc = "Bans a player, optionally for a duration such as 30m, 12h or 7d."
+const banPermission = "admin.commands.ban"
+
+func cmdBan(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	if len(args) < 2 {
+		player.EchoMessage(banUsage)
+		return
+	}
+
+	name := args[1]
+	expires, reason := parseBanArgs(args[2:])
+
+	bans := cmdHandler.AccessLists().Bans
+	if err := bans.Add(name, reason, player.Name(), expires); err != nil {
+		player.EchoMessage("Failed to save the ban: " + err.String())
+	}
+
+	// The ban is in force even if it could not be saved.
+	ban, _ := bans.Banned(name)
+	if cmdHandler.KickPlayer(name, ban.Message()) {
+		cmdHandler.BroadcastMessage(fmt.Sprintf("%s was banned %s", name, ban.Until()))
+	} else {
+		player.EchoMessage(fmt.Sprintf("Banned %s %s", name, ban.Until()))
+	}
+}
+
+// /banip <player|ip> [<duration>] [<reason>]
+const banIpCmd = "banip"
+const banIpUsage = "banip <player|ip> [<duration>] [<reason>]"
+const banIpDesc = "Bans an IP address, or the address that a player is connected from."
+const banIpPermission = "admin.commands.banip"
+
+func cmdBanIp(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	if len(args) < 2 {
+		player.EchoMessage(banIpUsage)
+		return
+	}
+
+	ip := args[1]
+	if net.ParseIP(ip) == nil {
+		var ok bool
+		if ip, ok = cmdHandler.PlayerIp(args[1]); !ok {
+			player.EchoMessage(fmt.Sprintf(msgNotConnected, args[1]))
+			return
+		}
+	}
+	expires, reason := parseBanArgs(args[2:])
+
+	ipBans := cmdHandler.AccessLists().IpBans
+	if err := ipBans.Add(ip, reason, player.Name(), expires); err != nil {
+		player.EchoMessage("Failed to save the ban: " + err.String())
+	}
+
+	ban, _ := ipBans.Banned(ip)
+	kicked := cmdHandler.KickIp(ip, ban.Message())
+	player.EchoMessage(fmt.Sprintf("Banned %s %s, kicking %d players", ip, ban.Until(), kicked))
+}
+
+// /unban <player|ip>
+const unbanCmd = "unban"
+const unbanUsage = "unban <player|ip>"
+const unbanDesc = "Lifts the ban of a player or IP address."
+const unbanPermission = "admin.commands.unban"
+
+func cmdUnban(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	if len(args) != 2 {
+		player.EchoMessage(unbanUsage)
+		return
+	}
+
+	lists := cmdHandler.AccessLists()
+	bans := lists.Bans
+	if net.ParseIP(args[1]) != nil {
+		bans = lists.IpBans
+	}
+
+	ok, err := bans.Remove(args[1])
+	switch {
+	case !ok:
+		player.EchoMessage(fmt.Sprintf("'%s' is not banned.", args[1]))
+	case err != nil:
+		player.EchoMessage("Failed to save the bans: " + err.String())
+	default:
+		player.EchoMessage(fmt.Sprintf("Unbanned %s", args[1]))
+	}
+}
+
+// parseBanArgs parses the optional duration and reason of a ban, returning
+// when the ban expires in seconds since the Unix epoch, or zero if it is
+// permanent.
+func parseBanArgs(args []string) (expires int64, reason string) {
+	if len(args) > 0 {
+		if seconds, err := access.ParseDuration(args[0]); err == nil {
+			expires = time.Seconds() + seconds
+			args = args[1:]
+		}
+	}
+	reason = strings.Join(args, " ")
+	return
+}
+
+// /whitelist on|off|add|remove|list
+const whitelistCmd = "whitelist"
+const whitelistUsage = "whitelist on|off|add <player>|remove <player>|list"
+const whitelistDesc = "Turns the whitelist on or off, and edits the players on it."
+const whitelistPermission = "admin.commands.whitelist"
+
+func cmdWhitelist(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	if len(args) < 2 {
+		player.EchoMessage(whitelistUsage)
+		return
+	}
+
+	whitelist := cmdHandler.AccessLists().Whitelist
+
+	var err os.Error
+	switch {
+	case args[1] == "on" && len(args) == 2:
+		if err = whitelist.SetEnabled(true); err == nil {
+			player.EchoMessage("The whitelist is on.")
+		}
+	case args[1] == "off" && len(args) == 2:
+		if err = whitelist.SetEnabled(false); err == nil {
+			player.EchoMessage("The whitelist is off.")
+		}
+	case args[1] == "add" && len(args) == 3:
+		if err = whitelist.Add(args[2]); err == nil {
+			player.EchoMessage(fmt.Sprintf("Added %s to the whitelist.", args[2]))
+		}
+	case args[1] == "remove" && len(args) == 3:
+		var ok bool
+		if ok, err = whitelist.Remove(args[2]); err == nil {
+			if ok {
+				player.EchoMessage(fmt.Sprintf("Removed %s from the whitelist.", args[2]))
+			} else {
+				player.EchoMessage(fmt.Sprintf("%s is not on the whitelist.", args[2]))
+			}
+		}
+	case args[1] == "list" && len(args) == 2:
+		state := "off"
+		if whitelist.Enabled() {
+			state = "on"
+		}
+		player.EchoMessage(fmt.Sprintf("The whitelist is %s: %s", state, strings.Join(whitelist.Names(), ", ")))
+	default:
+		player.EchoMessage(whitelistUsage)
+	}
+
+	if err != nil {
+		player.EchoMessage("Failed to save the whitelist: " + err.String())
+	}
+}
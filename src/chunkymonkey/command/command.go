@@ -13,9 +13,10 @@ type Command struct {
 	Trigger     string          // The initial text eg. "give".
 	Description string          // A description of what the command does.
 	Usage       string          // A usage string for the command.
+	Permission  string          // The permission node needed to use the command eg. "admin.commands.give".
 	Callback    CommandCallback // This function will be called if a Message begins with the CommandPrefix and the Trigger.
 }
 
-func NewCommand(trigger, desc, usage string, callback CommandCallback) *Command {
-	return &Command{Trigger: trigger, Description: desc, Usage: usage, Callback: callback}
+func NewCommand(trigger, desc, usage, permission string, callback CommandCallback) *Command {
+	return &Command{Trigger: trigger, Description: desc, Usage: usage, Permission: permission, Callback: callback}
 }
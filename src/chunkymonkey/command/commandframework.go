@@ -1,14 +1,16 @@
 package command
 
 import (
-	"strings"
 	"os"
+	"strings"
 
 	"chunkymonkey/gamerules"
 )
 
 var ErrCmdExists = os.NewError("The command already exists.")
 
+const msgPermissionDenied = "You do not have permission to use this command."
+
 // The CommandFramework handles all message based commands.
 // It uses channels to safly handle multiple calls.
 type CommandFramework struct {
@@ -20,7 +22,7 @@ type CommandFramework struct {
 func NewCommandFramework(prefix string) *CommandFramework {
 	cf := &CommandFramework{prefix: prefix}
 	cmds := getCommands()
-	commandHelp := NewCommand(helpCmd, helpDesc, helpUsage, func(player gamerules.IPlayerClient, msg string, game gamerules.IGame) {
+	commandHelp := NewCommand(helpCmd, helpDesc, helpUsage, helpPermission, func(player gamerules.IPlayerClient, msg string, game gamerules.IGame) {
 		cmdHelp(player, msg, cf, game)
 	})
 	cmds[helpCmd] = commandHelp
@@ -44,6 +46,16 @@ func (cf *CommandFramework) Process(player gamerules.IPlayerClient, message stri
 	attr := strings.Split(message, " ")
 	trigger := attr[0][1:]
 	if cmd, ok := cf.cmds[trigger]; ok {
+		if !cf.Allowed(player, cmd) {
+			player.EchoMessage(msgPermissionDenied)
+			return
+		}
 		cmd.Callback(player, message, game)
 	}
 }
+
+// Allowed returns true if the player has the permission needed to use the
+// command.
+func (cf *CommandFramework) Allowed(player gamerules.IPlayerClient, cmd *Command) bool {
+	return gamerules.Permissions().UserPermissions(player.Name()).Has(cmd.Permission)
+}
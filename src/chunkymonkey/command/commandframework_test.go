@@ -1,23 +1,98 @@
 package command
 
 import (
+	"fmt"
+	"os"
+	"strings"
 	"testing"
 
 	"gomock.googlecode.com/hg/gomock"
 
+	"chunkymonkey/access"
 	"chunkymonkey/gamerules"
+	"chunkymonkey/permission"
 	"testmatcher"
 )
 
+const (
+	testUsersJson = `{
+		"thePlayer": {
+			"groups": ["admin"]
+		}
+	}`
+	testGroupsJson = `{
+		"default": {
+			"default": true,
+			"permissions": ["user.commands.help"]
+		},
+		"admin": {
+			"inheritance": ["default"],
+			"permissions": ["admin.commands.*", "user.commands.*"]
+		}
+	}`
+)
+
+// commandList matches the list of commands given by /help, in any order.
+type commandList struct {
+	Triggers []string
+}
+
+func (m *commandList) Matches(x interface{}) bool {
+	s, ok := x.(string)
+	if !ok || !strings.HasPrefix(s, "Commands: ") {
+		return false
+	}
+
+	triggers := strings.Split(s[len("Commands: "):], ", ")
+	if len(triggers) != len(m.Triggers) {
+		return false
+	}
+	for _, want := range m.Triggers {
+		found := false
+		for _, trigger := range triggers {
+			if trigger == want {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *commandList) String() string {
+	return fmt.Sprintf("lists the commands %v", m.Triggers)
+}
+
+// setUpPermissions loads the test permissions. It returns a function that
+// restores the permissions from before.
+func setUpPermissions(t *testing.T) (restore func()) {
+	permissions, err := permission.LoadJsonPermission(strings.NewReader(testUsersJson), strings.NewReader(testGroupsJson))
+	if err != nil {
+		t.Fatalf("Failed to load permissions: %v", err)
+	}
+
+	oldPermissions := gamerules.Permissions()
+	gamerules.SetPermissions(permissions)
+
+	return func() {
+		gamerules.SetPermissions(oldPermissions)
+	}
+}
+
 func TestCommandFramework(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
+	defer setUpPermissions(t)()
+
 	itemType1 := gamerules.ItemType{1, "1", 64, 0, 0}
 
 	mockGame := gamerules.NewMockIGame(mockCtrl)
 	mockPlayer := gamerules.NewMockIPlayerClient(mockCtrl)
 	mockOther := gamerules.NewMockIPlayerClient(mockCtrl)
+	mockPlayer.EXPECT().Name().Return("thePlayer").AnyTimes()
 
 	cf := NewCommandFramework("/")
 
@@ -44,6 +119,57 @@ func TestCommandFramework(t *testing.T) {
 	mockPlayer.EXPECT().EchoMessage("Cannot give more than 512 items at once")
 	cf.Process(mockPlayer, "/give otherPlayer 1 513", mockGame)
 
+	mockGame.EXPECT().PlayerByName("otherPlayer").Return(mockOther)
+	mockOther.EXPECT().ReceiveWhisper("thePlayer", "hello there")
+	mockPlayer.EXPECT().EchoMessage("§7You whisper to otherPlayer: hello there")
+	cf.Process(mockPlayer, "/tell otherPlayer hello there", mockGame)
+
+	mockGame.EXPECT().PlayerByName("nobody")
+	mockPlayer.EXPECT().EchoMessage("'nobody' is not logged in")
+	cf.Process(mockPlayer, "/tell nobody hello", mockGame)
+
+	mockPlayer.EXPECT().EchoMessage(tellUsage)
+	cf.Process(mockPlayer, "/tell otherPlayer", mockGame)
+
+	mockPlayer.EXPECT().Correspondent().Return("")
+	mockPlayer.EXPECT().EchoMessage(msgNoCorrespondent)
+	cf.Process(mockPlayer, "/reply hi", mockGame)
+
+	mockPlayer.EXPECT().Correspondent().Return("otherPlayer")
+	mockGame.EXPECT().PlayerByName("otherPlayer").Return(mockOther)
+	mockOther.EXPECT().ReceiveWhisper("thePlayer", "hi")
+	mockPlayer.EXPECT().EchoMessage("§7You whisper to otherPlayer: hi")
+	cf.Process(mockPlayer, "/reply hi", mockGame)
+
+	mockGame.EXPECT().BroadcastMessageFrom("thePlayer", "* thePlayer waves")
+	cf.Process(mockPlayer, "/me waves", mockGame)
+
+	mockPlayer.EXPECT().SetIgnoring("otherPlayer", true).Return(true)
+	mockPlayer.EXPECT().EchoMessage("Ignoring otherPlayer")
+	cf.Process(mockPlayer, "/ignore otherPlayer", mockGame)
+
+	mockPlayer.EXPECT().Ignoring().Return([]string{"otherplayer"})
+	mockPlayer.EXPECT().EchoMessage("Ignoring: otherplayer")
+	cf.Process(mockPlayer, "/ignore", mockGame)
+
+	mockPlayer.EXPECT().SetIgnoring("otherPlayer", false).Return(false)
+	mockPlayer.EXPECT().EchoMessage("You are not ignoring otherPlayer")
+	cf.Process(mockPlayer, "/unignore otherPlayer", mockGame)
+
+	mockGame.EXPECT().StopServer("The server is stopping.")
+	cf.Process(mockPlayer, "/stop", mockGame)
+
+	mockGame.EXPECT().StopServer("Back in five minutes")
+	cf.Process(mockPlayer, "/stop Back in five minutes", mockGame)
+
+	mockGame.EXPECT().ReloadConfig().Return(nil)
+	mockPlayer.EXPECT().EchoMessage(msgReloaded)
+	cf.Process(mockPlayer, "/reload", mockGame)
+
+	mockGame.EXPECT().ReloadConfig().Return(os.NewError("bad config"))
+	mockPlayer.EXPECT().EchoMessage("Failed to reload: bad config")
+	cf.Process(mockPlayer, "/reload", mockGame)
+
 	mockPlayer.EXPECT().EchoMessage(&testmatcher.StringPrefix{"Commands:"})
 	cf.Process(mockPlayer, "/help", mockGame)
 
@@ -54,3 +180,94 @@ func TestCommandFramework(t *testing.T) {
 	)
 	cf.Process(mockPlayer, "/help help", mockGame)
 }
+
+func TestCommandFramework_Access(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	defer setUpPermissions(t)()
+
+	lists := &access.Lists{
+		Bans:      access.NewBanList(),
+		IpBans:    access.NewBanList(),
+		Whitelist: access.NewWhitelist(),
+	}
+
+	mockGame := gamerules.NewMockIGame(mockCtrl)
+	mockGame.EXPECT().AccessLists().Return(lists).AnyTimes()
+	mockPlayer := gamerules.NewMockIPlayerClient(mockCtrl)
+	mockPlayer.EXPECT().Name().Return("thePlayer").AnyTimes()
+
+	cf := NewCommandFramework("/")
+
+	mockGame.EXPECT().KickPlayer("griefer", "Go away").Return(true)
+	mockGame.EXPECT().BroadcastMessage("griefer was kicked: Go away")
+	cf.Process(mockPlayer, "/kick griefer Go away", mockGame)
+
+	mockGame.EXPECT().KickPlayer("nobody", msgKicked).Return(false)
+	mockPlayer.EXPECT().EchoMessage("'nobody' is not connected.")
+	cf.Process(mockPlayer, "/kick nobody", mockGame)
+
+	mockGame.EXPECT().KickPlayer("griefer", "You are banned forever: griefing").Return(true)
+	mockGame.EXPECT().BroadcastMessage("griefer was banned forever")
+	cf.Process(mockPlayer, "/ban griefer griefing", mockGame)
+	if ban, ok := lists.Bans.Banned("griefer"); !ok || ban.By != "thePlayer" {
+		t.Errorf("Expected griefer to be banned by thePlayer, got %#v, %t", ban, ok)
+	}
+
+	mockGame.EXPECT().KickPlayer("spammer", &testmatcher.StringPrefix{"You are banned until "}).Return(false)
+	mockPlayer.EXPECT().EchoMessage(&testmatcher.StringPrefix{"Banned spammer until "})
+	cf.Process(mockPlayer, "/ban spammer 1d", mockGame)
+	if ban, _ := lists.Bans.Banned("spammer"); ban.Expires == 0 {
+		t.Errorf("Expected the ban of spammer to expire")
+	}
+
+	mockGame.EXPECT().PlayerIp("griefer").Return("10.0.0.1", true)
+	mockGame.EXPECT().KickIp("10.0.0.1", "You are banned forever").Return(1)
+	mockPlayer.EXPECT().EchoMessage("Banned 10.0.0.1 forever, kicking 1 players")
+	cf.Process(mockPlayer, "/banip griefer", mockGame)
+
+	mockPlayer.EXPECT().EchoMessage("Unbanned 10.0.0.1")
+	cf.Process(mockPlayer, "/unban 10.0.0.1", mockGame)
+	mockPlayer.EXPECT().EchoMessage("Unbanned griefer")
+	cf.Process(mockPlayer, "/unban griefer", mockGame)
+	mockPlayer.EXPECT().EchoMessage("'griefer' is not banned.")
+	cf.Process(mockPlayer, "/unban griefer", mockGame)
+
+	mockPlayer.EXPECT().EchoMessage("Added Friend to the whitelist.")
+	cf.Process(mockPlayer, "/whitelist add Friend", mockGame)
+	mockPlayer.EXPECT().EchoMessage("The whitelist is on.")
+	cf.Process(mockPlayer, "/whitelist on", mockGame)
+	mockPlayer.EXPECT().EchoMessage("The whitelist is on: friend")
+	cf.Process(mockPlayer, "/whitelist list", mockGame)
+	if _, ok := lists.Check("stranger", "10.0.0.2"); ok {
+		t.Errorf("Expected the whitelist to refuse stranger")
+	}
+	mockPlayer.EXPECT().EchoMessage(whitelistUsage)
+	cf.Process(mockPlayer, "/whitelist add", mockGame)
+}
+
+func TestCommandFramework_Permissions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	defer setUpPermissions(t)()
+
+	mockGame := gamerules.NewMockIGame(mockCtrl)
+	mockPlayer := gamerules.NewMockIPlayerClient(mockCtrl)
+	mockPlayer.EXPECT().Name().Return("otherPlayer").AnyTimes()
+
+	cf := NewCommandFramework("/")
+
+	mockPlayer.EXPECT().EchoMessage(msgPermissionDenied)
+	cf.Process(mockPlayer, "/stop", mockGame)
+
+	mockPlayer.EXPECT().EchoMessage(msgPermissionDenied)
+	cf.Process(mockPlayer, "/give otherPlayer 1 64", mockGame)
+
+	mockPlayer.EXPECT().EchoMessage(&commandList{[]string{"?", "help"}})
+	cf.Process(mockPlayer, "/?", mockGame)
+
+	mockPlayer.EXPECT().EchoMessage(msgUnknownCommand)
+	cf.Process(mockPlayer, "/help give", mockGame)
+}
@@ -12,21 +12,33 @@ import (
 
 func getCommands() map[string]*Command {
 	cmds := map[string]*Command{}
-	cmds[sayCmd] = NewCommand(sayCmd, sayDesc, sayUsage, cmdSay)
-	cmds[tpCmd] = NewCommand(tpCmd, tpDesc, tpUsage, cmdTp)
-	cmds[killCmd] = NewCommand(killCmd, killDesc, killUsage, cmdKill)
-	cmds[tellCmd] = NewCommand(tellCmd, tellDesc, tellUsage, cmdTell)
-	cmds[giveCmd] = NewCommand(giveCmd, giveDesc, giveUsage, cmdGive)
+	cmds[sayCmd] = NewCommand(sayCmd, sayDesc, sayUsage, sayPermission, cmdSay)
+	cmds[tpCmd] = NewCommand(tpCmd, tpDesc, tpUsage, tpPermission, cmdTp)
+	cmds[killCmd] = NewCommand(killCmd, killDesc, killUsage, killPermission, cmdKill)
+	cmds[tellCmd] = NewCommand(tellCmd, tellDesc, tellUsage, tellPermission, cmdTell)
+	cmds[replyCmd] = NewCommand(replyCmd, replyDesc, replyUsage, replyPermission, cmdReply)
+	cmds[meCmd] = NewCommand(meCmd, meDesc, meUsage, mePermission, cmdMe)
+	cmds[ignoreCmd] = NewCommand(ignoreCmd, ignoreDesc, ignoreUsage, ignorePermission, cmdIgnore)
+	cmds[unignoreCmd] = NewCommand(unignoreCmd, unignoreDesc, unignoreUsage, unignorePermission, cmdUnignore)
+	cmds[giveCmd] = NewCommand(giveCmd, giveDesc, giveUsage, givePermission, cmdGive)
+	cmds[stopCmd] = NewCommand(stopCmd, stopDesc, stopUsage, stopPermission, cmdStop)
+	cmds[regionCmd] = NewCommand(regionCmd, regionDesc, regionUsage, regionPermission, cmdRegion)
+	cmds[reloadCmd] = NewCommand(reloadCmd, reloadDesc, reloadUsage, reloadPermission, cmdReload)
+	cmds[kickCmd] = NewCommand(kickCmd, kickDesc, kickUsage, kickPermission, cmdKick)
+	cmds[banCmd] = NewCommand(banCmd, banDesc, banUsage, banPermission, cmdBan)
+	cmds[banIpCmd] = NewCommand(banIpCmd, banIpDesc, banIpUsage, banIpPermission, cmdBanIp)
+	cmds[unbanCmd] = NewCommand(unbanCmd, unbanDesc, unbanUsage, unbanPermission, cmdUnban)
+	cmds[whitelistCmd] = NewCommand(whitelistCmd, whitelistDesc, whitelistUsage, whitelistPermission, cmdWhitelist)
 	return cmds
 }
 
-const msgNotImplemented = "We are sorry. This command is not yet implemented."
 const msgUnknownItem = "Unknown item ID"
 
 // say message
 const sayCmd = "say"
 const sayUsage = "say <message>"
 const sayDesc = "Broadcasts a message to all players without showing a player name. The message is colored pink."
+const sayPermission = "admin.commands.say"
 
 func cmdSay(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
 	args := strings.Split(message, " ")
@@ -43,6 +55,7 @@ func cmdSay(player gamerules.IPlayerClient, message string, cmdHandler gamerules
 const tpCmd = "tp"
 const tpUsage = "tp <player1> <player2>"
 const tpDesc = "Teleports player1 to player2."
+const tpPermission = "admin.commands.tp"
 
 func cmdTp(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
 	args := strings.Split(message, " ")
@@ -81,16 +94,17 @@ func cmdTp(player gamerules.IPlayerClient, message string, cmdHandler gamerules.
 const killCmd = "kill"
 const killUsage = "kill"
 const killDesc = "Inflicts damage to self. Useful when lost or stuck."
+const killPermission = "user.commands.kill"
 
 func cmdKill(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
-	// TODO inflict damage to player
-	player.EchoMessage(msgNotImplemented)
+	player.Kill()
 }
 
 // /tell player message
 const tellCmd = "tell"
 const tellUsage = "tell <player> <message>"
-const tellDesc = "Tells a player a message."
+const tellDesc = "Tells a player a message that no one else sees."
+const tellPermission = "user.commands.tell"
 
 func cmdTell(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
 	args := strings.Split(message, " ")
@@ -98,17 +112,110 @@ func cmdTell(player gamerules.IPlayerClient, message string, cmdHandler gamerule
 		player.EchoMessage(tellUsage)
 		return
 	}
-	/* TODO Get player to send message, too
-	player := args[1]
-	message := strings.Join(args[2:], " ")
-	*/
-	player.EchoMessage(msgNotImplemented)
+	whisper(player, args[1], strings.Join(args[2:], " "), cmdHandler)
+}
+
+// /reply message
+const replyCmd = "reply"
+const replyUsage = "reply <message>"
+const replyDesc = "Tells the player who last told you something a message."
+const replyPermission = "user.commands.reply"
+const msgNoCorrespondent = "No one has told you anything to reply to."
+
+func cmdReply(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	if len(args) < 2 {
+		player.EchoMessage(replyUsage)
+		return
+	}
+
+	to := player.Correspondent()
+	if to == "" {
+		player.EchoMessage(msgNoCorrespondent)
+		return
+	}
+	whisper(player, to, strings.Join(args[1:], " "), cmdHandler)
+}
+
+// whisper sends a private message from player to the named player.
+func whisper(player gamerules.IPlayerClient, to string, msg string, cmdHandler gamerules.IGame) {
+	recipient := cmdHandler.PlayerByName(to)
+	if recipient == nil {
+		player.EchoMessage(fmt.Sprintf("'%s' is not logged in", to))
+		return
+	}
+
+	recipient.ReceiveWhisper(player.Name(), msg)
+	player.EchoMessage(fmt.Sprintf("§7You whisper to %s: %s", to, msg))
+}
+
+// /me action
+const meCmd = "me"
+const meUsage = "me <action>"
+const meDesc = "Tells everyone what you are doing."
+const mePermission = "user.commands.me"
+
+func cmdMe(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	if len(args) < 2 {
+		player.EchoMessage(meUsage)
+		return
+	}
+	cmdHandler.BroadcastMessageFrom(player.Name(), fmt.Sprintf("* %s %s", player.Name(), strings.Join(args[1:], " ")))
+}
+
+// /ignore [player]
+const ignoreCmd = "ignore"
+const ignoreUsage = "ignore [<player>]"
+const ignoreDesc = "Ignores what a player tells you, or lists the players that you ignore."
+const ignorePermission = "user.commands.ignore"
+
+func cmdIgnore(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	switch len(args) {
+	case 1:
+		names := player.Ignoring()
+		if len(names) == 0 {
+			player.EchoMessage("You are not ignoring anyone.")
+		} else {
+			player.EchoMessage("Ignoring: " + strings.Join(names, ", "))
+		}
+	case 2:
+		if player.SetIgnoring(args[1], true) {
+			player.EchoMessage(fmt.Sprintf("Ignoring %s", args[1]))
+		} else {
+			player.EchoMessage(fmt.Sprintf("You are already ignoring %s", args[1]))
+		}
+	default:
+		player.EchoMessage(ignoreUsage)
+	}
+}
+
+// /unignore player
+const unignoreCmd = "unignore"
+const unignoreUsage = "unignore <player>"
+const unignoreDesc = "Stops ignoring what a player tells you."
+const unignorePermission = "user.commands.unignore"
+
+func cmdUnignore(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	if len(args) != 2 {
+		player.EchoMessage(unignoreUsage)
+		return
+	}
+
+	if player.SetIgnoring(args[1], false) {
+		player.EchoMessage(fmt.Sprintf("No longer ignoring %s", args[1]))
+	} else {
+		player.EchoMessage(fmt.Sprintf("You are not ignoring %s", args[1]))
+	}
 }
 
 const helpShortCmd = "?"
 const helpCmd = "help"
 const helpUsage = "help|?"
 const helpDesc = "Shows a list of all commands."
+const helpPermission = "user.commands.help"
 const msgUnknownCommand = "Command not available."
 
 func cmdHelp(player gamerules.IPlayerClient, message string, cmdFramework *CommandFramework, cmdHandler gamerules.IGame) {
@@ -120,7 +227,7 @@ func cmdHelp(player gamerules.IPlayerClient, message string, cmdFramework *Comma
 	cmds := cmdFramework.Commands()
 	if len(args) == 2 {
 		cmd := args[1]
-		if command, ok := cmds[cmd]; ok {
+		if command, ok := cmds[cmd]; ok && cmdFramework.Allowed(player, command) {
 			player.EchoMessage("Command: " + cmdFramework.Prefix() + command.Trigger)
 			player.EchoMessage("Usage: " + command.Usage)
 			player.EchoMessage("Description: " + command.Description)
@@ -130,21 +237,54 @@ func cmdHelp(player gamerules.IPlayerClient, message string, cmdFramework *Comma
 		return
 	}
 	var resp string
-	if len(cmds) == 0 {
-		resp = "No commands available."
-	} else {
-		resp = "Commands:"
-		for trigger, _ := range cmds {
+	for trigger, command := range cmds {
+		if cmdFramework.Allowed(player, command) {
 			resp += " " + trigger + ","
 		}
-		resp = resp[:len(resp)-1]
+	}
+	if len(resp) == 0 {
+		resp = "No commands available."
+	} else {
+		resp = "Commands:" + resp[:len(resp)-1]
 	}
 	player.EchoMessage(resp)
 }
 
+// /stop [message]
+const stopCmd = "stop"
+const stopUsage = "stop [<message>]"
+const stopDesc = "Saves the world and stops the server, showing the message to players."
+const stopPermission = "admin.commands.stop"
+const msgServerStopping = "The server is stopping."
+
+func cmdStop(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	reason := msgServerStopping
+	if len(args) >= 2 {
+		reason = strings.Join(args[1:], " ")
+	}
+	cmdHandler.StopServer(reason)
+}
+
+// /reload
+const reloadCmd = "reload"
+const reloadUsage = "reload"
+const reloadDesc = "Reloads the server configuration, permissions, regions and rules."
+const reloadPermission = "admin.commands.reload"
+const msgReloaded = "Reloaded. Some settings, such as the address and view distance, only change when the server is restarted."
+
+func cmdReload(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	if err := cmdHandler.ReloadConfig(); err != nil {
+		player.EchoMessage("Failed to reload: " + err.String())
+		return
+	}
+	player.EchoMessage(msgReloaded)
+}
+
 const giveCmd = "give"
 const giveUsage = "give <player> <item ID> [<quantity> [<data>]]"
 const giveDesc = "Gives x amount of y items to player."
+const givePermission = "admin.commands.give"
 
 func cmdGive(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
 	args := strings.Split(message, " ")
@@ -0,0 +1,216 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/region"
+	. "chunkymonkey/types"
+)
+
+// /region <subcommand> [<args>]
+//
+// Regions are checked by the shards. Changes are made to the frontend's
+// regions and saved to its regions file, so they do not reach shard servers
+// until those reload their game rules.
+const regionCmd = "region"
+const regionUsage = "region list|info|define|remove|addowner|removeowner|addmember|removemember|flag"
+const regionDesc = "Defines and edits protected regions. \"/region help\" shows the usage of each subcommand."
+const regionPermission = "admin.commands.region"
+
+const msgUnknownRegion = "There is no region called '%s'"
+
+// regionSubcmds maps each subcommand of /region to its usage and its
+// function. The function is called with the arguments after the subcommand,
+// and returns false if they do not fit the usage.
+var regionSubcmds = map[string]struct {
+	usage string
+	fn    func(player gamerules.IPlayerClient, args []string) bool
+}{
+	"list":         {"region list", cmdRegionList},
+	"info":         {"region info <name>", cmdRegionInfo},
+	"define":       {"region define <name> <x1> <y1> <z1> <x2> <y2> <z2> [<dimension>]", cmdRegionDefine},
+	"remove":       {"region remove <name>", cmdRegionRemove},
+	"addowner":     {"region addowner <name> <player>", regionNameEditor(addOwner)},
+	"removeowner":  {"region removeowner <name> <player>", regionNameEditor(removeOwner)},
+	"addmember":    {"region addmember <name> <player>", regionNameEditor(addMember)},
+	"removemember": {"region removemember <name> <player>", regionNameEditor(removeMember)},
+	"flag":         {"region flag <name> <" + strings.Join(region.Flags, "|") + "> allow|deny", cmdRegionFlag},
+}
+
+func cmdRegion(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	args := strings.Split(message, " ")
+	if len(args) < 2 {
+		player.EchoMessage(regionUsage)
+		return
+	}
+
+	subcmd, ok := regionSubcmds[args[1]]
+	if !ok {
+		for _, subcmd := range regionSubcmds {
+			player.EchoMessage(subcmd.usage)
+		}
+		return
+	}
+
+	if !subcmd.fn(player, args[2:]) {
+		player.EchoMessage(subcmd.usage)
+	}
+}
+
+func cmdRegionList(player gamerules.IPlayerClient, args []string) bool {
+	if len(args) != 0 {
+		return false
+	}
+
+	names := gamerules.Regions.Names()
+	if len(names) == 0 {
+		player.EchoMessage("There are no regions.")
+	} else {
+		player.EchoMessage("Regions: " + strings.Join(names, ", "))
+	}
+	return true
+}
+
+func cmdRegionInfo(player gamerules.IPlayerClient, args []string) bool {
+	if len(args) != 1 {
+		return false
+	}
+
+	r, ok := gamerules.Regions.Region(args[0])
+	if !ok {
+		player.EchoMessage(fmt.Sprintf(msgUnknownRegion, args[0]))
+		return true
+	}
+
+	allowed := make([]string, 0, len(region.Flags))
+	for _, flag := range region.Flags {
+		if r.Flags[flag] {
+			allowed = append(allowed, flag)
+		}
+	}
+
+	player.EchoMessage(fmt.Sprintf(
+		"Region %s: dimension %d from (%d, %d, %d) to (%d, %d, %d)",
+		args[0], r.Dimension, r.Min.X, r.Min.Y, r.Min.Z, r.Max.X, r.Max.Y, r.Max.Z))
+	player.EchoMessage("Owners: " + strings.Join(r.Owners, ", "))
+	player.EchoMessage("Members: " + strings.Join(r.Members, ", "))
+	player.EchoMessage("Allowed: " + strings.Join(allowed, ", "))
+	return true
+}
+
+func cmdRegionDefine(player gamerules.IPlayerClient, args []string) bool {
+	if len(args) != 7 && len(args) != 8 {
+		return false
+	}
+
+	var coords [6]int
+	for i := range coords {
+		var err os.Error
+		if coords[i], err = strconv.Atoi(args[i+1]); err != nil {
+			return false
+		}
+	}
+	if coords[1] < 0 || coords[1] >= ChunkSizeY || coords[4] < 0 || coords[4] >= ChunkSizeY {
+		player.EchoMessage(fmt.Sprintf("Y must be from 0 to %d", ChunkSizeY-1))
+		return true
+	}
+
+	dimension := DimensionNormal
+	if len(args) == 8 {
+		d, err := strconv.Atoi(args[7])
+		if err != nil {
+			return false
+		}
+		dimension = DimensionId(d)
+	}
+
+	r := region.NewRegion(
+		dimension,
+		&BlockXyz{BlockCoord(coords[0]), BlockYCoord(coords[1]), BlockCoord(coords[2])},
+		&BlockXyz{BlockCoord(coords[3]), BlockYCoord(coords[4]), BlockCoord(coords[5])})
+	if err := gamerules.Regions.Define(args[0], r); err != nil {
+		echoRegionsNotSaved(player, err)
+		return true
+	}
+
+	player.EchoMessage(fmt.Sprintf("Defined region %s", args[0]))
+	return true
+}
+
+func cmdRegionRemove(player gamerules.IPlayerClient, args []string) bool {
+	if len(args) != 1 {
+		return false
+	}
+
+	ok, err := gamerules.Regions.Remove(args[0])
+	if !ok {
+		player.EchoMessage(fmt.Sprintf(msgUnknownRegion, args[0]))
+	} else if err != nil {
+		echoRegionsNotSaved(player, err)
+	} else {
+		player.EchoMessage(fmt.Sprintf("Removed region %s", args[0]))
+	}
+	return true
+}
+
+func addOwner(r *region.Region, username string)     { r.AddOwner(username) }
+func removeOwner(r *region.Region, username string)  { r.RemoveOwner(username) }
+func addMember(r *region.Region, username string)    { r.AddMember(username) }
+func removeMember(r *region.Region, username string) { r.RemoveMember(username) }
+
+// regionNameEditor returns a subcommand that changes the owners or members of
+// a region with edit.
+func regionNameEditor(edit func(r *region.Region, username string)) func(player gamerules.IPlayerClient, args []string) bool {
+	return func(player gamerules.IPlayerClient, args []string) bool {
+		if len(args) != 2 {
+			return false
+		}
+
+		updateRegion(player, args[0], func(r *region.Region) {
+			edit(r, args[1])
+		})
+		return true
+	}
+}
+
+func cmdRegionFlag(player gamerules.IPlayerClient, args []string) bool {
+	if len(args) != 3 || !region.IsFlag(args[1]) {
+		return false
+	}
+
+	var allow bool
+	switch args[2] {
+	case "allow":
+		allow = true
+	case "deny":
+		allow = false
+	default:
+		return false
+	}
+
+	updateRegion(player, args[0], func(r *region.Region) {
+		r.SetFlag(args[1], allow)
+	})
+	return true
+}
+
+// updateRegion changes the named region with fn, and tells the player how it
+// went.
+func updateRegion(player gamerules.IPlayerClient, name string, fn func(r *region.Region)) {
+	ok, err := gamerules.Regions.Update(name, fn)
+	if !ok {
+		player.EchoMessage(fmt.Sprintf(msgUnknownRegion, name))
+	} else if err != nil {
+		echoRegionsNotSaved(player, err)
+	} else {
+		player.EchoMessage(fmt.Sprintf("Updated region %s", name))
+	}
+}
+
+func echoRegionsNotSaved(player gamerules.IPlayerClient, err os.Error) {
+	player.EchoMessage("The change was made, but the regions could not be saved: " + err.String())
+}
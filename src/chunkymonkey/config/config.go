@@ -0,0 +1,162 @@
+// Package config loads the settings of a chunkymonkey server from a JSON file.
+package config
+
+import (
+	"fmt"
+	"io"
+	"json"
+	"os"
+
+	. "chunkymonkey/types"
+)
+
+// The largest view distance that players can be given, in chunks.
+const MaxViewDistance = 15
+
+// The files that the game rules, permissions, bans and whitelist are loaded
+// from.
+type Files struct {
+	Blocks   string
+	Items    string
+	Recipes  string
+	Furnace  string
+	Spawning string
+	Users    string
+	Groups   string
+	Regions  string
+
+	// These are created when they are first changed.
+	Bans      string
+	IpBans    string
+	Whitelist string
+}
+
+// Config holds the settings of a server. Settings that are missing from the
+// file take their values from Default.
+type Config struct {
+	Addr     string // The address:port to serve on.
+	HttpAddr string // The address:port to serve HTTP diagnostics on.
+
+	// Addresses of the shard servers that run the normal world. If empty, its
+	// shards are run within the process. The Nether is always run within the
+	// process.
+	ShardServers []string
+
+	Motd                string // Shown to players when they log in.
+	MaxPlayers          int    // Zero allows any number of players.
+	UnderMaintenanceMsg string // If set, all logins are denied with this message.
+	CommandPrefix       string
+
+	// Players are authenticated against AuthUrl in online mode. In offline
+	// mode players may log in with any name.
+	OnlineMode bool
+	AuthUrl    string
+
+	ViewDistance     ChunkCoord // Chunks around each player that they are sent.
+	SaveIntervalSecs int
+	ChunkIdleSecs    int // Seconds that a chunk must be unused before it is unloaded. 0 keeps chunks loaded.
+	SpawnProtection  int // Blocks around the spawn point that only admins may build within.
+
+	// The generator of new worlds: "default", "biome", "flat" or "void". For
+	// "flat", the options are the layers from the bottom up, e.g "7,2x3,2" for
+	// bedrock, two layers of dirt and grass. For "void", the options are the
+	// block ID of the spawn platform, if any.
+	Generator        string
+	GeneratorOptions string
+
+	Files Files
+
+	filename string
+}
+
+// Default returns the settings that are used when they are not in the file.
+func Default() *Config {
+	return &Config{
+		Addr:     ":25565",
+		HttpAddr: ":25566",
+
+		Motd:          "Welcome to chunkymonkey!",
+		MaxPlayers:    20,
+		CommandPrefix: "/",
+
+		OnlineMode: true,
+		AuthUrl:    "http://www.minecraft.net/game/checkserver.jsp",
+
+		ViewDistance:     ChunkRadius,
+		SaveIntervalSecs: 60,
+		ChunkIdleSecs:    60,
+		SpawnProtection:  16,
+
+		Generator: "default",
+
+		Files: Files{
+			Blocks:   "blocks.json",
+			Items:    "items.json",
+			Recipes:  "recipes.json",
+			Furnace:  "furnace.json",
+			Spawning: "spawning.json",
+			Users:    "users.json",
+			Groups:   "groups.json",
+			Regions:  "regions.json",
+
+			Bans:      "bans.json",
+			IpBans:    "banned-ips.json",
+			Whitelist: "whitelist.json",
+		},
+	}
+}
+
+// LoadConfigFromFile loads the settings in filename.
+func LoadConfigFromFile(filename string) (config *Config, err os.Error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	if config, err = LoadConfig(file); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	config.filename = filename
+
+	return
+}
+
+// LoadConfig reads settings from reader.
+func LoadConfig(reader io.Reader) (config *Config, err os.Error) {
+	config = Default()
+
+	decoder := json.NewDecoder(reader)
+	if err = decoder.Decode(config); err != nil {
+		return nil, err
+	}
+
+	if err = config.check(); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Reload loads the settings again from the file that they came from.
+func (config *Config) Reload() (*Config, os.Error) {
+	return LoadConfigFromFile(config.filename)
+}
+
+func (config *Config) check() os.Error {
+	switch {
+	case config.MaxPlayers < 0:
+		return os.NewError("MaxPlayers must not be negative")
+	case config.CommandPrefix == "":
+		return os.NewError("CommandPrefix must not be empty")
+	case config.ViewDistance < MinChunkRadius || config.ViewDistance > MaxViewDistance:
+		return fmt.Errorf("ViewDistance must be from %d to %d", MinChunkRadius, MaxViewDistance)
+	case config.SaveIntervalSecs <= 0:
+		return os.NewError("SaveIntervalSecs must be positive")
+	case config.ChunkIdleSecs < 0:
+		return os.NewError("ChunkIdleSecs must not be negative")
+	case config.SpawnProtection < 0:
+		return os.NewError("SpawnProtection must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	config, err := LoadConfig(strings.NewReader(`{
+		"Motd": "Hello",
+		"OnlineMode": false,
+		"Files": {"Users": "other_users.json"}
+	}`))
+	if err != nil {
+		t.Fatalf("Expected config to load, got %v", err)
+	}
+
+	defaults := Default()
+
+	if config.Motd != "Hello" {
+		t.Errorf("Expected Motd %q, got %q", "Hello", config.Motd)
+	}
+	if config.OnlineMode {
+		t.Errorf("Expected OnlineMode to be false")
+	}
+	if config.Files.Users != "other_users.json" {
+		t.Errorf("Expected Files.Users %q, got %q", "other_users.json", config.Files.Users)
+	}
+
+	// Settings that are missing keep their default values.
+	if config.Addr != defaults.Addr {
+		t.Errorf("Expected Addr %q, got %q", defaults.Addr, config.Addr)
+	}
+	if config.ViewDistance != defaults.ViewDistance {
+		t.Errorf("Expected ViewDistance %d, got %d", defaults.ViewDistance, config.ViewDistance)
+	}
+	if config.Files.Groups != defaults.Files.Groups {
+		t.Errorf("Expected Files.Groups %q, got %q", defaults.Files.Groups, config.Files.Groups)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []string{
+		`{"MaxPlayers": -1}`,
+		`{"CommandPrefix": ""}`,
+		`{"ViewDistance": 1}`,
+		`{"ViewDistance": 16}`,
+		`{"SaveIntervalSecs": 0}`,
+		`{"ChunkIdleSecs": -1}`,
+		`{"SpawnProtection": -1}`,
+		`{"Motd": `,
+	}
+
+	for _, test := range tests {
+		if _, err := LoadConfig(strings.NewReader(test)); err == nil {
+			t.Errorf("Expected %s to fail to load", test)
+		}
+	}
+}
@@ -8,15 +8,28 @@ import (
 
 type EntityManager struct {
 	nextEntityId EntityId
-	entities     map[EntityId]bool
-	lock         sync.Mutex
+	// If lastEntityId is not zero, EntityIds are only created from
+	// firstEntityId up to but not including lastEntityId.
+	firstEntityId EntityId
+	lastEntityId  EntityId
+	entities      map[EntityId]bool
+	lock          sync.Mutex
 }
 
 func (mgr *EntityManager) Init() {
+	mgr.InitRange(0, 0)
+}
+
+// InitRange initializes the manager to only create EntityIds from first up to
+// but not including last, so that separate processes in the same world do not
+// create the same EntityIds. A range of (0, 0) allows any EntityId.
+func (mgr *EntityManager) InitRange(first, last EntityId) {
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
-	mgr.nextEntityId = 0
+	mgr.firstEntityId = first
+	mgr.lastEntityId = last
+	mgr.nextEntityId = first
 	mgr.entities = make(map[EntityId]bool)
 }
 
@@ -26,6 +39,9 @@ func (mgr *EntityManager) createEntityId() EntityId {
 	_, exists := mgr.entities[entityId]
 	for exists {
 		entityId++
+		if mgr.lastEntityId != 0 && entityId >= mgr.lastEntityId {
+			entityId = mgr.firstEntityId
+		}
 		if entityId == mgr.nextEntityId {
 			// TODO Better handling of this? It shouldn't happen, realistically - but
 			// neither should it explode.
@@ -34,6 +50,9 @@ func (mgr *EntityManager) createEntityId() EntityId {
 		_, exists = mgr.entities[entityId]
 	}
 	mgr.nextEntityId = entityId + 1
+	if mgr.lastEntityId != 0 && mgr.nextEntityId >= mgr.lastEntityId {
+		mgr.nextEntityId = mgr.firstEntityId
+	}
 
 	return entityId
 }
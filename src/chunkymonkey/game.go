@@ -8,14 +8,19 @@ import (
 	"os"
 	"rand"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
+	"chunkymonkey/access"
 	"chunkymonkey/command"
+	"chunkymonkey/config"
 	. "chunkymonkey/entity"
 	"chunkymonkey/gamerules"
 	"chunkymonkey/player"
 	"chunkymonkey/proto"
 	"chunkymonkey/server_auth"
+	"chunkymonkey/shardnet"
 	"chunkymonkey/shardserver"
 	. "chunkymonkey/types"
 	"chunkymonkey/worldstore"
@@ -26,8 +31,16 @@ import (
 // That is: characters that might be abused in filename components, etc.
 var validPlayerUsername = regexp.MustCompile(`^[\-a-zA-Z0-9_]+$`)
 
+const msgServerStopping = "The server is stopping."
+const msgServerFull = "The server is full."
+
+// How long the server waits for the data of players to be saved when it
+// stops.
+const playerSaveTimeout = 10 * NanosecondsInSecond
+
 type Game struct {
-	shardManager  *shardserver.LocalShardManager
+	// The shards of each dimension.
+	shardManagers map[DimensionId]gamerules.IShardConnecter
 	entityManager EntityManager
 	worldStore    *worldstore.WorldStore
 
@@ -40,37 +53,64 @@ type Game struct {
 	playerConnect    chan *player.Player
 	playerDisconnect chan EntityId
 
-	// Server information
-	time                Ticks
-	serverId            string
-	UnderMaintenanceMsg string // if set, logins are disallowed.
+	// Server information. config, access and serverId are replaced by
+	// reloadConfig, and are read by login through settings.
+	settingsLock sync.RWMutex
+	config       *config.Config
+	access       *access.Lists
+	serverId     string
+	time         Ticks
+	saveTicks    Ticks // How often the level data is saved.
+
+	// Shutdown state
+	listener net.Listener
+	stopping bool      // Set once StopServer has been called.
+	stopped  chan bool // Closed once the world has been saved.
 }
 
-func NewGame(worldPath string) (game *Game, err os.Error) {
+// NewGame creates a game for the world at worldPath. Shards are run within the
+// process if cfg.ShardServers is empty, otherwise on the shard servers at the
+// given network addresses. The shards of the Nether are always run within the
+// process.
+func NewGame(worldPath string, cfg *config.Config) (game *Game, err os.Error) {
 	worldStore, err := worldstore.LoadWorldStore(worldPath)
 	if err != nil {
 		return nil, err
 	}
 
+	accessLists, err := access.LoadListsFromFiles(cfg.Files.Bans, cfg.Files.IpBans, cfg.Files.Whitelist)
+	if err != nil {
+		return nil, err
+	}
+
 	game = &Game{
 		players:          make(map[EntityId]*player.Player),
 		playerNames:      make(map[string]*player.Player),
 		workQueue:        make(chan func(*Game), 256),
 		playerConnect:    make(chan *player.Player),
 		playerDisconnect: make(chan EntityId),
+		shardManagers:    make(map[DimensionId]gamerules.IShardConnecter),
+		stopped:          make(chan bool),
+		config:           cfg,
+		access:           accessLists,
 		time:             worldStore.Time,
+		saveTicks:        Ticks(cfg.SaveIntervalSecs) * TicksPerSecond,
 		worldStore:       worldStore,
 	}
 
-	game.entityManager.Init()
-
-	game.serverId = fmt.Sprintf("%016x", rand.NewSource(worldStore.Seed).Int63())
-	//game.serverId = "-"
+	if len(cfg.ShardServers) == 0 {
+		game.entityManager.Init()
+		game.shardManagers[DimensionNormal] = shardserver.NewLocalShardManager(worldStore.ChunkStore, worldStore.Populator, DimensionNormal, &game.entityManager, game.time)
+	} else {
+		game.entityManager.InitRange(shardnet.EntityIdRange(-1))
+		game.shardManagers[DimensionNormal] = shardnet.NewConnecter(shardnet.NewShardMap(cfg.ShardServers))
+	}
+	game.shardManagers[DimensionNether] = shardserver.NewLocalShardManager(worldStore.NetherChunkStore, nil, DimensionNether, &game.entityManager, game.time)
 
-	game.shardManager = shardserver.NewLocalShardManager(worldStore.ChunkStore, &game.entityManager)
+	gamerules.Regions.SetSpawn(&worldStore.SpawnPosition)
 
-	// TODO: Load the prefix from a config file
-	gamerules.CommandFramework = command.NewCommandFramework("/")
+	game.setServerId()
+	gamerules.SetCommandFramework(command.NewCommandFramework(cfg.CommandPrefix))
 
 	go game.mainLoop()
 	return
@@ -96,13 +136,31 @@ func (game *Game) mainLoop() {
 
 // A new player has connected to the server
 func (game *Game) onPlayerConnect(newPlayer *player.Player) {
+	if game.stopping {
+		// The player finished logging in after the server started to stop.
+		game.entityManager.RemoveEntityById(newPlayer.GetEntityId())
+		newPlayer.Kick(msgServerStopping)
+		return
+	}
+
 	game.players[newPlayer.GetEntityId()] = newPlayer
 	game.playerNames[newPlayer.Name()] = newPlayer
+
+	if game.config.Motd != "" {
+		client := newPlayer.Client()
+		for _, line := range strings.Split(game.config.Motd, "\n") {
+			client.EchoMessage(line)
+		}
+	}
 }
 
 // A player has disconnected from the server
 func (game *Game) onPlayerDisconnect(entityId EntityId) {
-	oldPlayer := game.players[entityId]
+	oldPlayer, ok := game.players[entityId]
+	if !ok {
+		// The player was already removed by kickPlayer.
+		return
+	}
 	game.players[entityId] = nil, false
 	game.playerNames[oldPlayer.Name()] = nil, false
 	game.entityManager.RemoveEntityById(entityId)
@@ -119,13 +177,23 @@ func (game *Game) onTick() {
 	if game.time%TicksPerSecond == 0 {
 		game.sendTimeUpdate()
 	}
+	if game.time%game.saveTicks == 0 {
+		game.writeLevelData()
+	}
+}
+
+// writeLevelData writes the world time and other level data to level.dat.
+func (game *Game) writeLevelData() {
+	game.worldStore.Time = game.time
+	if err := game.worldStore.WriteLevelData(); err != nil {
+		log.Printf("Failed when writing level data: %s", err)
+	}
 }
 
 // Negotiate a new player client login. This function runs in a new goroutine
 // for each player connection and therefore should not attempt to alter the
 // game structure without enqueue().
 func (game *Game) login(conn net.Conn) {
-	// TODO: This function should access game in a thread-safe way for reading
 	var err, clientErr os.Error
 
 	defer func() {
@@ -153,29 +221,44 @@ func (game *Game) login(conn net.Conn) {
 
 	log.Print("Client ", conn.RemoteAddr(), " connected as ", username)
 
-	if game.UnderMaintenanceMsg != "" {
+	cfg, accessLists, serverId := game.settings()
+
+	if cfg.UnderMaintenanceMsg != "" {
 		err = fmt.Errorf("Server under maintenance, kicking player: %q", username)
-		clientErr = os.NewError(game.UnderMaintenanceMsg)
+		clientErr = os.NewError(cfg.UnderMaintenanceMsg)
+		return
+	}
+
+	if cfg.MaxPlayers > 0 && game.playerCount() >= cfg.MaxPlayers {
+		err = fmt.Errorf("Server full, kicking player: %q", username)
+		clientErr = os.NewError(msgServerFull)
+		return
+	}
+
+	ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	if refusal, ok := accessLists.Check(username, ip); !ok {
+		err = fmt.Errorf("Refused login by %q from %s: %s", username, ip, refusal)
+		clientErr = os.NewError(refusal)
 		return
 	}
 
 	// Load player permissions.
-	permissions := gamerules.Permissions.UserPermissions(username)
+	permissions := gamerules.Permissions().UserPermissions(username)
 	if !permissions.Has("login") {
 		err = fmt.Errorf("Player %q does not have login permission", username)
 		clientErr = os.NewError("You do not have access to this server.")
 		return
 	}
 
-	if err = proto.ServerWriteHandshake(conn, game.serverId); err != nil {
+	if err = proto.ServerWriteHandshake(conn, serverId); err != nil {
 		clientErr = os.NewError("Handshake error.")
 		return
 	}
 
-	if game.serverId != "-" {
+	if serverId != "-" {
 		var authenticated bool
-		authserver := &server_auth.ServerAuth{"http://www.minecraft.net/game/checkserver.jsp"}
-		authenticated, err = authserver.Authenticate(game.serverId, username)
+		authserver := &server_auth.ServerAuth{cfg.AuthUrl}
+		authenticated, err = authserver.Authenticate(serverId, username)
 		if !authenticated || err != nil {
 			var reason string
 			if err != nil {
@@ -203,7 +286,7 @@ func (game *Game) login(conn net.Conn) {
 		return
 	}
 
-	player := player.NewPlayer(entityId, game.shardManager, conn, username, game.worldStore.SpawnPosition, game.playerDisconnect, game)
+	player := player.NewPlayer(entityId, game.shardManagers, conn, username, game.worldStore.SpawnPosition, game.playerDisconnect, game)
 	if playerData != nil {
 		if err = player.ReadNbt(playerData); err != nil {
 			// Don't let the player log in, as they will only have default inventory
@@ -219,6 +302,9 @@ func (game *Game) login(conn net.Conn) {
 	player.Start()
 }
 
+// Serve accepts player connections on addr. It returns once the server has
+// been stopped by StopServer and the world has been saved, or if accepting
+// connections fails.
 func (game *Game) Serve(addr string) {
 	listener, e := net.Listen("tcp", addr)
 	if e != nil {
@@ -226,6 +312,14 @@ func (game *Game) Serve(addr string) {
 	}
 	log.Print("Listening on ", addr)
 
+	game.enqueue(func(_ *Game) {
+		if game.stopping {
+			listener.Close()
+		} else {
+			game.listener = listener
+		}
+	})
+
 	for {
 		conn, e2 := listener.Accept()
 		if e2 != nil {
@@ -235,10 +329,179 @@ func (game *Game) Serve(addr string) {
 
 		go game.login(conn)
 	}
+
+	// The listener is closed when the server stops, but it may also have
+	// failed on its own, in which case there is nothing to wait for.
+	stopping := make(chan bool)
+	game.enqueue(func(_ *Game) {
+		stopping <- game.stopping
+	})
+	if <-stopping {
+		<-game.stopped
+	}
+}
+
+// stop stops accepting logins and disconnects all players. The world is saved
+// in another goroutine once their data has been, so that the game loop keeps
+// serving the requests that players and shards make while they are saving.
+func (game *Game) stop(reason string) {
+	if game.stopping {
+		return
+	}
+	game.stopping = true
+
+	log.Print("Stopping server: ", reason)
+
+	if game.listener != nil {
+		game.listener.Close()
+	}
+
+	var saves []chan bool
+	for _, player := range game.players {
+		saves = append(saves, game.kickPlayer(player, reason))
+	}
+
+	go func() {
+		game.waitForPlayerSaves(saves)
+
+		for dimension, shardManager := range game.shardManagers {
+			switch shardManager := shardManager.(type) {
+			case *shardserver.LocalShardManager:
+				log.Printf("Writing chunks in dimension %d", dimension)
+				shardManager.Save()
+			case *shardnet.Connecter:
+				log.Printf("Asking shard servers to write chunks in dimension %d", dimension)
+				shardManager.Save()
+			}
+		}
+
+		game.enqueue(func(_ *Game) {
+			game.writeLevelData()
+
+			log.Print("Server stopped")
+			close(game.stopped)
+		})
+	}()
+}
+
+// kickPlayer forgets the player and disconnects them with the given reason.
+// Their data is saved within their own goroutine, and the returned channel is
+// closed once it has been. Their entity ID is freed at the same time, as it is
+// when a player disconnects. The kick is queued from another goroutine, so that
+// a player who is not keeping up cannot hold up the game loop.
+func (game *Game) kickPlayer(kicked *player.Player, reason string) (saved chan bool) {
+	entityId := kicked.GetEntityId()
+	game.players[entityId] = nil, false
+	game.playerNames[kicked.Name()] = nil, false
+
+	saved = make(chan bool)
+	go func() {
+		kicked.Enqueue(func(kicked *player.Player) {
+			if err := game.worldStore.WritePlayerData(kicked.Name(), kicked.WriteNbt()); err != nil {
+				log.Printf("Failed when writing player data: %s", err)
+			}
+			game.entityManager.RemoveEntityById(entityId)
+			close(saved)
+		})
+		kicked.Kick(reason)
+	}()
+
+	return
+}
+
+// waitForPlayerSaves waits for the data of kicked players to be saved, giving
+// up after playerSaveTimeout in case any of them have stopped responding.
+func (game *Game) waitForPlayerSaves(saves []chan bool) {
+	timeout := time.After(playerSaveTimeout)
+	for _, saved := range saves {
+		select {
+		case <-saved:
+		case <-timeout:
+			log.Print("Timed out writing player data")
+			return
+		}
+	}
+}
+
+// playerByName returns the connected player with the given name, ignoring
+// case.
+func (game *Game) playerByName(name string) (found *player.Player, ok bool) {
+	if found, ok = game.playerNames[name]; ok {
+		return
+	}
+
+	name = strings.ToLower(name)
+	for playerName, player := range game.playerNames {
+		if strings.ToLower(playerName) == name {
+			return player, true
+		}
+	}
+	return nil, false
 }
 
 // Utility functions
 
+// setServerId sets the ID that clients authenticate with. It is "-" in offline
+// mode, which tells clients not to authenticate. It must be called with
+// game.settingsLock held.
+func (game *Game) setServerId() {
+	if game.config.OnlineMode {
+		game.serverId = fmt.Sprintf("%016x", rand.NewSource(game.worldStore.Seed).Int63())
+	} else {
+		game.serverId = "-"
+	}
+}
+
+// settings returns the configuration, access lists and server ID. Unlike the
+// fields, it is safe to call from any goroutine.
+func (game *Game) settings() (cfg *config.Config, accessLists *access.Lists, serverId string) {
+	game.settingsLock.RLock()
+	defer game.settingsLock.RUnlock()
+	return game.config, game.access, game.serverId
+}
+
+// playerCount returns the number of players that are connected. It is safe to
+// call from any goroutine.
+func (game *Game) playerCount() int {
+	result := make(chan int)
+	game.enqueue(func(_ *Game) {
+		result <- len(game.players)
+		close(result)
+	})
+	return <-result
+}
+
+// reloadConfig loads the configuration again and applies the settings that
+// can change while the server is running. The other settings keep the values
+// that they had when the server started.
+func (game *Game) reloadConfig() os.Error {
+	cfg, err := game.config.Reload()
+	if err != nil {
+		return err
+	}
+
+	files := &cfg.Files
+	accessLists, err := access.LoadListsFromFiles(files.Bans, files.IpBans, files.Whitelist)
+	if err != nil {
+		return err
+	}
+
+	err = gamerules.ReloadRules(files.Recipes, files.Furnace, files.Spawning, files.Users, files.Groups, files.Regions)
+	if err != nil {
+		return err
+	}
+
+	game.settingsLock.Lock()
+	game.config = cfg
+	game.access = accessLists
+	game.setServerId()
+	game.settingsLock.Unlock()
+	gamerules.SetCommandFramework(command.NewCommandFramework(cfg.CommandPrefix))
+
+	log.Print("Reloaded the configuration")
+	return nil
+}
+
 // Send a time/keepalive packet
 func (game *Game) sendTimeUpdate() {
 	buf := new(bytes.Buffer)
@@ -268,7 +531,6 @@ func (game *Game) enqueue(f func(*Game)) {
 	game.workQueue <- f
 }
 
-
 // The following functions implement the IGame interface
 
 func (game *Game) BroadcastMessage(msg string) {
@@ -280,6 +542,19 @@ func (game *Game) BroadcastMessage(msg string) {
 	})
 }
 
+func (game *Game) BroadcastMessageFrom(from, msg string) {
+	buf := new(bytes.Buffer)
+	proto.WriteChatMessage(buf, msg)
+
+	game.enqueue(func(_ *Game) {
+		for _, player := range game.players {
+			if !player.IsIgnoring(from) {
+				player.TransmitPacket(buf.Bytes())
+			}
+		}
+	})
+}
+
 func (game *Game) ItemTypeById(id int) (gamerules.ItemType, bool) {
 	itemType, ok := gamerules.Items[ItemTypeId(id)]
 	return *itemType, ok
@@ -299,10 +574,25 @@ func (game *Game) PlayerByEntityId(id EntityId) gamerules.IPlayerClient {
 	return <-result
 }
 
+func (game *Game) StopServer(reason string) {
+	game.enqueue(func(_ *Game) {
+		game.stop(reason)
+	})
+}
+
+func (game *Game) ReloadConfig() os.Error {
+	result := make(chan os.Error)
+	game.enqueue(func(_ *Game) {
+		result <- game.reloadConfig()
+		close(result)
+	})
+	return <-result
+}
+
 func (game *Game) PlayerByName(name string) gamerules.IPlayerClient {
 	result := make(chan gamerules.IPlayerClient)
 	game.enqueue(func(_ *Game) {
-		player, ok := game.playerNames[name]
+		player, ok := game.playerByName(name)
 		if ok {
 			result <- player.Client()
 		} else {
@@ -312,3 +602,55 @@ func (game *Game) PlayerByName(name string) gamerules.IPlayerClient {
 	})
 	return <-result
 }
+
+func (game *Game) AccessLists() *access.Lists {
+	result := make(chan *access.Lists)
+	game.enqueue(func(_ *Game) {
+		result <- game.access
+		close(result)
+	})
+	return <-result
+}
+
+func (game *Game) PlayerIp(name string) (ip string, ok bool) {
+	result := make(chan string)
+	game.enqueue(func(_ *Game) {
+		if player, ok := game.playerByName(name); ok {
+			result <- player.Ip()
+		}
+		close(result)
+	})
+	ip, ok = <-result
+	return
+}
+
+func (game *Game) KickPlayer(name, reason string) bool {
+	result := make(chan bool)
+	game.enqueue(func(_ *Game) {
+		player, ok := game.playerByName(name)
+		if ok {
+			game.kickPlayer(player, reason)
+		}
+		result <- ok
+		close(result)
+	})
+	return <-result
+}
+
+func (game *Game) KickIp(ip, reason string) int {
+	result := make(chan int)
+	game.enqueue(func(_ *Game) {
+		var kicked []*player.Player
+		for _, player := range game.players {
+			if player.Ip() == ip {
+				kicked = append(kicked, player)
+			}
+		}
+		for _, player := range kicked {
+			game.kickPlayer(player, reason)
+		}
+		result <- len(kicked)
+		close(result)
+	})
+	return <-result
+}
@@ -17,6 +17,41 @@ type IChunkBlock interface {
 	ItemType(itemTypeId ItemTypeId) (itemType *ItemType, ok bool)
 	AddEntity(s INonPlayerEntity)
 	SetBlockByIndex(blockIndex BlockIndex, blockId BlockId, blockData byte)
+
+	// BlockAt returns the type and data of a block in the chunk, a loaded
+	// neighbouring chunk within the same shard, or a block in another shard
+	// that has been queried before. ok=false if the block is not currently
+	// known. Blocks in another shard are queried when not known, and the blocks
+	// adjoining them made active once the reply arrives.
+	BlockAt(blockLoc *BlockXyz) (blockType *BlockType, blockData byte, ok bool)
+
+	// SetBlockAt sets a block anywhere in the world, loading the chunk that it
+	// is within if need be. Blocks in another shard are set at the end of the
+	// tick. Returns false if the block could not be set.
+	SetBlockAt(blockLoc *BlockXyz, blockId BlockId, blockData byte) (ok bool)
+
+	// CurrentTick returns the number of ticks that the shard has run for.
+	CurrentTick() Ticks
+
+	// ScheduleBlockTick flags a block in the chunk itself as active after the
+	// given number of ticks have passed.
+	ScheduleBlockTick(blockIndex BlockIndex, delay Ticks)
+
+	// IsOccupied returns true if any player or other entity is within the
+	// given block in the chunk.
+	IsOccupied(blockLoc *BlockXyz) bool
+
+	// MulticastPlayers sends a packet to all players subscribed to the chunk.
+	MulticastPlayers(packet []byte)
+
+	// PlayersNear returns the players within maxDistance of position. Only
+	// players in loaded chunks within the chunk's shard are found.
+	PlayersNear(position *AbsXyz, maxDistance AbsCoord) []NearbyPlayer
+
+	// MobsNear returns the living mobs within maxDistance of position. Only
+	// mobs in loaded chunks within the chunk's shard are found.
+	MobsNear(position *AbsXyz, maxDistance AbsCoord) []IMob
+
 	BlockExtra(blockIndex BlockIndex) interface{}
 	SetBlockExtra(blockIndex BlockIndex, extra interface{})
 	AddOnUnsubscribe(entityId EntityId, observer IUnsubscribed)
@@ -29,6 +64,18 @@ type IChunkBlock interface {
 	AddActiveBlockIndex(blockIndex BlockIndex)
 }
 
+// IPlaceableAspect is implemented by the aspects of blocks that depend on how
+// the player placed them.
+type IPlaceableAspect interface {
+	// PlaceAs returns the block type and data to place when placed against the
+	// given face of another block, with the player looking in the given
+	// direction. ok=false if the block cannot be placed that way.
+	PlaceAs(againstFace Face, look *LookDegrees) (blockId BlockId, blockData byte, ok bool)
+
+	// Placed is called after a player has placed the block.
+	Placed(instance *BlockInstance, player IPlayerClient)
+}
+
 // IUnsubscribed is the interface by which blocks (and potentially other
 // things) can register themselves to be called when a player unsubscribes from
 // a chunk.
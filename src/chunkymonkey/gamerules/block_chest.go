@@ -19,5 +19,10 @@ func createChestInventory(instance *BlockInstance) *blockInventory {
 		inv,
 		false,
 		InvTypeIdChest,
+		"Chest",
 	)
 }
+
+func newBlankChest() ITileEntity {
+	return createChestInventory(new(BlockInstance))
+}
@@ -0,0 +1,94 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+const (
+	doorOpenFlag = 0x4
+	doorTopFlag  = 0x8
+)
+
+func makeDoorAspect() (aspect IBlockAspect) {
+	return &DoorAspect{}
+}
+
+// DoorAspect is the behaviour of the two blocks that make up a door. The 3rd
+// bit of the block data is set while the door is open, and the 4th bit is set
+// on the top half of the door. The door opens and closes as the power to it
+// changes, and can also be opened by hand if Manual is set.
+type DoorAspect struct {
+	StandardAspect
+	// Manual is true if players can open and close the door by hand.
+	Manual bool
+}
+
+func (aspect *DoorAspect) Name() string {
+	return "Door"
+}
+
+// otherHalf returns the location of the other block of the door.
+func (aspect *DoorAspect) otherHalf(instance *BlockInstance) *BlockXyz {
+	if instance.Data&doorTopFlag != 0 {
+		return neighbourLoc(&instance.BlockLoc, FaceBottom)
+	}
+	return neighbourLoc(&instance.BlockLoc, FaceTop)
+}
+
+// setOpen opens or closes both halves of the door.
+func (aspect *DoorAspect) setOpen(instance *BlockInstance, open bool) {
+	doorId := instance.BlockType.id
+	newData := instance.Data &^ doorOpenFlag
+	if open {
+		newData |= doorOpenFlag
+	}
+	instance.Chunk.SetBlockByIndex(instance.Index, doorId, newData)
+
+	if otherLoc := aspect.otherHalf(instance); otherLoc != nil {
+		if otherType, otherData, ok := instance.Chunk.BlockAt(otherLoc); ok && otherType.id == doorId {
+			instance.Chunk.SetBlockAt(otherLoc, doorId, (otherData&^doorOpenFlag)|(newData&doorOpenFlag))
+		}
+	}
+}
+
+func (aspect *DoorAspect) Interact(instance *BlockInstance, player IPlayerClient) {
+	if aspect.Manual {
+		aspect.setOpen(instance, instance.Data&doorOpenFlag == 0)
+	}
+}
+
+func (aspect *DoorAspect) Destroy(instance *BlockInstance) {
+	aspect.StandardAspect.Destroy(instance)
+
+	if otherLoc := aspect.otherHalf(instance); otherLoc != nil {
+		if otherType, _, ok := instance.Chunk.BlockAt(otherLoc); ok && otherType.id == instance.BlockType.id {
+			instance.Chunk.SetBlockAt(otherLoc, BlockIdAir, 0)
+		}
+	}
+}
+
+func (aspect *DoorAspect) Tick(instance *BlockInstance) bool {
+	if instance.Data&doorTopFlag != 0 {
+		// The bottom half of the door handles power for both halves.
+		if otherLoc := aspect.otherHalf(instance); otherLoc != nil {
+			instance.Chunk.AddActiveBlock(otherLoc)
+		}
+		return false
+	}
+
+	powered := isBlockPowered(instance.Chunk, &instance.BlockLoc)
+	if topLoc := aspect.otherHalf(instance); !powered && topLoc != nil {
+		powered = isBlockPowered(instance.Chunk, topLoc)
+	}
+
+	// Only react to changes in power, so that doors opened by hand stay open.
+	wasPowered, known := instance.Chunk.BlockExtra(instance.Index).(bool)
+	if known && wasPowered != powered {
+		aspect.setOpen(instance, powered)
+	}
+	if !known || wasPowered != powered {
+		instance.Chunk.SetBlockExtra(instance.Index, powered)
+	}
+
+	return false
+}
@@ -0,0 +1,49 @@
+package gamerules
+
+import (
+	"os"
+
+	. "chunkymonkey/types"
+)
+
+func makeFallingAspect() (aspect IBlockAspect) {
+	return &FallingAspect{}
+}
+
+// FallingAspect is the behaviour of blocks such as sand and gravel that fall
+// when there is nothing underneath them. They become a falling object entity
+// while in the air, and turn back into a block when they land.
+type FallingAspect struct {
+	StandardAspect
+	// Object is the type of the object that the block becomes while falling.
+	Object ObjTypeId
+}
+
+func (aspect *FallingAspect) Name() string {
+	return "Falling"
+}
+
+func (aspect *FallingAspect) Check() os.Error {
+	if _, ok := fallingBlockTypes[aspect.Object]; !ok {
+		return os.NewError("Falling Object must be the object type of a falling block")
+	}
+	return aspect.StandardAspect.Check()
+}
+
+func (aspect *FallingAspect) Tick(instance *BlockInstance) bool {
+	dx, dy, dz := FaceBottom.Dxyz()
+	belowLoc := instance.BlockLoc.AddXyz(dx, dy, dz)
+	if belowLoc == nil {
+		return false
+	}
+
+	below, _, ok := instance.Chunk.BlockAt(belowLoc)
+	if !ok || !below.Replaceable {
+		return false
+	}
+
+	instance.Chunk.SetBlockByIndex(instance.Index, BlockIdAir, 0)
+	instance.Chunk.AddEntity(NewFallingBlock(aspect.Object, &instance.BlockLoc))
+
+	return false
+}
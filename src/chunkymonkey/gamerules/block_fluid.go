@@ -0,0 +1,265 @@
+package gamerules
+
+import (
+	"os"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	fluidLevelMask   = 0x7
+	fluidFallingFlag = 0x8
+)
+
+// The faces that a fluid can spread sideways through.
+var fluidHorizontalFaces = []Face{FaceEast, FaceWest, FaceNorth, FaceSouth}
+
+func makeFluidAspect() (aspect IBlockAspect) {
+	return &FluidAspect{}
+}
+
+// FluidAspect is the behaviour of water and lava.
+//
+// The lower 3 bits of the block data hold the level of the fluid, which is 0
+// for a source block and increases as the fluid flows away from its source.
+// The 4th bit is set on fluid that is falling.
+//
+// A fluid block only ticks while it might need to change. Changes to a block
+// make its neighbours active, and they then work out their own level from the
+// fluid around them and spread into neighbouring blocks in turn.
+type FluidAspect struct {
+	VoidAspect
+	// Flowing is the block ID of the fluid while it is moving.
+	Flowing BlockId
+	// Stationary is the block ID of the fluid once it has settled.
+	Stationary BlockId
+	// MaxLevel is the highest level that the fluid will spread to.
+	MaxLevel byte
+	// TickDelay is the number of ticks between each step of the fluid flowing.
+	TickDelay Ticks
+	// InfiniteSource is true if a new source block forms between two adjacent
+	// source blocks.
+	InfiniteSource bool
+	// SourceSolidifies is the block ID that a source block turns into on
+	// contact with a different fluid. Zero means that it does not solidify.
+	SourceSolidifies BlockId
+	// FlowingSolidifies is the block ID that a non-source block turns into on
+	// contact with a different fluid. Zero means that it does not solidify.
+	FlowingSolidifies BlockId
+}
+
+func (aspect *FluidAspect) Name() string {
+	return "Fluid"
+}
+
+func (aspect *FluidAspect) Check() os.Error {
+	if aspect.Flowing == aspect.Stationary {
+		return os.NewError("Fluid must have different Flowing and Stationary block IDs")
+	}
+	if aspect.MaxLevel < 1 || aspect.MaxLevel > fluidLevelMask {
+		return os.NewError("Fluid MaxLevel must be between 1 and 7")
+	}
+	if aspect.TickDelay < 1 {
+		return os.NewError("Fluid TickDelay must be at least 1")
+	}
+	return nil
+}
+
+func (aspect *FluidAspect) Tick(instance *BlockInstance) bool {
+	if instance.Chunk.CurrentTick()%aspect.TickDelay != 0 {
+		// Not time to flow yet, stay active until it is.
+		return true
+	}
+
+	if aspect.solidify(instance) {
+		return false
+	}
+
+	if instance.Data != 0 {
+		// Non-source blocks take their level from the fluid feeding them.
+		if data, known := aspect.fedData(instance); known && data != instance.Data {
+			if data&fluidLevelMask > aspect.MaxLevel {
+				// Nothing feeds this block any more, so it dries up.
+				instance.Chunk.SetBlockByIndex(instance.Index, BlockIdAir, 0)
+				return false
+			}
+			instance.Chunk.SetBlockByIndex(instance.Index, aspect.Flowing, data)
+			return true
+		}
+	}
+
+	if aspect.spread(instance) {
+		if instance.BlockType.id != aspect.Flowing {
+			instance.Chunk.SetBlockByIndex(instance.Index, aspect.Flowing, instance.Data)
+		}
+		return true
+	}
+
+	// Nothing left to do, settle the fluid.
+	if instance.BlockType.id != aspect.Stationary {
+		instance.Chunk.SetBlockByIndex(instance.Index, aspect.Stationary, instance.Data)
+	}
+
+	return false
+}
+
+// isSame returns true if the block type is either form of this fluid.
+func (aspect *FluidAspect) isSame(blockType *BlockType) bool {
+	return blockType.id == aspect.Flowing || blockType.id == aspect.Stationary
+}
+
+// isOther returns true if the block type is a fluid other than this one.
+func (aspect *FluidAspect) isOther(blockType *BlockType) bool {
+	_, isFluid := blockType.Aspect.(*FluidAspect)
+	return isFluid && !aspect.isSame(blockType)
+}
+
+// canFlowInto returns true if the fluid can replace the given block type.
+func (aspect *FluidAspect) canFlowInto(blockType *BlockType) bool {
+	if _, isFluid := blockType.Aspect.(*FluidAspect); isFluid {
+		// Other fluid blocks work out their own level, or solidify.
+		return false
+	}
+	return blockType.Replaceable
+}
+
+// neighbour returns the block type and data next to the instance on the given
+// face. ok=false if the neighbouring block is not known.
+func (aspect *FluidAspect) neighbour(instance *BlockInstance, face Face) (loc *BlockXyz, blockType *BlockType, data byte, ok bool) {
+	dx, dy, dz := face.Dxyz()
+	if loc = instance.BlockLoc.AddXyz(dx, dy, dz); loc == nil {
+		return
+	}
+	blockType, data, ok = instance.Chunk.BlockAt(loc)
+	return
+}
+
+// solidify turns the fluid into a solid block if it is touching a different
+// fluid on any face, including below, as when lava flows over water. Returns
+// true if it solidified.
+func (aspect *FluidAspect) solidify(instance *BlockInstance) bool {
+	if aspect.SourceSolidifies == 0 && aspect.FlowingSolidifies == 0 {
+		return false
+	}
+
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		_, blockType, _, ok := aspect.neighbour(instance, face)
+		if !ok || !aspect.isOther(blockType) {
+			continue
+		}
+
+		newBlockId := aspect.FlowingSolidifies
+		if instance.Data == 0 {
+			newBlockId = aspect.SourceSolidifies
+		}
+		if newBlockId != 0 {
+			instance.Chunk.SetBlockByIndex(instance.Index, newBlockId, 0)
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBlockedBelow returns true if fluid sitting on top of the given block
+// cannot fall any further.
+func (aspect *FluidAspect) isBlockedBelow(blockType *BlockType) bool {
+	return aspect.isSame(blockType) || !aspect.canFlowInto(blockType)
+}
+
+// fedData works out what the data for a non-source block should be, given the
+// fluid around it. known=false if a neighbouring block could not be read, in
+// which case the block should be left as it is.
+func (aspect *FluidAspect) fedData(instance *BlockInstance) (data byte, known bool) {
+	_, above, _, ok := aspect.neighbour(instance, FaceTop)
+	if !ok {
+		return
+	}
+	if aspect.isSame(above) {
+		return fluidFallingFlag, true
+	}
+
+	_, below, belowData, ok := aspect.neighbour(instance, FaceBottom)
+	if !ok {
+		return
+	}
+
+	level := aspect.MaxLevel + 1
+	numSources := 0
+
+	for _, face := range fluidHorizontalFaces {
+		loc, blockType, nData, ok := aspect.neighbour(instance, face)
+		if !ok {
+			return
+		}
+		if !aspect.isSame(blockType) {
+			continue
+		}
+
+		if nData == 0 {
+			numSources++
+		} else {
+			// Fluid that is still falling doesn't spread sideways.
+			dx, dy, dz := FaceBottom.Dxyz()
+			underLoc := loc.AddXyz(dx, dy, dz)
+			if underLoc == nil {
+				continue
+			}
+			under, _, ok := instance.Chunk.BlockAt(underLoc)
+			if !ok {
+				return
+			}
+			if !aspect.isBlockedBelow(under) {
+				continue
+			}
+		}
+
+		nLevel := nData & fluidLevelMask
+		if nData&fluidFallingFlag != 0 {
+			nLevel = 0
+		}
+		if nLevel+1 < level {
+			level = nLevel + 1
+		}
+	}
+
+	if aspect.InfiniteSource && numSources >= 2 {
+		if !aspect.canFlowInto(below) && (!aspect.isSame(below) || belowData == 0) {
+			return 0, true
+		}
+	}
+
+	return level, true
+}
+
+// spread flows the fluid into neighbouring blocks. Returns true if any block
+// was changed.
+func (aspect *FluidAspect) spread(instance *BlockInstance) (changed bool) {
+	belowLoc, below, _, ok := aspect.neighbour(instance, FaceBottom)
+	if ok && aspect.canFlowInto(below) {
+		return instance.Chunk.SetBlockAt(belowLoc, aspect.Flowing, fluidFallingFlag)
+	}
+	if !ok || !aspect.isBlockedBelow(below) {
+		// Don't spread sideways until it's known that the fluid can't fall.
+		return false
+	}
+
+	level := instance.Data & fluidLevelMask
+	if instance.Data&fluidFallingFlag != 0 {
+		level = 0
+	}
+	if level >= aspect.MaxLevel {
+		return false
+	}
+
+	for _, face := range fluidHorizontalFaces {
+		loc, blockType, _, ok := aspect.neighbour(instance, face)
+		if ok && aspect.canFlowInto(blockType) {
+			if instance.Chunk.SetBlockAt(loc, aspect.Flowing, level+1) {
+				changed = true
+			}
+		}
+	}
+
+	return
+}
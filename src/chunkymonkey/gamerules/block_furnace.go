@@ -25,9 +25,14 @@ func createFurnaceInventory(instance *BlockInstance) *blockInventory {
 		NewFurnaceInventory(),
 		false,
 		InvTypeIdFurnace,
+		"Furnace",
 	)
 }
 
+func newBlankFurnace() ITileEntity {
+	return createFurnaceInventory(new(BlockInstance))
+}
+
 func (aspect *FurnaceAspect) InventoryClick(instance *BlockInstance, player IPlayerClient, click *Click) {
 
 	aspect.InventoryAspect.InventoryClick(instance, player, click)
@@ -1,5 +1,18 @@
 package gamerules
 
+// iInventoryAspect is implemented by the aspects of blocks that have
+// inventory.
+type iInventoryAspect interface {
+	blockInv(instance *BlockInstance, create bool) *blockInventory
+}
+
+// HasInventory returns true if blocks of the type have inventory that players
+// can open.
+func (blockType *BlockType) HasInventory() bool {
+	_, ok := blockType.Aspect.(iInventoryAspect)
+	return ok
+}
+
 // InventoryAspect is the common behaviour for blocks that have inventory.
 type InventoryAspect struct {
 	StandardAspect
@@ -1,28 +1,35 @@
 package gamerules
 
 import (
+	"os"
+
 	. "chunkymonkey/types"
+	"nbt"
 )
 
 // blockInventory is the data stored in Chunk.SetBlockExtra by some block
 // aspects that contain inventories. It also implements IInventorySubscriber to
-// relay events to player(s) subscribed to the inventories.
+// relay events to player(s) subscribed to the inventories, and ITileEntity to
+// store the inventory with the chunk.
 type blockInventory struct {
 	instance           BlockInstance
 	inv                IInventory
 	subscribers        map[EntityId]IPlayerClient
 	ejectOnUnsubscribe bool
 	invTypeId          InvTypeId
+	tileEntityId       string
 }
 
-// newBlockInventory creates a new blockInventory.
-func newBlockInventory(instance *BlockInstance, inv IInventory, ejectOnUnsubscribe bool, invTypeId InvTypeId) *blockInventory {
+// newBlockInventory creates a new blockInventory. The inventory is not stored
+// with the chunk if tileEntityId is empty.
+func newBlockInventory(instance *BlockInstance, inv IInventory, ejectOnUnsubscribe bool, invTypeId InvTypeId, tileEntityId string) *blockInventory {
 	blkInv := &blockInventory{
 		instance:           *instance,
 		inv:                inv,
 		subscribers:        make(map[EntityId]IPlayerClient),
 		ejectOnUnsubscribe: ejectOnUnsubscribe,
 		invTypeId:          invTypeId,
+		tileEntityId:       tileEntityId,
 	}
 
 	blkInv.inv.SetSubscriber(blkInv)
@@ -40,6 +47,10 @@ func (blkInv *blockInventory) Click(player IPlayerClient, click *Click) {
 }
 
 func (blkInv *blockInventory) SlotUpdate(slot *Slot, slotId SlotId) {
+	// Setting the block extra again flags the chunk as needing to be stored
+	// with the new inventory contents.
+	blkInv.instance.Chunk.SetBlockExtra(blkInv.instance.Index, blkInv)
+
 	for _, subscriber := range blkInv.subscribers {
 		subscriber.InventorySlotUpdate(blkInv.instance.BlockLoc, *slot, slotId)
 	}
@@ -95,3 +106,32 @@ func (blkInv *blockInventory) EjectItems() {
 		spawnItemInBlock(&blkInv.instance, slot.ItemTypeId, slot.Count, slot.Data)
 	}
 }
+
+func (blkInv *blockInventory) Block() BlockXyz {
+	return blkInv.instance.BlockLoc
+}
+
+func (blkInv *blockInventory) SetChunk(chunk IChunkBlock) {
+	blkInv.instance.Chunk = chunk
+}
+
+func (blkInv *blockInventory) ReadNbt(tag nbt.ITag) (err os.Error) {
+	if err = readTileEntityInstance(tag, &blkInv.instance); err != nil {
+		return
+	}
+	return blkInv.inv.ReadNbt(tag)
+}
+
+func (blkInv *blockInventory) WriteNbt() nbt.ITag {
+	if blkInv.tileEntityId == "" {
+		return nil
+	}
+	if blkInv.ejectOnUnsubscribe && len(blkInv.subscribers) == 0 {
+		// The items have already been ejected.
+		return nil
+	}
+
+	tag := newTileEntityNbt(blkInv.tileEntityId, &blkInv.instance.BlockLoc)
+	blkInv.inv.WriteNbt(tag)
+	return tag
+}
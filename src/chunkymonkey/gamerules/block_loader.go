@@ -189,12 +189,25 @@ func LoadBlocksFromFile(filename string) (blockTypes BlockTypeList, err os.Error
 
 func init() {
 	aspectMakers = map[string]aspectMakerFn{
-		"Chest":     makeChestAspect,
-		"Furnace":   makeFurnaceAspect,
-		"Sapling":   makeSaplingAspect,
-		"Standard":  makeStandardAspect,
-		"Todo":      makeTodoAspect,
-		"Void":      makeVoidAspect,
-		"Workbench": makeWorkbenchAspect,
+		"Button":        makeButtonAspect,
+		"Chest":         makeChestAspect,
+		"Door":          makeDoorAspect,
+		"Falling":       makeFallingAspect,
+		"Fluid":         makeFluidAspect,
+		"Furnace":       makeFurnaceAspect,
+		"Lever":         makeLeverAspect,
+		"MobSpawner":    makeMobSpawnerAspect,
+		"Portal":        makePortalAspect,
+		"PressurePlate": makePressurePlateAspect,
+		"RedstoneTorch": makeRedstoneTorchAspect,
+		"RedstoneWire":  makeRedstoneWireAspect,
+		"Repeater":      makeRepeaterAspect,
+		"Sapling":       makeSaplingAspect,
+		"Sign":          makeSignAspect,
+		"Standard":      makeStandardAspect,
+		"Tnt":           makeTntAspect,
+		"Todo":          makeTodoAspect,
+		"Void":          makeVoidAspect,
+		"Workbench":     makeWorkbenchAspect,
 	}
 }
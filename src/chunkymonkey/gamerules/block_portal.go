@@ -0,0 +1,175 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+const (
+	BlockIdPortal       = BlockId(90)
+	ItemIdFlintAndSteel = ItemTypeId(259)
+
+	blockIdObsidian = BlockId(49)
+
+	// The inside of a portal frame is portalWidth blocks wide and portalHeight
+	// blocks high.
+	portalWidth  = 2
+	portalHeight = 3
+)
+
+// portalAxes are the directions along which a portal frame can lie.
+var portalAxes = [][2]BlockCoord{{1, 0}, {0, 1}}
+
+func makePortalAspect() (aspect IBlockAspect) {
+	return &PortalAspect{}
+}
+
+// PortalAspect is the behaviour of the blocks inside a lit portal frame, which
+// take players who stand in them to another dimension. Portal blocks break
+// when their frame does.
+type PortalAspect struct {
+	StandardAspect
+}
+
+func (aspect *PortalAspect) Name() string {
+	return "Portal"
+}
+
+func (aspect *PortalAspect) Tick(instance *BlockInstance) bool {
+	if !isPortalFramed(instance.Chunk, &instance.BlockLoc) {
+		instance.Chunk.SetBlockByIndex(instance.Index, BlockIdAir, 0)
+	}
+	return false
+}
+
+// isPortalFramed returns true if the portal block at blockLoc is still held in
+// place by portal or obsidian blocks above, below and to both sides of it
+// along one of the axes. Blocks that are not known are assumed to be in place.
+func isPortalFramed(chunk IChunkBlock, blockLoc *BlockXyz) bool {
+	if !isPortalOrFrame(chunk, blockLoc.AddXyz(0, 1, 0)) || !isPortalOrFrame(chunk, blockLoc.AddXyz(0, -1, 0)) {
+		return false
+	}
+
+	for _, axis := range portalAxes {
+		if isPortalOrFrame(chunk, blockLoc.AddXyz(axis[0], 0, axis[1])) &&
+			isPortalOrFrame(chunk, blockLoc.AddXyz(-axis[0], 0, -axis[1])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPortalOrFrame(chunk IChunkBlock, blockLoc *BlockXyz) bool {
+	if blockLoc == nil {
+		return false
+	}
+	blockType, _, ok := chunk.BlockAt(blockLoc)
+	return !ok || blockType.id == BlockIdPortal || blockType.id == blockIdObsidian
+}
+
+// LightPortal fills the inside of an empty obsidian portal frame with portal
+// blocks, if blockLoc is inside such a frame. It is used when a player lights
+// a frame with flint and steel. Returns false if there is no frame to light.
+func LightPortal(chunk IChunkBlock, blockLoc *BlockXyz) bool {
+	for _, axis := range portalAxes {
+		// blockLoc could be any of the blocks inside the frame.
+		for i := 0; i < portalWidth; i++ {
+			for j := 0; j < portalHeight; j++ {
+				origin := blockLoc.AddXyz(-BlockCoord(i)*axis[0], -BlockYCoord(j), -BlockCoord(i)*axis[1])
+				if origin == nil || !isEmptyPortalFrame(chunk, origin, axis) {
+					continue
+				}
+
+				forPortalFrame(origin, axis, func(loc *BlockXyz, inside bool) {
+					if inside {
+						chunk.SetBlockAt(loc, BlockIdPortal, 0)
+					}
+				})
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isEmptyPortalFrame returns true if there is a complete obsidian frame along
+// the axis, with nothing inside, whose lowest inside block nearest the origin
+// of the axis is at origin. The corners of the frame are not needed.
+func isEmptyPortalFrame(chunk IChunkBlock, origin *BlockXyz, axis [2]BlockCoord) bool {
+	complete := true
+	forPortalFrame(origin, axis, func(loc *BlockXyz, inside bool) {
+		if !complete {
+			return
+		}
+		if loc == nil {
+			complete = false
+			return
+		}
+		blockType, _, ok := chunk.BlockAt(loc)
+		if !ok {
+			complete = false
+		} else if inside {
+			complete = blockType.id == BlockIdAir
+		} else {
+			complete = blockType.id == blockIdObsidian
+		}
+	})
+	return complete
+}
+
+// forPortalFrame calls fn for each block of the frame (except its corners) and
+// each block inside it, for the frame along the axis whose lowest inside block
+// nearest the origin of the axis is at origin. loc is nil for blocks outside of
+// the world.
+func forPortalFrame(origin *BlockXyz, axis [2]BlockCoord, fn func(loc *BlockXyz, inside bool)) {
+	for i := -1; i <= portalWidth; i++ {
+		for j := -1; j <= portalHeight; j++ {
+			edgeI, edgeJ := i == -1 || i == portalWidth, j == -1 || j == portalHeight
+			if edgeI && edgeJ {
+				continue
+			}
+			loc := origin.AddXyz(BlockCoord(i)*axis[0], BlockYCoord(j), BlockCoord(i)*axis[1])
+			fn(loc, !edgeI && !edgeJ)
+		}
+	}
+}
+
+// BuildPortal builds a lit portal, for players arriving from another
+// dimension where there is none. It lies along the X axis with the lowest
+// inside block nearest the origin at origin, with obsidian to stand on either
+// side of it and the space around it cleared. All of the blocks must lie
+// within loaded chunks in the same shard as chunk. Returns the position at
+// the middle of the bottom of the portal.
+func BuildPortal(chunk IChunkBlock, origin *BlockXyz) AbsXyz {
+	axis := portalAxes[0]
+
+	for dz := BlockCoord(-1); dz <= 1; dz++ {
+		for i := -1; i <= portalWidth; i++ {
+			for j := -1; j <= portalHeight; j++ {
+				loc := origin.AddXyz(BlockCoord(i), BlockYCoord(j), dz)
+				if loc == nil {
+					continue
+				}
+
+				blockId := BlockIdAir
+				if j == -1 || (dz == 0 && (i == -1 || i == portalWidth || j == portalHeight)) {
+					blockId = blockIdObsidian
+				}
+				chunk.SetBlockAt(loc, blockId, 0)
+			}
+		}
+	}
+
+	forPortalFrame(origin, axis, func(loc *BlockXyz, inside bool) {
+		if inside && loc != nil {
+			chunk.SetBlockAt(loc, BlockIdPortal, 0)
+		}
+	})
+
+	return AbsXyz{
+		X: AbsCoord(origin.X) + portalWidth/2.0,
+		Y: AbsCoord(origin.Y),
+		Z: AbsCoord(origin.Z) + 0.5,
+	}
+}
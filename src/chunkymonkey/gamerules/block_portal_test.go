@@ -0,0 +1,152 @@
+package gamerules
+
+import (
+	"fmt"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+// testPortalChunk keeps blocks in memory for the portal functions. Blocks that
+// have not been set are air. Its other IChunkBlock methods are not
+// implemented.
+type testPortalChunk struct {
+	IChunkBlock
+	blocks map[string]BlockId
+}
+
+func newTestPortalChunk() *testPortalChunk {
+	return &testPortalChunk{blocks: make(map[string]BlockId)}
+}
+
+func testBlockKey(loc *BlockXyz) string {
+	return fmt.Sprintf("%d,%d,%d", loc.X, loc.Y, loc.Z)
+}
+
+func (chunk *testPortalChunk) BlockAt(blockLoc *BlockXyz) (blockType *BlockType, blockData byte, ok bool) {
+	blockType, ok = Blocks.Get(chunk.blocks[testBlockKey(blockLoc)])
+	return
+}
+
+func (chunk *testPortalChunk) SetBlockAt(blockLoc *BlockXyz, blockId BlockId, blockData byte) (ok bool) {
+	chunk.blocks[testBlockKey(blockLoc)] = blockId
+	return true
+}
+
+func (chunk *testPortalChunk) blockId(loc BlockXyz) BlockId {
+	return chunk.blocks[testBlockKey(&loc)]
+}
+
+// buildFrame builds an empty obsidian portal frame along the axis, whose
+// lowest inside block nearest the origin of the axis is at origin.
+func (chunk *testPortalChunk) buildFrame(origin BlockXyz, axis [2]BlockCoord) {
+	forPortalFrame(&origin, axis, func(loc *BlockXyz, inside bool) {
+		if !inside {
+			chunk.SetBlockAt(loc, blockIdObsidian, 0)
+		}
+	})
+}
+
+// expectPortal checks whether the inside of the frame along the axis at
+// origin is filled with framed portal blocks.
+func (chunk *testPortalChunk) expectPortal(t *testing.T, origin BlockXyz, axis [2]BlockCoord, lit bool) {
+	forPortalFrame(&origin, axis, func(loc *BlockXyz, inside bool) {
+		if !inside {
+			return
+		}
+		if isPortal := chunk.blockId(*loc) == BlockIdPortal; isPortal != lit {
+			t.Errorf("expected portal block at %#v to be %t", *loc, lit)
+		} else if lit && !isPortalFramed(chunk, loc) {
+			t.Errorf("expected portal block at %#v to be framed", *loc)
+		}
+	})
+}
+
+func TestLightPortal(t *testing.T) {
+	for _, axis := range portalAxes {
+		chunk := newTestPortalChunk()
+		origin := BlockXyz{10, 64, 20}
+		chunk.buildFrame(origin, axis)
+
+		// Any of the blocks inside the frame can be lit.
+		litLoc := origin.AddXyz(axis[0], 2, axis[1])
+		if !LightPortal(chunk, litLoc) {
+			t.Fatalf("axis %v: expected the frame to be lit", axis)
+		}
+		chunk.expectPortal(t, origin, axis, true)
+	}
+}
+
+func TestLightPortal_IncompleteFrame(t *testing.T) {
+	chunk := newTestPortalChunk()
+	origin := BlockXyz{10, 64, 20}
+	axis := portalAxes[0]
+	chunk.buildFrame(origin, axis)
+	chunk.SetBlockAt(origin.AddXyz(0, portalHeight, 0), BlockIdAir, 0)
+
+	if LightPortal(chunk, &origin) {
+		t.Errorf("expected a frame with a gap not to be lit")
+	}
+	chunk.expectPortal(t, origin, axis, false)
+}
+
+func TestLightPortal_NotEmpty(t *testing.T) {
+	chunk := newTestPortalChunk()
+	origin := BlockXyz{10, 64, 20}
+	axis := portalAxes[0]
+	chunk.buildFrame(origin, axis)
+	chunk.SetBlockAt(origin.AddXyz(1, 1, 0), blockIdObsidian, 0)
+
+	if LightPortal(chunk, &origin) {
+		t.Errorf("expected a frame with a block inside it not to be lit")
+	}
+}
+
+func TestIsPortalFramed(t *testing.T) {
+	chunk := newTestPortalChunk()
+	origin := BlockXyz{10, 64, 20}
+	axis := portalAxes[0]
+	chunk.buildFrame(origin, axis)
+	LightPortal(chunk, &origin)
+
+	// Breaking the side of the frame next to the origin leaves the portal
+	// block there without a frame, but not the one beyond it.
+	chunk.SetBlockAt(origin.AddXyz(-1, 0, 0), BlockIdAir, 0)
+	if isPortalFramed(chunk, &origin) {
+		t.Errorf("expected the portal block next to the broken frame not to be framed")
+	}
+	if beyond := origin.AddXyz(1, 1, 0); !isPortalFramed(chunk, beyond) {
+		t.Errorf("expected the portal block at %#v to still be framed", *beyond)
+	}
+
+	// Nor is a portal block at the bottom of the world.
+	if bottom := (BlockXyz{10, 0, 20}); isPortalFramed(chunk, &bottom) {
+		t.Errorf("expected a portal block at the bottom of the world not to be framed")
+	}
+}
+
+func TestBuildPortal(t *testing.T) {
+	chunk := newTestPortalChunk()
+	origin := BlockXyz{10, 64, 20}
+
+	// The space that the portal is built in is cleared.
+	chunk.SetBlockAt(origin.AddXyz(1, 1, 1), blockIdObsidian, 0)
+
+	position := BuildPortal(chunk, &origin)
+
+	expected := AbsXyz{11, 64, 20.5}
+	if position.X != expected.X || position.Y != expected.Y || position.Z != expected.Z {
+		t.Errorf("expected the portal at %#v, got %#v", expected, position)
+	}
+
+	axis := portalAxes[0]
+	chunk.expectPortal(t, origin, axis, true)
+	if id := chunk.blockId(*origin.AddXyz(1, 1, 1)); id != BlockIdAir {
+		t.Errorf("expected the space beside the portal to be cleared, got block %d", id)
+	}
+	for dz := BlockCoord(-1); dz <= 1; dz++ {
+		if id := chunk.blockId(*origin.AddXyz(0, -1, dz)); id != blockIdObsidian {
+			t.Errorf("expected obsidian to stand on at dz=%d, got block %d", dz, id)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package gamerules
+
+import (
+	"os"
+
+	. "chunkymonkey/types"
+)
+
+// The number of ticks for a redstone torch to change state.
+const torchDelay = 2
+
+// repeaterOutputFaces maps the lower 2 bits of a repeater's data to the face
+// that it outputs power through. It takes input through the opposite face.
+var repeaterOutputFaces = [4]Face{FaceEast, FaceSouth, FaceWest, FaceNorth}
+
+func makeRedstoneWireAspect() (aspect IBlockAspect) {
+	return &RedstoneWireAspect{}
+}
+
+// RedstoneWireAspect is the behaviour of redstone wire. The block data holds
+// the power level of the wire, which is one less than the most powerful wire
+// next to it, or the maximum if it is next to a power source.
+type RedstoneWireAspect struct {
+	StandardAspect
+}
+
+func (aspect *RedstoneWireAspect) Name() string {
+	return "RedstoneWire"
+}
+
+func (aspect *RedstoneWireAspect) PowerTo(blockData byte, face Face) byte {
+	if face == FaceTop {
+		return powerNone
+	}
+	return blockData & 0xf
+}
+
+func (aspect *RedstoneWireAspect) Tick(instance *BlockInstance) bool {
+	if level := aspect.level(instance); level != instance.Data {
+		instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, level)
+	}
+	return false
+}
+
+// level works out the power level that the wire should have.
+func (aspect *RedstoneWireAspect) level(instance *BlockInstance) (level byte) {
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		loc := neighbourLoc(&instance.BlockLoc, face)
+		if loc == nil {
+			continue
+		}
+		blockType, blockData, ok := instance.Chunk.BlockAt(loc)
+		if !ok {
+			continue
+		}
+
+		if isWire(blockType) {
+			if blockData > level+1 {
+				level = blockData - 1
+			}
+		} else if source, isSource := blockType.Aspect.(IPowerSource); isSource {
+			if source.PowerTo(blockData, face.Opposite()) > powerNone {
+				return powerMax
+			}
+		} else if blockType.Solid && receivedPower(instance.Chunk, loc, false) > powerNone {
+			return powerMax
+		}
+	}
+	return
+}
+
+func makeRedstoneTorchAspect() (aspect IBlockAspect) {
+	return &RedstoneTorchAspect{}
+}
+
+// RedstoneTorchAspect is the behaviour of a redstone torch. It powers all of
+// its neighbours except for the block that it is attached to, and turns off
+// while that block is powered.
+type RedstoneTorchAspect struct {
+	StandardAspect
+	// Lit is the block ID of the torch while it is on.
+	Lit BlockId
+	// Unlit is the block ID of the torch while it is off.
+	Unlit BlockId
+}
+
+func (aspect *RedstoneTorchAspect) Name() string {
+	return "RedstoneTorch"
+}
+
+func (aspect *RedstoneTorchAspect) Check() os.Error {
+	if aspect.Lit == aspect.Unlit {
+		return os.NewError("RedstoneTorch must have different Lit and Unlit block IDs")
+	}
+	return aspect.StandardAspect.Check()
+}
+
+func (aspect *RedstoneTorchAspect) PowerTo(blockData byte, face Face) byte {
+	if aspect.blockAttrs.id == aspect.Lit && face != attachedFace(blockData) {
+		return powerMax
+	}
+	return powerNone
+}
+
+func (aspect *RedstoneTorchAspect) Tick(instance *BlockInstance) bool {
+	wantLit := !isPoweredThrough(instance.Chunk, &instance.BlockLoc, attachedFace(instance.Data))
+	isLit := instance.BlockType.id == aspect.Lit
+
+	if switchAfterDelay(instance, wantLit != isLit, torchDelay) {
+		newBlockId := aspect.Unlit
+		if wantLit {
+			newBlockId = aspect.Lit
+		}
+		instance.Chunk.SetBlockByIndex(instance.Index, newBlockId, instance.Data)
+	}
+	return false
+}
+
+func makeLeverAspect() (aspect IBlockAspect) {
+	return &LeverAspect{}
+}
+
+// LeverAspect is the behaviour of a lever, which is switched on and off by
+// players. The 4th bit of the block data is set while it is on.
+type LeverAspect struct {
+	StandardAspect
+}
+
+func (aspect *LeverAspect) Name() string {
+	return "Lever"
+}
+
+func (aspect *LeverAspect) PowerTo(blockData byte, face Face) byte {
+	if blockData&0x8 != 0 {
+		return powerMax
+	}
+	return powerNone
+}
+
+func (aspect *LeverAspect) Interact(instance *BlockInstance, player IPlayerClient) {
+	instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, instance.Data^0x8)
+}
+
+func makeButtonAspect() (aspect IBlockAspect) {
+	return &ButtonAspect{}
+}
+
+// ButtonAspect is the behaviour of a button, which provides power for a short
+// time after being pressed. The 4th bit of the block data is set while it is
+// pressed.
+type ButtonAspect struct {
+	StandardAspect
+	// PressTicks is the number of ticks that the button stays pressed for.
+	PressTicks Ticks
+}
+
+func (aspect *ButtonAspect) Name() string {
+	return "Button"
+}
+
+func (aspect *ButtonAspect) PowerTo(blockData byte, face Face) byte {
+	if blockData&0x8 != 0 {
+		return powerMax
+	}
+	return powerNone
+}
+
+func (aspect *ButtonAspect) Interact(instance *BlockInstance, player IPlayerClient) {
+	if instance.Data&0x8 != 0 {
+		return
+	}
+
+	chunk := instance.Chunk
+	chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, instance.Data|0x8)
+	chunk.SetBlockExtra(instance.Index, chunk.CurrentTick()+aspect.PressTicks)
+	chunk.ScheduleBlockTick(instance.Index, aspect.PressTicks)
+}
+
+func (aspect *ButtonAspect) Tick(instance *BlockInstance) bool {
+	if instance.Data&0x8 == 0 {
+		return false
+	}
+
+	due, pressed := instance.Chunk.BlockExtra(instance.Index).(Ticks)
+	if !pressed || instance.Chunk.CurrentTick() >= due {
+		instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, instance.Data&^0x8)
+	}
+	return false
+}
+
+func makePressurePlateAspect() (aspect IBlockAspect) {
+	return &PressurePlateAspect{}
+}
+
+// PressurePlateAspect is the behaviour of a pressure plate, which provides
+// power while something is on top of it. The block data is 1 while it is
+// pressed.
+// TODO Stone pressure plates should only be pressed by players and mobs.
+type PressurePlateAspect struct {
+	StandardAspect
+}
+
+func (aspect *PressurePlateAspect) Name() string {
+	return "PressurePlate"
+}
+
+func (aspect *PressurePlateAspect) PowerTo(blockData byte, face Face) byte {
+	if blockData&0x1 != 0 {
+		return powerMax
+	}
+	return powerNone
+}
+
+func (aspect *PressurePlateAspect) Tick(instance *BlockInstance) bool {
+	occupied := instance.Chunk.IsOccupied(&instance.BlockLoc)
+	pressed := instance.Data&0x1 != 0
+
+	if occupied != pressed {
+		var newData byte
+		if occupied {
+			newData = 1
+		}
+		instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, newData)
+	}
+
+	// Keep checking until nothing is on the plate.
+	return occupied
+}
+
+func makeRepeaterAspect() (aspect IBlockAspect) {
+	return &RepeaterAspect{}
+}
+
+// RepeaterAspect is the behaviour of a redstone repeater. It outputs full
+// power in the direction it faces some time after being powered from behind.
+// The lower 2 bits of the block data hold the direction, and the next 2 bits
+// the delay.
+type RepeaterAspect struct {
+	StandardAspect
+	// Powered is the block ID of the repeater while it is on.
+	Powered BlockId
+	// Unpowered is the block ID of the repeater while it is off.
+	Unpowered BlockId
+}
+
+func (aspect *RepeaterAspect) Name() string {
+	return "Repeater"
+}
+
+func (aspect *RepeaterAspect) Check() os.Error {
+	if aspect.Powered == aspect.Unpowered {
+		return os.NewError("Repeater must have different Powered and Unpowered block IDs")
+	}
+	return aspect.StandardAspect.Check()
+}
+
+func (aspect *RepeaterAspect) outputFace(blockData byte) Face {
+	return repeaterOutputFaces[blockData&0x3]
+}
+
+// delay returns the number of ticks that the repeater takes to switch, which
+// is between 1 and 4 redstone ticks of 2 game ticks each.
+func (aspect *RepeaterAspect) delay(blockData byte) Ticks {
+	return Ticks((blockData>>2)&0x3+1) * 2
+}
+
+func (aspect *RepeaterAspect) PowerTo(blockData byte, face Face) byte {
+	if aspect.blockAttrs.id == aspect.Powered && face == aspect.outputFace(blockData) {
+		return powerMax
+	}
+	return powerNone
+}
+
+func (aspect *RepeaterAspect) Interact(instance *BlockInstance, player IPlayerClient) {
+	// Cycle through the delay settings.
+	instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, (instance.Data+0x4)&0xf)
+}
+
+func (aspect *RepeaterAspect) Tick(instance *BlockInstance) bool {
+	inputFace := aspect.outputFace(instance.Data).Opposite()
+	wantPowered := isPoweredThrough(instance.Chunk, &instance.BlockLoc, inputFace)
+	isPowered := instance.BlockType.id == aspect.Powered
+
+	if switchAfterDelay(instance, wantPowered != isPowered, aspect.delay(instance.Data)) {
+		newBlockId := aspect.Unpowered
+		if wantPowered {
+			newBlockId = aspect.Powered
+		}
+		instance.Chunk.SetBlockByIndex(instance.Index, newBlockId, instance.Data)
+	}
+	return false
+}
@@ -3,8 +3,8 @@ package gamerules
 import (
 	"rand"
 	"time"
+
 	. "chunkymonkey/types"
-	"log"
 )
 
 // Behaviour of a sapling block, takes care of growing or dying depending on
@@ -32,25 +32,16 @@ func (aspect *SaplingAspect) Tick(instance *BlockInstance) bool {
 }
 
 func (aspect *SaplingAspect) makeTree(instance *BlockInstance) bool {
-	loc := instance.SubLoc
 	minheight := 3
 	maxheight := 6
 	height := minheight + rand.Intn(maxheight-minheight)
-	maxy := loc.Y + SubChunkCoord(height)
 
-	for y := loc.Y; y < maxy; y++ {
-		loc.Y = y
-		index, ok := loc.BlockIndex()
-		if !ok {
-			// TODO: Can't place a block outside chunk boundaries
-			log.Printf("Couldn't place a tree block (%v,%v,%v)", loc.X, loc.Y, loc.Z)
-		} else {
-			instance.Chunk.SetBlockByIndex(index, BlockId(17), byte(0))
-		}
+	for y := 0; y < height; y++ {
+		aspect.setTreeBlock(instance, 0, y, 0, BlockId(17))
 	}
 
-	// Store the location at the top block of the tree
-	treex, treey, treez := int(loc.X), int(loc.Y), int(loc.Z)
+	// Store the height of the top block of the tree
+	treey := height - 1
 	cradius := height / 2
 
 	// Start one block above the tree and move down
@@ -66,19 +57,10 @@ func (aspect *SaplingAspect) makeTree(instance *BlockInstance) bool {
 			radius = cradius - 1
 		}
 
-		for x := treex - radius; x <= treex+radius; x++ {
-			for z := treez - radius; z <= treez+radius; z++ {
-				if y > treey || x != treex || z != treez {
-					loc.X = SubChunkCoord(x)
-					loc.Y = SubChunkCoord(y)
-					loc.Z = SubChunkCoord(z)
-					index, ok := loc.BlockIndex()
-					if !ok {
-						// TODO: Can't place a block outside chunk boundaries
-						log.Printf("Couldn't place a leaf block (%v,%v,%v)", loc.X, loc.Y, loc.Z)
-					} else {
-						instance.Chunk.SetBlockByIndex(index, BlockId(18), byte(0))
-					}
+		for x := -radius; x <= radius; x++ {
+			for z := -radius; z <= radius; z++ {
+				if y > treey || x != 0 || z != 0 {
+					aspect.setTreeBlock(instance, x, y, z, BlockId(18))
 				}
 			}
 		}
@@ -86,3 +68,12 @@ func (aspect *SaplingAspect) makeTree(instance *BlockInstance) bool {
 
 	return true
 }
+
+// setTreeBlock sets a block of a tree growing from the sapling, at the given
+// offset from it. The block can be in a neighbouring chunk or shard. Blocks
+// above the top of the world are left out.
+func (aspect *SaplingAspect) setTreeBlock(instance *BlockInstance, dx, dy, dz int, blockId BlockId) {
+	if blockLoc := instance.BlockLoc.AddXyz(BlockCoord(dx), BlockYCoord(dy), BlockCoord(dz)); blockLoc != nil {
+		instance.Chunk.SetBlockAt(blockLoc, blockId, 0)
+	}
+}
@@ -0,0 +1,150 @@
+package gamerules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+// The maximum number of characters on each line of a sign.
+const signMaxLineLength = 15
+
+func makeSignAspect() (aspect IBlockAspect) {
+	return &SignAspect{}
+}
+
+// SignAspect is the behaviour of signs. A sign placed on top of a block is a
+// sign post, whose data holds which of 16 directions it faces. A sign placed
+// on the side of a block is a wall sign, whose data holds the face of the
+// block it is attached to.
+//
+// The text of the sign is set by the player who placed it, and is kept in a
+// signEntity in the block extra data.
+type SignAspect struct {
+	StandardAspect
+	// Post is the block ID of a sign placed on top of a block.
+	Post BlockId
+	// Wall is the block ID of a sign placed on the side of a block.
+	Wall BlockId
+}
+
+func (aspect *SignAspect) Name() string {
+	return "Sign"
+}
+
+func (aspect *SignAspect) Check() os.Error {
+	if aspect.Post == aspect.Wall {
+		return os.NewError("Sign must have different Post and Wall block IDs")
+	}
+	return aspect.StandardAspect.Check()
+}
+
+func (aspect *SignAspect) PlaceAs(againstFace Face, look *LookDegrees) (blockId BlockId, blockData byte, ok bool) {
+	switch againstFace {
+	case FaceTop:
+		// Face the player, in steps of 1/16th of a turn.
+		rotation := math.Floor(float64(look.Yaw+180)*16/360 + 0.5)
+		return aspect.Post, byte(int(rotation) & 0xf), true
+	case FaceEast, FaceWest, FaceNorth, FaceSouth:
+		return aspect.Wall, byte(againstFace), true
+	}
+	return
+}
+
+func (aspect *SignAspect) Placed(instance *BlockInstance, player IPlayerClient) {
+	instance.Chunk.SetBlockExtra(instance.Index, &signEntity{
+		instance: *instance,
+		placer:   player.GetEntityId(),
+		editable: true,
+	})
+}
+
+// SetText sets the text of the sign, if the player placed the sign and has not
+// already set its text. The text is sent to all players subscribed to the
+// chunk.
+func (aspect *SignAspect) SetText(instance *BlockInstance, player IPlayerClient, lines [4]string) {
+	sign, ok := instance.Chunk.BlockExtra(instance.Index).(*signEntity)
+	if !ok || !sign.editable || sign.placer != player.GetEntityId() {
+		return
+	}
+
+	for i := range lines {
+		sign.lines[i] = truncateSignLine(lines[i])
+	}
+	sign.editable = false
+	instance.Chunk.SetBlockExtra(instance.Index, sign)
+
+	buf := new(bytes.Buffer)
+	sign.SendUpdate(buf)
+	instance.Chunk.MulticastPlayers(buf.Bytes())
+}
+
+// truncateSignLine shortens a line of text to the number of characters that
+// fit on a sign.
+func truncateSignLine(line string) string {
+	numChars := 0
+	for i := range line {
+		if numChars == signMaxLineLength {
+			return line[:i]
+		}
+		numChars++
+	}
+	return line
+}
+
+// signEntity is the block extra data for signs. It implements ITileEntity to
+// store the text with the chunk.
+type signEntity struct {
+	instance BlockInstance
+	lines    [4]string
+	// placer is the player that placed the sign, who can set the text while
+	// editable is true. This is not stored with the chunk.
+	placer   EntityId
+	editable bool
+}
+
+func newBlankSign() ITileEntity {
+	return new(signEntity)
+}
+
+func (sign *signEntity) Block() BlockXyz {
+	return sign.instance.BlockLoc
+}
+
+func (sign *signEntity) SetChunk(chunk IChunkBlock) {
+	sign.instance.Chunk = chunk
+}
+
+func (sign *signEntity) ReadNbt(tag nbt.ITag) (err os.Error) {
+	if err = readTileEntityInstance(tag, &sign.instance); err != nil {
+		return
+	}
+
+	for i := range sign.lines {
+		line, ok := tag.Lookup(fmt.Sprintf("Text%d", i+1)).(*nbt.String)
+		if !ok {
+			return os.NewError("bad sign text")
+		}
+		sign.lines[i] = line.Value
+	}
+
+	return
+}
+
+func (sign *signEntity) WriteNbt() nbt.ITag {
+	tag := newTileEntityNbt("Sign", &sign.instance.BlockLoc)
+	for i, line := range sign.lines {
+		tag.Tags[fmt.Sprintf("Text%d", i+1)] = &nbt.String{line}
+	}
+	return tag
+}
+
+func (sign *signEntity) SendUpdate(writer io.Writer) os.Error {
+	return proto.WriteSignUpdate(writer, &sign.instance.BlockLoc, sign.lines)
+}
@@ -0,0 +1,147 @@
+package gamerules
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	testBlockIdSignPost = BlockId(63)
+	testBlockIdWallSign = BlockId(68)
+)
+
+// testSignChunk keeps the block extra data of a single sign. Its other
+// IChunkBlock methods are not implemented.
+type testSignChunk struct {
+	IChunkBlock
+	extra   interface{}
+	packets int
+}
+
+func (chunk *testSignChunk) BlockExtra(index BlockIndex) interface{} {
+	return chunk.extra
+}
+
+func (chunk *testSignChunk) SetBlockExtra(index BlockIndex, extra interface{}) {
+	chunk.extra = extra
+}
+
+func (chunk *testSignChunk) MulticastPlayers(packet []byte) {
+	chunk.packets++
+}
+
+// testSignPlayer only implements the IPlayerClient methods used by signs.
+type testSignPlayer struct {
+	IPlayerClient
+	entityId EntityId
+}
+
+func (player *testSignPlayer) GetEntityId() EntityId {
+	return player.entityId
+}
+
+func testSignAspect(t *testing.T) *SignAspect {
+	blockType, ok := Blocks.Get(testBlockIdSignPost)
+	if !ok {
+		t.Fatalf("no block type %d", testBlockIdSignPost)
+	}
+	aspect, ok := blockType.Aspect.(*SignAspect)
+	if !ok {
+		t.Fatalf("expected block type %d to be a sign, got %T", testBlockIdSignPost, blockType.Aspect)
+	}
+	return aspect
+}
+
+func expectSignLines(t *testing.T, sign *signEntity, expected [4]string) {
+	for i := range expected {
+		if sign.lines[i] != expected[i] {
+			t.Errorf("expected sign lines %q, got %q", expected, sign.lines)
+			return
+		}
+	}
+}
+
+func TestSignAspect_PlaceAs(t *testing.T) {
+	aspect := testSignAspect(t)
+
+	type Test struct {
+		againstFace Face
+		yaw         AngleDegrees
+		blockId     BlockId
+		blockData   byte
+		ok          bool
+	}
+
+	tests := []Test{
+		// Sign posts face the player, in 16 steps.
+		{FaceTop, 0, testBlockIdSignPost, 8, true},
+		{FaceTop, 90, testBlockIdSignPost, 12, true},
+		{FaceTop, -90, testBlockIdSignPost, 4, true},
+		{FaceTop, 180, testBlockIdSignPost, 0, true},
+		// Wall signs take the face that they were placed against.
+		{FaceEast, 0, testBlockIdWallSign, byte(FaceEast), true},
+		{FaceNorth, 90, testBlockIdWallSign, byte(FaceNorth), true},
+		// Signs cannot hang from the bottom of a block.
+		{FaceBottom, 0, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		look := LookDegrees{test.yaw, 0}
+		blockId, blockData, ok := aspect.PlaceAs(test.againstFace, &look)
+		if blockId != test.blockId || blockData != test.blockData || ok != test.ok {
+			t.Errorf("PlaceAs(%d, yaw %v): expected %d/%d %t, got %d/%d %t",
+				test.againstFace, test.yaw, test.blockId, test.blockData, test.ok, blockId, blockData, ok)
+		}
+	}
+}
+
+func TestSignAspect_SetText(t *testing.T) {
+	aspect := testSignAspect(t)
+	chunk := &testSignChunk{}
+	instance := &BlockInstance{Chunk: chunk, BlockLoc: BlockXyz{1, 64, 2}}
+
+	placer := &testSignPlayer{entityId: 1}
+	other := &testSignPlayer{entityId: 2}
+	aspect.Placed(instance, placer)
+
+	// Only the player that placed the sign may write on it.
+	aspect.SetText(instance, other, [4]string{"graffiti", "", "", ""})
+	sign := chunk.extra.(*signEntity)
+	if sign.lines[0] != "" || !sign.editable || chunk.packets != 0 {
+		t.Errorf("expected the sign to be left blank, got %q", sign.lines)
+	}
+
+	// Long lines are cut short.
+	aspect.SetText(instance, placer, [4]string{"0123456789abcdefghij", "hello", "", ""})
+	expected := [4]string{"0123456789abcde", "hello", "", ""}
+	expectSignLines(t, sign, expected)
+	if chunk.packets != 1 {
+		t.Errorf("expected the text to be sent to players, got %d packets", chunk.packets)
+	}
+
+	// The text can only be set once.
+	aspect.SetText(instance, placer, [4]string{"changed", "", "", ""})
+	expectSignLines(t, sign, expected)
+	if chunk.packets != 1 {
+		t.Errorf("expected the unchanged text not to be sent, got %d packets", chunk.packets)
+	}
+}
+
+func TestSignEntityNbt(t *testing.T) {
+	sign := &signEntity{
+		instance: BlockInstance{BlockLoc: BlockXyz{-20, 70, 35}},
+		lines:    [4]string{"one", "two", "", "four"},
+	}
+
+	read := newBlankSign()
+	if err := read.ReadNbt(sign.WriteNbt()); err != nil {
+		t.Fatal(err)
+	}
+
+	result := read.(*signEntity)
+	if loc := result.Block(); !loc.Equals(sign.Block()) {
+		t.Errorf("expected sign at %v, got %v", sign.Block(), loc)
+	}
+	expectSignLines(t, result, sign.lines)
+}
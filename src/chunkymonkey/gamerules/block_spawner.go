@@ -0,0 +1,167 @@
+package gamerules
+
+import (
+	"os"
+
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+const (
+	// Mobs are spawned while a player is within spawnerPlayerRange of the
+	// spawner, in groups of up to spawnerMaxGroup, within spawnerSpawnRange
+	// blocks of it horizontally.
+	spawnerPlayerRange = 16
+	spawnerMaxGroup    = 4
+	spawnerSpawnRange  = 4
+
+	// The number of ticks between spawns is picked from spawnerMinDelay up to
+	// spawnerMaxDelay.
+	spawnerMinDelay = 200
+	spawnerMaxDelay = 800
+
+	// A spawner stops spawning while there are spawnerMaxNearby mobs within
+	// spawnerCountRange blocks of it.
+	spawnerMaxNearby  = 6
+	spawnerCountRange = 8
+
+	// A spawner checks for players every spawnerCheckTicks while any are
+	// within spawnerWatchRange, and otherwise sleeps.
+	spawnerCheckTicks = TicksPerSecond
+	spawnerWatchRange = 128
+)
+
+func makeMobSpawnerAspect() (aspect IBlockAspect) {
+	return &MobSpawnerAspect{}
+}
+
+// MobSpawnerAspect is the behaviour of mob spawners, which spawn mobs of one
+// type around themselves while players are near. The type of mob and the time
+// until the next spawn are kept in a mobSpawnerEntity in the block extra data.
+//
+// A spawner sleeps once no players are within spawnerWatchRange, so that its
+// chunk can be unloaded. It wakes when the chunk is next loaded, or when a
+// block next to it changes.
+type MobSpawnerAspect struct {
+	StandardAspect
+}
+
+func (aspect *MobSpawnerAspect) Name() string {
+	return "MobSpawner"
+}
+
+func (aspect *MobSpawnerAspect) Tick(instance *BlockInstance) bool {
+	spawner, ok := instance.Chunk.BlockExtra(instance.Index).(*mobSpawnerEntity)
+	if !ok {
+		return false
+	}
+
+	centre := instance.BlockLoc.MidPointToAbsXyz()
+	watchers := instance.Chunk.PlayersNear(&centre, spawnerWatchRange)
+	if len(watchers) == 0 {
+		return false
+	}
+
+	for i := range watchers {
+		if watchers[i].Position.IsWithinDistanceOf(&centre, spawnerPlayerRange) {
+			spawner.delay -= spawnerCheckTicks
+			if spawner.delay <= 0 {
+				spawner.spawn(instance)
+			}
+			instance.Chunk.SetBlockExtra(instance.Index, spawner)
+			break
+		}
+	}
+
+	instance.Chunk.ScheduleBlockTick(instance.Index, spawnerCheckTicks)
+	return false
+}
+
+// mobSpawnerEntity is the block extra data for mob spawners. It implements
+// ITileEntity to store the spawner's state with the chunk.
+type mobSpawnerEntity struct {
+	instance BlockInstance
+	// mobType is the name of the type of mob spawned, e.g "Zombie".
+	mobType string
+	// delay is the number of ticks until the next spawn.
+	delay Ticks
+}
+
+func newBlankMobSpawner() ITileEntity {
+	return new(mobSpawnerEntity)
+}
+
+// spawn creates a group of mobs around the spawner, where there is room for
+// them, and picks the delay until the next group. No more mobs are spawned
+// once there are spawnerMaxNearby around it.
+func (spawner *mobSpawnerEntity) spawn(instance *BlockInstance) {
+	rand := instance.Chunk.Rand()
+	spawner.delay = Ticks(spawnerMinDelay + rand.Intn(spawnerMaxDelay-spawnerMinDelay))
+
+	centre := instance.BlockLoc.MidPointToAbsXyz()
+	nearby := len(instance.Chunk.MobsNear(&centre, spawnerCountRange))
+
+	count := 1 + rand.Intn(spawnerMaxGroup)
+	for i := 0; i < count && nearby < spawnerMaxNearby; i++ {
+		dx := BlockCoord(rand.Intn(2*spawnerSpawnRange+1) - spawnerSpawnRange)
+		dy := BlockYCoord(rand.Intn(3) - 1)
+		dz := BlockCoord(rand.Intn(2*spawnerSpawnRange+1) - spawnerSpawnRange)
+
+		blockLoc := instance.BlockLoc.AddXyz(dx, dy, dz)
+		if blockLoc == nil || !isOpenBlock(instance.Chunk, blockLoc) {
+			continue
+		}
+		if aboveLoc := blockLoc.AddXyz(0, 1, 0); aboveLoc == nil || !isOpenBlock(instance.Chunk, aboveLoc) {
+			continue
+		}
+
+		position := blockLoc.MidPointToAbsXyz()
+		position.Y = AbsCoord(blockLoc.Y)
+		look := LookDegrees{AngleDegrees(rand.Intn(360)), 0}
+		if mob := NewMobByTypeName(spawner.mobType, &position, &look); mob != nil {
+			instance.Chunk.AddEntity(mob)
+			nearby++
+		}
+	}
+}
+
+// isOpenBlock returns true if a mob could stand in the block.
+func isOpenBlock(chunk IChunkBlock, blockLoc *BlockXyz) bool {
+	blockType, _, ok := chunk.BlockAt(blockLoc)
+	return ok && !blockType.Solid
+}
+
+func (spawner *mobSpawnerEntity) Block() BlockXyz {
+	return spawner.instance.BlockLoc
+}
+
+func (spawner *mobSpawnerEntity) SetChunk(chunk IChunkBlock) {
+	spawner.instance.Chunk = chunk
+}
+
+func (spawner *mobSpawnerEntity) ReadNbt(tag nbt.ITag) (err os.Error) {
+	if err = readTileEntityInstance(tag, &spawner.instance); err != nil {
+		return
+	}
+
+	mobType, ok := tag.Lookup("EntityId").(*nbt.String)
+	if !ok {
+		return os.NewError("bad mob spawner EntityId")
+	}
+	delay, ok := tag.Lookup("Delay").(*nbt.Short)
+	if !ok {
+		return os.NewError("bad mob spawner Delay")
+	}
+
+	spawner.mobType = mobType.Value
+	spawner.delay = Ticks(delay.Value)
+
+	return
+}
+
+func (spawner *mobSpawnerEntity) WriteNbt() nbt.ITag {
+	tag := newTileEntityNbt("MobSpawner", &spawner.instance.BlockLoc)
+	tag.Tags["EntityId"] = &nbt.String{spawner.mobType}
+	tag.Tags["Delay"] = &nbt.Short{int16(spawner.delay)}
+	return tag
+}
@@ -0,0 +1,21 @@
+package gamerules
+
+func makeTntAspect() (aspect IBlockAspect) {
+	return &TntAspect{}
+}
+
+// TntAspect is the behaviour of TNT, which is primed when it is powered.
+type TntAspect struct {
+	StandardAspect
+}
+
+func (aspect *TntAspect) Name() string {
+	return "Tnt"
+}
+
+func (aspect *TntAspect) Tick(instance *BlockInstance) bool {
+	if isBlockPowered(instance.Chunk, &instance.BlockLoc) {
+		primeTnt(instance.Chunk, &instance.BlockLoc)
+	}
+	return false
+}
@@ -8,11 +8,13 @@ type BlockAttrs struct {
 	id           BlockId
 	Name         string
 	Opacity      int8
+	Luminance    int8
 	defined      bool
 	Destructable bool
 	Solid        bool
 	Replaceable  bool
 	Attachable   bool
+	Burns        bool
 }
 
 // The core information about any block type.
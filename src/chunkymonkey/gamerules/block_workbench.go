@@ -18,5 +18,8 @@ func createWorkbenchInventory(instance *BlockInstance) *blockInventory {
 		inv,
 		true,
 		InvTypeIdWorkbench,
+		// The crafting grid is emptied when it is closed, so there is
+		// nothing to store.
+		"",
 	)
 }
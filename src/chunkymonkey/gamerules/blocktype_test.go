@@ -2,6 +2,8 @@ package gamerules
 
 import (
 	"testing"
+
+	. "chunkymonkey/types"
 )
 
 func TestMergeBlockItems(t *testing.T) {
@@ -102,3 +104,35 @@ func TestMergeBlockItems(t *testing.T) {
 		itemTypes[5],
 	)
 }
+
+func TestHasTileEntity(t *testing.T) {
+	type Test struct {
+		blockId  BlockId
+		typeName string
+		expected bool
+	}
+
+	tests := []Test{
+		{54, "Chest", true},
+		{61, "Furnace", true},
+		{62, "Furnace", true},
+		{63, "Sign", true},
+		{68, "Sign", true},
+		{52, "MobSpawner", true},
+		{54, "Furnace", false},
+		{1, "Chest", false},
+		// The crafting grid of workbenches is not stored.
+		{58, "Workbench", false},
+	}
+
+	for _, test := range tests {
+		blockType, ok := Blocks.Get(test.blockId)
+		if !ok {
+			t.Errorf("Block type %d not defined", test.blockId)
+			continue
+		}
+		if result := blockType.HasTileEntity(test.typeName); result != test.expected {
+			t.Errorf("Block type %d HasTileEntity(%q) expected %t, got %t", test.blockId, test.typeName, test.expected, result)
+		}
+	}
+}
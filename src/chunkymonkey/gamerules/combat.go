@@ -0,0 +1,56 @@
+package gamerules
+
+import (
+	"math"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	// The damage done by attacking with an empty hand, or an item that is not
+	// a weapon or tool.
+	handDamage = Health(1)
+
+	// The horizontal and upwards speed at which an attacked entity is knocked
+	// away from its attacker.
+	knockbackSpeed = 0.4
+	knockbackLift  = 0.4
+)
+
+// IAttackable is implemented by non-player entities that can be attacked,
+// such as mobs.
+type IAttackable interface {
+	// Attacked applies the damage from an attack, and knocks the entity back.
+	// hurt is false if the entity ignored the attack, for example because it
+	// was hurt very recently. dead is true if the entity has been killed.
+	Attacked(damage Health, knockback *AbsVelocity) (hurt, dead bool)
+
+	// Expired returns true once the entity has been dead for long enough that
+	// it should be removed.
+	Expired() bool
+}
+
+// AttackDamage returns the damage done by attacking with the held item.
+func AttackDamage(held *Slot) Health {
+	if itemType := held.ItemType(); itemType != nil && itemType.Damage > handDamage {
+		return itemType.Damage
+	}
+	return handDamage
+}
+
+// Knockback returns the velocity with which an entity at target is knocked
+// away from an attacker at attacker.
+func Knockback(attacker, target *AbsXyz) (knockback AbsVelocity) {
+	dx := float64(target.X - attacker.X)
+	dz := float64(target.Z - attacker.Z)
+	knockback.Y = knockbackLift
+
+	distance := math.Sqrt(dx*dx + dz*dz)
+	if distance < 1e-4 {
+		return
+	}
+
+	knockback.X = AbsVelocityCoord(knockbackSpeed * dx / distance)
+	knockback.Z = AbsVelocityCoord(knockbackSpeed * dz / distance)
+	return
+}
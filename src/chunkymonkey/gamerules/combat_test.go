@@ -0,0 +1,58 @@
+package gamerules
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestKnockback(t *testing.T) {
+	type Test struct {
+		attacker, target AbsXyz
+		expected         AbsVelocity
+	}
+
+	tests := []Test{
+		{AbsXyz{0, 64, 0}, AbsXyz{2, 64, 0}, AbsVelocity{knockbackSpeed, knockbackLift, 0}},
+		{AbsXyz{0, 64, 0}, AbsXyz{0, 70, -3}, AbsVelocity{0, knockbackLift, -knockbackSpeed}},
+		// Directly above or below, so only knocked upwards.
+		{AbsXyz{5, 64, 5}, AbsXyz{5, 66, 5}, AbsVelocity{0, knockbackLift, 0}},
+	}
+
+	for _, test := range tests {
+		result := Knockback(&test.attacker, &test.target)
+		if result.X != test.expected.X || result.Y != test.expected.Y || result.Z != test.expected.Z {
+			t.Errorf("Knockback(%v, %v) = %v, expected %v", test.attacker, test.target, result, test.expected)
+		}
+	}
+}
+
+func TestMob_Attacked(t *testing.T) {
+	pig := NewPig().(*Pig)
+	knockback := AbsVelocity{}
+
+	if hurt, dead := pig.Attacked(4, &knockback); !hurt || dead {
+		t.Errorf("first attack: expected hurt=true dead=false, got hurt=%t dead=%t", hurt, dead)
+	}
+
+	// Recently hurt, so the next attack is ignored.
+	if hurt, _ := pig.Attacked(4, &knockback); hurt {
+		t.Errorf("attack while recently hurt: expected hurt=false")
+	}
+
+	for i := 0; i < mobHurtTicks; i++ {
+		pig.hurtTime--
+	}
+
+	if hurt, dead := pig.Attacked(PigType.MaxHealth, &knockback); !hurt || !dead {
+		t.Errorf("lethal attack: expected hurt=true dead=true, got hurt=%t dead=%t", hurt, dead)
+	}
+
+	if pig.Expired() {
+		t.Errorf("expected mob not to expire immediately after dying")
+	}
+	pig.deathTime = mobDeathTicks
+	if !pig.Expired() {
+		t.Errorf("expected mob to expire after dying for %d ticks", mobDeathTicks)
+	}
+}
@@ -5,6 +5,7 @@ package gamerules
 
 import (
 	"io"
+	"math"
 	"os"
 
 	"chunkymonkey/physics"
@@ -12,6 +13,12 @@ import (
 	"nbt"
 )
 
+// The size of the space taken up by a player.
+const (
+	playerHalfWidth = 0.3
+	playerHeight    = 1.8
+)
+
 // ISpawn represents common elements to all types of entities that can be
 // present in a chunk.
 type IEntity interface {
@@ -30,3 +37,56 @@ type INonPlayerEntity interface {
 	SetEntityId(EntityId)
 	Tick(physics.IBlockQuerier) (leftBlock bool)
 }
+
+// IBlockEntity is implemented by entities that act upon the blocks around
+// them, such as falling sand landing or primed TNT exploding. After each tick
+// in which such an entity stays within its chunk, the chunk calls BlockTick,
+// and removes the entity if it returns true.
+type IBlockEntity interface {
+	BlockTick(chunk IChunkBlock) (remove bool)
+}
+
+// IThinkingEntity is implemented by entities that decide for themselves what
+// to do, such as mobs. The chunk calls Think once per tick before the entity
+// moves, and removes the entity if it returns true.
+type IThinkingEntity interface {
+	Think(chunk IChunkBlock) (remove bool)
+}
+
+// NearbyPlayer is a player found by IChunkBlock.PlayersNear.
+type NearbyPlayer struct {
+	EntityId EntityId
+	Position AbsXyz
+	Client   IPlayerClient
+}
+
+// Contains returns true if position is within the space taken up by the
+// player.
+func (player *NearbyPlayer) Contains(position *AbsXyz) bool {
+	return position.X >= player.Position.X-playerHalfWidth &&
+		position.X <= player.Position.X+playerHalfWidth &&
+		position.Z >= player.Position.Z-playerHalfWidth &&
+		position.Z <= player.Position.Z+playerHalfWidth &&
+		position.Y >= player.Position.Y &&
+		position.Y <= player.Position.Y+playerHeight
+}
+
+// nearestPlayer returns the player closest to position within maxDistance.
+func nearestPlayer(chunk IChunkBlock, position *AbsXyz, maxDistance AbsCoord) (nearest NearbyPlayer, ok bool) {
+	bestDistance := math.MaxFloat64
+	for _, player := range chunk.PlayersNear(position, maxDistance) {
+		if distance := distanceSquared(position, &player.Position); distance < bestDistance {
+			nearest = player
+			bestDistance = distance
+			ok = true
+		}
+	}
+	return
+}
+
+func distanceSquared(a, b *AbsXyz) float64 {
+	dx := float64(a.X - b.X)
+	dy := float64(a.Y - b.Y)
+	dz := float64(a.Z - b.Z)
+	return dx*dx + dy*dy + dz*dz
+}
@@ -0,0 +1,92 @@
+package gamerules
+
+import (
+	"bytes"
+	"math"
+
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+// Number of ticks between TNT being primed and it exploding.
+const tntFuseTicks = 4 * TicksPerSecond
+
+// Power of a TNT explosion, which is also the radius in blocks that it
+// destroys.
+const tntExplosionPower = 3
+
+// primeTnt replaces the TNT block at blockLoc with a primed TNT object, which
+// explodes after a short time.
+func primeTnt(chunk IChunkBlock, blockLoc *BlockXyz) {
+	if chunk.SetBlockAt(blockLoc, BlockIdAir, 0) {
+		chunk.AddEntity(NewPrimedTnt(blockLoc))
+	}
+}
+
+// explode destroys the blocks around position, hurts players nearby and shows
+// the explosion to players. Any TNT caught in the explosion is primed. Blocks
+// are destroyed in neighbouring shards too, but only players within the
+// chunk's shard are hurt.
+func explode(chunk IChunkBlock, position *AbsXyz, power float32) {
+	center := position.ToBlockXyz()
+	radius := int(power)
+	offsets := make([]proto.ExplosionOffsetXyz, 0, 32)
+
+	for dx := -radius; dx <= radius; dx++ {
+		for dy := -radius; dy <= radius; dy++ {
+			for dz := -radius; dz <= radius; dz++ {
+				if dx*dx+dy*dy+dz*dz > radius*radius {
+					continue
+				}
+
+				loc := center.AddXyz(BlockCoord(dx), BlockYCoord(dy), BlockCoord(dz))
+				if loc == nil {
+					continue
+				}
+
+				blockType, _, ok := chunk.BlockAt(loc)
+				if !ok || blockType.id == BlockIdAir || !blockType.Destructable {
+					continue
+				}
+				if _, isFluid := blockType.Aspect.(*FluidAspect); isFluid {
+					continue
+				}
+
+				if _, isTnt := blockType.Aspect.(*TntAspect); isTnt {
+					primeTnt(chunk, loc)
+				} else if !chunk.SetBlockAt(loc, BlockIdAir, 0) {
+					continue
+				}
+
+				offsets = append(offsets, proto.ExplosionOffsetXyz{int8(dx), int8(dy), int8(dz)})
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	proto.WriteExplosion(buf, position, power, offsets)
+	chunk.MulticastPlayers(buf.Bytes())
+
+	hurtPlayersInExplosion(chunk, position, power)
+}
+
+// hurtPlayersInExplosion hurts and knocks back the players within twice the
+// power of an explosion, by more the closer they are to it.
+func hurtPlayersInExplosion(chunk IChunkBlock, position *AbsXyz, power float32) {
+	reach := 2 * float64(power)
+
+	for _, player := range chunk.PlayersNear(position, AbsCoord(reach)) {
+		distance := math.Sqrt(distanceSquared(position, &player.Position))
+		impact := 1 - distance/reach
+		if impact <= 0 {
+			continue
+		}
+
+		damage := Health((impact*impact+impact)/2*8*float64(power) + 1)
+		knockback := Knockback(position, &player.Position)
+		knockback.X *= AbsVelocityCoord(impact)
+		knockback.Y *= AbsVelocityCoord(impact)
+		knockback.Z *= AbsVelocityCoord(impact)
+		player.Client.Attacked(damage, knockback)
+	}
+}
@@ -2,22 +2,76 @@ package gamerules
 
 import (
 	"os"
+	"sync"
 
 	"chunkymonkey/permission"
+	"chunkymonkey/region"
 )
 
-// GameRules is a container type for block, item and recipe definitions.
+// GameRules is a container type for block, item, recipe and mob spawning
+// definitions.
 var (
-	Blocks           BlockTypeList
-	Items            ItemTypeMap
-	Recipes          *RecipeSet
-	FurnaceReactions FurnaceData
-	// TODO: Commands should maybe be accessible via IGame.
-	CommandFramework ICommandFramework
-	Permissions      permission.IPermissions
+	Blocks  BlockTypeList
+	Items   ItemTypeMap
+	Regions *region.Regions
 )
 
-func LoadGameRules(blocksDefFile, itemsDefFile, recipesDefFile, furnaceDefFile, userDefFile, groupDefFile string) (err os.Error) {
+// reloadable holds the rules that ReloadRules replaces while the server is
+// running. Shards and players use them from their own goroutines, so they are
+// only accessed through the functions below.
+var reloadable struct {
+	sync.RWMutex
+	recipes          *RecipeSet
+	furnaceReactions FurnaceData
+	spawning         *SpawnRules
+	commandFramework ICommandFramework
+	permissions      permission.IPermissions
+}
+
+func Recipes() *RecipeSet {
+	reloadable.RLock()
+	defer reloadable.RUnlock()
+	return reloadable.recipes
+}
+
+func FurnaceReactions() FurnaceData {
+	reloadable.RLock()
+	defer reloadable.RUnlock()
+	return reloadable.furnaceReactions
+}
+
+func Spawning() *SpawnRules {
+	reloadable.RLock()
+	defer reloadable.RUnlock()
+	return reloadable.spawning
+}
+
+// TODO: Commands should maybe be accessible via IGame.
+func CommandFramework() ICommandFramework {
+	reloadable.RLock()
+	defer reloadable.RUnlock()
+	return reloadable.commandFramework
+}
+
+func SetCommandFramework(commandFramework ICommandFramework) {
+	reloadable.Lock()
+	defer reloadable.Unlock()
+	reloadable.commandFramework = commandFramework
+}
+
+func Permissions() permission.IPermissions {
+	reloadable.RLock()
+	defer reloadable.RUnlock()
+	return reloadable.permissions
+}
+
+func SetPermissions(permissions permission.IPermissions) {
+	reloadable.Lock()
+	defer reloadable.Unlock()
+	reloadable.permissions = permissions
+}
+
+func LoadGameRules(blocksDefFile, itemsDefFile, recipesDefFile, furnaceDefFile, spawnDefFile, userDefFile, groupDefFile, regionDefFile string) (err os.Error) {
 	Blocks, err = LoadBlocksFromFile(blocksDefFile)
 	if err != nil {
 		return
@@ -30,21 +84,33 @@ func LoadGameRules(blocksDefFile, itemsDefFile, recipesDefFile, furnaceDefFile,
 
 	Blocks.CreateBlockItemTypes(Items)
 
-	Recipes, err = LoadRecipesFromFile(recipesDefFile, Items)
+	recipes, err := LoadRecipesFromFile(recipesDefFile, Items)
+	if err != nil {
+		return
+	}
+
+	furnaceReactions, err := LoadFurnaceDataFromFile(furnaceDefFile)
+	if err != nil {
+		return
+	}
+
+	spawning, err := LoadSpawnRulesFromFile(spawnDefFile)
 	if err != nil {
 		return
 	}
 
-	FurnaceReactions, err = LoadFurnaceDataFromFile(furnaceDefFile)
+	permissions, err := permission.LoadJsonPermissionFromFiles(userDefFile, groupDefFile)
 	if err != nil {
 		return
 	}
 
-	Permissions, err = permission.LoadJsonPermissionFromFiles(userDefFile, groupDefFile)
+	Regions, err = region.LoadRegionsFromFile(regionDefFile)
 	if err != nil {
 		return
 	}
 
+	setRules(recipes, furnaceReactions, spawning, permissions)
+
 	// Ensure that the block aspects are configured correctly, now that
 	// everything is loaded.
 	for i := range Blocks {
@@ -55,3 +121,46 @@ func LoadGameRules(blocksDefFile, itemsDefFile, recipesDefFile, furnaceDefFile,
 
 	return
 }
+
+// ReloadRules loads again the definitions that can change while the server is
+// running: recipes, furnace reactions, mob spawning rules, permissions and
+// regions. Block and item types are not reloaded, as the world depends on
+// them. Nothing is changed if any of the files fail to load.
+func ReloadRules(recipesDefFile, furnaceDefFile, spawnDefFile, userDefFile, groupDefFile, regionDefFile string) (err os.Error) {
+	recipes, err := LoadRecipesFromFile(recipesDefFile, Items)
+	if err != nil {
+		return
+	}
+
+	furnaceReactions, err := LoadFurnaceDataFromFile(furnaceDefFile)
+	if err != nil {
+		return
+	}
+
+	spawning, err := LoadSpawnRulesFromFile(spawnDefFile)
+	if err != nil {
+		return
+	}
+
+	permissions, err := permission.LoadJsonPermissionFromFiles(userDefFile, groupDefFile)
+	if err != nil {
+		return
+	}
+
+	if err = Regions.Reload(regionDefFile); err != nil {
+		return
+	}
+
+	setRules(recipes, furnaceReactions, spawning, permissions)
+
+	return
+}
+
+func setRules(recipes *RecipeSet, furnaceReactions FurnaceData, spawning *SpawnRules, permissions permission.IPermissions) {
+	reloadable.Lock()
+	defer reloadable.Unlock()
+	reloadable.recipes = recipes
+	reloadable.furnaceReactions = furnaceReactions
+	reloadable.spawning = spawning
+	reloadable.permissions = permissions
+}
@@ -23,7 +23,7 @@ func (inv *CraftingInventory) init(width, height int) {
 	inv.Inventory.Init(1 + width*height)
 	inv.width = width
 	inv.height = height
-	inv.recipes.Init(Recipes)
+	inv.recipes.Init(Recipes())
 }
 
 // InitWorkbenchInventory initializes inv as a 2x2 player crafting inventory.
@@ -1,7 +1,10 @@
 package gamerules
 
 import (
+	"os"
+
 	. "chunkymonkey/types"
+	"nbt"
 )
 
 const (
@@ -52,7 +55,7 @@ func (inv *FurnaceInventory) Click(click *Click) (txState TxState) {
 		}
 	case furnaceSlotFuel:
 		cursorItemId := click.Cursor.ItemTypeId
-		_, cursorIsFuel := FurnaceReactions.Fuels[cursorItemId]
+		_, cursorIsFuel := FurnaceReactions().Fuels[cursorItemId]
 		if cursorIsFuel || click.Cursor.IsEmpty() {
 			txState = inv.Inventory.Click(click)
 		}
@@ -73,8 +76,8 @@ func (inv *FurnaceInventory) stateCheck() {
 	fuelSlot := &inv.slots[furnaceSlotFuel]
 	outputSlot := &inv.slots[furnaceSlotOutput]
 
-	reaction, haveReagent := FurnaceReactions.Reactions[reagentSlot.ItemTypeId]
-	fuelTicks, haveFuel := FurnaceReactions.Fuels[fuelSlot.ItemTypeId]
+	reaction, haveReagent := FurnaceReactions().Reactions[reagentSlot.ItemTypeId]
+	fuelTicks, haveFuel := FurnaceReactions().Fuels[fuelSlot.ItemTypeId]
 
 	// Work out if the output slot is ready for items to be produced from the
 	// reaction.
@@ -185,3 +188,35 @@ func (inv *FurnaceInventory) Tick() {
 		inv.sendProgressUpdates()
 	}
 }
+
+// ReadNbt reads the furnace contents, along with how long the current fuel
+// will burn for and how far through cooking the current item is.
+func (inv *FurnaceInventory) ReadNbt(tag nbt.ITag) (err os.Error) {
+	if err = inv.Inventory.ReadNbt(tag); err != nil {
+		return
+	}
+
+	burnTime, burnOk := tag.Lookup("BurnTime").(*nbt.Short)
+	cookTime, cookOk := tag.Lookup("CookTime").(*nbt.Short)
+	if !burnOk || !cookOk {
+		return os.NewError("bad furnace data")
+	}
+
+	// The total burn time of the fuel is not stored, so the fire progress bar
+	// starts from full.
+	inv.curFuel = Ticks(burnTime.Value)
+	inv.maxFuel = inv.curFuel
+
+	inv.reactionRemaining = reactionDuration - Ticks(cookTime.Value)
+	if inv.reactionRemaining < 0 {
+		inv.reactionRemaining = 0
+	}
+
+	return
+}
+
+func (inv *FurnaceInventory) WriteNbt(tag *nbt.Compound) {
+	inv.Inventory.WriteNbt(tag)
+	tag.Tags["BurnTime"] = &nbt.Short{int16(inv.curFuel)}
+	tag.Tags["CookTime"] = &nbt.Short{int16(reactionDuration - inv.reactionRemaining)}
+}
@@ -26,6 +26,14 @@ type IInventory interface {
 	WriteProtoSlots(slots []proto.WindowSlot)
 	TakeAllItems() (items []Slot)
 	ReadNbtSlot(tag nbt.ITag, slotId SlotId) (err os.Error)
+
+	// ReadNbt reads the contents of the inventory from the NBT of a tile
+	// entity.
+	ReadNbt(tag nbt.ITag) (err os.Error)
+
+	// WriteNbt writes the contents of the inventory into the NBT of a tile
+	// entity.
+	WriteNbt(tag *nbt.Compound)
 }
 
 type Click struct {
@@ -222,3 +230,37 @@ func (inv *Inventory) ReadNbtSlot(tag nbt.ITag, slotId SlotId) (err os.Error) {
 	}
 	return inv.slots[slotId].ReadNbt(tag)
 }
+
+func (inv *Inventory) ReadNbt(tag nbt.ITag) (err os.Error) {
+	itemList, ok := tag.Lookup("Items").(*nbt.List)
+	if !ok {
+		return os.NewError("bad inventory - not a list")
+	}
+
+	for _, slotTag := range itemList.Value {
+		var slotIdTag *nbt.Byte
+		if slotIdTag, ok = slotTag.Lookup("Slot").(*nbt.Byte); !ok {
+			return os.NewError("Slot ID not a byte")
+		}
+		if err = inv.ReadNbtSlot(slotTag, SlotId(slotIdTag.Value)); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (inv *Inventory) WriteNbt(tag *nbt.Compound) {
+	items := make([]nbt.ITag, 0, len(inv.slots))
+
+	for i := range inv.slots {
+		slot := &inv.slots[i]
+		if !slot.IsEmpty() {
+			slotTag := slot.WriteNbt()
+			slotTag.Tags["Slot"] = &nbt.Byte{int8(i)}
+			items = append(items, slotTag)
+		}
+	}
+
+	tag.Tags["Items"] = &nbt.List{nbt.TagCompound, items}
+}
@@ -2,6 +2,8 @@ package gamerules
 
 import (
 	"testing"
+
+	"nbt"
 )
 
 func TestInventory_Init(t *testing.T) {
@@ -14,3 +16,57 @@ func TestInventory_Init(t *testing.T) {
 		}
 	}
 }
+
+func TestInventory_NbtRoundTrip(t *testing.T) {
+	var inv Inventory
+	inv.Init(5)
+	inv.slots[1] = Slot{ItemTypeId: 4, Count: 10, Data: 0}
+	inv.slots[4] = Slot{ItemTypeId: 35, Count: 1, Data: 14}
+
+	tag := &nbt.Compound{make(map[string]nbt.ITag)}
+	inv.WriteNbt(tag)
+
+	if items, ok := tag.Lookup("Items").(*nbt.List); !ok || len(items.Value) != 2 {
+		t.Fatalf("Expected 2 items written, got %#v", tag.Lookup("Items"))
+	}
+
+	var readInv Inventory
+	readInv.Init(5)
+	if err := readInv.ReadNbt(tag); err != nil {
+		t.Fatalf("ReadNbt returned error: %v", err)
+	}
+
+	for i := range inv.slots {
+		if !inv.slots[i].Equals(&readInv.slots[i]) {
+			t.Errorf("Slot %d: expected %+v, got %+v", i, inv.slots[i], readInv.slots[i])
+		}
+	}
+}
+
+func TestInventory_ReadNbt_BadSlot(t *testing.T) {
+	var inv Inventory
+	inv.Init(5)
+	tag := &nbt.Compound{make(map[string]nbt.ITag)}
+	inv.slots[0] = Slot{ItemTypeId: 4, Count: 1}
+	inv.WriteNbt(tag)
+
+	var smallInv Inventory
+	smallInv.Init(0)
+	if err := smallInv.ReadNbt(tag); err == nil {
+		t.Errorf("Expected error reading slot beyond end of inventory")
+	}
+}
+
+func TestWorkbench_NotStored(t *testing.T) {
+	workbench := createWorkbenchInventory(new(BlockInstance))
+
+	// Even while a player has it open, the crafting grid is not stored.
+	workbench.subscribers[1] = nil
+	if tag := workbench.WriteNbt(); tag != nil {
+		t.Errorf("Expected no tile entity for the workbench, got %#v", tag)
+	}
+
+	if tileEntity := NewTileEntityByTypeName("Workbench"); tileEntity != nil {
+		t.Errorf("Expected no Workbench tile entity type, got %#v", tileEntity)
+	}
+}
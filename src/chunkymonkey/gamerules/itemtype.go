@@ -16,6 +16,12 @@ type ItemType struct {
 	MaxStack ItemCount
 	ToolType ToolTypeId
 	ToolUses ItemData
+	// PlacesBlock is the block that the item creates when placed, for items
+	// that are not blocks themselves. Zero if the item cannot be placed.
+	PlacesBlock BlockId
+	// Damage is the damage done by attacking with the item. Zero means that
+	// the item does no more damage than attacking with an empty hand.
+	Damage Health
 }
 
 type ItemTypeMap map[ItemTypeId]*ItemType
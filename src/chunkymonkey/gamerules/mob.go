@@ -20,13 +20,49 @@ func init() {
 	expVarMobSpawnCount = expvar.NewInt("mob-spawn-count")
 }
 
+const (
+	// The number of ticks after taking damage during which a mob ignores
+	// further attacks.
+	mobHurtTicks = 10
+	// The number of ticks that a mob takes to die before it is removed.
+	mobDeathTicks = 20
+)
+
+// IMob is implemented by all types of mob.
+type IMob interface {
+	INonPlayerEntity
+	GetMobType() EntityMobType
+	IsAlive() bool
+	getMob() *Mob
+}
+
+// NewMobByTypeName creates a mob of the named type, e.g "Zombie", standing at
+// position. It returns nil if there is no such type of mob.
+func NewMobByTypeName(typeName string, position *AbsXyz, look *LookDegrees) IMob {
+	mob, ok := NewEntityByTypeName(typeName).(IMob)
+	if !ok {
+		return nil
+	}
+
+	mob.getMob().PointObject.Init(position, &AbsVelocity{})
+	mob.SetLook(*look)
+	return mob
+}
+
 // When using an object of type Mob or a sub-type, the caller must set an
 // EntityId, most likely obtained from the EntityManager.
 type Mob struct {
 	EntityId
 	physics.PointObject
-	mobType EntityMobType
-	look    LookDegrees
+	mobType   EntityMobType
+	look      LookDegrees
+	health    Health
+	hurtTime  int16
+	deathTime int16
+	behaviour IMobBehaviour
+	state     mobState
+	// The look last sent to clients.
+	lastSentLook LookBytes
 	// TODO(nictuku): Move to a more structured form.
 	metadata map[byte]byte
 	// TODO: Change to an AABB object when we have that.
@@ -34,6 +70,10 @@ type Mob struct {
 
 func (mob *Mob) Init(id EntityMobType) {
 	mob.mobType = id
+	if mobType, ok := Mobs[id]; ok {
+		mob.health = mobType.MaxHealth
+		mob.behaviour = mobType.Behaviour
+	}
 	mob.metadata = map[byte]byte{
 		0:  byte(0),
 		16: byte(0),
@@ -51,19 +91,35 @@ func (mob *Mob) ReadNbt(tag nbt.ITag) (err os.Error) {
 		return
 	}
 
+	health, err := nbtutil.ReadShort(tag, "Health")
+	if err != nil {
+		return
+	}
+	// Older versions of the server stored zero health for all mobs, so only
+	// take it if the mob was not already dead.
+	if health > 0 {
+		mob.health = Health(health)
+	}
+
+	if mob.hurtTime, err = nbtutil.ReadShort(tag, "HurtTime"); err != nil {
+		return
+	}
+
 	// TODO
 	_ = tag.Lookup("Air").(*nbt.Short).Value
 	_ = tag.Lookup("AttackTime").(*nbt.Short).Value
-	_ = tag.Lookup("DeathTime").(*nbt.Short).Value
 	_ = tag.Lookup("FallDistance").(*nbt.Float).Value
 	_ = tag.Lookup("Fire").(*nbt.Short).Value
-	_ = tag.Lookup("Health").(*nbt.Short).Value
-	_ = tag.Lookup("HurtTime").(*nbt.Short).Value
 
 	return nil
 }
 
 func (mob *Mob) WriteNbt() nbt.ITag {
+	if mob.health <= 0 {
+		// Dead mobs are not kept.
+		return nil
+	}
+
 	mobTypeName, ok := MobNameByType[mob.mobType]
 	if !ok {
 		return nil
@@ -80,13 +136,26 @@ func (mob *Mob) WriteNbt() nbt.ITag {
 		"DeathTime":    &nbt.Short{0},
 		"FallDistance": &nbt.Float{0},
 		"Fire":         &nbt.Short{0},
-		"Health":       &nbt.Short{0},
-		"HurtTime":     &nbt.Short{0},
+		"Health":       &nbt.Short{int16(mob.health)},
+		"HurtTime":     &nbt.Short{mob.hurtTime},
 	}}
 	mob.PointObject.WriteIntoNbt(tag)
 	return tag
 }
 
+func (mob *Mob) GetMobType() EntityMobType {
+	return mob.mobType
+}
+
+// IsAlive returns false once the mob has been killed.
+func (mob *Mob) IsAlive() bool {
+	return mob.health > 0
+}
+
+func (mob *Mob) getMob() *Mob {
+	return mob
+}
+
 func (mob *Mob) SetLook(look LookDegrees) {
 	mob.look = look
 }
@@ -100,10 +169,54 @@ func (mob *Mob) SetBurning(burn bool) {
 }
 
 func (mob *Mob) Tick(blockQuerier physics.IBlockQuerier) (leftBlock bool) {
+	if mob.hurtTime > 0 {
+		mob.hurtTime--
+	}
+	if mob.health <= 0 {
+		mob.deathTime++
+	}
+
 	// TODO: Spontaneous mob movement.
 	return mob.PointObject.Tick(blockQuerier)
 }
 
+// Think runs the mob's behaviour for a tick.
+func (mob *Mob) Think(chunk IChunkBlock) (remove bool) {
+	if mob.health <= 0 || mob.behaviour == nil {
+		return false
+	}
+
+	if mob.state.attackCooldown > 0 {
+		mob.state.attackCooldown--
+	}
+
+	return mob.behaviour.Think(mob, chunk)
+}
+
+func (mob *Mob) Attacked(damage Health, knockback *AbsVelocity) (hurt, dead bool) {
+	if mob.health <= 0 {
+		return false, true
+	}
+	if mob.hurtTime > 0 || damage <= 0 {
+		return false, false
+	}
+
+	mob.health -= damage
+	mob.hurtTime = mobHurtTicks
+	mob.PointObject.Push(knockback)
+
+	// Run away in the direction of the blow, for those mobs that do.
+	mob.state.fleeX = float64(knockback.X)
+	mob.state.fleeZ = float64(knockback.Z)
+	mob.state.fleeTicks = mobFleeTicks
+
+	return true, mob.health <= 0
+}
+
+func (mob *Mob) Expired() bool {
+	return mob.health <= 0 && mob.deathTime >= mobDeathTicks
+}
+
 func (mob *Mob) FormatMetadata() []proto.EntityMetadata {
 	x := make([]proto.EntityMetadata, len(mob.metadata))
 	i := 0
@@ -119,7 +232,17 @@ func (mob *Mob) SendUpdate(writer io.Writer) (err os.Error) {
 		return
 	}
 
-	err = mob.PointObject.SendUpdate(writer, mob.EntityId, mob.look.ToLookBytes())
+	look := mob.look.ToLookBytes()
+	if err = mob.PointObject.SendUpdate(writer, mob.EntityId, look); err != nil {
+		return
+	}
+
+	if look.Yaw != mob.lastSentLook.Yaw || look.Pitch != mob.lastSentLook.Pitch {
+		if err = proto.WriteEntityLook(writer, mob.EntityId, look); err != nil {
+			return
+		}
+		mob.lastSentLook = *look
+	}
 
 	return
 }
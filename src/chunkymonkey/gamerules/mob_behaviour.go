@@ -0,0 +1,324 @@
+package gamerules
+
+import (
+	"bytes"
+	"math"
+
+	"chunkymonkey/physics"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+const (
+	// Mobs wander to a random spot up to wanderRange blocks away, picking a
+	// new spot (or to stand still) every wanderMinTicks to wanderMaxTicks.
+	wanderRange    = 8
+	wanderMinTicks = 5 * TicksPerSecond
+	wanderMaxTicks = 10 * TicksPerSecond
+
+	// The number of ticks that a mob runs away for after being hurt.
+	mobFleeTicks = 3 * TicksPerSecond
+
+	// The upwards speed with which a mob jumps onto the block in front of it.
+	mobJumpSpeed = 0.42
+
+	// The height from a mob's feet that it shoots arrows from, and the height
+	// from a player's feet that they are aimed at.
+	mobEyeHeight    = 1.5
+	arrowTargetLift = 1.2
+
+	// The metadata used to tell clients whether a creeper is about to
+	// explode.
+	creeperFuseMetadata = 16
+	creeperFuseOff      = byte(255)
+	creeperFuseOn       = byte(1)
+)
+
+// IMobBehaviour decides what a type of mob does. A behaviour is shared by all
+// mobs of its type, and keeps the state of each mob in its mobState.
+type IMobBehaviour interface {
+	// Think is called each tick for a living mob, before it moves. It returns
+	// true if the mob should be removed, for example because it exploded.
+	Think(mob *Mob, chunk IChunkBlock) (remove bool)
+}
+
+// mobState is what a mob's behaviour remembers between ticks.
+type mobState struct {
+	// Where the mob is wandering to, if it is wandering at all, and the
+	// number of ticks until it decides again.
+	wanderDest  AbsXyz
+	wandering   bool
+	wanderTicks Ticks
+	// The direction that the mob is running away in, and for how long.
+	fleeX, fleeZ float64
+	fleeTicks    Ticks
+	// The number of ticks until the mob can attack again.
+	attackCooldown Ticks
+	// The number of ticks that a creeper's fuse has been burning for.
+	fuse Ticks
+}
+
+// PassiveBehaviour is for mobs that wander about, and run away when hurt.
+type PassiveBehaviour struct {
+	Speed     float64
+	FleeSpeed float64
+}
+
+func (b *PassiveBehaviour) Think(mob *Mob, chunk IChunkBlock) (remove bool) {
+	if !mob.flee(chunk, b.FleeSpeed) {
+		mob.wander(chunk, b.Speed)
+	}
+	return false
+}
+
+// MeleeBehaviour is for hostile mobs that chase the nearest player and attack
+// them up close.
+type MeleeBehaviour struct {
+	Speed float64
+	// FollowRange is the distance from which the mob notices players.
+	FollowRange AbsCoord
+	// AttackRange is the distance from which the mob can hit a player.
+	AttackRange AbsCoord
+	Damage      Health
+	// AttackTicks is the number of ticks between each attack.
+	AttackTicks Ticks
+}
+
+func (b *MeleeBehaviour) Think(mob *Mob, chunk IChunkBlock) (remove bool) {
+	target, ok := nearestPlayer(chunk, mob.Position(), b.FollowRange)
+	if !ok {
+		mob.wander(chunk, b.Speed)
+		return false
+	}
+
+	if mob.Position().IsWithinDistanceOf(&target.Position, b.AttackRange) {
+		mob.stop()
+		mob.faceTowards(&target.Position)
+		mob.attack(&target, b.Damage, b.AttackTicks)
+	} else {
+		mob.walkTowards(chunk, &target.Position, b.Speed)
+	}
+	return false
+}
+
+// CreeperBehaviour is for mobs that chase the nearest player, and explode if
+// they stay close to them for long enough.
+type CreeperBehaviour struct {
+	Speed       float64
+	FollowRange AbsCoord
+	// FuseRange is the distance from a player within which the fuse burns.
+	FuseRange AbsCoord
+	// FuseTicks is the number of ticks that the fuse burns for.
+	FuseTicks Ticks
+	// Power is the power of the explosion.
+	Power float32
+}
+
+func (b *CreeperBehaviour) Think(mob *Mob, chunk IChunkBlock) (remove bool) {
+	state := &mob.state
+
+	target, ok := nearestPlayer(chunk, mob.Position(), b.FollowRange)
+	if ok && mob.Position().IsWithinDistanceOf(&target.Position, b.FuseRange) {
+		mob.stop()
+		mob.faceTowards(&target.Position)
+		if state.fuse == 0 {
+			mob.setMetadata(chunk, creeperFuseMetadata, creeperFuseOn)
+		}
+		state.fuse++
+		if state.fuse >= b.FuseTicks {
+			explode(chunk, mob.Position(), b.Power)
+			return true
+		}
+		return false
+	}
+
+	if state.fuse > 0 {
+		// The player got away.
+		state.fuse = 0
+		mob.setMetadata(chunk, creeperFuseMetadata, creeperFuseOff)
+	}
+
+	if ok {
+		mob.walkTowards(chunk, &target.Position, b.Speed)
+	} else {
+		mob.wander(chunk, b.Speed)
+	}
+	return false
+}
+
+// ArcherBehaviour is for mobs that shoot arrows at the nearest player from a
+// distance.
+type ArcherBehaviour struct {
+	Speed       float64
+	FollowRange AbsCoord
+	// ShootRange is the distance from which the mob shoots at a player.
+	ShootRange AbsCoord
+	// ShootTicks is the number of ticks between each arrow.
+	ShootTicks Ticks
+	// ArrowSpeed is the horizontal speed of the arrows, in blocks per tick.
+	ArrowSpeed float64
+}
+
+func (b *ArcherBehaviour) Think(mob *Mob, chunk IChunkBlock) (remove bool) {
+	target, ok := nearestPlayer(chunk, mob.Position(), b.FollowRange)
+	if !ok {
+		mob.wander(chunk, b.Speed)
+		return false
+	}
+
+	if !mob.Position().IsWithinDistanceOf(&target.Position, b.ShootRange) {
+		mob.walkTowards(chunk, &target.Position, b.Speed)
+		return false
+	}
+
+	mob.stop()
+	mob.faceTowards(&target.Position)
+	if mob.state.attackCooldown == 0 {
+		mob.shootAt(chunk, &target.Position, b.ArrowSpeed)
+		mob.state.attackCooldown = b.ShootTicks
+	}
+	return false
+}
+
+// wander moves the mob towards a randomly chosen spot nearby, pausing every so
+// often.
+func (mob *Mob) wander(chunk IChunkBlock, speed float64) {
+	state := &mob.state
+
+	if state.wanderTicks > 0 {
+		state.wanderTicks--
+	} else {
+		rand := chunk.Rand()
+		state.wanderTicks = Ticks(wanderMinTicks + rand.Intn(wanderMaxTicks-wanderMinTicks))
+		state.wandering = rand.Intn(2) == 0
+		position := mob.Position()
+		state.wanderDest = AbsXyz{
+			position.X + AbsCoord(wanderRange*(2*rand.Float64()-1)),
+			position.Y,
+			position.Z + AbsCoord(wanderRange*(2*rand.Float64()-1)),
+		}
+	}
+
+	if state.wandering {
+		if mob.walkTowards(chunk, &state.wanderDest, speed) {
+			state.wandering = false
+		}
+	} else {
+		mob.stop()
+	}
+}
+
+// flee keeps the mob running away for a while after it has been hurt. It
+// returns false if the mob is not running away.
+func (mob *Mob) flee(chunk IChunkBlock, speed float64) bool {
+	state := &mob.state
+	if state.fleeTicks <= 0 {
+		return false
+	}
+
+	state.fleeTicks--
+	mob.walk(chunk, state.fleeX, state.fleeZ, speed)
+	return true
+}
+
+// walkTowards moves the mob horizontally towards target. It returns true once
+// the mob has got there.
+func (mob *Mob) walkTowards(chunk IChunkBlock, target *AbsXyz, speed float64) (arrived bool) {
+	position := mob.Position()
+	dx := float64(target.X - position.X)
+	dz := float64(target.Z - position.Z)
+
+	if dx*dx+dz*dz < 0.25 {
+		mob.stop()
+		return true
+	}
+
+	mob.walk(chunk, dx, dz, speed)
+	return false
+}
+
+// walk moves the mob horizontally in the direction (dx, dz), jumping onto the
+// block in front of it if it can.
+func (mob *Mob) walk(chunk IChunkBlock, dx, dz float64, speed float64) {
+	distance := math.Sqrt(dx*dx + dz*dz)
+	if distance < 1e-4 {
+		return
+	}
+	dx /= distance
+	dz /= distance
+
+	velocity := mob.PointObject.Velocity()
+	velocity.X = AbsVelocityCoord(speed * dx)
+	velocity.Z = AbsVelocityCoord(speed * dz)
+	mob.look.Yaw = yawTowards(dx, dz)
+
+	if !mob.PointObject.OnGround() {
+		return
+	}
+
+	position := mob.Position()
+	ahead := AbsXyz{position.X + AbsCoord(dx), position.Y, position.Z + AbsCoord(dz)}
+	aheadLoc := ahead.ToBlockXyz()
+	if !isSolidAt(chunk, aheadLoc) {
+		return
+	}
+	aboveLoc := aheadLoc.AddXyz(0, 1, 0)
+	if aboveLoc != nil && !isSolidAt(chunk, aboveLoc) {
+		mob.PointObject.Push(&AbsVelocity{0, mobJumpSpeed, 0})
+	}
+}
+
+// stop stops the mob from walking.
+func (mob *Mob) stop() {
+	velocity := mob.PointObject.Velocity()
+	velocity.X = 0
+	velocity.Z = 0
+}
+
+// faceTowards turns the mob to look towards target.
+func (mob *Mob) faceTowards(target *AbsXyz) {
+	position := mob.Position()
+	mob.look.Yaw = yawTowards(float64(target.X-position.X), float64(target.Z-position.Z))
+}
+
+// attack hits the player, unless the mob has attacked too recently.
+func (mob *Mob) attack(target *NearbyPlayer, damage Health, cooldown Ticks) {
+	if mob.state.attackCooldown > 0 {
+		return
+	}
+
+	target.Client.Attacked(damage, Knockback(mob.Position(), &target.Position))
+	mob.state.attackCooldown = cooldown
+}
+
+// shootAt fires an arrow at a player standing at target.
+func (mob *Mob) shootAt(chunk IChunkBlock, target *AbsXyz, speed float64) {
+	from := *mob.Position()
+	from.Y += mobEyeHeight
+	to := *target
+	to.Y += arrowTargetLift
+
+	velocity := physics.AimVelocity(&from, &to, speed)
+	chunk.AddEntity(NewShotArrow(&from, &velocity))
+}
+
+// setMetadata changes a metadata value of the mob, and sends the change to
+// players.
+func (mob *Mob) setMetadata(chunk IChunkBlock, key byte, value byte) {
+	mob.metadata[key] = value
+
+	buf := new(bytes.Buffer)
+	proto.WriteEntityMetadata(buf, mob.EntityId, mob.FormatMetadata())
+	chunk.MulticastPlayers(buf.Bytes())
+}
+
+// yawTowards returns the yaw of something facing in the direction (dx, dz).
+func yawTowards(dx, dz float64) AngleDegrees {
+	return AngleDegrees(math.Atan2(-dx, dz) * 180 / math.Pi)
+}
+
+// isSolidAt returns true if the block at loc is known and solid.
+func isSolidAt(chunk IChunkBlock, loc *BlockXyz) bool {
+	blockType, _, ok := chunk.BlockAt(loc)
+	return ok && blockType.Solid
+}
@@ -0,0 +1,219 @@
+package gamerules
+
+import (
+	"rand"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+// testMobChunk is an empty chunk of air with players in it, for mobs to think
+// in. Its other IChunkBlock methods are not implemented.
+type testMobChunk struct {
+	IChunkBlock
+	rand     *rand.Rand
+	players  []NearbyPlayer
+	entities []INonPlayerEntity
+	packets  int
+}
+
+func newTestMobChunk() *testMobChunk {
+	return &testMobChunk{rand: rand.New(rand.NewSource(1))}
+}
+
+// addPlayer adds a player to the chunk at position, returning the player's
+// client.
+func (chunk *testMobChunk) addPlayer(position AbsXyz) *testMobPlayer {
+	player := &testMobPlayer{}
+	chunk.players = append(chunk.players, NearbyPlayer{EntityId(len(chunk.players) + 1), position, player})
+	return player
+}
+
+func (chunk *testMobChunk) Rand() *rand.Rand {
+	return chunk.rand
+}
+
+func (chunk *testMobChunk) BlockAt(blockLoc *BlockXyz) (blockType *BlockType, blockData byte, ok bool) {
+	blockType, ok = Blocks.Get(BlockIdAir)
+	return
+}
+
+func (chunk *testMobChunk) AddEntity(entity INonPlayerEntity) {
+	chunk.entities = append(chunk.entities, entity)
+}
+
+func (chunk *testMobChunk) MulticastPlayers(packet []byte) {
+	chunk.packets++
+}
+
+func (chunk *testMobChunk) PlayersNear(position *AbsXyz, maxDistance AbsCoord) (players []NearbyPlayer) {
+	for _, player := range chunk.players {
+		if position.IsWithinDistanceOf(&player.Position, maxDistance) {
+			players = append(players, player)
+		}
+	}
+	return
+}
+
+// testMobPlayer records the attacks on a player. Its other IPlayerClient
+// methods are not implemented.
+type testMobPlayer struct {
+	IPlayerClient
+	attacks int
+	damage  Health
+}
+
+func (player *testMobPlayer) Attacked(damage Health, knockback AbsVelocity) {
+	player.attacks++
+	player.damage += damage
+}
+
+// newTestMob puts the mob at position, at rest.
+func newTestMob(entity INonPlayerEntity, position AbsXyz) *Mob {
+	mob := entity.(IMob).getMob()
+	mob.PointObject.Init(&position, &AbsVelocity{})
+	return mob
+}
+
+func TestMeleeBehaviour(t *testing.T) {
+	chunk := newTestMobChunk()
+	zombie := newTestMob(NewZombie(), AbsXyz{0.5, 64, 0.5})
+
+	// The zombie walks towards a player that it can see.
+	player := chunk.addPlayer(AbsXyz{8.5, 64, 0.5})
+	zombie.Think(chunk)
+	if velocity := zombie.Velocity(); velocity.X <= 0 || velocity.Z != 0 {
+		t.Errorf("expected the zombie to walk towards the player, got velocity %v", *velocity)
+	}
+	if player.attacks != 0 {
+		t.Errorf("expected the zombie not to attack from afar, got %d attacks", player.attacks)
+	}
+
+	// It attacks a player next to it, and then waits before attacking again.
+	chunk.players[0].Position = AbsXyz{1.5, 64, 0.5}
+	zombie.Think(chunk)
+	zombie.Think(chunk)
+	if player.attacks != 1 || player.damage != zombieBehaviour.Damage {
+		t.Errorf("expected one attack of %d damage, got %d attacks of %d damage",
+			zombieBehaviour.Damage, player.attacks, player.damage)
+	}
+	if velocity := zombie.Velocity(); velocity.X != 0 || velocity.Z != 0 {
+		t.Errorf("expected the zombie to stop to attack, got velocity %v", *velocity)
+	}
+
+	for i := Ticks(0); i < zombieBehaviour.AttackTicks; i++ {
+		zombie.Think(chunk)
+	}
+	if player.attacks != 2 {
+		t.Errorf("expected the zombie to attack again, got %d attacks", player.attacks)
+	}
+}
+
+func TestCreeperBehaviour(t *testing.T) {
+	chunk := newTestMobChunk()
+	creeper := newTestMob(NewCreeper(), AbsXyz{0.5, 64, 0.5})
+
+	// The fuse starts burning when a player comes close.
+	player := chunk.addPlayer(AbsXyz{2.5, 64, 0.5})
+	if creeper.Think(chunk) {
+		t.Fatalf("expected the creeper not to explode straight away")
+	}
+	if creeper.state.fuse != 1 || creeper.metadata[creeperFuseMetadata] != creeperFuseOn {
+		t.Errorf("expected the fuse to be burning, got fuse %d and metadata %d",
+			creeper.state.fuse, creeper.metadata[creeperFuseMetadata])
+	}
+	if chunk.packets != 1 {
+		t.Errorf("expected players to be told of the fuse, got %d packets", chunk.packets)
+	}
+
+	// And goes out if the player gets away.
+	chunk.players[0].Position = AbsXyz{10.5, 64, 0.5}
+	creeper.Think(chunk)
+	if creeper.state.fuse != 0 || creeper.metadata[creeperFuseMetadata] != creeperFuseOff {
+		t.Errorf("expected the fuse to be out, got fuse %d and metadata %d",
+			creeper.state.fuse, creeper.metadata[creeperFuseMetadata])
+	}
+
+	// If the player stays close, the creeper explodes and hurts them.
+	chunk.players[0].Position = AbsXyz{2.5, 64, 0.5}
+	for i := Ticks(1); i < creeperBehaviour.FuseTicks; i++ {
+		if creeper.Think(chunk) {
+			t.Fatalf("expected the creeper to explode after %d ticks, exploded after %d",
+				creeperBehaviour.FuseTicks, i)
+		}
+	}
+	if player.attacks != 0 {
+		t.Errorf("expected the player not to be hurt before the explosion")
+	}
+	if !creeper.Think(chunk) {
+		t.Fatalf("expected the creeper to explode")
+	}
+	if player.attacks != 1 || player.damage <= 0 {
+		t.Errorf("expected the explosion to hurt the player, got %d attacks of %d damage",
+			player.attacks, player.damage)
+	}
+}
+
+func TestArcherBehaviour(t *testing.T) {
+	chunk := newTestMobChunk()
+	skeleton := newTestMob(NewSkeleton(), AbsXyz{0.5, 64, 0.5})
+
+	// The skeleton walks closer to a player out of its range.
+	chunk.addPlayer(AbsXyz{0.5, 64, 14.5})
+	skeleton.Think(chunk)
+	if len(chunk.entities) != 0 {
+		t.Errorf("expected no arrows to be shot out of range, got %d", len(chunk.entities))
+	}
+	if velocity := skeleton.Velocity(); velocity.Z <= 0 {
+		t.Errorf("expected the skeleton to walk towards the player, got velocity %v", *velocity)
+	}
+
+	// It shoots at a player within range, and then waits before shooting again.
+	chunk.players[0].Position = AbsXyz{0.5, 64, 6.5}
+	skeleton.Think(chunk)
+	skeleton.Think(chunk)
+	if len(chunk.entities) != 1 {
+		t.Fatalf("expected one arrow to be shot, got %d", len(chunk.entities))
+	}
+
+	arrow, ok := chunk.entities[0].(*Object)
+	if !ok || arrow.ObjTypeId != ObjTypeIdArrow {
+		t.Fatalf("expected an arrow, got %#v", chunk.entities[0])
+	}
+	if velocity := arrow.Velocity(); velocity.Z <= 0 {
+		t.Errorf("expected the arrow to fly towards the player, got velocity %v", *velocity)
+	}
+	if position := arrow.Position(); position.Y != 64+mobEyeHeight {
+		t.Errorf("expected the arrow to be shot from the skeleton's eyes, got %v", *position)
+	}
+}
+
+func TestPassiveBehaviour(t *testing.T) {
+	chunk := newTestMobChunk()
+	pig := newTestMob(NewPig(), AbsXyz{0.5, 64, 0.5})
+
+	// Players are ignored.
+	player := chunk.addPlayer(AbsXyz{1.5, 64, 0.5})
+	pig.Think(chunk)
+	if player.attacks != 0 {
+		t.Errorf("expected the pig not to attack")
+	}
+	if pig.state.wanderTicks <= 0 {
+		t.Errorf("expected the pig to have decided where to wander")
+	}
+
+	// The pig runs away in the direction that it was hit.
+	pig.Attacked(1, &AbsVelocity{0, 0, -0.5})
+	pig.Think(chunk)
+	if velocity := pig.Velocity(); velocity.X != 0 || velocity.Z >= 0 {
+		t.Errorf("expected the pig to run away, got velocity %v", *velocity)
+	}
+
+	// Until it has run for long enough.
+	for i := Ticks(1); i < mobFleeTicks; i++ {
+		pig.Think(chunk)
+	}
+	if pig.state.fleeTicks != 0 {
+		t.Errorf("expected the pig to have stopped running away, %d ticks left", pig.state.fleeTicks)
+	}
+}
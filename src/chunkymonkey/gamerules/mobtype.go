@@ -5,8 +5,12 @@ import (
 )
 
 type MobType struct {
-	Id   EntityMobType
-	Name string
+	Id        EntityMobType
+	Name      string
+	MaxHealth Health
+	// Behaviour decides what mobs of the type do. nil means that they do
+	// nothing.
+	Behaviour IMobBehaviour
 }
 
 type MobTypeMap map[EntityMobType]*MobType
@@ -29,17 +33,53 @@ var Mobs = MobTypeMap{
 	MobTypeIdWolf:         &WolfType,
 }
 
-var CreeperType = MobType{MobTypeIdCreeper, "creeper"}
-var SkeletonType = MobType{MobTypeIdSkeleton, "skeleton"}
-var SpiderType = MobType{MobTypeIdSpider, "spider"}
-var GiantZombieType = MobType{MobTypeIdGiantZombie, "giantzombie"}
-var ZombieType = MobType{MobTypeIdZombie, "zombie"}
-var SlimeType = MobType{MobTypeIdSlime, "slime"}
-var GhastType = MobType{MobTypeIdGhast, "ghast"}
-var ZombiePigmanType = MobType{MobTypeIdZombiePigman, "zombiepigman"}
-var PigType = MobType{MobTypeIdPig, "pig"}
-var SheepType = MobType{MobTypeIdSheep, "sheep"}
-var CowType = MobType{MobTypeIdCow, "cow"}
-var HenType = MobType{MobTypeIdHen, "hen"}
-var SquidType = MobType{MobTypeIdSquid, "squid"}
-var WolfType = MobType{MobTypeIdWolf, "wolf"}
+// Behaviours shared by the mob types.
+var (
+	passiveBehaviour = &PassiveBehaviour{
+		Speed:     0.1,
+		FleeSpeed: 0.2,
+	}
+	zombieBehaviour = &MeleeBehaviour{
+		Speed:       0.12,
+		FollowRange: 16,
+		AttackRange: 1.5,
+		Damage:      4,
+		AttackTicks: TicksPerSecond,
+	}
+	spiderBehaviour = &MeleeBehaviour{
+		Speed:       0.16,
+		FollowRange: 16,
+		AttackRange: 1.5,
+		Damage:      2,
+		AttackTicks: TicksPerSecond,
+	}
+	creeperBehaviour = &CreeperBehaviour{
+		Speed:       0.12,
+		FollowRange: 16,
+		FuseRange:   3,
+		FuseTicks:   TicksPerSecond * 3 / 2,
+		Power:       3,
+	}
+	skeletonBehaviour = &ArcherBehaviour{
+		Speed:       0.12,
+		FollowRange: 16,
+		ShootRange:  10,
+		ShootTicks:  2 * TicksPerSecond,
+		ArrowSpeed:  1.6,
+	}
+)
+
+var CreeperType = MobType{MobTypeIdCreeper, "creeper", 20, creeperBehaviour}
+var SkeletonType = MobType{MobTypeIdSkeleton, "skeleton", 20, skeletonBehaviour}
+var SpiderType = MobType{MobTypeIdSpider, "spider", 16, spiderBehaviour}
+var GiantZombieType = MobType{MobTypeIdGiantZombie, "giantzombie", 100, nil}
+var ZombieType = MobType{MobTypeIdZombie, "zombie", 20, zombieBehaviour}
+var SlimeType = MobType{MobTypeIdSlime, "slime", 16, nil}
+var GhastType = MobType{MobTypeIdGhast, "ghast", 10, nil}
+var ZombiePigmanType = MobType{MobTypeIdZombiePigman, "zombiepigman", 20, nil}
+var PigType = MobType{MobTypeIdPig, "pig", 10, passiveBehaviour}
+var SheepType = MobType{MobTypeIdSheep, "sheep", 8, passiveBehaviour}
+var CowType = MobType{MobTypeIdCow, "cow", 10, passiveBehaviour}
+var HenType = MobType{MobTypeIdHen, "hen", 4, passiveBehaviour}
+var SquidType = MobType{MobTypeIdSquid, "squid", 10, passiveBehaviour}
+var WolfType = MobType{MobTypeIdWolf, "wolf", 8, passiveBehaviour}
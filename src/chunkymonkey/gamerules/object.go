@@ -5,6 +5,7 @@ package gamerules
 
 import (
 	"io"
+	"math"
 	"os"
 
 	"chunkymonkey/physics"
@@ -15,11 +16,26 @@ import (
 
 // TODO Object sub-types?
 
+// Damage done by an arrow that hits a player.
+const arrowDamage = 4
+
+// The number of points along its last tick of flight at which an arrow checks
+// for hitting a player, so that fast arrows don't pass straight through them.
+const arrowHitChecks = 4
+
+// fallingBlockTypes maps the object types that are falling blocks to the block
+// type that they land as.
+var fallingBlockTypes = map[ObjTypeId]BlockId{
+	ObjTypeIdFallingSand:   BlockId(12),
+	ObjTypeIdFallingGravel: BlockId(13),
+}
+
 type Object struct {
 	EntityId
 	ObjTypeId
 	physics.PointObject
 	orientation OrientationBytes
+	fuse        Ticks // Ticks since a primed TNT object was created.
 }
 
 func NewObject(objType ObjTypeId) (object *Object) {
@@ -88,6 +104,52 @@ func (object *Object) SendUpdate(writer io.Writer) (err os.Error) {
 	return
 }
 
+func (object *Object) BlockTick(chunk IChunkBlock) (remove bool) {
+	if object.ObjTypeId == ObjTypeIdActivatedTnt {
+		object.fuse++
+		if object.fuse < tntFuseTicks {
+			return false
+		}
+		explode(chunk, object.PointObject.Position(), tntExplosionPower)
+		return true
+	}
+
+	if blockId, isFallingBlock := fallingBlockTypes[object.ObjTypeId]; isFallingBlock {
+		return object.placeBlock(chunk, blockId)
+	}
+
+	if object.ObjTypeId == ObjTypeIdArrow {
+		return object.arrowHit(chunk)
+	}
+
+	return false
+}
+
+// placeBlock turns a falling block object that has landed back into a block,
+// or into an item if the block can't be placed where it landed.
+func (object *Object) placeBlock(chunk IChunkBlock, blockId BlockId) (placed bool) {
+	if !object.PointObject.OnGround() {
+		return false
+	}
+
+	blockLoc := object.PointObject.Position().ToBlockXyz()
+	if blockType, _, ok := chunk.BlockAt(blockLoc); ok && blockType.Replaceable {
+		if chunk.SetBlockAt(blockLoc, blockId, 0) {
+			return true
+		}
+	}
+
+	chunk.AddEntity(
+		NewItem(
+			ItemTypeId(blockId), 1, 0,
+			object.PointObject.Position(),
+			&AbsVelocity{0, 0, 0},
+			0,
+		),
+	)
+
+	return true
+}
 
 func NewBoat() INonPlayerEntity {
 	return NewObject(ObjTypeIdBoat)
@@ -109,10 +171,54 @@ func NewActivatedTnt() INonPlayerEntity {
 	return NewObject(ObjTypeIdActivatedTnt)
 }
 
+// NewPrimedTnt creates primed TNT at the given block location, which explodes
+// once its fuse has run out.
+func NewPrimedTnt(blockLoc *BlockXyz) (object *Object) {
+	object = NewObject(ObjTypeIdActivatedTnt)
+	position := blockLoc.MidPointToAbsXyz()
+	object.PointObject.Init(&position, &AbsVelocity{0, 0, 0})
+	return
+}
+
+// arrowHit hurts any player that an arrow has flown into. The arrow is
+// removed once it hits a player or lands.
+func (object *Object) arrowHit(chunk IChunkBlock) (remove bool) {
+	position := object.PointObject.Position()
+	velocity := object.PointObject.Velocity()
+	speed := math.Sqrt(float64(velocity.X*velocity.X + velocity.Y*velocity.Y + velocity.Z*velocity.Z))
+
+	for _, player := range chunk.PlayersNear(position, AbsCoord(speed)+playerHeight) {
+		for i := 0; i < arrowHitChecks; i++ {
+			back := float64(i) / arrowHitChecks
+			point := AbsXyz{
+				position.X - AbsCoord(back*float64(velocity.X)),
+				position.Y - AbsCoord(back*float64(velocity.Y)),
+				position.Z - AbsCoord(back*float64(velocity.Z)),
+			}
+			if !player.Contains(&point) {
+				continue
+			}
+
+			from := AbsXyz{position.X - AbsCoord(velocity.X), position.Y, position.Z - AbsCoord(velocity.Z)}
+			player.Client.Attacked(arrowDamage, Knockback(&from, &player.Position))
+			return true
+		}
+	}
+
+	return object.PointObject.OnGround()
+}
+
 func NewArrow() INonPlayerEntity {
 	return NewObject(ObjTypeIdArrow)
 }
 
+// NewShotArrow creates an arrow in flight from position.
+func NewShotArrow(position *AbsXyz, velocity *AbsVelocity) (object *Object) {
+	object = NewObject(ObjTypeIdArrow)
+	object.PointObject.Init(position, velocity)
+	return
+}
+
 func NewThrownSnowball() INonPlayerEntity {
 	return NewObject(ObjTypeIdThrownSnowball)
 }
@@ -129,6 +235,17 @@ func NewFallingGravel() INonPlayerEntity {
 	return NewObject(ObjTypeIdFallingGravel)
 }
 
+// NewFallingBlock creates a falling block object of the given type, starting
+// at rest at the given block location.
+func NewFallingBlock(objType ObjTypeId, blockLoc *BlockXyz) (object *Object) {
+	object = NewObject(objType)
+	position := blockLoc.ToAbsXyz()
+	position.X += 0.5
+	position.Z += 0.5
+	object.PointObject.Init(position, &AbsVelocity{0, 0, 0})
+	return
+}
+
 func NewFishingFloat() INonPlayerEntity {
 	return NewObject(ObjTypeIdFishingFloat)
 }
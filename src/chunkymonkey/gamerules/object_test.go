@@ -0,0 +1,33 @@
+package gamerules
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestObjectNbt_FallingGravel(t *testing.T) {
+	gravel := NewFallingBlock(ObjTypeIdFallingGravel, &BlockXyz{1, 70, -2})
+
+	tag := gravel.WriteNbt()
+	if tag == nil {
+		t.Fatal("expected falling gravel to be written")
+	}
+
+	entity := NewEntityByTypeName(ObjNameByType[ObjTypeIdFallingGravel])
+	if entity == nil {
+		t.Fatal("expected an entity for the falling gravel type name")
+	}
+	if err := entity.ReadNbt(tag); err != nil {
+		t.Fatal(err)
+	}
+
+	object, ok := entity.(*Object)
+	if !ok || object.ObjTypeId != ObjTypeIdFallingGravel {
+		t.Fatalf("expected falling gravel, got %#v", entity)
+	}
+	expected := AbsXyz{1.5, 70, -1.5}
+	if position := object.Position(); position.X != expected.X || position.Y != expected.Y || position.Z != expected.Z {
+		t.Errorf("expected falling gravel at %v, got %v", expected, *position)
+	}
+}
@@ -0,0 +1,65 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+// PlayerEnvironment describes the blocks around a player that can harm them.
+type PlayerEnvironment struct {
+	// HeadInWater is true while the player cannot breathe.
+	HeadInWater bool
+	// InWater is true while the player is in water, which puts out fire.
+	InWater bool
+	// InLava is true while the player is in lava.
+	InLava bool
+	// InFire is true while the player is in a burning block other than lava.
+	InFire bool
+	// InPortal is true while the player is in a portal to another dimension.
+	InPortal bool
+}
+
+func (env *PlayerEnvironment) Equals(other *PlayerEnvironment) bool {
+	return env.HeadInWater == other.HeadInWater &&
+		env.InWater == other.InWater &&
+		env.InLava == other.InLava &&
+		env.InFire == other.InFire &&
+		env.InPortal == other.InPortal
+}
+
+// PlayerEnvironmentAt works out the environment of a player standing at
+// position, with their eyes eyeHeight above it.
+func PlayerEnvironmentAt(chunk IChunkBlock, position *AbsXyz, eyeHeight AbsCoord) (env PlayerEnvironment) {
+	if position.Y < 0 {
+		// Below the bottom of the world.
+		return
+	}
+
+	feetLoc := position.ToBlockXyz()
+	eyes := AbsXyz{position.X, position.Y + eyeHeight, position.Z}
+	eyesLoc := eyes.ToBlockXyz()
+
+	for _, loc := range []*BlockXyz{feetLoc, eyesLoc} {
+		blockType, _, ok := chunk.BlockAt(loc)
+		if !ok {
+			continue
+		}
+
+		_, isFluid := blockType.Aspect.(*FluidAspect)
+		_, isPortal := blockType.Aspect.(*PortalAspect)
+		switch {
+		case isFluid && blockType.Burns:
+			env.InLava = true
+		case isFluid:
+			env.InWater = true
+			if loc == eyesLoc {
+				env.HeadInWater = true
+			}
+		case blockType.Burns:
+			env.InFire = true
+		case isPortal:
+			env.InPortal = true
+		}
+	}
+
+	return
+}
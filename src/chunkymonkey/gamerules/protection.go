@@ -0,0 +1,40 @@
+package gamerules
+
+import (
+	"chunkymonkey/region"
+	. "chunkymonkey/types"
+)
+
+// Permission nodes that control what players may do to the world.
+const (
+	PermBuild         = "world.build"          // Digging and placing blocks.
+	PermUse           = "world.use"            // Opening blocks with inventory.
+	PermPvp           = "world.pvp"            // Attacking other players.
+	PermBypassRegions = "world.regions.bypass" // Ignoring the flags of regions.
+)
+
+// MayBuild returns true if the player may dig or place the block at blockLoc.
+func MayBuild(username string, dimension DimensionId, blockLoc *BlockXyz) bool {
+	return mayAct(username, dimension, blockLoc, PermBuild, region.FlagBuild)
+}
+
+// MayUse returns true if the player may open the inventory of the block at
+// blockLoc.
+func MayUse(username string, dimension DimensionId, blockLoc *BlockXyz) bool {
+	return mayAct(username, dimension, blockLoc, PermUse, region.FlagChest)
+}
+
+// MayAttackPlayers returns true if the player may attack other players while
+// standing at blockLoc.
+func MayAttackPlayers(username string, dimension DimensionId, blockLoc *BlockXyz) bool {
+	return mayAct(username, dimension, blockLoc, PermPvp, region.FlagPvp)
+}
+
+func mayAct(username string, dimension DimensionId, blockLoc *BlockXyz, node string, flag string) bool {
+	permissions := Permissions().UserPermissions(username)
+	if !permissions.Has(node) {
+		return false
+	}
+
+	return permissions.Has(PermBypassRegions) || Regions.Allows(username, dimension, blockLoc, flag)
+}
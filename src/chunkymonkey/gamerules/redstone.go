@@ -0,0 +1,132 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+// Redstone power levels range from 0 (unpowered) to 15.
+const (
+	powerNone = 0
+	powerMax  = 15
+)
+
+// IPowerSource is implemented by the aspects of blocks that provide redstone
+// power to their neighbours.
+type IPowerSource interface {
+	// PowerTo returns the power level that a block of this type with the given
+	// data provides to the neighbouring block on the given face of it.
+	PowerTo(blockData byte, face Face) byte
+}
+
+// attachedFaces maps the lower 3 bits of the data of torches, levers and
+// buttons to the face of the block that they are attached to.
+var attachedFaces = [8]Face{
+	FaceBottom,
+	FaceNorth,
+	FaceSouth,
+	FaceEast,
+	FaceWest,
+	FaceBottom,
+	FaceBottom,
+	FaceBottom,
+}
+
+func attachedFace(blockData byte) Face {
+	return attachedFaces[blockData&0x7]
+}
+
+// neighbourLoc returns the location of the block next to blockLoc on the given
+// face, or nil if there is no such location.
+func neighbourLoc(blockLoc *BlockXyz, face Face) *BlockXyz {
+	dx, dy, dz := face.Dxyz()
+	return blockLoc.AddXyz(dx, dy, dz)
+}
+
+// isWire returns true if the block type is redstone wire.
+func isWire(blockType *BlockType) bool {
+	_, ok := blockType.Aspect.(*RedstoneWireAspect)
+	return ok
+}
+
+// powerFromFace returns the power that the block at blockLoc receives from its
+// neighbour on the given face.
+func powerFromFace(chunk IChunkBlock, blockLoc *BlockXyz, face Face, includeWire bool) byte {
+	loc := neighbourLoc(blockLoc, face)
+	if loc == nil {
+		return powerNone
+	}
+
+	blockType, blockData, ok := chunk.BlockAt(loc)
+	if !ok {
+		return powerNone
+	}
+
+	source, isSource := blockType.Aspect.(IPowerSource)
+	if !isSource || (!includeWire && isWire(blockType)) {
+		return powerNone
+	}
+
+	return source.PowerTo(blockData, face.Opposite())
+}
+
+// receivedPower returns the highest power that the block at blockLoc receives
+// directly from its neighbours. Wire is only counted if includeWire is true -
+// a solid block powered only by wire is too weak to power wire in turn.
+func receivedPower(chunk IChunkBlock, blockLoc *BlockXyz, includeWire bool) (power byte) {
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		if p := powerFromFace(chunk, blockLoc, face, includeWire); p > power {
+			power = p
+		}
+	}
+	return
+}
+
+// isPoweredThrough returns true if the block at blockLoc is powered by its
+// neighbour on the given face, either directly or through that neighbour
+// being a solid block that is powered.
+func isPoweredThrough(chunk IChunkBlock, blockLoc *BlockXyz, face Face) bool {
+	if powerFromFace(chunk, blockLoc, face, true) > powerNone {
+		return true
+	}
+
+	loc := neighbourLoc(blockLoc, face)
+	if loc == nil {
+		return false
+	}
+	blockType, _, ok := chunk.BlockAt(loc)
+	return ok && blockType.Solid && receivedPower(chunk, loc, true) > powerNone
+}
+
+// isBlockPowered returns true if the block at blockLoc is powered, either
+// directly by a neighbour or through a neighbouring solid block. Block aspects
+// that react to power should check this when they tick, which happens
+// whenever a neighbouring block changes.
+func isBlockPowered(chunk IChunkBlock, blockLoc *BlockXyz) bool {
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		if isPoweredThrough(chunk, blockLoc, face) {
+			return true
+		}
+	}
+	return false
+}
+
+// switchAfterDelay is used by blocks that switch between two states some time
+// after their input changes. It returns true when the block should switch
+// now. Otherwise it schedules the block to tick again when the delay is over.
+func switchAfterDelay(instance *BlockInstance, wantSwitch bool, delay Ticks) bool {
+	if !wantSwitch {
+		if instance.Chunk.BlockExtra(instance.Index) != nil {
+			instance.Chunk.SetBlockExtra(instance.Index, nil)
+		}
+		return false
+	}
+
+	now := instance.Chunk.CurrentTick()
+	if due, pending := instance.Chunk.BlockExtra(instance.Index).(Ticks); pending {
+		return now >= due
+	}
+
+	instance.Chunk.SetBlockExtra(instance.Index, now+delay)
+	instance.Chunk.ScheduleBlockTick(instance.Index, delay)
+	return false
+}
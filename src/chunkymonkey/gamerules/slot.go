@@ -274,3 +274,11 @@ func (s *Slot) ReadNbt(tag nbt.ITag) (err os.Error) {
 
 	return
 }
+
+func (s *Slot) WriteNbt() *nbt.Compound {
+	return &nbt.Compound{map[string]nbt.ITag{
+		"id":     &nbt.Short{int16(s.ItemTypeId)},
+		"Count":  &nbt.Byte{int8(s.Count)},
+		"Damage": &nbt.Short{int16(s.Data)},
+	}}
+}
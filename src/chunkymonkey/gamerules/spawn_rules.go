@@ -0,0 +1,173 @@
+package gamerules
+
+import (
+	"fmt"
+	"io"
+	"json"
+	"os"
+
+	. "chunkymonkey/types"
+)
+
+// SpawnTime restricts the time of day at which a mob can spawn naturally.
+type SpawnTime byte
+
+const (
+	SpawnTimeAny = SpawnTime(iota)
+	SpawnTimeDay
+	SpawnTimeNight
+)
+
+var spawnTimeByName = map[string]SpawnTime{
+	"":      SpawnTimeAny,
+	"any":   SpawnTimeAny,
+	"day":   SpawnTimeDay,
+	"night": SpawnTimeNight,
+}
+
+// SpawnRules controls how mobs spawn and despawn naturally.
+type SpawnRules struct {
+	// AttemptTicks is the number of ticks between attempts to spawn mobs.
+	AttemptTicks Ticks
+	// ChunkRadius is the distance in chunks around each player within which
+	// mobs can spawn.
+	ChunkRadius ChunkCoord
+	// Mobs never spawn within MinPlayerDistance of a player.
+	MinPlayerDistance AbsCoord
+	// Mobs that despawn are removed once they are further than
+	// DespawnDistance from all players.
+	DespawnDistance AbsCoord
+	Mobs            []MobSpawnRule
+}
+
+// MobSpawnRule controls the natural spawning of one type of mob.
+type MobSpawnRule struct {
+	// Mob is the name of the type of mob, e.g "Zombie".
+	Mob     string
+	MobType EntityMobType
+	// Chance is the probability of trying to spawn a group of the mob on each
+	// spawn attempt.
+	Chance   float64
+	MinGroup int
+	MaxGroup int
+	// Cap is the most mobs of the type that can be in a shard before no more
+	// spawn.
+	Cap int
+	// Mobs only spawn in blocks whose light is between MinLight and MaxLight
+	// inclusive.
+	MinLight byte
+	MaxLight byte
+	Time     SpawnTime
+	// SpawnOn is the set of block types that the mob can spawn on top of.
+	SpawnOn map[BlockId]bool
+	// Despawns is true if the mob is removed when far from all players.
+	Despawns bool
+}
+
+// spawnRulesDef is used in unmarshalling data from the JSON definition of
+// SpawnRules.
+type spawnRulesDef struct {
+	AttemptTicks      Ticks
+	ChunkRadius       ChunkCoord
+	MinPlayerDistance AbsCoord
+	DespawnDistance   AbsCoord
+	Mobs              []struct {
+		Mob      string
+		Chance   float64
+		MinGroup int
+		MaxGroup int
+		Cap      int
+		MinLight byte
+		MaxLight byte
+		Time     string
+		SpawnOn  []BlockId
+		Despawns bool
+	}
+}
+
+// LoadSpawnRules reads SpawnRules from the reader.
+func LoadSpawnRules(reader io.Reader) (rules *SpawnRules, err os.Error) {
+	decoder := json.NewDecoder(reader)
+
+	var rulesDef spawnRulesDef
+
+	if err = decoder.Decode(&rulesDef); err != nil {
+		return
+	}
+
+	if rulesDef.AttemptTicks <= 0 {
+		return nil, os.NewError("Spawn rules must have a positive AttemptTicks")
+	}
+
+	rules = &SpawnRules{
+		AttemptTicks:      rulesDef.AttemptTicks,
+		ChunkRadius:       rulesDef.ChunkRadius,
+		MinPlayerDistance: rulesDef.MinPlayerDistance,
+		DespawnDistance:   rulesDef.DespawnDistance,
+		Mobs:              make([]MobSpawnRule, len(rulesDef.Mobs)),
+	}
+
+	for i, mobDef := range rulesDef.Mobs {
+		mobType, ok := MobTypeByName[mobDef.Mob]
+		if !ok {
+			return nil, fmt.Errorf("Spawn rule has unknown mob type %q", mobDef.Mob)
+		}
+		if _, ok := EntityCreateByName[mobDef.Mob]; !ok {
+			return nil, fmt.Errorf("Spawn rule has mob type %q which cannot be created", mobDef.Mob)
+		}
+
+		spawnTime, ok := spawnTimeByName[mobDef.Time]
+		if !ok {
+			return nil, fmt.Errorf("Spawn rule for %q has unknown time %q", mobDef.Mob, mobDef.Time)
+		}
+
+		if mobDef.MinGroup < 1 || mobDef.MaxGroup < mobDef.MinGroup {
+			return nil, fmt.Errorf("Spawn rule for %q has bad group size", mobDef.Mob)
+		}
+
+		spawnOn := make(map[BlockId]bool)
+		for _, blockId := range mobDef.SpawnOn {
+			if _, ok := Blocks.Get(blockId); !ok {
+				return nil, fmt.Errorf("Spawn rule for %q has unknown block type %d", mobDef.Mob, blockId)
+			}
+			spawnOn[blockId] = true
+		}
+
+		rules.Mobs[i] = MobSpawnRule{
+			Mob:      mobDef.Mob,
+			MobType:  mobType,
+			Chance:   mobDef.Chance,
+			MinGroup: mobDef.MinGroup,
+			MaxGroup: mobDef.MaxGroup,
+			Cap:      mobDef.Cap,
+			MinLight: mobDef.MinLight,
+			MaxLight: mobDef.MaxLight,
+			Time:     spawnTime,
+			SpawnOn:  spawnOn,
+			Despawns: mobDef.Despawns,
+		}
+	}
+
+	return
+}
+
+// LoadSpawnRulesFromFile reads SpawnRules from the named file.
+func LoadSpawnRulesFromFile(filename string) (rules *SpawnRules, err os.Error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	return LoadSpawnRules(file)
+}
+
+// Rule returns the spawn rule for the mob type, if it has one.
+func (rules *SpawnRules) Rule(mobType EntityMobType) (rule *MobSpawnRule, ok bool) {
+	for i := range rules.Mobs {
+		if rules.Mobs[i].MobType == mobType {
+			return &rules.Mobs[i], true
+		}
+	}
+	return nil, false
+}
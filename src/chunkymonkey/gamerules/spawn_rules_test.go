@@ -0,0 +1,69 @@
+package gamerules
+
+import (
+	"strings"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+const zombieSpawning = `{
+  "AttemptTicks": 20,
+  "ChunkRadius": 8,
+  "MinPlayerDistance": 24,
+  "DespawnDistance": 128,
+  "Mobs": [
+    {
+      "Mob": "Zombie",
+      "Chance": 0.5,
+      "MinGroup": 1,
+      "MaxGroup": 4,
+      "Cap": 20,
+      "MaxLight": 7,
+      "Time": "night",
+      "SpawnOn": [1, 2],
+      "Despawns": true
+    }
+  ]
+}`
+
+func TestLoadSpawnRules(t *testing.T) {
+	rules, err := LoadSpawnRules(strings.NewReader(zombieSpawning))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	rule, ok := rules.Rule(MobTypeIdZombie)
+	if !ok {
+		t.Fatalf("Expected a rule for zombies")
+	}
+	if rule.Time != SpawnTimeNight || rule.Cap != 20 || !rule.Despawns {
+		t.Errorf("Zombie rule loaded incorrectly: %#v", rule)
+	}
+	if !rule.SpawnOn[1] || !rule.SpawnOn[2] || rule.SpawnOn[3] {
+		t.Errorf("Expected zombies to spawn on block types 1 and 2 only, got %v", rule.SpawnOn)
+	}
+
+	if _, ok := rules.Rule(MobTypeIdPig); ok {
+		t.Errorf("Expected no rule for pigs")
+	}
+}
+
+func TestLoadSpawnRules_Errors(t *testing.T) {
+	tests := []string{
+		// Unknown mob.
+		`{"AttemptTicks": 20, "Mobs": [{"Mob": "Dragon", "MinGroup": 1, "MaxGroup": 1}]}`,
+		// Unknown time of day.
+		`{"AttemptTicks": 20, "Mobs": [{"Mob": "Pig", "MinGroup": 1, "MaxGroup": 1, "Time": "dusk"}]}`,
+		// Bad group size.
+		`{"AttemptTicks": 20, "Mobs": [{"Mob": "Pig", "MinGroup": 2, "MaxGroup": 1}]}`,
+		// No spawn attempts.
+		`{"AttemptTicks": 0, "Mobs": []}`,
+	}
+
+	for _, test := range tests {
+		if _, err := LoadSpawnRules(strings.NewReader(test)); err == nil {
+			t.Errorf("Expected an error loading %s", test)
+		}
+	}
+}
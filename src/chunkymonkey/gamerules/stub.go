@@ -1,6 +1,9 @@
 package gamerules
 
 import (
+	"os"
+
+	"chunkymonkey/access"
 	"chunkymonkey/proto"
 	. "chunkymonkey/types"
 )
@@ -48,8 +51,10 @@ type IPlayerShardClient interface {
 	// ReqPlaceItem requests that the item passed be placed at the given target
 	// location. The shard *may* choose not to do this, but if it cannot, then it
 	// *must* account for the item in some way (maybe hand it back to the player
-	// or just drop it on the ground).
-	ReqPlaceItem(target BlockXyz, slot Slot)
+	// or just drop it on the ground). againstFace is the face of the block that
+	// the item was placed against, and look is the direction that the player
+	// was looking in, which some blocks take their orientation from.
+	ReqPlaceItem(target BlockXyz, slot Slot, againstFace Face, look LookDegrees)
 
 	// ReqTakeItem requests that the item with the specified entityId is given to
 	// the player. The chunk doesn't have to respect this (particularly if the
@@ -68,6 +73,22 @@ type IPlayerShardClient interface {
 	// ReqInventoryUnsubscribed requests that the inventory for the block be
 	// unsubscribed to.
 	ReqInventoryUnsubscribed(block BlockXyz)
+
+	// ReqSetSignText requests that the text on the sign at the given location
+	// be set. Only the player that placed the sign may set its text.
+	ReqSetSignText(target BlockXyz, lines [4]string)
+
+	// ReqAttackEntity requests that the player or other entity with the given
+	// ID be attacked by a player at position, using the held item. The shard
+	// ignores the request if the entity is not within reach in its chunks.
+	// mayHurtPlayers is false if the attacker is not allowed to attack other
+	// players.
+	ReqAttackEntity(target EntityId, held Slot, position AbsXyz, mayHurtPlayers bool)
+
+	// ReqArriveByPortal requests that a player arriving from another dimension
+	// be moved into the nearest portal to target, keeping the given look. A
+	// portal is built at target if there is none near.
+	ReqArriveByPortal(target BlockXyz, look LookDegrees)
 }
 
 // IShardShardClient provides an interface for shards to make requests against
@@ -79,6 +100,60 @@ type IShardShardClient interface {
 	ReqSetActiveBlocks(blocks []BlockXyz)
 
 	ReqTransferEntity(loc ChunkXz, entity INonPlayerEntity)
+
+	// ReqQueryBlocks requests the state of the given blocks. The shard replies
+	// to the requesting shard with ReqRemoteBlocks for those blocks that are
+	// in loaded chunks.
+	ReqQueryBlocks(requester ShardXz, blocks []BlockXyz)
+
+	// ReqRemoteBlocks informs the shard of the state of blocks in another
+	// shard, in reply to ReqQueryBlocks.
+	ReqRemoteBlocks(blocks []BlockState)
+
+	// ReqUpdateLight passes on changes in light that have spread across the
+	// edge of another shard into this one.
+	ReqUpdateLight(updates []LightUpdate)
+
+	// ReqSetBlocks sets blocks within the shard on behalf of another shard,
+	// such as fluid flowing across the edge between them, and then gives
+	// blocks the tile entities, which know their own location. The chunks
+	// that the blocks are within are loaded if need be.
+	ReqSetBlocks(blocks []BlockState, tileEntities []ITileEntity)
+
+	// ReqQueryChunks requests the blocks of the given chunks, which another
+	// shard needs to populate a chunk next to them. The shard replies to the
+	// requesting shard with ReqRemoteChunks for those chunks that are loaded.
+	ReqQueryChunks(requester ShardXz, chunks []ChunkXz)
+
+	// ReqRemoteChunks informs the shard of the blocks of chunks in another
+	// shard, in reply to ReqQueryChunks.
+	ReqRemoteChunks(chunks []ChunkBlocks)
+}
+
+// BlockState is the type and data of a single block. It is used to tell a
+// shard about blocks that lie outside of it.
+type BlockState struct {
+	Loc       BlockXyz
+	BlockId   BlockId
+	BlockData byte
+}
+
+// ChunkBlocks is the type and data of the blocks in a chunk.
+type ChunkBlocks struct {
+	Loc       ChunkXz
+	Blocks    []byte
+	BlockData []byte
+}
+
+// LightUpdate is a change in light that has reached a block from its
+// neighbour in another shard. Level is the light level of that neighbour, or
+// its previous level if Removed is true. Sky is true for sky light, and false
+// for light given off by blocks.
+type LightUpdate struct {
+	Loc     BlockXyz
+	Sky     bool
+	Level   byte
+	Removed bool
 }
 
 // IGame provide an interface for interacting with and taking action on the
@@ -87,6 +162,10 @@ type IGame interface {
 	// Broadcast a message to all players on the server
 	BroadcastMessage(msg string)
 
+	// Broadcast a message from the named player to all players on the server
+	// that are not ignoring them
+	BroadcastMessageFrom(from, msg string)
+
 	// Return a player from their name
 	PlayerByName(name string) IPlayerClient
 
@@ -96,6 +175,29 @@ type IGame interface {
 	// Return an ItemType from a numeric item. The boolean flag indicates
 	// whether or not 'id' was a valid item type.
 	ItemTypeById(id int) (ItemType, bool)
+
+	// Stop the server, disconnecting all players with the given reason and
+	// saving the world.
+	StopServer(reason string)
+
+	// ReloadConfig loads the server configuration, permissions, regions and
+	// the game rules that can change while the server is running.
+	ReloadConfig() os.Error
+
+	// AccessLists returns the bans and the whitelist of the server.
+	AccessLists() *access.Lists
+
+	// PlayerIp returns the IP address that the named player is connected
+	// from. ok is false if they are not connected.
+	PlayerIp(name string) (ip string, ok bool)
+
+	// KickPlayer disconnects the named player with the given reason. It
+	// returns false if they are not connected.
+	KickPlayer(name, reason string) bool
+
+	// KickIp disconnects all players connected from the IP address with the
+	// given reason, returning how many were kicked.
+	KickIp(ip, reason string) int
 }
 
 // IShardClient is the interface by which shards communicate to players on
@@ -103,6 +205,9 @@ type IGame interface {
 type IPlayerClient interface {
 	GetEntityId() EntityId
 
+	// Name returns the player's name.
+	Name() string
+
 	TransmitPacket(packet []byte)
 
 	// NotifyChunkLoad informs Player that a chunk subscription request with
@@ -136,8 +241,9 @@ type IPlayerClient interface {
 	// PlaceHeldItem requests that the player frontend take one item from the
 	// held item stack and send it in a ReqPlaceItem to the target block.  The
 	// player code may *not* honour this request (e.g there might be no suitable
-	// held item).
-	PlaceHeldItem(target BlockXyz, wasHeld Slot)
+	// held item). againstFace is the face of the block that the item is placed
+	// against.
+	PlaceHeldItem(target BlockXyz, wasHeld Slot, againstFace Face)
 
 	// OfferItem requests that the player check if it can take the item.  If
 	// it can then it should ReqTakeItem from the chunk.
@@ -160,6 +266,32 @@ type IPlayerClient interface {
 
 	// EchoMessage displays a message to the player
 	EchoMessage(msg string)
+
+	// SetEnvironment informs the player of a change in the blocks around them
+	// that can harm them.
+	SetEnvironment(env PlayerEnvironment)
+
+	// Kill kills the player, who drops their items and must then respawn.
+	Kill()
+
+	// Attacked informs the player that they have been attacked, and how they
+	// are knocked back by it.
+	Attacked(damage Health, knockback AbsVelocity)
+
+	// ReceiveWhisper shows the player a private message from the named
+	// player, unless they are ignoring them. /reply then answers the sender.
+	ReceiveWhisper(from, msg string)
+
+	// Correspondent returns the name of the player that last whispered to
+	// this one, or "" if there is none.
+	Correspondent() string
+
+	// SetIgnoring adds the named player to, or removes them from, the players
+	// whose whispers are ignored. It returns false if that changed nothing.
+	SetIgnoring(name string, ignore bool) bool
+
+	// Ignoring returns the names of the players that are ignored.
+	Ignoring() []string
 }
 
 type ICommandFramework interface {
@@ -0,0 +1,96 @@
+package gamerules
+
+import (
+	"io"
+	"os"
+
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+// ITileEntity is implemented by the block extra data of blocks that keep
+// their state in the chunk store, such as the contents of chests. These are
+// stored as "tile entities" in the Notchian chunk format.
+type ITileEntity interface {
+	// Block returns the location of the block that the tile entity belongs to.
+	Block() BlockXyz
+
+	// SetChunk sets the chunk that the tile entity is within, after it has been
+	// read from the chunk store.
+	SetChunk(chunk IChunkBlock)
+
+	// ReadNbt reads the tile entity from its NBT representation.
+	ReadNbt(tag nbt.ITag) os.Error
+
+	// WriteNbt creates an NBT tag representing the tile entity. This can be nil
+	// if there is nothing to store.
+	WriteNbt() nbt.ITag
+}
+
+// IClientTileEntity is implemented by tile entities that players need to know
+// the state of, such as sign text.
+type IClientTileEntity interface {
+	// SendUpdate writes the packets that tell a player the state of the tile
+	// entity. It is sent when the player subscribes to the chunk.
+	SendUpdate(writer io.Writer) os.Error
+}
+
+var TileEntityCreateByName = map[string]func() ITileEntity{
+	"Chest":      newBlankChest,
+	"Furnace":    newBlankFurnace,
+	"MobSpawner": newBlankMobSpawner,
+	"Sign":       newBlankSign,
+}
+
+// NewTileEntityByTypeName creates the appropriate tile entity type based on the
+// input string, e.g "Chest" or "Furnace". Returns nil if typeName is unknown.
+func NewTileEntityByTypeName(typeName string) ITileEntity {
+	if fn, ok := TileEntityCreateByName[typeName]; ok {
+		return fn()
+	}
+
+	return nil
+}
+
+// HasTileEntity returns true if blocks of the type keep their state in tile
+// entities of the named type. Each type of tile entity has the name of the
+// aspect of the blocks that it belongs to.
+func (blockType *BlockType) HasTileEntity(typeName string) bool {
+	_, ok := TileEntityCreateByName[typeName]
+	return ok && blockType.Aspect.Name() == typeName
+}
+
+// newTileEntityNbt creates the NBT tag common to all tile entities, for
+// further data to be added to.
+func newTileEntityNbt(typeName string, blockLoc *BlockXyz) *nbt.Compound {
+	return &nbt.Compound{map[string]nbt.ITag{
+		"id": &nbt.String{typeName},
+		"x":  &nbt.Int{int32(blockLoc.X)},
+		"y":  &nbt.Int{int32(blockLoc.Y)},
+		"z":  &nbt.Int{int32(blockLoc.Z)},
+	}}
+}
+
+// readTileEntityInstance reads the location of a tile entity from its NBT
+// representation into instance. instance.Chunk is left for SetChunk to fill
+// in.
+func readTileEntityInstance(tag nbt.ITag, instance *BlockInstance) (err os.Error) {
+	x, xOk := tag.Lookup("x").(*nbt.Int)
+	y, yOk := tag.Lookup("y").(*nbt.Int)
+	z, zOk := tag.Lookup("z").(*nbt.Int)
+	if !xOk || !yOk || !zOk {
+		return os.NewError("bad tile entity location")
+	}
+
+	instance.BlockLoc = BlockXyz{BlockCoord(x.Value), BlockYCoord(y.Value), BlockCoord(z.Value)}
+
+	_, subLoc := instance.BlockLoc.ToChunkLocal()
+	index, ok := subLoc.BlockIndex()
+	if !ok {
+		return os.NewError("bad tile entity location")
+	}
+	instance.SubLoc = *subLoc
+	instance.Index = index
+
+	return
+}
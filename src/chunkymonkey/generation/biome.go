@@ -0,0 +1,296 @@
+package generation
+
+import (
+	"os"
+
+	"chunkymonkey/chunkstore"
+	. "chunkymonkey/types"
+	"perlin"
+)
+
+// Biome describes the land in an area of the world: the blocks at its
+// surface, how high and hilly it is, and what grows on it.
+type Biome struct {
+	Name string
+
+	// Blocks at the surface, and in the few layers beneath it.
+	SurfaceBlock    byte
+	SubsurfaceBlock byte
+	// Block at the surface of beaches and the sea bed.
+	ShoreBlock byte
+	// Block that covers the surface, or 0 for none.
+	CoverBlock byte
+	// Whether the sea freezes over.
+	Frozen bool
+
+	// Height of the land relative to sea level, and how much it varies.
+	BaseHeight  float64
+	HeightScale float64
+
+	// Chances out of 1000 that a surface block has a tree, tall grass, a flower
+	// or a cactus on it.
+	TreeChance   int
+	GrassChance  int
+	FlowerChance int
+	CactusChance int
+}
+
+var (
+	BiomeOcean = &Biome{
+		Name:            "Ocean",
+		SurfaceBlock:    12, // sand
+		SubsurfaceBlock: 12, // sand
+		ShoreBlock:      13, // gravel
+		BaseHeight:      -20,
+		HeightScale:     8,
+	}
+
+	BiomePlains = &Biome{
+		Name:            "Plains",
+		SurfaceBlock:    2, // grass
+		SubsurfaceBlock: 3, // dirt
+		ShoreBlock:      12,
+		BaseHeight:      4,
+		HeightScale:     6,
+		TreeChance:      2,
+		GrassChance:     150,
+		FlowerChance:    15,
+	}
+
+	BiomeForest = &Biome{
+		Name:            "Forest",
+		SurfaceBlock:    2, // grass
+		SubsurfaceBlock: 3, // dirt
+		ShoreBlock:      12,
+		BaseHeight:      8,
+		HeightScale:     16,
+		TreeChance:      25,
+		GrassChance:     40,
+		FlowerChance:    5,
+	}
+
+	BiomeDesert = &Biome{
+		Name:            "Desert",
+		SurfaceBlock:    12, // sand
+		SubsurfaceBlock: 24, // sandstone
+		ShoreBlock:      12,
+		BaseHeight:      4,
+		HeightScale:     5,
+		CactusChance:    8,
+	}
+
+	BiomeTundra = &Biome{
+		Name:            "Tundra",
+		SurfaceBlock:    2, // grass
+		SubsurfaceBlock: 3, // dirt
+		ShoreBlock:      13,
+		CoverBlock:      78, // snow
+		Frozen:          true,
+		BaseHeight:      6,
+		HeightScale:     12,
+		TreeChance:      4,
+	}
+)
+
+// BiomeFor returns the biome for an area with the given temperature and
+// rainfall, each from 0 to 1.
+func BiomeFor(temperature, rainfall float64) *Biome {
+	switch {
+	case rainfall > 0.8:
+		return BiomeOcean
+	case temperature < 0.25:
+		return BiomeTundra
+	case temperature > 0.7 && rainfall < 0.35:
+		return BiomeDesert
+	case rainfall > 0.5:
+		return BiomeForest
+	}
+	return BiomePlains
+}
+
+const (
+	// The height profiles of the biomes around each column are averaged over
+	// a square of (2*biomeBlendRadius+1)^2 samples biomeBlendSpacing blocks
+	// apart, so that the land does not step between biomes.
+	biomeBlendRadius  = 1
+	biomeBlendSpacing = 8
+)
+
+// BiomeGenerator generates terrain whose surface, height and vegetation
+// depend on the biomes picked by temperature and rainfall noise. It
+// implements chunkstore.IChunkStoreForeground and chunkstore.IPopulator.
+type BiomeGenerator struct {
+	seed         int64
+	temperature  ISource
+	rainfall     ISource
+	heightSource ISource
+	caves        *caveCarver
+	populator    *populator
+}
+
+func NewBiomeGenerator(seed int64) *BiomeGenerator {
+	perlin := perlin.NewPerlinNoise(seed)
+
+	gen := &BiomeGenerator{
+		seed:  seed,
+		caves: newCaveCarver(perlin),
+		temperature: &Add{
+			Source: &Scale{
+				Wavelength: 400,
+				Amplitude:  1.5,
+				Source:     &Offset{1000.5, 0, perlin},
+			},
+			Value: 0.5,
+		},
+		rainfall: &Add{
+			Source: &Scale{
+				Wavelength: 300,
+				Amplitude:  1.5,
+				Source:     &Offset{0, 1000.5, perlin},
+			},
+			Value: 0.5,
+		},
+		heightSource: &Sum{
+			Inputs: []ISource{
+				&Turbulence{
+					Dx:     &Scale{50, 1, &Offset{20.1, 0, perlin}},
+					Dy:     &Scale{50, 1, &Offset{10.1, 0, perlin}},
+					Factor: 30,
+					Source: &Scale{
+						Wavelength: 100,
+						Amplitude:  1.5,
+						Source:     perlin,
+					},
+				},
+				&Scale{
+					Wavelength: 20,
+					Amplitude:  0.3,
+					Source:     perlin,
+				},
+			},
+		},
+	}
+	gen.populator = newPopulator(seed, gen)
+
+	return gen
+}
+
+func (gen *BiomeGenerator) SupportsWrite() bool {
+	return false
+}
+
+func (gen *BiomeGenerator) Writer() chunkstore.IChunkWriter {
+	return nil
+}
+
+func (gen *BiomeGenerator) WriteChunk(writer chunkstore.IChunkWriter) os.Error {
+	return os.NewError("writes not supported by BiomeGenerator")
+}
+
+// BiomeAt returns the biome at the given block column.
+func (gen *BiomeGenerator) BiomeAt(x, z float64) *Biome {
+	return BiomeFor(clamp01(gen.temperature.At2d(x, z)), clamp01(gen.rainfall.At2d(x, z)))
+}
+
+func (gen *BiomeGenerator) biomeAt(x, z BlockCoord) *Biome {
+	return gen.BiomeAt(float64(x), float64(z))
+}
+
+// heightAt returns the height of the land at the given block column.
+func (gen *BiomeGenerator) heightAt(x, z float64) int {
+	var baseHeight, heightScale float64
+	samples := 0
+	for dx := -biomeBlendRadius; dx <= biomeBlendRadius; dx++ {
+		for dz := -biomeBlendRadius; dz <= biomeBlendRadius; dz++ {
+			biome := gen.BiomeAt(x+float64(dx*biomeBlendSpacing), z+float64(dz*biomeBlendSpacing))
+			baseHeight += biome.BaseHeight
+			heightScale += biome.HeightScale
+			samples++
+		}
+	}
+	baseHeight /= float64(samples)
+	heightScale /= float64(samples)
+
+	height := int(SeaLevel + baseHeight + gen.heightSource.At2d(x, z)*heightScale)
+	if height < 1 {
+		height = 1
+	} else if height >= ChunkSizeY-2 {
+		height = ChunkSizeY - 3
+	}
+
+	return height
+}
+
+func (gen *BiomeGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkReader, err os.Error) {
+	return gen.populator.readChunk(chunkLoc)
+}
+
+func (gen *BiomeGenerator) Populate(loc ChunkXz, blocks, blockData [4][]byte) []chunkstore.BlockChange {
+	return gen.populator.populate(loc, blocks, blockData)
+}
+
+func (gen *BiomeGenerator) terrain(chunkLoc ChunkXz) *ChunkData {
+	baseBlockXyz := chunkLoc.ChunkCornerBlockXY()
+	baseX, baseZ := baseBlockXyz.X, baseBlockXyz.Z
+
+	data := newChunkData(chunkLoc)
+
+	baseIndex := BlockIndex(0)
+	for x := 0; x < ChunkSizeH; x++ {
+		for z := 0; z < ChunkSizeH; z++ {
+			xf, zf := float64(x)+float64(baseX), float64(z)+float64(baseZ)
+
+			setBiomeBlockStack(
+				gen.BiomeAt(xf, zf), gen.heightAt(xf, zf),
+				data.blocks[baseIndex:baseIndex+ChunkSizeY])
+
+			baseIndex += ChunkSizeY
+		}
+	}
+
+	gen.caves.carve(data)
+	addOres(data, gen.seed)
+
+	return data
+}
+
+// setBiomeBlockStack fills in a column of blocks with land up to height,
+// topped with the biome's blocks, and with water up to sea level.
+func setBiomeBlockStack(biome *Biome, height int, blocks []byte) {
+	blocks[0] = 7 // bedrock
+	for y := 1; y < height-3; y++ {
+		blocks[y] = 1 // stone
+	}
+
+	surfaceBlock, subsurfaceBlock := biome.SurfaceBlock, biome.SubsurfaceBlock
+	if height <= SeaLevel+1 {
+		surfaceBlock, subsurfaceBlock = biome.ShoreBlock, biome.ShoreBlock
+	}
+
+	for y := height - 3; y < height; y++ {
+		if y > 0 {
+			blocks[y] = subsurfaceBlock
+		}
+	}
+	blocks[height] = surfaceBlock
+
+	if height < SeaLevel {
+		for y := height + 1; y <= SeaLevel; y++ {
+			blocks[y] = 9 // stationary water
+		}
+		if biome.Frozen {
+			blocks[SeaLevel] = 79 // ice
+		}
+	} else if biome.CoverBlock != 0 && height+1 < ChunkSizeY {
+		blocks[height+1] = biome.CoverBlock
+	}
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	} else if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,93 @@
+package generation
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestBiomeFor(t *testing.T) {
+	type Test struct {
+		temperature, rainfall float64
+		expected              *Biome
+	}
+
+	tests := []Test{
+		{0.5, 0.9, BiomeOcean},
+		{0.1, 0.9, BiomeOcean},
+		{0.1, 0.5, BiomeTundra},
+		{0.9, 0.1, BiomeDesert},
+		{0.9, 0.6, BiomeForest},
+		{0.5, 0.6, BiomeForest},
+		{0.5, 0.3, BiomePlains},
+		{0.9, 0.4, BiomePlains},
+	}
+
+	for _, test := range tests {
+		if result := BiomeFor(test.temperature, test.rainfall); result != test.expected {
+			t.Errorf("BiomeFor(%v, %v): expected %s, got %s",
+				test.temperature, test.rainfall, test.expected.Name, result.Name)
+		}
+	}
+}
+
+func TestSetBiomeBlockStack(t *testing.T) {
+	blocks := make([]byte, ChunkSizeY)
+	setBiomeBlockStack(BiomeDesert, SeaLevel+10, blocks)
+
+	if blocks[SeaLevel+10] != 12 {
+		t.Errorf("expected sand at the surface of a desert, got %d", blocks[SeaLevel+10])
+	}
+	if blocks[SeaLevel+9] != 24 {
+		t.Errorf("expected sandstone beneath the surface of a desert, got %d", blocks[SeaLevel+9])
+	}
+	if blocks[0] != 7 {
+		t.Errorf("expected bedrock at the bottom, got %d", blocks[0])
+	}
+
+	blocks = make([]byte, ChunkSizeY)
+	setBiomeBlockStack(BiomeTundra, SeaLevel-10, blocks)
+
+	if blocks[SeaLevel] != 79 {
+		t.Errorf("expected ice at sea level in tundra, got %d", blocks[SeaLevel])
+	}
+	if blocks[SeaLevel-1] != 9 {
+		t.Errorf("expected water beneath the ice, got %d", blocks[SeaLevel-1])
+	}
+
+	blocks = make([]byte, ChunkSizeY)
+	setBiomeBlockStack(BiomeTundra, SeaLevel+10, blocks)
+
+	if blocks[SeaLevel+11] != 78 {
+		t.Errorf("expected snow on the surface of tundra, got %d", blocks[SeaLevel+11])
+	}
+}
+
+func TestNewGenerator(t *testing.T) {
+	if _, ok := mustGenerator(t, "").(*TestGenerator); !ok {
+		t.Errorf("expected an empty generator name to give the default generator")
+	}
+	if _, ok := mustGenerator(t, GeneratorBiome).(*BiomeGenerator); !ok {
+		t.Errorf("expected %q to give the biome generator", GeneratorBiome)
+	}
+	if _, ok := mustGenerator(t, GeneratorFlat).(*FlatGenerator); !ok {
+		t.Errorf("expected %q to give the flat generator", GeneratorFlat)
+	}
+	if _, ok := mustGenerator(t, GeneratorVoid).(*VoidGenerator); !ok {
+		t.Errorf("expected %q to give the void generator", GeneratorVoid)
+	}
+	if _, err := NewGenerator(GeneratorFlat, "nonsense", 0); err == nil {
+		t.Errorf("expected an error for bad generator options")
+	}
+	if _, err := NewGenerator("nonsense", "", 0); err == nil {
+		t.Errorf("expected an error for an unknown generator")
+	}
+}
+
+func mustGenerator(t *testing.T, name string) interface{} {
+	gen, err := NewGenerator(name, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gen
+}
@@ -0,0 +1,77 @@
+package generation
+
+import (
+	. "chunkymonkey/types"
+	"perlin"
+)
+
+const (
+	// Caves are carved where both of the cave noise sources are within
+	// caveThreshold of zero. Each source is zero on a winding surface, and
+	// where two such surfaces cross they form a tunnel.
+	caveThreshold = 0.06
+
+	// Caves are not carved into the bottom layers of the world, and caves at
+	// or below caveLavaLevel are filled with lava.
+	caveMinY      = 2
+	caveLavaLevel = 10
+)
+
+// caveCarver carves caves out of the stone in generated chunks. Whether a
+// block is carved depends only on its position, so caves continue across
+// chunk edges without seams.
+type caveCarver struct {
+	a, b ISource3d
+}
+
+func newCaveCarver(noise *perlin.PerlinNoise) *caveCarver {
+	return &caveCarver{
+		a: &Scale3d{
+			Wavelength:  40,
+			WavelengthY: 20,
+			Amplitude:   1,
+			Source:      &Offset3d{300.5, 0, 0, noise},
+		},
+		b: &Scale3d{
+			Wavelength:  40,
+			WavelengthY: 20,
+			Amplitude:   1,
+			Source:      &Offset3d{0, 300.5, 700.5, noise},
+		},
+	}
+}
+
+// carve replaces stone in the chunk with air, or with lava deep down, where
+// there are caves.
+func (c *caveCarver) carve(data *ChunkData) {
+	baseBlockXyz := data.loc.ChunkCornerBlockXY()
+	baseX, baseZ := float64(baseBlockXyz.X), float64(baseBlockXyz.Z)
+
+	baseIndex := 0
+	for x := 0; x < ChunkSizeH; x++ {
+		for z := 0; z < ChunkSizeH; z++ {
+			xf, zf := baseX+float64(x), baseZ+float64(z)
+			for y := caveMinY; y < ChunkSizeY; y++ {
+				index := baseIndex + y
+				if data.blocks[index] != 1 { // stone
+					continue
+				}
+
+				if !inCaveBand(c.a.At3d(xf, float64(y), zf)) || !inCaveBand(c.b.At3d(xf, float64(y), zf)) {
+					continue
+				}
+
+				if y <= caveLavaLevel {
+					data.blocks[index] = 11 // stationary lava
+				} else {
+					data.blocks[index] = 0 // air
+				}
+			}
+			baseIndex += ChunkSizeY
+		}
+	}
+}
+
+func inCaveBand(v float64) bool {
+	return v > -caveThreshold && v < caveThreshold
+}
@@ -9,19 +9,20 @@ import (
 	"nbt"
 	"perlin"
 	"rand"
-	"time"
 )
 
 const SeaLevel = 63
 
 // ChunkData implements chunkstore.IChunkReader.
 type ChunkData struct {
-	loc        ChunkXz
-	blocks     []byte
-	blockData  []byte
-	blockLight []byte
-	skyLight   []byte
-	heightMap  []byte
+	loc          ChunkXz
+	blocks       []byte
+	blockData    []byte
+	blockLight   []byte
+	skyLight     []byte
+	heightMap    []byte
+	tileEntities map[BlockIndex]gamerules.ITileEntity
+	populated    bool
 }
 
 func newChunkData(loc ChunkXz) *ChunkData {
@@ -32,6 +33,8 @@ func newChunkData(loc ChunkXz) *ChunkData {
 		skyLight:   make([]byte, (ChunkSizeH*ChunkSizeH*ChunkSizeY)>>1),
 		blockLight: make([]byte, (ChunkSizeH*ChunkSizeH*ChunkSizeY)>>1),
 		heightMap:  make([]byte, ChunkSizeH*ChunkSizeH),
+
+		tileEntities: make(map[BlockIndex]gamerules.ITileEntity),
 	}
 }
 
@@ -59,30 +62,48 @@ func (data *ChunkData) HeightMap() []byte {
 	return data.heightMap
 }
 
+func (data *ChunkData) TerrainPopulated() bool {
+	return data.populated
+}
+
 func (data *ChunkData) Entities() []gamerules.INonPlayerEntity {
 	return nil
 }
 
+func (data *ChunkData) TileEntities() (tileEntities []gamerules.ITileEntity) {
+	for _, tileEntity := range data.tileEntities {
+		tileEntities = append(tileEntities, tileEntity)
+	}
+	return
+}
+
 func (data *ChunkData) RootTag() nbt.ITag {
 	return nil
 }
 
-// TestGenerator implements chunkstore.IChunkStore.
+// chunkRand returns the random number generator for the random decisions made
+// when generating the chunk at loc. It depends only on the world seed and the
+// chunk location, so that a chunk is generated the same every time.
+func chunkRand(seed int64, loc ChunkXz) *rand.Rand {
+	chunkSeed := seed ^ int64(loc.X)*341873128712 ^ int64(loc.Z)*132897987541
+	return rand.New(rand.NewSource(chunkSeed))
+}
+
+// TestGenerator implements chunkstore.IChunkStoreForeground and
+// chunkstore.IPopulator.
 type TestGenerator struct {
+	seed         int64
 	heightSource ISource
-	randSource   rand.Source
-	randGen      *rand.Rand
+	caves        *caveCarver
+	populator    *populator
 }
 
 func NewTestGenerator(seed int64) *TestGenerator {
 	perlin := perlin.NewPerlinNoise(seed)
 
-	randSource := rand.NewSource(time.Nanoseconds())
-	randGen := rand.New(randSource)
-
-	return &TestGenerator{
-		randSource: randSource,
-		randGen:    randGen,
+	gen := &TestGenerator{
+		seed:  seed,
+		caves: newCaveCarver(perlin),
 		heightSource: &Sum{
 			Inputs: []ISource{
 				&Turbulence{
@@ -121,6 +142,9 @@ func NewTestGenerator(seed int64) *TestGenerator {
 			},
 		},
 	}
+	gen.populator = newPopulator(seed, gen)
+
+	return gen
 }
 
 func (s *TestGenerator) SupportsWrite() bool {
@@ -136,6 +160,19 @@ func (s *TestGenerator) WriteChunk(writer chunkstore.IChunkWriter) os.Error {
 }
 
 func (gen *TestGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkReader, err os.Error) {
+	return gen.populator.readChunk(chunkLoc)
+}
+
+func (gen *TestGenerator) Populate(loc ChunkXz, blocks, blockData [4][]byte) []chunkstore.BlockChange {
+	return gen.populator.populate(loc, blocks, blockData)
+}
+
+// The TestGenerator is decorated as a forest throughout.
+func (gen *TestGenerator) biomeAt(x, z BlockCoord) *Biome {
+	return BiomeForest
+}
+
+func (gen *TestGenerator) terrain(chunkLoc ChunkXz) *ChunkData {
 	baseBlockXyz := chunkLoc.ChunkCornerBlockXY()
 
 	baseX, baseZ := baseBlockXyz.X, baseBlockXyz.Z
@@ -143,7 +180,6 @@ func (gen *TestGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkR
 	data := newChunkData(chunkLoc)
 
 	baseIndex := BlockIndex(0)
-	heightMapIndex := 0
 	for x := 0; x < ChunkSizeH; x++ {
 		for z := 0; z < ChunkSizeH; z++ {
 			xf, zf := float64(x)+float64(baseX), float64(z)+float64(baseZ)
@@ -159,18 +195,16 @@ func (gen *TestGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkR
 				height,
 				data.blocks[baseIndex:baseIndex+ChunkSizeY])
 
-			data.heightMap[heightMapIndex] = byte(skyLightHeight)
+			data.heightMap[z<<ChunkHShift|x] = byte(skyLightHeight)
 
-			heightMapIndex++
 			baseIndex += ChunkSizeY
 		}
 	}
 
-	// The chunk has been generated, now add some trees if appropriate
-	gen.addSaplings(data)
-	gen.setSkylight(data)
+	gen.caves.carve(data)
+	addOres(data, gen.seed)
 
-	return data, nil
+	return data
 }
 
 func (gen *TestGenerator) setBlockStack(height int, blocks []byte) (skyLightHeight int) {
@@ -208,93 +242,129 @@ func (gen *TestGenerator) setBlockStack(height int, blocks []byte) (skyLightHeig
 	return
 }
 
-func (gen *TestGenerator) setSkyLightStack(skyLightHeight int, blocks []byte, skyLight []byte) {
-	for y := ChunkSizeY - 1; y >= skyLightHeight; y-- {
-		BlockIndex(y).SetBlockData(skyLight, 15)
-	}
-
-	var lightLevel int8 = 15
-
-	for y := skyLightHeight; y >= 0 && lightLevel > 0; y-- {
-		blockType, ok := gamerules.Blocks.Get(BlockId(blocks[y]))
-		if lightLevel > 0 && ok && blockType.Opacity > 0 {
-			lightLevel -= blockType.Opacity
+// lightChunk works out the height map, sky light and block light for a newly
+// generated chunk. Light is only spread within the chunk, and is filled in
+// across chunk edges as blocks change.
+func lightChunk(data *ChunkData) {
+	var opacity, luminance [256]byte
+	for id := range opacity {
+		if blockType, ok := gamerules.Blocks.Get(BlockId(id)); ok {
+			if blockType.Opacity > 0 {
+				opacity[id] = byte(blockType.Opacity)
+			}
+			if blockType.Luminance > 0 {
+				luminance[id] = byte(blockType.Luminance)
+			}
+		} else {
+			opacity[id] = 15
 		}
-
-		BlockIndex(y).SetBlockData(skyLight, byte(lightLevel))
 	}
-}
-
-func (gen *TestGenerator) setSkylight(data *ChunkData) {
-	baseIndex := 0
-	heightMapIndex := 0
 
+	// Blocks above the highest block that blocks light are fully lit by the
+	// sky.
+	var subLoc SubChunkXyz
 	for x := 0; x < ChunkSizeH; x++ {
 		for z := 0; z < ChunkSizeH; z++ {
-			lightBase := baseIndex >> 1
+			subLoc.X, subLoc.Z = SubChunkCoord(x), SubChunkCoord(z)
+			height := 0
+			for y := ChunkSizeY - 1; y >= 0; y-- {
+				subLoc.Y = SubChunkCoord(y)
+				index, _ := subLoc.BlockIndex()
+				if opacity[data.blocks[index]] > 0 {
+					height = y + 1
+					break
+				}
+			}
+			data.heightMap[z<<ChunkHShift|x] = byte(height)
 
-			gen.setSkyLightStack(
-				int(data.heightMap[heightMapIndex]),
-				data.blocks[baseIndex:baseIndex+ChunkSizeY],
-				data.skyLight[lightBase:lightBase+ChunkSizeY/2])
-			heightMapIndex++
-			baseIndex += ChunkSizeY
+			for y := height; y < ChunkSizeY; y++ {
+				subLoc.Y = SubChunkCoord(y)
+				index, _ := subLoc.BlockIndex()
+				index.SetBlockData(data.skyLight, 15)
+			}
 		}
 	}
 
-}
-
-func (gen *TestGenerator) addSaplings(data *ChunkData) {
-	baseIndex := 0
-	heightMapIndex := 0
-
-	// Move throughout the chunk, but refuse to create trees on chunk
-	// boundaries.
+	// Sky light spreads sideways from the parts of columns that are higher
+	// than their neighbours, and downwards from the top of each column.
+	var skyQueue []BlockIndex
 	for x := 0; x < ChunkSizeH; x++ {
 		for z := 0; z < ChunkSizeH; z++ {
-			topBlock := int(data.heightMap[heightMapIndex] - 1)
-			blockIndex := baseIndex + int(topBlock)
-
-			if data.blocks[blockIndex] == 2 {
-				// We could add a tree, check to see if we want to
-				addTree := gen.randGen.Intn(100) > 95
-				if addTree && x > 0 && x < ChunkSizeH-1 && z > 0 && z < ChunkSizeH-1 {
-					if !adjacentBlockIs(data, x, topBlock, z, 2, 2, 2, 6) {
-						// Check if an adjacent block has a sapling already
-						data.blocks[blockIndex+1] = 6
-					}
+			height := int(data.heightMap[z<<ChunkHShift|x])
+			top := height
+			for _, face := range horizontalFaces {
+				dx, _, dz := face.Dxyz()
+				nx, nz := x+int(dx), z+int(dz)
+				if nx < 0 || nx >= ChunkSizeH || nz < 0 || nz >= ChunkSizeH {
+					continue
+				}
+				if nHeight := int(data.heightMap[nz<<ChunkHShift|nx]); nHeight > top {
+					top = nHeight
 				}
 			}
 
-			heightMapIndex++
-			baseIndex += ChunkSizeY
+			subLoc.X, subLoc.Z = SubChunkCoord(x), SubChunkCoord(z)
+			for y := height; y <= top && y < ChunkSizeY; y++ {
+				subLoc.Y = SubChunkCoord(y)
+				index, _ := subLoc.BlockIndex()
+				skyQueue = append(skyQueue, index)
+			}
+		}
+	}
+	spreadChunkLight(data.skyLight, data.blocks, &opacity, skyQueue)
+
+	// Block light spreads from blocks that give off light.
+	var blockQueue []BlockIndex
+	for i, id := range data.blocks {
+		if luminance[id] > 0 {
+			index := BlockIndex(i)
+			index.SetBlockData(data.blockLight, luminance[id])
+			blockQueue = append(blockQueue, index)
 		}
 	}
+	spreadChunkLight(data.blockLight, data.blocks, &opacity, blockQueue)
 }
 
-// adjacentBlockIs return whether or not at least one block adjacent to
-// (bx,by,bz) is of type 'blockType'. The area which this function checks is
-// specified by dx,dy,dz. Blocks outside the given chunk are not checked.
-func adjacentBlockIs(data *ChunkData, bx, by, bz, dx, dy, dz int, blockType byte) bool {
-	subChunk := new(SubChunkXyz)
-
-	for x := bx - dx; x <= bx+dx; x++ {
-		for z := bz - dz; z <= bz+dz; z++ {
-			for y := by - dy; y <= by+dy; y++ {
-				if x != bx || y != by || z != bz {
-					subChunk.X = SubChunkCoord(x)
-					subChunk.Y = SubChunkCoord(y)
-					subChunk.Z = SubChunkCoord(z)
-					index, ok := subChunk.BlockIndex()
-					if ok {
-						if data.blocks[index] == blockType {
-							return true
-						}
-					}
-				}
+// The faces that are next to a block horizontally.
+var horizontalFaces = []Face{FaceEast, FaceWest, FaceNorth, FaceSouth}
+
+// spreadChunkLight spreads light outwards from the blocks in queue, within a
+// single chunk. Light is reduced by at least 1 for each block that it passes
+// into, or by the opacity of the block.
+func spreadChunkLight(light []byte, blocks []byte, opacity *[256]byte, queue []BlockIndex) {
+	for len(queue) > 0 {
+		index := queue[0]
+		queue = queue[1:]
+
+		level := index.BlockData(light)
+		if level <= 1 {
+			continue
+		}
+
+		subLoc := index.ToSubChunkXyz()
+		for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+			dx, dy, dz := face.Dxyz()
+			neighbourLoc := SubChunkXyz{
+				X: SubChunkCoord(int(subLoc.X) + int(dx)),
+				Y: SubChunkCoord(int(subLoc.Y) + int(dy)),
+				Z: SubChunkCoord(int(subLoc.Z) + int(dz)),
+			}
+			neighbour, ok := neighbourLoc.BlockIndex()
+			if !ok {
+				continue
+			}
+
+			attenuation := opacity[blocks[neighbour]]
+			if attenuation < 1 {
+				attenuation = 1
+			}
+			if level <= attenuation {
+				continue
+			}
+			if newLevel := level - attenuation; newLevel > neighbour.BlockData(light) {
+				neighbour.SetBlockData(light, newLevel)
+				queue = append(queue, neighbour)
 			}
 		}
 	}
-
-	return false
 }
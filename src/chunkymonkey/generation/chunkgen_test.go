@@ -1,11 +1,170 @@
 package generation
 
 import (
+	"bytes"
 	"testing"
 
+	"chunkymonkey/chunkstore"
 	. "chunkymonkey/types"
+	"perlin"
 )
 
+// generateAll generates the chunks at locs with gen, in the order given.
+func generateAll(t *testing.T, gen chunkstore.IChunkStoreForeground, locs []ChunkXz) map[uint64]*ChunkData {
+	chunks := make(map[uint64]*ChunkData)
+	for _, loc := range locs {
+		reader, err := gen.ReadChunk(loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks[loc.ChunkKey()] = reader.(*ChunkData)
+	}
+	return chunks
+}
+
+// checkDeterministic checks that generators created by newGenerator with the
+// same seed generate the same chunks.
+func checkDeterministic(t *testing.T, newGenerator func(seed int64) chunkstore.IChunkStoreForeground) {
+	locs := []ChunkXz{
+		{0, 0},
+		{1, 0},
+		{-5, 3},
+		{100, -100},
+	}
+	reversed := make([]ChunkXz, len(locs))
+	for i, loc := range locs {
+		reversed[len(locs)-1-i] = loc
+	}
+
+	// The chunks are generated in a different order by each generator, so that
+	// a chunk cannot depend on which chunks were generated before it.
+	chunksA := generateAll(t, newGenerator(1234), locs)
+	chunksB := generateAll(t, newGenerator(1234), reversed)
+
+	for _, loc := range locs {
+		a := chunksA[loc.ChunkKey()]
+		b := chunksB[loc.ChunkKey()]
+
+		type Field struct {
+			name string
+			a, b []byte
+		}
+		fields := []Field{
+			{"blocks", a.blocks, b.blocks},
+			{"blockData", a.blockData, b.blockData},
+			{"blockLight", a.blockLight, b.blockLight},
+			{"skyLight", a.skyLight, b.skyLight},
+			{"heightMap", a.heightMap, b.heightMap},
+		}
+		for _, field := range fields {
+			if !bytes.Equal(field.a, field.b) {
+				t.Errorf("chunk %v: %s differs between runs with the same seed", loc, field.name)
+			}
+		}
+		if a.TerrainPopulated() {
+			t.Errorf("chunk %v: expected generated chunk to be left for the shard to populate", loc)
+		}
+	}
+}
+
+func TestTestGenerator_Deterministic(t *testing.T) {
+	checkDeterministic(t, func(seed int64) chunkstore.IChunkStoreForeground {
+		return NewTestGenerator(seed)
+	})
+}
+
+func TestBiomeGenerator_Deterministic(t *testing.T) {
+	checkDeterministic(t, func(seed int64) chunkstore.IChunkStoreForeground {
+		return NewBiomeGenerator(seed)
+	})
+}
+
+func TestChunkRand(t *testing.T) {
+	a := chunkRand(1234, ChunkXz{3, -7}).Int63()
+	if b := chunkRand(1234, ChunkXz{3, -7}).Int63(); a != b {
+		t.Errorf("expected the same value for the same seed and chunk, got %d and %d", a, b)
+	}
+	if b := chunkRand(1234, ChunkXz{-7, 3}).Int63(); a == b {
+		t.Errorf("expected different values for different chunks, got %d for both", a)
+	}
+	if b := chunkRand(4321, ChunkXz{3, -7}).Int63(); a == b {
+		t.Errorf("expected different values for different seeds, got %d for both", a)
+	}
+}
+
+// stoneChunk returns a chunk that is entirely stone.
+func stoneChunk(loc ChunkXz) *ChunkData {
+	data := newChunkData(loc)
+	for i := range data.blocks {
+		data.blocks[i] = 1
+	}
+	return data
+}
+
+func TestAddOres(t *testing.T) {
+	counts := make(map[byte]int)
+	for x := 0; x < 4; x++ {
+		for z := 0; z < 4; z++ {
+			data := stoneChunk(ChunkXz{ChunkCoord(x), ChunkCoord(z)})
+			addOres(data, 1234)
+
+			for i, blockType := range data.blocks {
+				counts[blockType]++
+
+				// Veins can wander a little above the height at which they start.
+				y := BlockIndex(i).ToSubChunkXyz().Y
+				for _, ore := range oreTypes {
+					if blockType == ore.block && int(y) >= ore.maxY+ore.veinSize {
+						t.Errorf("ore %d found at y=%d, above its range", blockType, y)
+					}
+				}
+			}
+		}
+	}
+
+	for _, ore := range oreTypes {
+		if counts[ore.block] == 0 {
+			t.Errorf("expected ore %d in 16 chunks, found none", ore.block)
+		}
+	}
+	if counts[16] <= counts[56] {
+		t.Errorf("expected more coal than diamond, got %d coal and %d diamond", counts[16], counts[56])
+	}
+}
+
+func TestCaveCarver(t *testing.T) {
+	carver := newCaveCarver(perlin.NewPerlinNoise(1234))
+
+	var air, lava int
+	for x := 0; x < 4; x++ {
+		data := stoneChunk(ChunkXz{ChunkCoord(x), 0})
+		carver.carve(data)
+
+		for i, blockType := range data.blocks {
+			y := int(BlockIndex(i).ToSubChunkXyz().Y)
+			switch blockType {
+			case 0:
+				air++
+				if y <= caveLavaLevel {
+					t.Errorf("expected lava, not air, at y=%d", y)
+				}
+			case 11:
+				lava++
+			}
+			if y < caveMinY && blockType != 1 {
+				t.Errorf("expected stone at y=%d, got %d", y, blockType)
+			}
+		}
+	}
+
+	if air == 0 {
+		t.Errorf("expected caves in 4 chunks, found none")
+	}
+	if total := 4 * len(stoneChunk(ChunkXz{}).blocks); air+lava > total/4 {
+		t.Errorf("expected caves to be sparse, got %d of %d blocks carved", air+lava, total)
+	}
+}
+
 func Benchmark_TestGenerator_generate(b *testing.B) {
 	gen := NewTestGenerator(0)
 	var loc ChunkXz
@@ -0,0 +1,177 @@
+package generation
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"chunkymonkey/chunkstore"
+	. "chunkymonkey/types"
+)
+
+// DefaultFlatLayers is the layer specification used by the flat generator
+// when none is given: bedrock, two layers of dirt and grass.
+const DefaultFlatLayers = "7,2x3,2"
+
+// FlatLayer is a layer of blocks in a flat world.
+type FlatLayer struct {
+	BlockId   byte
+	BlockData byte
+	Thickness int
+}
+
+// ParseFlatLayers parses a specification of the layers of a flat world. The
+// layers are separated by commas, from the bottom of the world upwards. Each
+// layer is a block ID, optionally followed by ":" and the block data, and
+// optionally preceded by the thickness of the layer and "x". For example,
+// "7,2x3,2" is a layer of bedrock, two layers of dirt and a layer of grass.
+func ParseFlatLayers(spec string) (layers []FlatLayer, err os.Error) {
+	height := 0
+	for _, layerSpec := range strings.Split(spec, ",") {
+		layerSpec = strings.TrimSpace(layerSpec)
+
+		layer := FlatLayer{Thickness: 1}
+		if i := strings.Index(layerSpec, "x"); i >= 0 {
+			if layer.Thickness, err = strconv.Atoi(layerSpec[:i]); err != nil || layer.Thickness < 1 {
+				return nil, BadGeneratorOptions(spec)
+			}
+			layerSpec = layerSpec[i+1:]
+		}
+
+		if layer.BlockId, layer.BlockData, err = parseBlockSpec(layerSpec); err != nil {
+			return nil, BadGeneratorOptions(spec)
+		}
+
+		height += layer.Thickness
+		layers = append(layers, layer)
+	}
+
+	if height > ChunkSizeY {
+		return nil, BadGeneratorOptions(spec)
+	}
+
+	return layers, nil
+}
+
+// parseBlockSpec parses a block ID, optionally followed by ":" and the block
+// data, e.g "35:14".
+func parseBlockSpec(spec string) (blockId, blockData byte, err os.Error) {
+	idSpec, dataSpec := spec, "0"
+	if i := strings.Index(spec, ":"); i >= 0 {
+		idSpec, dataSpec = spec[:i], spec[i+1:]
+	}
+
+	id, err := strconv.Atoi(idSpec)
+	if err != nil {
+		return
+	}
+	data, err := strconv.Atoi(dataSpec)
+	if err != nil {
+		return
+	}
+	if id < 0 || id > 255 || data < 0 || data > 15 {
+		err = os.NewError("block ID or data out of range")
+		return
+	}
+
+	return byte(id), byte(data), nil
+}
+
+// FlatGenerator generates a flat world of the same layers of blocks
+// everywhere. It implements chunkstore.IChunkStoreForeground.
+type FlatGenerator struct {
+	layers []FlatLayer
+	height int
+
+	// template is generated on first use, and copied for each chunk.
+	template *ChunkData
+}
+
+// NewFlatGenerator creates a flat generator from a layer specification, as
+// parsed by ParseFlatLayers. An empty specification gives DefaultFlatLayers.
+func NewFlatGenerator(spec string) (gen *FlatGenerator, err os.Error) {
+	if spec == "" {
+		spec = DefaultFlatLayers
+	}
+
+	layers, err := ParseFlatLayers(spec)
+	if err != nil {
+		return
+	}
+
+	gen = &FlatGenerator{layers: layers}
+	for _, layer := range layers {
+		gen.height += layer.Thickness
+	}
+
+	return
+}
+
+func (gen *FlatGenerator) SupportsWrite() bool {
+	return false
+}
+
+func (gen *FlatGenerator) Writer() chunkstore.IChunkWriter {
+	return nil
+}
+
+func (gen *FlatGenerator) WriteChunk(writer chunkstore.IChunkWriter) os.Error {
+	return os.NewError("writes not supported by FlatGenerator")
+}
+
+func (gen *FlatGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkReader, err os.Error) {
+	if gen.template == nil {
+		gen.template = gen.generateTemplate()
+	}
+
+	return gen.template.cloneAt(chunkLoc), nil
+}
+
+// SpawnPosition returns the block that players first spawn at in a new world,
+// on top of the layers.
+func (gen *FlatGenerator) SpawnPosition() BlockXyz {
+	y := gen.height
+	if y >= ChunkSizeY {
+		y = ChunkSizeY - 1
+	}
+	return BlockXyz{0, BlockYCoord(y), 0}
+}
+
+func (gen *FlatGenerator) generateTemplate() *ChunkData {
+	data := newChunkData(ChunkXz{0, 0})
+
+	var subLoc SubChunkXyz
+	for x := 0; x < ChunkSizeH; x++ {
+		for z := 0; z < ChunkSizeH; z++ {
+			subLoc.X, subLoc.Z = SubChunkCoord(x), SubChunkCoord(z)
+			y := 0
+			for _, layer := range gen.layers {
+				for i := 0; i < layer.Thickness; i++ {
+					subLoc.Y = SubChunkCoord(y)
+					index, _ := subLoc.BlockIndex()
+					data.blocks[index] = layer.BlockId
+					index.SetBlockData(data.blockData, layer.BlockData)
+					y++
+				}
+			}
+		}
+	}
+
+	lightChunk(data)
+	data.populated = true
+
+	return data
+}
+
+// cloneAt returns a copy of the chunk data, at a different location. The
+// chunk must not have tile entities.
+func (data *ChunkData) cloneAt(loc ChunkXz) *ChunkData {
+	clone := newChunkData(loc)
+	copy(clone.blocks, data.blocks)
+	copy(clone.blockData, data.blockData)
+	copy(clone.blockLight, data.blockLight)
+	copy(clone.skyLight, data.skyLight)
+	copy(clone.heightMap, data.heightMap)
+	clone.populated = data.populated
+	return clone
+}
@@ -0,0 +1,131 @@
+package generation
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestParseFlatLayers(t *testing.T) {
+	type Test struct {
+		spec   string
+		layers []FlatLayer
+	}
+
+	tests := []Test{
+		{"7", []FlatLayer{{7, 0, 1}}},
+		{"7,2x3,2", []FlatLayer{{7, 0, 1}, {3, 0, 2}, {2, 0, 1}}},
+		{"7, 60x1, 35:14", []FlatLayer{{7, 0, 1}, {1, 0, 60}, {35, 14, 1}}},
+		{"128x1", []FlatLayer{{1, 0, 128}}},
+	}
+
+	for _, test := range tests {
+		layers, err := ParseFlatLayers(test.spec)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.spec, err)
+			continue
+		}
+		if len(layers) != len(test.layers) {
+			t.Errorf("%q: expected %v, got %v", test.spec, test.layers, layers)
+			continue
+		}
+		for i, layer := range layers {
+			expected := test.layers[i]
+			if layer.BlockId != expected.BlockId || layer.BlockData != expected.BlockData || layer.Thickness != expected.Thickness {
+				t.Errorf("%q: expected %v, got %v", test.spec, test.layers, layers)
+				break
+			}
+		}
+	}
+
+	bad := []string{"", "stone", "256", "1:16", "0x1", "x1", "1,", "7,128x1"}
+	for _, spec := range bad {
+		if _, err := ParseFlatLayers(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestFlatGenerator(t *testing.T) {
+	gen, err := NewFlatGenerator("7,2x3,35:14")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spawn := gen.SpawnPosition(); spawn.Y != 4 {
+		t.Errorf("expected spawn on top of the layers at y=4, got %v", spawn)
+	}
+
+	expected := []byte{7, 3, 3, 35, 0}
+	for _, loc := range []ChunkXz{{0, 0}, {-3, 5}} {
+		reader, err := gen.ReadChunk(loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := reader.(*ChunkData)
+
+		if chunkLoc := data.ChunkLoc(); chunkLoc.X != loc.X || chunkLoc.Z != loc.Z {
+			t.Errorf("expected chunk at %v, got %v", loc, data.ChunkLoc())
+		}
+		if !data.TerrainPopulated() {
+			t.Errorf("chunk %v: expected flat chunk to be populated", loc)
+		}
+
+		for y, blockId := range expected {
+			subLoc := SubChunkXyz{5, SubChunkCoord(y), 9}
+			index, _ := subLoc.BlockIndex()
+			if data.blocks[index] != blockId {
+				t.Errorf("chunk %v: expected block %d at y=%d, got %d", loc, blockId, y, data.blocks[index])
+			}
+		}
+		subLoc := SubChunkXyz{5, 3, 9}
+		index, _ := subLoc.BlockIndex()
+		if blockData := index.BlockData(data.blockData); blockData != 14 {
+			t.Errorf("chunk %v: expected block data 14 at y=3, got %d", loc, blockData)
+		}
+	}
+}
+
+func TestVoidGenerator(t *testing.T) {
+	gen, err := NewVoidGenerator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, _ := gen.ReadChunk(ChunkXz{0, 0})
+	for i, blockId := range reader.Blocks() {
+		if blockId != 0 {
+			t.Fatalf("expected an empty chunk, got block %d at %v", blockId, BlockIndex(i).ToSubChunkXyz())
+		}
+	}
+
+	if _, err = NewVoidGenerator("stone"); err == nil {
+		t.Errorf("expected an error for a bad platform block")
+	}
+
+	gen, err = NewVoidGenerator("20")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var platform int
+	for _, loc := range []ChunkXz{{-1, -1}, {-1, 0}, {0, -1}, {0, 0}, {1, 0}} {
+		reader, _ := gen.ReadChunk(loc)
+		for i, blockId := range reader.Blocks() {
+			if blockId == 0 {
+				continue
+			}
+			if blockId != 20 || BlockIndex(i).ToSubChunkXyz().Y != voidPlatformY {
+				t.Errorf("chunk %v: unexpected block %d at %v", loc, blockId, BlockIndex(i).ToSubChunkXyz())
+			}
+			platform++
+		}
+	}
+
+	size := 2*voidPlatformRadius + 1
+	if platform != size*size {
+		t.Errorf("expected a platform of %d blocks, got %d", size*size, platform)
+	}
+	if spawn := gen.SpawnPosition(); spawn.Y != voidPlatformY+1 {
+		t.Errorf("expected spawn above the platform, got %v", spawn)
+	}
+}
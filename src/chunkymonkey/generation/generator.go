@@ -0,0 +1,63 @@
+package generation
+
+import (
+	"fmt"
+	"os"
+
+	"chunkymonkey/chunkstore"
+	. "chunkymonkey/types"
+)
+
+// Names of the world generators, as stored in the generatorName tag of
+// level.dat.
+const (
+	GeneratorDefault = "default"
+	GeneratorBiome   = "biome"
+	GeneratorFlat    = "flat"
+	GeneratorVoid    = "void"
+)
+
+// ISpawnPositioner is implemented by generators that know where players should
+// first spawn in a new world.
+type ISpawnPositioner interface {
+	SpawnPosition() BlockXyz
+}
+
+// NewGenerator creates the world generator with the given name. An empty name
+// is taken to be GeneratorDefault, for worlds that do not name one. options
+// configures generators that take options, as stored in the generatorOptions
+// tag of level.dat: the layers of a flat world (see ParseFlatLayers), or the
+// block of the spawn platform in a void world (empty for none).
+func NewGenerator(name, options string, seed int64) (gen chunkstore.IChunkStoreForeground, err os.Error) {
+	switch name {
+	case "", GeneratorDefault:
+		gen = NewTestGenerator(seed)
+	case GeneratorBiome:
+		gen = NewBiomeGenerator(seed)
+	case GeneratorFlat:
+		var flat *FlatGenerator
+		if flat, err = NewFlatGenerator(options); err == nil {
+			gen = flat
+		}
+	case GeneratorVoid:
+		var void *VoidGenerator
+		if void, err = NewVoidGenerator(options); err == nil {
+			gen = void
+		}
+	default:
+		err = UnknownGenerator(name)
+	}
+	return
+}
+
+type UnknownGenerator string
+
+func (err UnknownGenerator) String() string {
+	return fmt.Sprintf("Unknown world generator %q", string(err))
+}
+
+type BadGeneratorOptions string
+
+func (err BadGeneratorOptions) String() string {
+	return fmt.Sprintf("Bad world generator options %q", string(err))
+}
@@ -0,0 +1,223 @@
+package generation
+
+import (
+	"os"
+
+	"chunkymonkey/chunkstore"
+	. "chunkymonkey/types"
+	"perlin"
+)
+
+const (
+	netherSeedSalt    = 0x6e657468
+	glowstoneSeedSalt = 0x676c6f77
+
+	// Air pockets below netherLavaLevel are filled with lava.
+	netherLavaLevel = 31
+
+	// Netherrack is solid where the density is above netherThreshold. The
+	// density rises by netherClosing for each block within netherFloorDepth of
+	// the bottom of the world or netherCeilingDepth of the top, so that the
+	// caverns close off there.
+	netherThreshold    = -0.1
+	netherClosing      = 0.15
+	netherFloorDepth   = 8
+	netherCeilingDepth = 10
+
+	// Bedrock reaches up to netherBedrockDepth blocks into the world from the
+	// bottom and the top, with fewer blocks further in.
+	netherBedrockDepth = 4
+
+	// Soul sand and gravel cover the netherrack within netherShoreDepth blocks
+	// of the level of the lava sea.
+	netherShoreDepth = 4
+
+	// Clusters of glowstone, of up to glowstoneSize blocks, hang from the
+	// ceilings of caverns. glowstoneTries places are tried in each chunk.
+	glowstoneTries = 10
+	glowstoneSize  = 40
+)
+
+// NetherGenerator generates the caverns of netherrack, lava, soul sand and
+// glowstone of the Nether. It implements chunkstore.IChunkStoreForeground.
+type NetherGenerator struct {
+	seed     int64
+	density  []ISource3d
+	soulSand ISource
+	gravel   ISource
+}
+
+func NewNetherGenerator(seed int64) *NetherGenerator {
+	perlin := perlin.NewPerlinNoise(seed ^ netherSeedSalt)
+
+	return &NetherGenerator{
+		seed: seed,
+		density: []ISource3d{
+			&Scale3d{
+				Wavelength:  60,
+				WavelengthY: 30,
+				Amplitude:   1,
+				Source:      perlin,
+			},
+			&Scale3d{
+				Wavelength:  20,
+				WavelengthY: 12,
+				Amplitude:   0.3,
+				Source:      &Offset3d{100.5, 0, 50.5, perlin},
+			},
+		},
+		soulSand: &Scale{
+			Wavelength: 30,
+			Amplitude:  1,
+			Source:     &Offset{500.5, 0, perlin},
+		},
+		gravel: &Scale{
+			Wavelength: 30,
+			Amplitude:  1,
+			Source:     &Offset{0, 500.5, perlin},
+		},
+	}
+}
+
+func (gen *NetherGenerator) SupportsWrite() bool {
+	return false
+}
+
+func (gen *NetherGenerator) Writer() chunkstore.IChunkWriter {
+	return nil
+}
+
+func (gen *NetherGenerator) WriteChunk(writer chunkstore.IChunkWriter) os.Error {
+	return os.NewError("writes not supported by NetherGenerator")
+}
+
+func (gen *NetherGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkReader, err os.Error) {
+	data := newChunkData(chunkLoc)
+	rnd := chunkRand(gen.seed^netherSeedSalt, chunkLoc)
+
+	corner := chunkLoc.ChunkCornerBlockXY()
+	baseX, baseZ := int(corner.X), int(corner.Z)
+
+	baseIndex := 0
+	for x := 0; x < ChunkSizeH; x++ {
+		for z := 0; z < ChunkSizeH; z++ {
+			blocks := data.blocks[baseIndex : baseIndex+ChunkSizeY]
+			gen.setBlockStack(baseX+x, baseZ+z, blocks)
+
+			for y := 0; y < netherBedrockDepth; y++ {
+				if rnd.Intn(netherBedrockDepth+1) >= y {
+					blocks[y] = 7              // bedrock
+					blocks[ChunkSizeY-1-y] = 7 // bedrock
+				}
+			}
+
+			baseIndex += ChunkSizeY
+		}
+	}
+
+	gen.addGlowstone(data)
+
+	lightChunk(data)
+	data.populated = true
+
+	return data, nil
+}
+
+// solidAt returns true if there is netherrack (or bedrock) at the given
+// position, before anything else is added.
+func (gen *NetherGenerator) solidAt(x, y, z int) bool {
+	if y <= 0 || y >= ChunkSizeY-1 {
+		return true
+	}
+
+	xf, yf, zf := float64(x), float64(y), float64(z)
+	var density float64
+	for _, source := range gen.density {
+		density += source.At3d(xf, yf, zf)
+	}
+
+	if y < netherFloorDepth {
+		density += float64(netherFloorDepth-y) * netherClosing
+	} else if top := ChunkSizeY - 1 - netherCeilingDepth; y > top {
+		density += float64(y-top) * netherClosing
+	}
+
+	return density > netherThreshold
+}
+
+// setBlockStack fills in the column of blocks at x, z.
+func (gen *NetherGenerator) setBlockStack(x, z int, blocks []byte) {
+	var shoreBlock byte
+	xf, zf := float64(x), float64(z)
+	if gen.soulSand.At2d(xf, zf) > 0.3 {
+		shoreBlock = 88 // soul sand
+	} else if gen.gravel.At2d(xf, zf) > 0.4 {
+		shoreBlock = 13 // gravel
+	}
+
+	aboveSolid := true
+	for y := ChunkSizeY - 1; y >= 0; y-- {
+		solid := gen.solidAt(x, y, z)
+		switch {
+		case solid && !aboveSolid && shoreBlock != 0 &&
+			y >= netherLavaLevel-netherShoreDepth && y <= netherLavaLevel+netherShoreDepth:
+			blocks[y] = shoreBlock
+		case solid:
+			blocks[y] = 87 // netherrack
+		case y <= netherLavaLevel:
+			blocks[y] = 11 // stationary lava
+		}
+		aboveSolid = solid
+	}
+}
+
+// addGlowstone adds the clusters of glowstone that hang in the chunk. The
+// clusters may start in the neighbouring chunks and reach into this one. The
+// same random numbers are used whether or not the blocks are within this
+// chunk, so clusters continue across chunk edges.
+func (gen *NetherGenerator) addGlowstone(data *ChunkData) {
+	corner := data.loc.ChunkCornerBlockXY()
+	baseX, baseZ := int(corner.X), int(corner.Z)
+
+	for dx := -1; dx <= 1; dx++ {
+		for dz := -1; dz <= 1; dz++ {
+			loc := ChunkXz{data.loc.X + ChunkCoord(dx), data.loc.Z + ChunkCoord(dz)}
+			rnd := chunkRand(gen.seed^netherSeedSalt^glowstoneSeedSalt, loc)
+
+			for try := 0; try < glowstoneTries; try++ {
+				x := dx*ChunkSizeH + rnd.Intn(ChunkSizeH)
+				y := netherLavaLevel + rnd.Intn(ChunkSizeY-netherLavaLevel-2)
+				z := dz*ChunkSizeH + rnd.Intn(ChunkSizeH)
+				size := 1 + rnd.Intn(glowstoneSize)
+
+				// Clusters only start just beneath a ceiling.
+				if gen.solidAt(baseX+x, y, baseZ+z) || !gen.solidAt(baseX+x, y+1, baseZ+z) {
+					continue
+				}
+
+				for block := 0; block < size; block++ {
+					if y > netherLavaLevel && !gen.solidAt(baseX+x, y, baseZ+z) {
+						setInChunk(data, x, y, z, 89) // glowstone
+					}
+
+					// The cluster wanders, mostly downwards.
+					face := Face(FaceMinValid + rnd.Intn(FaceMaxValid-FaceMinValid+1))
+					if face == FaceTop {
+						face = FaceBottom
+					}
+					fdx, fdy, fdz := face.Dxyz()
+					x, y, z = x+int(fdx), y+int(fdy), z+int(fdz)
+				}
+			}
+		}
+	}
+}
+
+// setInChunk sets the block at the given position within the chunk. Positions
+// outside the chunk are ignored.
+func setInChunk(data *ChunkData, x, y, z int, blockType byte) {
+	if x < 0 || x >= ChunkSizeH || z < 0 || z >= ChunkSizeH || y < 0 || y >= ChunkSizeY {
+		return
+	}
+	data.blocks[(x*ChunkSizeH+z)*ChunkSizeY+y] = blockType
+}
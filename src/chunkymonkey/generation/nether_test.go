@@ -0,0 +1,51 @@
+package generation
+
+import (
+	"testing"
+
+	"chunkymonkey/chunkstore"
+	. "chunkymonkey/types"
+)
+
+func TestNetherGenerator_Deterministic(t *testing.T) {
+	checkDeterministic(t, func(seed int64) chunkstore.IChunkStoreForeground {
+		return NewNetherGenerator(seed)
+	})
+}
+
+func TestNetherGenerator(t *testing.T) {
+	gen := NewNetherGenerator(1234)
+
+	counts := make(map[byte]int)
+	for x := 0; x < 4; x++ {
+		for z := 0; z < 4; z++ {
+			reader, err := gen.ReadChunk(ChunkXz{ChunkCoord(x), ChunkCoord(z)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, blockType := range reader.Blocks() {
+				counts[blockType]++
+
+				y := int(BlockIndex(i).ToSubChunkXyz().Y)
+				switch {
+				case (y == 0 || y == ChunkSizeY-1) && blockType != 7:
+					t.Errorf("expected bedrock at y=%d, got %d", y, blockType)
+				case blockType == 11 && y > netherLavaLevel:
+					t.Errorf("expected no lava above y=%d, got some at y=%d", netherLavaLevel, y)
+				case blockType == 0 && y <= netherLavaLevel:
+					t.Errorf("expected no air at or below y=%d, got some at y=%d", netherLavaLevel, y)
+				}
+			}
+		}
+	}
+
+	for _, blockType := range []byte{0, 11, 87, 89} {
+		if counts[blockType] == 0 {
+			t.Errorf("expected block %d in 16 chunks, found none", blockType)
+		}
+	}
+	if counts[87] <= counts[89] {
+		t.Errorf("expected more netherrack than glowstone, got %d netherrack and %d glowstone", counts[87], counts[89])
+	}
+}
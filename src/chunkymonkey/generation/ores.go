@@ -0,0 +1,77 @@
+package generation
+
+import (
+	. "chunkymonkey/types"
+)
+
+// oreSeedSalt is mixed into the world seed for the random numbers used to
+// place ores, so that they do not follow the other random decisions made for
+// the chunk.
+const oreSeedSalt = 0x5eed0fe5
+
+// oreType describes how often and how deep an ore is found.
+type oreType struct {
+	block         byte
+	veinsPerChunk int
+	veinSize      int
+	// Veins start at heights from minY up to but not including maxY.
+	minY, maxY int
+}
+
+// oreTypes follows the distribution of ores in the Notchian server.
+var oreTypes = []oreType{
+	{16, 20, 16, 0, 128}, // coal
+	{15, 20, 8, 0, 64},   // iron
+	{14, 2, 8, 0, 32},    // gold
+	{73, 8, 7, 0, 16},    // redstone
+	{56, 1, 7, 0, 16},    // diamond
+	{21, 1, 6, 0, 32},    // lapis lazuli
+}
+
+// addOres places ore veins in the stone of the chunk. The veins that start in
+// each chunk depend only on the world seed and that chunk's location. A vein
+// can reach at most veinSize blocks from where it starts, which is less than
+// a chunk, so the veins from the chunk and its neighbours are placed, keeping
+// only the blocks within this chunk. In this way veins cross chunk edges
+// without seams.
+func addOres(data *ChunkData, seed int64) {
+	for dx := -1; dx <= 1; dx++ {
+		for dz := -1; dz <= 1; dz++ {
+			loc := ChunkXz{data.loc.X + ChunkCoord(dx), data.loc.Z + ChunkCoord(dz)}
+			rnd := chunkRand(seed^oreSeedSalt, loc)
+
+			for i := range oreTypes {
+				ore := &oreTypes[i]
+				for vein := 0; vein < ore.veinsPerChunk; vein++ {
+					x := dx*ChunkSizeH + rnd.Intn(ChunkSizeH)
+					y := ore.minY + rnd.Intn(ore.maxY-ore.minY)
+					z := dz*ChunkSizeH + rnd.Intn(ChunkSizeH)
+
+					// The vein wanders from block to block. The same random
+					// numbers are used whether or not the blocks are within
+					// this chunk.
+					for block := 0; block < ore.veinSize; block++ {
+						setIfStone(data, x, y, z, ore.block)
+
+						face := Face(FaceMinValid + rnd.Intn(FaceMaxValid-FaceMinValid+1))
+						fdx, fdy, fdz := face.Dxyz()
+						x, y, z = x+int(fdx), y+int(fdy), z+int(fdz)
+					}
+				}
+			}
+		}
+	}
+}
+
+// setIfStone sets the block at the given position within the chunk to
+// blockType if it is stone. Positions outside the chunk are ignored.
+func setIfStone(data *ChunkData, x, y, z int, blockType byte) {
+	if x < 0 || x >= ChunkSizeH || y < 0 || y >= ChunkSizeY || z < 0 || z >= ChunkSizeH {
+		return
+	}
+
+	subLoc := SubChunkXyz{SubChunkCoord(x), SubChunkCoord(y), SubChunkCoord(z)}
+	if index, ok := subLoc.BlockIndex(); ok && data.blocks[index] == 1 { // stone
+		data.blocks[index] = blockType
+	}
+}
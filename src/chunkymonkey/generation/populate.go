@@ -0,0 +1,671 @@
+package generation
+
+import (
+	"log"
+	"os"
+	"rand"
+
+	"chunkymonkey/chunkstore"
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+// Chunks are generated in two phases. First the terrain of the chunk is
+// generated: its land, water, caves and ores. Then the terrain is decorated by
+// population passes, which place lakes, dungeons, trees and other plants.
+//
+// The population pass for the chunk at (x, z) decorates the columns from the
+// middle of that chunk to the middle of the chunk at (x+1, z+1), so that its
+// decorations can spread into the three neighbouring chunks at (x+1, z),
+// (x, z+1) and (x+1, z+1). The generator only makes the terrain. The shard
+// that the chunk is in runs the pass once all four chunks exist, and applies
+// the changes that it makes to them, which marks the chunk as populated.
+
+const (
+	// populationSeedSalt is mixed into the world seed for the random numbers
+	// used by population passes.
+	populationSeedSalt = 0x7095eed
+
+	// The size of the area that a pass can change, and the offset and size of
+	// the columns that it decorates within it.
+	populationAreaSize = 2 * ChunkSizeH
+	decoratedOffset    = ChunkSizeH / 2
+	decoratedSize      = ChunkSizeH
+
+	// Chances of a lake in each pass.
+	waterLakeChance = 4 // 1 in waterLakeChance
+	lavaLakeChance  = 8 // 1 in lavaLakeChance
+
+	// The size of the box that a lake is carved within, and how deep the
+	// liquid in it is.
+	lakeSize        = 12
+	lakeHeight      = 8
+	lakeLiquidDepth = 4
+
+	// The number of places tried for a dungeon in each pass.
+	dungeonTries = 8
+
+	// Chance of a patch of pumpkins in each pass, and the number of places
+	// tried for pumpkins in the patch.
+	pumpkinChance = 32 // 1 in pumpkinChance
+	pumpkinTries  = 8
+)
+
+// iTerrainGenerator is implemented by generators whose chunks are decorated
+// by a populator.
+type iTerrainGenerator interface {
+	// terrain generates the land, water, caves and ores of a chunk.
+	terrain(loc ChunkXz) *ChunkData
+
+	// biomeAt returns the biome at the given block column, which decides the
+	// plants that grow there. It must be safe to call from any goroutine.
+	biomeAt(x, z BlockCoord) *Biome
+}
+
+// populator generates the terrain of chunks with an iTerrainGenerator, and
+// runs the population passes over them.
+type populator struct {
+	seed int64
+	gen  iTerrainGenerator
+}
+
+func newPopulator(seed int64, gen iTerrainGenerator) *populator {
+	return &populator{
+		seed: seed,
+		gen:  gen,
+	}
+}
+
+// readChunk generates the terrain of the chunk at loc. The chunk is not yet
+// populated.
+func (p *populator) readChunk(loc ChunkXz) (reader chunkstore.IChunkReader, err os.Error) {
+	data := p.gen.terrain(loc)
+	lightChunk(data)
+
+	return data, nil
+}
+
+// populate runs the population pass for the chunk at loc. See
+// chunkstore.IPopulator.
+func (p *populator) populate(loc ChunkXz, blocks, blockData [4][]byte) []chunkstore.BlockChange {
+	area := &populationArea{
+		origin: *loc.ChunkCornerBlockXY(),
+		gen:    p.gen,
+		rnd:    chunkRand(p.seed^populationSeedSalt, loc),
+	}
+	for i := range area.blocks {
+		area.blocks[i] = cloneBytes(blocks[i])
+		area.blockData[i] = cloneBytes(blockData[i])
+	}
+
+	area.populate()
+
+	changes := make([]chunkstore.BlockChange, len(area.changes))
+	for i := range area.changes {
+		changes[i] = area.changes[i].toBlockChange()
+	}
+	return changes
+}
+
+// blockChange is a change made to a block by a population pass.
+type blockChange struct {
+	loc       BlockXyz
+	blockId   byte
+	blockData byte
+	// tileEntity is the NBT for the tile entity at the block, or nil for none.
+	tileEntity *nbt.Compound
+}
+
+// toBlockChange returns the change for the shard to apply, with its tile
+// entity created from the NBT.
+func (change *blockChange) toBlockChange() (result chunkstore.BlockChange) {
+	result.Loc = change.loc
+	result.BlockId = BlockId(change.blockId)
+	result.BlockData = change.blockData
+
+	if change.tileEntity == nil {
+		return
+	}
+
+	typeName, ok := change.tileEntity.Lookup("id").(*nbt.String)
+	if !ok {
+		return
+	}
+	tileEntity := gamerules.NewTileEntityByTypeName(typeName.Value)
+	if tileEntity == nil {
+		log.Printf("%v: unknown tile entity type %q", change.loc, typeName.Value)
+		return
+	}
+	if err := tileEntity.ReadNbt(change.tileEntity); err != nil {
+		log.Printf("%v: error reading generated tile entity: %v", change.loc, err)
+		return
+	}
+	result.TileEntity = tileEntity
+
+	return
+}
+
+// populationArea is the working copy of the terrain of the four chunks that a
+// population pass can change. Blocks in it are addressed relative to the
+// corner of the chunk that the pass is for.
+type populationArea struct {
+	origin    BlockXyz
+	gen       iTerrainGenerator
+	rnd       *rand.Rand
+	blocks    [4][]byte
+	blockData [4][]byte
+	changes   []blockChange
+}
+
+// index returns which of the four chunks a block is in, and its index within
+// that chunk. ok=false if the block is outside the area.
+func (area *populationArea) index(x, y, z int) (chunk int, index BlockIndex, ok bool) {
+	if x < 0 || x >= populationAreaSize || y < 0 || y >= ChunkSizeY || z < 0 || z >= populationAreaSize {
+		return
+	}
+
+	subLoc := SubChunkXyz{SubChunkCoord(x & ChunkHMask), SubChunkCoord(y), SubChunkCoord(z & ChunkHMask)}
+	index, ok = subLoc.BlockIndex()
+	chunk = (x>>ChunkHShift)*2 + z>>ChunkHShift
+	return
+}
+
+// blockAt returns the type of a block in the area, or air if it is outside.
+func (area *populationArea) blockAt(x, y, z int) byte {
+	if chunk, index, ok := area.index(x, y, z); ok {
+		return area.blocks[chunk][index]
+	}
+	return 0
+}
+
+// setBlock changes a block in the area. Blocks outside the area are ignored.
+func (area *populationArea) setBlock(x, y, z int, blockId, blockData byte) {
+	area.setBlockWithEntity(x, y, z, blockId, blockData, nil)
+}
+
+// setBlockWithEntity changes a block in the area, and gives it a tile entity.
+// The tile entity's location is filled in.
+func (area *populationArea) setBlockWithEntity(x, y, z int, blockId, blockData byte, tileEntity *nbt.Compound) {
+	chunk, index, ok := area.index(x, y, z)
+	if !ok {
+		return
+	}
+
+	area.blocks[chunk][index] = blockId
+	index.SetBlockData(area.blockData[chunk], blockData)
+
+	loc := BlockXyz{
+		area.origin.X + BlockCoord(x),
+		BlockYCoord(y),
+		area.origin.Z + BlockCoord(z),
+	}
+	if tileEntity != nil {
+		tileEntity.Tags["x"] = &nbt.Int{int32(loc.X)}
+		tileEntity.Tags["y"] = &nbt.Int{int32(loc.Y)}
+		tileEntity.Tags["z"] = &nbt.Int{int32(loc.Z)}
+	}
+
+	area.changes = append(area.changes, blockChange{loc, blockId, blockData, tileEntity})
+}
+
+// surfaceY returns the height of the highest block in the column that isn't
+// air, or -1 if there is none.
+func (area *populationArea) surfaceY(x, z int) int {
+	for y := ChunkSizeY - 1; y >= 0; y-- {
+		if area.blockAt(x, y, z) != 0 {
+			return y
+		}
+	}
+	return -1
+}
+
+// biomeAt returns the biome at a column in the area.
+func (area *populationArea) biomeAt(x, z int) *Biome {
+	return area.gen.biomeAt(area.origin.X+BlockCoord(x), area.origin.Z+BlockCoord(z))
+}
+
+// populate runs the population pass over the area.
+func (area *populationArea) populate() {
+	if area.rnd.Intn(waterLakeChance) == 0 {
+		area.addLake(9) // stationary water
+	}
+	if area.rnd.Intn(lavaLakeChance) == 0 {
+		area.addLake(11) // stationary lava
+	}
+
+	for i := 0; i < dungeonTries; i++ {
+		area.addDungeon()
+	}
+
+	area.addPlants()
+
+	if area.rnd.Intn(pumpkinChance) == 0 {
+		area.addPumpkins()
+	}
+}
+
+// decoratedColumn picks a random column from those that the pass decorates.
+func (area *populationArea) decoratedColumn() (x, z int) {
+	return decoratedOffset + area.rnd.Intn(decoratedSize), decoratedOffset + area.rnd.Intn(decoratedSize)
+}
+
+// addLake carves a lake made of a few overlapping blobs, with liquid in its
+// lower half. Water lakes are at the surface, and lava lakes are usually
+// underground. No lake is made if the liquid could leak out of it.
+func (area *populationArea) addLake(liquid byte) {
+	rnd := area.rnd
+
+	x, z := area.decoratedColumn()
+	x, z = x-lakeSize/2, z-lakeSize/2
+
+	var y int
+	if liquid == 11 {
+		y = 4 + rnd.Intn(SeaLevel-12)
+	} else {
+		y = area.surfaceY(x+lakeSize/2, z+lakeSize/2) - lakeLiquidDepth
+	}
+	if y < 2 {
+		return
+	}
+
+	var mask [lakeSize][lakeHeight][lakeSize]bool
+	for blobs := 4 + rnd.Intn(4); blobs > 0; blobs-- {
+		// The size and centre of the blob.
+		sx := rnd.Float64()*4 + 3
+		sy := rnd.Float64()*2 + 2
+		sz := rnd.Float64()*4 + 3
+		cx := rnd.Float64()*(lakeSize-sx-2) + 1 + sx/2
+		cy := rnd.Float64()*(lakeHeight-sy-4) + 2 + sy/2
+		cz := rnd.Float64()*(lakeSize-sz-2) + 1 + sz/2
+
+		for dx := 1; dx < lakeSize-1; dx++ {
+			for dy := 1; dy < lakeHeight-1; dy++ {
+				for dz := 1; dz < lakeSize-1; dz++ {
+					ex := (float64(dx) - cx) / (sx / 2)
+					ey := (float64(dy) - cy) / (sy / 2)
+					ez := (float64(dz) - cz) / (sz / 2)
+					if ex*ex+ey*ey+ez*ez < 1 {
+						mask[dx][dy][dz] = true
+					}
+				}
+			}
+		}
+	}
+
+	inLake := func(dx, dy, dz int) bool {
+		if dx < 0 || dx >= lakeSize || dy < 0 || dy >= lakeHeight || dz < 0 || dz >= lakeSize {
+			return false
+		}
+		return mask[dx][dy][dz]
+	}
+
+	// Check that the blocks around the lake hold the liquid in, and that there
+	// is no liquid already above it to pour in.
+	for dx := 0; dx < lakeSize; dx++ {
+		for dy := 0; dy < lakeHeight; dy++ {
+			for dz := 0; dz < lakeSize; dz++ {
+				if inLake(dx, dy, dz) {
+					continue
+				}
+				bordersLake := false
+				for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+					fdx, fdy, fdz := face.Dxyz()
+					if inLake(dx+int(fdx), dy+int(fdy), dz+int(fdz)) {
+						bordersLake = true
+						break
+					}
+				}
+				if !bordersLake {
+					continue
+				}
+
+				blockId := area.blockAt(x+dx, y+dy, z+dz)
+				if dy >= lakeLiquidDepth && isLiquid(blockId) {
+					return
+				}
+				if dy < lakeLiquidDepth && !isSolid(blockId) && blockId != liquid {
+					return
+				}
+			}
+		}
+	}
+
+	frozen := liquid == 9 && area.biomeAt(x+lakeSize/2, z+lakeSize/2).Frozen
+
+	for dx := 0; dx < lakeSize; dx++ {
+		for dy := 0; dy < lakeHeight; dy++ {
+			for dz := 0; dz < lakeSize; dz++ {
+				if !inLake(dx, dy, dz) {
+					continue
+				}
+				switch {
+				case dy >= lakeLiquidDepth:
+					area.setBlock(x+dx, y+dy, z+dz, 0, 0)
+				case frozen && dy == lakeLiquidDepth-1:
+					area.setBlock(x+dx, y+dy, z+dz, 79, 0) // ice
+				default:
+					area.setBlock(x+dx, y+dy, z+dz, liquid, 0)
+				}
+			}
+		}
+	}
+}
+
+// addDungeon picks a place for a dungeon, and builds it there if it is in
+// solid ground next to a cave.
+func (area *populationArea) addDungeon() bool {
+	rnd := area.rnd
+	x, z := area.decoratedColumn()
+	y := rnd.Intn(ChunkSizeY)
+	radiusX := 2 + rnd.Intn(2)
+	radiusZ := 2 + rnd.Intn(2)
+
+	return area.tryDungeon(x, y, z, radiusX, radiusZ)
+}
+
+// The height of the space inside a dungeon.
+const dungeonHeight = 3
+
+// tryDungeon builds a dungeon around the block at (x, y, z), which is the
+// middle of its floor, if the ground around it is solid and there are from 1
+// to 5 openings into it at floor level. The dungeon has a mob spawner in the
+// middle and up to two chests of loot along its walls.
+func (area *populationArea) tryDungeon(x, y, z, radiusX, radiusZ int) bool {
+	rnd := area.rnd
+
+	if y < 1 || y+dungeonHeight >= ChunkSizeY {
+		return false
+	}
+
+	openings := 0
+	for dx := -radiusX - 1; dx <= radiusX+1; dx++ {
+		for dz := -radiusZ - 1; dz <= radiusZ+1; dz++ {
+			bx, bz := x+dx, z+dz
+			if !isSolid(area.blockAt(bx, y-1, bz)) || !isSolid(area.blockAt(bx, y+dungeonHeight, bz)) {
+				return false
+			}
+
+			isWall := dx == -radiusX-1 || dx == radiusX+1 || dz == -radiusZ-1 || dz == radiusZ+1
+			if isWall && area.blockAt(bx, y, bz) == 0 && area.blockAt(bx, y+1, bz) == 0 {
+				openings++
+			}
+		}
+	}
+	if openings < 1 || openings > 5 {
+		return false
+	}
+
+	for dx := -radiusX - 1; dx <= radiusX+1; dx++ {
+		for dz := -radiusZ - 1; dz <= radiusZ+1; dz++ {
+			for dy := dungeonHeight; dy >= -1; dy-- {
+				bx, by, bz := x+dx, y+dy, z+dz
+				isWall := dx == -radiusX-1 || dx == radiusX+1 || dz == -radiusZ-1 || dz == radiusZ+1
+
+				switch {
+				case !isWall && dy >= 0 && dy < dungeonHeight:
+					area.setBlock(bx, by, bz, 0, 0)
+				case dy == -1:
+					if rnd.Intn(4) != 0 {
+						area.setBlock(bx, by, bz, 48, 0) // mossy cobblestone
+					} else {
+						area.setBlock(bx, by, bz, 4, 0) // cobblestone
+					}
+				case isSolid(area.blockAt(bx, by, bz)):
+					area.setBlock(bx, by, bz, 4, 0) // cobblestone
+				}
+			}
+		}
+	}
+
+	// Chests are put against the walls.
+	for chests := 0; chests < 2; chests++ {
+		for tries := 0; tries < 3; tries++ {
+			bx := x + rnd.Intn(2*radiusX+1) - radiusX
+			bz := z + rnd.Intn(2*radiusZ+1) - radiusZ
+			if area.blockAt(bx, y, bz) != 0 || area.solidSides(bx, y, bz) != 1 {
+				continue
+			}
+			area.setBlockWithEntity(bx, y, bz, 54, 0, dungeonChest(rnd))
+			break
+		}
+	}
+
+	spawner := &nbt.Compound{map[string]nbt.ITag{
+		"id":       &nbt.String{"MobSpawner"},
+		"EntityId": &nbt.String{dungeonMobs[rnd.Intn(len(dungeonMobs))]},
+		"Delay":    &nbt.Short{20},
+	}}
+	area.setBlockWithEntity(x, y, z, 52, 0, spawner)
+
+	return true
+}
+
+// The types of mob spawned in dungeons. Zombies are twice as common as the
+// others.
+var dungeonMobs = []string{"Skeleton", "Zombie", "Zombie", "Spider"}
+
+// dungeonLootItem is an item that may be found in a dungeon chest.
+type dungeonLootItem struct {
+	itemTypeId ItemTypeId
+	data       ItemData
+	maxCount   int
+	// chance out of 100 that the item is found when picked.
+	chance int
+}
+
+// dungeonLoot is picked from evenly for each of the slots filled in a dungeon
+// chest.
+var dungeonLoot = []dungeonLootItem{
+	{329, 0, 1, 100}, // saddle
+	{265, 0, 4, 100}, // iron ingot
+	{297, 0, 1, 100}, // bread
+	{296, 0, 4, 100}, // wheat
+	{289, 0, 4, 100}, // gunpowder
+	{287, 0, 4, 100}, // string
+	{325, 0, 1, 100}, // bucket
+	{322, 0, 1, 1},   // golden apple
+	{331, 0, 4, 50},  // redstone
+	{2256, 0, 1, 5},  // gold record
+	{2257, 0, 1, 5},  // green record
+	{351, 3, 1, 100}, // cocoa beans
+}
+
+// The number of slots picked to be filled in a dungeon chest, and the number
+// of slots in a chest.
+const (
+	dungeonChestPicks = 8
+	chestSlots        = 27
+)
+
+// dungeonChest returns the NBT for a chest filled with loot.
+func dungeonChest(rnd *rand.Rand) *nbt.Compound {
+	var items []nbt.ITag
+	used := make(map[int]bool)
+
+	for i := 0; i < dungeonChestPicks; i++ {
+		loot := &dungeonLoot[rnd.Intn(len(dungeonLoot))]
+		slot := rnd.Intn(chestSlots)
+		if rnd.Intn(100) >= loot.chance || used[slot] {
+			continue
+		}
+		used[slot] = true
+
+		items = append(items, &nbt.Compound{map[string]nbt.ITag{
+			"Slot":   &nbt.Byte{int8(slot)},
+			"id":     &nbt.Short{int16(loot.itemTypeId)},
+			"Count":  &nbt.Byte{int8(1 + rnd.Intn(loot.maxCount))},
+			"Damage": &nbt.Short{int16(loot.data)},
+		}})
+	}
+
+	return &nbt.Compound{map[string]nbt.ITag{
+		"id":    &nbt.String{"Chest"},
+		"Items": &nbt.List{nbt.TagCompound, items},
+	}}
+}
+
+// solidSides returns the number of blocks horizontally next to a block that
+// are solid.
+func (area *populationArea) solidSides(x, y, z int) (count int) {
+	for _, face := range horizontalFaces {
+		dx, _, dz := face.Dxyz()
+		if isSolid(area.blockAt(x+int(dx), y, z+int(dz))) {
+			count++
+		}
+	}
+	return
+}
+
+// addPlants grows trees, tall grass, flowers and cacti on the surface of the
+// decorated columns, depending on their biome.
+func (area *populationArea) addPlants() {
+	rnd := area.rnd
+
+	for x := decoratedOffset; x < decoratedOffset+decoratedSize; x++ {
+		for z := decoratedOffset; z < decoratedOffset+decoratedSize; z++ {
+			biome := area.biomeAt(x, z)
+			roll := rnd.Intn(1000)
+
+			y := area.surfaceY(x, z)
+			covered := false
+			if biome.CoverBlock != 0 && area.blockAt(x, y, z) == biome.CoverBlock {
+				y--
+				covered = true
+			}
+			if y < 0 || y+1 >= ChunkSizeY {
+				continue
+			}
+
+			switch area.blockAt(x, y, z) {
+			case 2: // grass
+				switch {
+				case roll < biome.TreeChance:
+					area.addTree(x, y+1, z)
+				case covered:
+					// Only trees grow up through the cover.
+				case roll < biome.TreeChance+biome.GrassChance:
+					area.setBlock(x, y+1, z, 31, 1) // tall grass
+				case roll < biome.TreeChance+biome.GrassChance+biome.FlowerChance:
+					if rnd.Intn(2) == 0 {
+						area.setBlock(x, y+1, z, 37, 0) // dandelion
+					} else {
+						area.setBlock(x, y+1, z, 38, 0) // rose
+					}
+				}
+
+			case 12: // sand
+				if roll < biome.CactusChance {
+					area.addCactus(x, y+1, z, 1+rnd.Intn(3))
+				}
+			}
+		}
+	}
+}
+
+// addTree grows a tree with its trunk starting at the given block, if there
+// is room for it.
+func (area *populationArea) addTree(x, y, z int) {
+	rnd := area.rnd
+	height := 4 + rnd.Intn(3)
+
+	if y+height+1 >= ChunkSizeY {
+		return
+	}
+	for dy := 0; dy <= height; dy++ {
+		if blockId := area.blockAt(x, y+dy, z); blockId != 0 && (dy > 0 || !isReplaceablePlant(blockId)) {
+			return
+		}
+	}
+
+	area.setBlock(x, y-1, z, 3, 0) // dirt
+
+	// The leaves are in four layers, the lower two wider than the upper two.
+	// Some corners are left out to round them off.
+	top := y + height
+	for ly := top - 3; ly <= top; ly++ {
+		fromTop := top - ly
+		radius := 1 + fromTop/2
+		for dx := -radius; dx <= radius; dx++ {
+			for dz := -radius; dz <= radius; dz++ {
+				isCorner := (dx == -radius || dx == radius) && (dz == -radius || dz == radius)
+				if isCorner && (fromTop == 0 || rnd.Intn(2) == 0) {
+					continue
+				}
+				if blockId := area.blockAt(x+dx, ly, z+dz); blockId == 0 || isReplaceablePlant(blockId) {
+					area.setBlock(x+dx, ly, z+dz, 18, 0) // leaves
+				}
+			}
+		}
+	}
+
+	for dy := 0; dy < height; dy++ {
+		area.setBlock(x, y+dy, z, 17, 0) // log
+	}
+}
+
+// addCactus places a cactus of up to the given height with its base at the
+// given block, stopping where it would touch another block at its sides.
+func (area *populationArea) addCactus(x, y, z, height int) {
+	for i := 0; i < height && y+i < ChunkSizeY; i++ {
+		if area.blockAt(x, y+i, z) != 0 || area.openSides(x, y+i, z) != len(horizontalFaces) {
+			return
+		}
+		area.setBlock(x, y+i, z, 81, 0) // cactus
+	}
+}
+
+// openSides returns the number of blocks horizontally next to a block that
+// are air.
+func (area *populationArea) openSides(x, y, z int) (count int) {
+	for _, face := range horizontalFaces {
+		dx, _, dz := face.Dxyz()
+		if area.blockAt(x+int(dx), y, z+int(dz)) == 0 {
+			count++
+		}
+	}
+	return
+}
+
+// addPumpkins places a patch of pumpkins on the grass around a column.
+func (area *populationArea) addPumpkins() {
+	rnd := area.rnd
+	cx, cz := area.decoratedColumn()
+
+	for i := 0; i < pumpkinTries; i++ {
+		x := cx + rnd.Intn(8) - rnd.Intn(8)
+		z := cz + rnd.Intn(8) - rnd.Intn(8)
+		y := area.surfaceY(x, z)
+		facing := byte(rnd.Intn(4))
+		if y >= 0 && area.blockAt(x, y, z) == 2 && y+1 < ChunkSizeY {
+			area.setBlock(x, y+1, z, 86, facing) // pumpkin
+		}
+	}
+}
+
+// isLiquid returns true for water and lava blocks.
+func isLiquid(blockId byte) bool {
+	return blockId >= 8 && blockId <= 11
+}
+
+// isSolid returns true for blocks that liquids cannot flow through, and that
+// dungeons can be built into. Only the blocks that terrain is made of are
+// considered.
+func isSolid(blockId byte) bool {
+	return blockId != 0 && !isLiquid(blockId) && !isReplaceablePlant(blockId)
+}
+
+// isReplaceablePlant returns true for blocks that trees can grow over.
+func isReplaceablePlant(blockId byte) bool {
+	switch blockId {
+	case 31, 37, 38, 78: // tall grass, flowers, snow
+		return true
+	}
+	return false
+}
+
+func cloneBytes(in []byte) []byte {
+	out := make([]byte, len(in))
+	copy(out, in)
+	return out
+}
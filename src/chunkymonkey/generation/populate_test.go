@@ -0,0 +1,137 @@
+package generation
+
+import (
+	"bytes"
+	"testing"
+
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+// stoneArea returns a populationArea whose four chunks are entirely stone.
+func stoneArea() *populationArea {
+	area := &populationArea{
+		gen: NewTestGenerator(0),
+		rnd: chunkRand(0, ChunkXz{0, 0}),
+	}
+	for i := range area.blocks {
+		data := stoneChunk(ChunkXz{})
+		area.blocks[i] = data.blocks
+		area.blockData[i] = data.blockData
+	}
+	return area
+}
+
+func TestPopulationArea_index(t *testing.T) {
+	area := stoneArea()
+
+	area.setBlock(ChunkSizeH+1, 10, 2, 3, 0)
+	if result := area.blockAt(ChunkSizeH+1, 10, 2); result != 3 {
+		t.Errorf("expected block 3 to be set, got %d", result)
+	}
+	if result := area.blockAt(1, 10, 2); result != 1 {
+		t.Errorf("expected block in another chunk of the area to be unchanged, got %d", result)
+	}
+
+	// Blocks outside the area are air, and are not changed.
+	area.setBlock(-1, 10, 2, 3, 0)
+	if result := area.blockAt(-1, 10, 2); result != 0 {
+		t.Errorf("expected air outside the area, got %d", result)
+	}
+	if len(area.changes) != 1 {
+		t.Errorf("expected 1 change, got %d", len(area.changes))
+	}
+	if loc := area.changes[0].loc; loc.X != ChunkSizeH+1 || loc.Y != 10 || loc.Z != 2 {
+		t.Errorf("expected change at (%d, 10, 2), got %v", ChunkSizeH+1, loc)
+	}
+}
+
+func TestPopulationArea_tryDungeon(t *testing.T) {
+	const x, y, z = 16, 20, 16
+
+	area := stoneArea()
+	if area.tryDungeon(x, y, z, 2, 2) {
+		t.Fatalf("expected no dungeon without an opening")
+	}
+
+	// Open a passage into the east wall.
+	area.setBlock(x+3, y, z, 0, 0)
+	area.setBlock(x+3, y+1, z, 0, 0)
+	area.changes = nil
+
+	if !area.tryDungeon(x, y, z, 2, 2) {
+		t.Fatalf("expected a dungeon with one opening")
+	}
+
+	if result := area.blockAt(x, y, z); result != 52 {
+		t.Errorf("expected a spawner in the middle, got %d", result)
+	}
+	if result := area.blockAt(x+1, y+1, z+1); result != 0 {
+		t.Errorf("expected air inside, got %d", result)
+	}
+	if result := area.blockAt(x+3, y+1, z+1); result != 4 {
+		t.Errorf("expected cobblestone walls, got %d", result)
+	}
+	if result := area.blockAt(x+3, y, z); result != 0 {
+		t.Errorf("expected the opening to be kept, got %d", result)
+	}
+
+	var spawners int
+	for _, change := range area.changes {
+		if change.blockId == 52 {
+			spawners++
+			mobType, ok := change.tileEntity.Lookup("EntityId").(*nbt.String)
+			if !ok || mobType.Value == "" {
+				t.Errorf("expected spawner tile entity with a mob type, got %v", change.tileEntity)
+			}
+		}
+		if change.blockId == 54 && change.tileEntity == nil {
+			t.Errorf("expected chest at %v to have a tile entity", change.loc)
+		}
+	}
+	if spawners != 1 {
+		t.Errorf("expected 1 spawner, got %d", spawners)
+	}
+}
+
+func TestPopulator_populate(t *testing.T) {
+	gen := NewTestGenerator(1234)
+	loc := ChunkXz{3, -2}
+
+	var blocks, blockData [4][]byte
+	for dx := 0; dx < 2; dx++ {
+		for dz := 0; dz < 2; dz++ {
+			terrain := gen.terrain(ChunkXz{loc.X + ChunkCoord(dx), loc.Z + ChunkCoord(dz)})
+			blocks[dx*2+dz] = terrain.blocks
+			blockData[dx*2+dz] = terrain.blockData
+		}
+	}
+	original := cloneBytes(blocks[0])
+
+	changes := gen.Populate(loc, blocks, blockData)
+	if len(changes) == 0 {
+		t.Fatalf("expected the forest to be decorated")
+	}
+	if !bytes.Equal(original, blocks[0]) {
+		t.Errorf("expected the blocks passed in to be left unaltered")
+	}
+
+	corner := loc.ChunkCornerBlockXY()
+	for _, change := range changes {
+		dx, dz := change.Loc.X-corner.X, change.Loc.Z-corner.Z
+		if dx < 0 || dx >= populationAreaSize || dz < 0 || dz >= populationAreaSize {
+			t.Errorf("expected changes within the four chunks, got one at %v", change.Loc)
+		}
+	}
+
+	// The pass depends only on the blocks and the world seed.
+	again := gen.Populate(loc, blocks, blockData)
+	if len(again) != len(changes) {
+		t.Fatalf("expected %d changes the second time, got %d", len(changes), len(again))
+	}
+	for i := range changes {
+		if again[i].BlockState != changes[i].BlockState {
+			t.Errorf("change %d differs the second time: %v and %v", i, changes[i].BlockState, again[i].BlockState)
+		}
+	}
+}
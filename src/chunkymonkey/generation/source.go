@@ -89,3 +89,29 @@ func (gen *Sum) At2d(x, y float64) float64 {
 	}
 	return accum
 }
+
+type ISource3d interface {
+	At3d(x, y, z float64) float64
+}
+
+type Offset3d struct {
+	Dx, Dy, Dz float64
+	Source     ISource3d
+}
+
+func (gen *Offset3d) At3d(x, y, z float64) float64 {
+	return gen.Source.At3d(x+gen.Dx, y+gen.Dy, z+gen.Dz)
+}
+
+// Scale3d is like Scale, but with a separate wavelength for the y axis so that
+// features can be flattened or stretched vertically.
+type Scale3d struct {
+	Wavelength  float64
+	WavelengthY float64
+	Amplitude   float64
+	Source      ISource3d
+}
+
+func (gen *Scale3d) At3d(x, y, z float64) float64 {
+	return gen.Source.At3d(x/gen.Wavelength, y/gen.WavelengthY, z/gen.Wavelength) * gen.Amplitude
+}
@@ -0,0 +1,102 @@
+package generation
+
+import (
+	"os"
+
+	"chunkymonkey/chunkstore"
+	. "chunkymonkey/types"
+)
+
+const (
+	// The height of the spawn platform in a void world, and how far it
+	// reaches from the spawn point in each direction.
+	voidPlatformY      = SeaLevel
+	voidPlatformRadius = 2
+)
+
+// VoidGenerator generates an empty world, with an optional platform for
+// players to spawn on. It implements chunkstore.IChunkStoreForeground.
+type VoidGenerator struct {
+	platform          bool
+	platformBlockId   byte
+	platformBlockData byte
+
+	// empty is generated on first use, and copied for each chunk without
+	// part of the platform.
+	empty *ChunkData
+}
+
+// NewVoidGenerator creates a void generator. If platformSpec is not empty, it
+// is the block that the spawn platform is made of, e.g "1" for stone or
+// "35:14" for red wool.
+func NewVoidGenerator(platformSpec string) (gen *VoidGenerator, err os.Error) {
+	gen = &VoidGenerator{}
+
+	if platformSpec != "" {
+		gen.platform = true
+		if gen.platformBlockId, gen.platformBlockData, err = parseBlockSpec(platformSpec); err != nil {
+			return nil, BadGeneratorOptions(platformSpec)
+		}
+	}
+
+	return
+}
+
+func (gen *VoidGenerator) SupportsWrite() bool {
+	return false
+}
+
+func (gen *VoidGenerator) Writer() chunkstore.IChunkWriter {
+	return nil
+}
+
+func (gen *VoidGenerator) WriteChunk(writer chunkstore.IChunkWriter) os.Error {
+	return os.NewError("writes not supported by VoidGenerator")
+}
+
+func (gen *VoidGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkReader, err os.Error) {
+	// The platform is within the chunks around the origin.
+	if gen.platform && chunkLoc.X >= -1 && chunkLoc.X <= 0 && chunkLoc.Z >= -1 && chunkLoc.Z <= 0 {
+		return gen.platformChunk(chunkLoc), nil
+	}
+
+	if gen.empty == nil {
+		gen.empty = newChunkData(ChunkXz{0, 0})
+		lightChunk(gen.empty)
+		gen.empty.populated = true
+	}
+
+	return gen.empty.cloneAt(chunkLoc), nil
+}
+
+// SpawnPosition returns the block that players first spawn at in a new world,
+// on top of the platform if there is one.
+func (gen *VoidGenerator) SpawnPosition() BlockXyz {
+	return BlockXyz{0, voidPlatformY + 1, 0}
+}
+
+// platformChunk generates a chunk containing part of the spawn platform.
+func (gen *VoidGenerator) platformChunk(chunkLoc ChunkXz) *ChunkData {
+	data := newChunkData(chunkLoc)
+	corner := chunkLoc.ChunkCornerBlockXY()
+
+	for x := 0; x < ChunkSizeH; x++ {
+		for z := 0; z < ChunkSizeH; z++ {
+			blockX, blockZ := int(corner.X)+x, int(corner.Z)+z
+			if blockX < -voidPlatformRadius || blockX > voidPlatformRadius ||
+				blockZ < -voidPlatformRadius || blockZ > voidPlatformRadius {
+				continue
+			}
+
+			subLoc := SubChunkXyz{SubChunkCoord(x), voidPlatformY, SubChunkCoord(z)}
+			index, _ := subLoc.BlockIndex()
+			data.blocks[index] = gen.platformBlockId
+			index.SetBlockData(data.blockData, gen.platformBlockData)
+		}
+	}
+
+	lightChunk(data)
+	data.populated = true
+
+	return data
+}
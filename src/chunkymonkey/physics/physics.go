@@ -54,6 +54,17 @@ func (obj *PointObject) Position() *AbsXyz {
 	return &obj.position
 }
 
+// Velocity returns the object's velocity, which may be changed by the caller.
+func (obj *PointObject) Velocity() *AbsVelocity {
+	return &obj.velocity
+}
+
+// OnGround returns true if the object has come to rest on top of a solid
+// block.
+func (obj *PointObject) OnGround() bool {
+	return obj.onGround
+}
+
 func (obj *PointObject) Init(position *AbsXyz, velocity *AbsVelocity) {
 	obj.LastSentPosition = *position.ToAbsIntXyz()
 	obj.LastSentVelocity = *velocity.ToVelocity()
@@ -62,6 +73,17 @@ func (obj *PointObject) Init(position *AbsXyz, velocity *AbsVelocity) {
 	obj.onGround = false
 }
 
+// Push adds to the velocity of the object, for example when it is knocked back
+// by an attack.
+func (obj *PointObject) Push(velocity *AbsVelocity) {
+	obj.velocity.X += velocity.X
+	obj.velocity.Y += velocity.Y
+	obj.velocity.Z += velocity.Z
+	if velocity.Y > 0 {
+		obj.onGround = false
+	}
+}
+
 func (obj *PointObject) ReadNbt(tag nbt.ITag) (err os.Error) {
 	// Position within the chunk
 	if obj.position, err = nbtutil.ReadAbsXyz(tag, "Pos"); err != nil {
@@ -155,8 +177,17 @@ func (obj *PointObject) Tick(blockQuerier IBlockQuerier) (leftChunk bool) {
 	p := &obj.position
 	v := &obj.velocity
 
-	// FIXME note that if the block under the item should become non-solid,
-	// then we need to turn off onGround to re-enable physics
+	if obj.onGround {
+		// Start falling again if the block underneath is no longer solid.
+		below := p.ToBlockXyz()
+		below.Y--
+		if below.Y >= 0 {
+			if isSolid, _ := blockQuerier.BlockQuery(*below); !isSolid {
+				obj.onGround = false
+			}
+		}
+	}
+
 	// TODO if the object has stopped moving (i.e is at rest on top of a solid
 	// block and not inside a flowing block), take the object out of a
 	// "physically active" list. Note that the object will have to be re-added
@@ -370,6 +401,32 @@ func applyCollision(p *AbsCoord, v *AbsVelocityCoord) {
 	*v = 0
 }
 
+// AimVelocity returns the velocity with which to throw or shoot an object
+// from one position so that it falls onto another, moving horizontally at the
+// given speed. Air resistance is not taken into account, so the object will
+// fall somewhat short of a distant target.
+func AimVelocity(from, to *AbsXyz, speed float64) AbsVelocity {
+	dx := float64(to.X - from.X)
+	dy := float64(to.Y - from.Y)
+	dz := float64(to.Z - from.Z)
+
+	distance := math.Sqrt(dx*dx + dz*dz)
+	if distance < 1e-4 {
+		return AbsVelocity{0, AbsVelocityCoord(speed), 0}
+	}
+
+	// Time to reach the target, and the upwards velocity needed to counter
+	// gravity over that time.
+	ticks := distance / speed
+	lift := dy/ticks + gravityBlocksPerTick2*ticks/2
+
+	return AbsVelocity{
+		AbsVelocityCoord(speed * dx / distance),
+		AbsVelocityCoord(lift),
+		AbsVelocityCoord(speed * dz / distance),
+	}
+}
+
 // Create a velocity from a look (yaw and pitch) and a momentum.
 func VelocityFromLook(look LookDegrees, speed float64) AbsVelocity {
 	yaw := float64(look.Yaw) * (math.Pi / 180)
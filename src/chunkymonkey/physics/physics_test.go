@@ -257,3 +257,22 @@ func Test_PointObject_TickOnce(t *testing.T) {
 		test.test(t)
 	}
 }
+
+func TestAimVelocity(t *testing.T) {
+	from := AbsXyz{0, 64, 0}
+	to := AbsXyz{10, 64, 0}
+	speed := 1.0
+
+	v := AimVelocity(&from, &to, speed)
+	if !almostEqual(float64(v.X), speed) || !almostEqual(float64(v.Z), 0) {
+		t.Errorf("expected horizontal velocity {%v, 0}, got {%v, %v}", speed, v.X, v.Z)
+	}
+
+	// Following the velocity under gravity should arrive back at the same
+	// height after reaching the target.
+	ticks := 10.0
+	y := float64(v.Y)*ticks - gravityBlocksPerTick2*ticks*ticks/2
+	if !almostEqual(y, 0) {
+		t.Errorf("expected arrow to reach target height, was off by %v", y)
+	}
+}
@@ -0,0 +1,110 @@
+package player
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"chunkymonkey/proto"
+	"nbt"
+)
+
+// receiveWhisper shows the player a private message from another player,
+// unless they are ignoring them. The sender becomes the player that /reply
+// answers. It must be called with player.lock held.
+func (player *Player) receiveWhisper(from, msg string) {
+	if player.IsIgnoring(from) {
+		return
+	}
+	player.setCorrespondent(from)
+
+	buf := new(bytes.Buffer)
+	proto.WriteChatMessage(buf, fmt.Sprintf("§7%s whispers: %s", from, msg))
+	player.TransmitPacket(buf.Bytes())
+}
+
+// Correspondent returns the name of the player that last whispered to this
+// one, or "" if none has. It is safe to call from any goroutine.
+func (player *Player) Correspondent() string {
+	player.chatLock.Lock()
+	defer player.chatLock.Unlock()
+
+	return player.correspondent
+}
+
+func (player *Player) setCorrespondent(name string) {
+	player.chatLock.Lock()
+	defer player.chatLock.Unlock()
+
+	player.correspondent = name
+}
+
+// IsIgnoring returns true if the messages of the named player are ignored. It
+// is safe to call from any goroutine.
+func (player *Player) IsIgnoring(name string) bool {
+	player.chatLock.Lock()
+	defer player.chatLock.Unlock()
+
+	return player.ignoring[strings.ToLower(name)]
+}
+
+// setIgnoring adds the named player to, or removes them from, the players
+// whose messages are ignored. It returns false if that did not change
+// anything. It is safe to call from any goroutine.
+func (player *Player) setIgnoring(name string, ignore bool) bool {
+	player.chatLock.Lock()
+	defer player.chatLock.Unlock()
+
+	name = strings.ToLower(name)
+	if player.ignoring[name] == ignore {
+		return false
+	}
+
+	if ignore {
+		player.ignoring[name] = true
+	} else {
+		player.ignoring[name] = false, false
+	}
+	return true
+}
+
+// ignoringNames returns the players that are ignored, in order. It is safe to
+// call from any goroutine, so that the player data can be written from them.
+func (player *Player) ignoringNames() []string {
+	player.chatLock.Lock()
+	defer player.chatLock.Unlock()
+
+	names := make([]string, 0, len(player.ignoring))
+	for name := range player.ignoring {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// readIgnoringNbt reads the ignored players from the player data. Player data
+// that was saved before players could be ignored has none.
+func (player *Player) readIgnoringNbt(playerData nbt.ITag) {
+	list, ok := playerData.Lookup("Ignoring").(*nbt.List)
+	if !ok {
+		return
+	}
+
+	for _, tag := range list.Value {
+		if name, ok := tag.(*nbt.String); ok {
+			player.setIgnoring(name.Value, true)
+		}
+	}
+}
+
+func (player *Player) writeIgnoringNbt() *nbt.List {
+	names := player.ignoringNames()
+	tags := make([]nbt.ITag, len(names))
+	for i, name := range names {
+		tags[i] = &nbt.String{name}
+	}
+
+	return &nbt.List{nbt.TagString, tags}
+}
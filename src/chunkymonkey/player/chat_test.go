@@ -0,0 +1,73 @@
+package player
+
+import (
+	"reflect"
+	"testing"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+func init() {
+	if err := gamerules.LoadGameRules("blocks.json", "items.json", "recipes.json", "furnace.json", "spawning.json", "users.json", "groups.json", "regions.json"); err != nil {
+		panic(err)
+	}
+}
+
+func newTestPlayer(name string) *Player {
+	shardConnecters := map[DimensionId]gamerules.IShardConnecter{DimensionNormal: nil}
+	return NewPlayer(EntityId(1), shardConnecters, nil, name, BlockXyz{0, 64, 0}, nil, nil)
+}
+
+func TestIgnoring_Nbt(t *testing.T) {
+	player := newTestPlayer("thePlayer")
+	player.setIgnoring("Griefer", true)
+	player.setIgnoring("spammer", true)
+	player.setIgnoring("friend", true)
+	player.setIgnoring("Friend", false)
+
+	data := player.WriteNbt()
+
+	loaded := newTestPlayer("thePlayer")
+	if err := loaded.ReadNbt(data); err != nil {
+		t.Fatalf("ReadNbt failed: %v", err)
+	}
+
+	expected := []string{"griefer", "spammer"}
+	if names := loaded.ignoringNames(); !reflect.DeepEqual(expected, names) {
+		t.Errorf("expected to be ignoring %v, got %v", expected, names)
+	}
+
+	// Player data saved before players could be ignored has no list.
+	data.Tags["Ignoring"] = nil, false
+	loaded = newTestPlayer("thePlayer")
+	if err := loaded.ReadNbt(data); err != nil {
+		t.Fatalf("ReadNbt without Ignoring failed: %v", err)
+	}
+	if names := loaded.ignoringNames(); len(names) != 0 {
+		t.Errorf("expected to be ignoring nobody, got %v", names)
+	}
+}
+
+// The private message state is used by commands from other goroutines, and
+// must not wait on the player's mainloop, which is not running here.
+func TestPlayerClient_Chat(t *testing.T) {
+	player := newTestPlayer("thePlayer")
+	client := player.Client()
+
+	if !client.SetIgnoring("Griefer", true) {
+		t.Errorf("expected ignoring Griefer to change the ignored players")
+	}
+	if !player.IsIgnoring("griefer") {
+		t.Errorf("expected griefer to be ignored")
+	}
+
+	if to := client.Correspondent(); to != "" {
+		t.Errorf("expected no correspondent, got %q", to)
+	}
+	player.receiveWhisper("griefer", "hello")
+	player.receiveWhisper("friend", "hi")
+	if to := client.Correspondent(); to != "friend" {
+		t.Errorf("expected correspondent \"friend\", got %q", to)
+	}
+}
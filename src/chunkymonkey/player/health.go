@@ -0,0 +1,258 @@
+package player
+
+import (
+	"bytes"
+	"math"
+	"rand"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+const (
+	// The number of ticks that a player can hold their breath for.
+	maxAir = 300
+	// The player takes drownDamage each time their air runs down to
+	// drownAirLimit, after which it starts again from zero.
+	drownAirLimit = -20
+	drownDamage   = 2
+
+	// The distance a player can fall without taking damage. They take one
+	// point of damage for every block fallen beyond this.
+	safeFallDistance = 3
+
+	// Damage taken for each tick in lava, or in a burning block such as fire.
+	lavaDamage = 4
+	fireDamage = 1
+
+	// The number of ticks that a player keeps burning after leaving lava or
+	// fire, and the damage taken every second while they burn.
+	lavaBurnTicks = 15 * TicksPerSecond
+	fireBurnTicks = 8 * TicksPerSecond
+	burnDamage    = 1
+
+	// Players below voidY take voidDamage every tick.
+	voidY      = -64
+	voidDamage = 4
+
+	// The number of ticks after taking damage during which further damage is
+	// ignored.
+	hurtImmunityTicks = 10
+
+	// Speed at which items are thrown out of the inventory of a dead player.
+	deathDropSpeed = 0.2
+)
+
+// tick updates the player's health, and takes them through any portal they
+// are in, for a single tick. It must be called with player.lock held.
+func (player *Player) tick() {
+	if !player.spawnComplete {
+		return
+	}
+
+	if player.health <= 0 {
+		player.deathTime++
+		return
+	}
+
+	if player.hurtTime > 0 {
+		player.hurtTime--
+	}
+
+	env := &player.environment
+
+	if env.HeadInWater {
+		player.air--
+		if player.air <= drownAirLimit {
+			player.air = 0
+			player.hurt(drownDamage)
+		}
+	} else {
+		player.air = maxAir
+	}
+
+	switch {
+	case env.InWater:
+		player.fire = 0
+	case env.InLava:
+		player.fire = lavaBurnTicks
+		player.hurt(lavaDamage)
+	case env.InFire:
+		if player.fire < fireBurnTicks {
+			player.fire = fireBurnTicks
+		}
+		player.hurt(fireDamage)
+	}
+
+	if player.fire > 0 {
+		if player.fire%TicksPerSecond == 0 {
+			player.hurt(burnDamage)
+		}
+		player.fire--
+	}
+
+	if player.position.Y < voidY {
+		player.hurt(voidDamage)
+	}
+
+	if player.health > 0 {
+		player.portalTick()
+	}
+}
+
+// updateFall keeps track of how far the player has fallen as they move, and
+// hurts them when they land. It must be called with player.lock held, before
+// player.position is updated.
+func (player *Player) updateFall(newY AbsCoord, onGround bool) {
+	if player.environment.InWater {
+		player.fallDistance = 0
+	} else if newY < player.position.Y {
+		player.fallDistance += float32(player.position.Y - newY)
+	}
+
+	if !onGround {
+		player.onGround = 0
+		return
+	}
+
+	player.onGround = 1
+	if player.fallDistance > safeFallDistance {
+		player.hurt(Health(math.Ceil(float64(player.fallDistance - safeFallDistance))))
+	}
+	player.fallDistance = 0
+}
+
+// setEnvironment is called when the blocks around the player that can harm
+// them have changed.
+func (player *Player) setEnvironment(env *gamerules.PlayerEnvironment) {
+	player.environment = *env
+}
+
+// hurt reduces the player's health, unless they were recently hurt. It
+// returns true if the player was hurt. It must be called with player.lock
+// held.
+func (player *Player) hurt(damage Health) bool {
+	if player.health <= 0 || player.hurtTime > 0 || damage <= 0 {
+		return false
+	}
+
+	player.health -= damage
+	player.hurtTime = hurtImmunityTicks
+
+	status := EntityStatusHurt
+	if player.health <= 0 {
+		player.health = 0
+		status = EntityStatusDead
+	}
+
+	buf := new(bytes.Buffer)
+	proto.WriteEntityStatus(buf, player.EntityId, status)
+	player.multicastPacket(buf.Bytes(), true)
+
+	buf = new(bytes.Buffer)
+	proto.WriteUpdateHealth(buf, player.health)
+	player.TransmitPacket(buf.Bytes())
+
+	if player.health <= 0 {
+		player.die()
+	}
+
+	return true
+}
+
+// attacked hurts the player, and knocks them back if they were hurt. It must
+// be called with player.lock held.
+func (player *Player) attacked(damage Health, knockback *AbsVelocity) {
+	if !player.hurt(damage) {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	proto.WriteEntityVelocity(buf, player.EntityId, knockback.ToVelocity())
+	player.TransmitPacket(buf.Bytes())
+}
+
+// kill hurts the player enough to kill them, regardless of any recent damage.
+// It must be called with player.lock held.
+func (player *Player) kill() {
+	player.hurtTime = 0
+	player.hurt(player.health)
+}
+
+// die drops everything that the player was carrying where they died. The
+// player stays dead until they respawn.
+func (player *Player) die() {
+	player.closeCurrentWindow(true)
+	player.deathTime = 0
+	player.fire = 0
+
+	// The items are left with the player if there is nowhere to drop them,
+	// rather than being lost.
+	shardClient, ok := player.chunkSubs.CurrentShardClient()
+	if !ok {
+		return
+	}
+
+	items := player.inventory.TakeAllItems()
+	if !player.cursor.IsEmpty() {
+		items = append(items, player.cursor)
+		player.cursor.Clear()
+	}
+
+	for _, item := range items {
+		angle := rand.Float64() * 2 * math.Pi
+		velocity := AbsVelocity{
+			X: AbsVelocityCoord(deathDropSpeed * math.Cos(angle)),
+			Y: AbsVelocityCoord(deathDropSpeed),
+			Z: AbsVelocityCoord(deathDropSpeed * math.Sin(angle)),
+		}
+		shardClient.ReqDropItem(item, player.position, velocity, TicksPerSecond)
+	}
+}
+
+// respawn brings a dead player back to life at the spawn point, which is in
+// the normal dimension.
+func (player *Player) respawn() {
+	if player.health > 0 {
+		return
+	}
+
+	player.health = MaxHealth
+	player.air = maxAir
+	player.fire = 0
+	player.fallDistance = 0
+	player.hurtTime = 0
+	player.deathTime = 0
+	player.environment = gamerules.PlayerEnvironment{}
+
+	spawnPosition := AbsXyz{
+		X: AbsCoord(player.spawnBlock.X) + 0.5,
+		Y: AbsCoord(player.spawnBlock.Y),
+		Z: AbsCoord(player.spawnBlock.Z) + 0.5,
+	}
+
+	if player.dimension != DimensionNormal {
+		// The health is sent once the player is in the new dimension.
+		player.changeDimension(DimensionNormal, spawnPosition)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	proto.WriteRespawn(buf, DimensionNormal)
+	proto.WriteUpdateHealth(buf, player.health)
+	player.TransmitPacket(buf.Bytes())
+
+	player.position = spawnPosition
+	player.height = StanceNormal - spawnPosition.Y
+
+	// Replace the body seen by other players with the respawned player.
+	if player.chunkSubs.Respawn(&player.position) {
+		// The spawn chunk isn't loaded. Wait for it.
+		player.spawnComplete = false
+	} else {
+		buf := new(bytes.Buffer)
+		proto.WritePlayerPosition(buf, &player.position, StanceNormal, true)
+		player.TransmitPacket(buf.Bytes())
+	}
+}
@@ -0,0 +1,64 @@
+package player
+
+import (
+	"testing"
+
+	"gomock.googlecode.com/hg/gomock"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+func TestRespawn(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	shard := gamerules.NewMockIPlayerShardClient(mockCtrl)
+	shard.EXPECT().Disconnect().AnyTimes()
+	shard.EXPECT().ReqSubscribeChunk(gomock.Any(), gomock.Any()).AnyTimes()
+	shard.EXPECT().ReqUnsubscribeChunk(gomock.Any()).AnyTimes()
+
+	connecter := gamerules.NewMockIShardConnecter(mockCtrl)
+	connecter.EXPECT().PlayerShardConnect(gomock.Any(), gomock.Any(), gomock.Any()).Return(shard).AnyTimes()
+	shardConnecters := map[DimensionId]gamerules.IShardConnecter{DimensionNormal: connecter}
+
+	player := NewPlayer(EntityId(1), shardConnecters, nil, "thePlayer", BlockXyz{0, 64, 0}, nil, nil)
+	player.position = AbsXyz{800.5, 64, -1600.5}
+	deathChunk := ChunkXz{50, -101}
+	shard.EXPECT().ReqAddPlayerData(deathChunk, "thePlayer", player.position, gomock.Any(), gomock.Any())
+	player.chunkSubs.Init(player)
+
+	// The body is taken out of the chunk that the player died in before the
+	// player is added to the chunk at the spawn position.
+	spawnPosition := AbsXyz{0.5, 64, 0.5}
+	gomock.InOrder(
+		shard.EXPECT().ReqRemovePlayerData(deathChunk, true),
+		shard.EXPECT().ReqAddPlayerData(ChunkXz{0, 0}, "thePlayer", spawnPosition, gomock.Any(), gomock.Any()),
+	)
+
+	player.health = 0
+	player.respawn()
+
+	expectPosition(t, player, DimensionNormal, spawnPosition)
+	if player.health != MaxHealth {
+		t.Errorf("expected the player to have %d health, got %d", MaxHealth, player.health)
+	}
+}
+
+func TestDie_NoShardClient(t *testing.T) {
+	player := newTestPlayer("thePlayer")
+	player.inventory.PutItem(&gamerules.Slot{ItemTypeId: 1, Count: 10})
+
+	// With no shard to drop the items in, they are kept rather than lost.
+	player.health = 0
+	player.die()
+
+	items := player.inventory.TakeAllItems()
+	count := ItemCount(0)
+	for _, item := range items {
+		count += item.Count
+	}
+	if count != 10 {
+		t.Errorf("expected the player to still have 10 items, got %d", count)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"log"
 	"net"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"chunkymonkey/gamerules"
 	"chunkymonkey/nbtutil"
@@ -38,40 +40,51 @@ func init() {
 type Player struct {
 	// These entities should be unchanged through a single login
 	EntityId
-	playerClient   playerClient
-	shardConnecter gamerules.IShardConnecter
-	conn           net.Conn
-	name           string
-	loginComplete  bool
-	spawnComplete  bool
+	playerClient    playerClient
+	shardConnecters map[DimensionId]gamerules.IShardConnecter
+	conn            net.Conn
+	name            string
+	loginComplete   bool
+	spawnComplete   bool
 
 	// Data entries that may change
-	spawnBlock BlockXyz
-	position   AbsXyz
-	height     AbsCoord
-	look       LookDegrees
-	chunkSubs  chunkSubscriptions
-	health     Health
-
-	// The following data fields are loaded, but not used yet
-	dimension    int32
+	spawnBlock     BlockXyz
+	position       AbsXyz
+	height         AbsCoord
+	look           LookDegrees
+	chunkSubs      chunkSubscriptions
+	dimension      DimensionId
+	shardConnecter gamerules.IShardConnecter // For the current dimension.
+	portalTicks    Ticks                     // Time spent standing in a portal.
+
+	// Health and the things that affect it.
+	health       Health
+	environment  gamerules.PlayerEnvironment
 	onGround     int8
-	sleeping     int8
 	fallDistance float32
-	sleepTimer   int16
-	attackTime   int16
 	deathTime    int16
 	hurtTime     int16
-	motion       AbsVelocity
 	air          int16
 	fire         int16
 
+	// The following data fields are loaded, but not used yet
+	sleeping   int8
+	sleepTimer int16
+	attackTime int16
+	motion     AbsVelocity
+
 	cursor       gamerules.Slot // Item being moved by mouse cursor.
 	inventory    window.PlayerInventory
 	curWindow    window.IWindow
 	nextWindowId WindowId
 	remoteInv    *RemoteInventory
 
+	// Private messages. chatLock guards them, so that they can be read and
+	// changed from other goroutines, such as by WriteNbt and commands.
+	correspondent string          // The player that last whispered to this one.
+	ignoring      map[string]bool // Lowercase names of players whose messages are ignored.
+	chatLock      sync.Mutex
+
 	mainQueue chan func(*Player)
 	txQueue   chan []byte
 
@@ -84,13 +97,16 @@ type Player struct {
 	onDisconnect chan<- EntityId
 }
 
-func NewPlayer(entityId EntityId, shardConnecter gamerules.IShardConnecter, conn net.Conn, name string, spawnBlock BlockXyz, onDisconnect chan<- EntityId, game gamerules.IGame) *Player {
+// NewPlayer creates a player, who connects to the shards of each dimension
+// through shardConnecters. The normal dimension must be among them.
+func NewPlayer(entityId EntityId, shardConnecters map[DimensionId]gamerules.IShardConnecter, conn net.Conn, name string, spawnBlock BlockXyz, onDisconnect chan<- EntityId, game gamerules.IGame) *Player {
 	player := &Player{
-		EntityId:       entityId,
-		shardConnecter: shardConnecter,
-		conn:           conn,
-		name:           name,
-		spawnBlock:     spawnBlock,
+		EntityId:        entityId,
+		shardConnecters: shardConnecters,
+		shardConnecter:  shardConnecters[DimensionNormal],
+		conn:            conn,
+		name:            name,
+		spawnBlock:      spawnBlock,
 		position: AbsXyz{
 			X: AbsCoord(spawnBlock.X),
 			Y: AbsCoord(spawnBlock.Y),
@@ -100,10 +116,13 @@ func NewPlayer(entityId EntityId, shardConnecter gamerules.IShardConnecter, conn
 		look:   LookDegrees{0, 0},
 
 		health: MaxHealth,
+		air:    maxAir,
 
 		curWindow:    nil,
 		nextWindowId: WindowIdFreeMin,
 
+		ignoring: make(map[string]bool),
+
 		mainQueue: make(chan func(*Player), 128),
 		txQueue:   make(chan []byte, 128),
 
@@ -122,6 +141,15 @@ func (player *Player) Name() string {
 	return player.name
 }
 
+// Ip returns the IP address that the player is connected from.
+func (player *Player) Ip() string {
+	ip, _, err := net.SplitHostPort(player.conn.RemoteAddr().String())
+	if err != nil {
+		return player.conn.RemoteAddr().String()
+	}
+	return ip
+}
+
 func (player *Player) Position() AbsXyz {
 	return player.position
 }
@@ -163,9 +191,18 @@ func (player *Player) ReadNbt(playerData nbt.ITag) (err os.Error) {
 		return
 	}
 
-	if player.dimension, err = nbtutil.ReadInt(playerData, "Dimension"); err != nil {
+	dimension, err := nbtutil.ReadInt(playerData, "Dimension")
+	if err != nil {
 		return
 	}
+	if shardConnecter, ok := player.shardConnecters[DimensionId(dimension)]; ok {
+		player.dimension = DimensionId(dimension)
+		player.shardConnecter = shardConnecter
+	} else {
+		// The server does not run the dimension that the player was in.
+		log.Printf("Player %q was in unknown dimension %d, moving them to the spawn point", player.name, dimension)
+		player.position = player.spawnBlock.MidPointToAbsXyz()
+	}
 
 	if player.sleeping, err = nbtutil.ReadByte(playerData, "Sleeping"); err != nil {
 		return
@@ -203,6 +240,8 @@ func (player *Player) ReadNbt(playerData nbt.ITag) (err os.Error) {
 		return
 	}
 
+	player.readIgnoringNbt(playerData)
+
 	return
 }
 
@@ -211,7 +250,7 @@ func (player *Player) WriteNbt() *nbt.Compound {
 	data := &nbt.Compound{
 		map[string]nbt.ITag{
 			"OnGround":     &nbt.Byte{player.onGround},
-			"Dimension":    &nbt.Int{player.dimension},
+			"Dimension":    &nbt.Int{int32(player.dimension)},
 			"Sleeping":     &nbt.Byte{player.sleeping},
 			"FallDistance": &nbt.Float{player.fallDistance},
 			"SleepTimer":   &nbt.Short{player.sleepTimer},
@@ -234,8 +273,9 @@ func (player *Player) WriteNbt() *nbt.Compound {
 				&nbt.Double{float64(player.position.Y)},
 				&nbt.Double{float64(player.position.Z)},
 			}},
-			"Fire":   &nbt.Short{player.fire},
-			"Health": &nbt.Short{int16(player.health)},
+			"Fire":     &nbt.Short{player.fire},
+			"Health":   &nbt.Short{int16(player.health)},
+			"Ignoring": player.writeIgnoringNbt(),
 		},
 	}
 
@@ -253,9 +293,7 @@ func (player *Player) getHeldItemTypeId() ItemTypeId {
 
 func (player *Player) Start() {
 	buf := &bytes.Buffer{}
-	// TODO pass proper dimension. This is low priority, because we don't yet
-	// support multiple dimensions.
-	proto.ServerWriteLogin(buf, player.EntityId, 0, DimensionNormal)
+	proto.ServerWriteLogin(buf, player.EntityId, 0, player.dimension)
 	proto.WriteSpawnPosition(buf, &player.spawnBlock)
 	player.TransmitPacket(buf.Bytes())
 
@@ -272,11 +310,11 @@ func (player *Player) PacketKeepAlive() {
 }
 
 func (player *Player) PacketChatMessage(message string) {
-	prefix := gamerules.CommandFramework.Prefix()
-	if message[0:len(prefix)] == prefix {
+	commandFramework := gamerules.CommandFramework()
+	if strings.HasPrefix(message, commandFramework.Prefix()) {
 		// We pass the IPlayerClient to the command framework to avoid having
 		// to fetch it as the first part of every command.
-		gamerules.CommandFramework.Process(&player.playerClient, message, player.game)
+		commandFramework.Process(&player.playerClient, message, player.game)
 	} else {
 		player.sendChatMessage(fmt.Sprintf("<%s> %s", player.name, message), true)
 	}
@@ -286,12 +324,44 @@ func (player *Player) PacketEntityAction(entityId EntityId, action EntityAction)
 }
 
 func (player *Player) PacketUseEntity(user EntityId, target EntityId, leftClick bool) {
+	if !leftClick {
+		// TODO Right-clicking entities, e.g to ride them.
+		return
+	}
+
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	if player.health <= 0 || target == player.EntityId {
+		return
+	}
+
+	held, _ := player.inventory.HeldItem()
+	mayHurtPlayers := gamerules.MayAttackPlayers(player.name, player.dimension, player.position.ToBlockXyz())
+
+	// The target could be in any shard within reach of the player. Only the
+	// shard that has it will act on the attack.
+	for _, shardClient := range player.chunkSubs.ShardClientsNear(&player.position, MaxInteractDistance) {
+		shardClient.ReqAttackEntity(target, held, player.position, mayHurtPlayers)
+	}
 }
 
 func (player *Player) PacketRespawn(dimension DimensionId) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	player.respawn()
 }
 
 func (player *Player) PacketPlayer(onGround bool) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	if !player.spawnComplete {
+		return
+	}
+
+	player.updateFall(player.position.Y, onGround)
 }
 
 func (player *Player) PacketPlayerPosition(position *AbsXyz, stance AbsCoord, onGround bool) {
@@ -309,6 +379,7 @@ func (player *Player) PacketPlayerPosition(position *AbsXyz, stance AbsCoord, on
 			position.X, position.Y, position.Z)
 		return
 	}
+	player.updateFall(position.Y, onGround)
 	player.position = *position
 	player.height = stance - position.Y
 	player.chunkSubs.Move(position)
@@ -405,6 +476,17 @@ func (player *Player) PacketHoldingChange(slotId SlotId) {
 }
 
 func (player *Player) PacketEntityAnimation(entityId EntityId, animation EntityAnimation) {
+	if animation != EntityAnimationSwingArm {
+		return
+	}
+
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	// Let other players see the player swing their arm.
+	buf := new(bytes.Buffer)
+	proto.WriteEntityAnimation(buf, player.EntityId, animation)
+	player.multicastPacket(buf.Bytes(), false)
 }
 
 func (player *Player) PacketUnknown0x1b(field1, field2 float32, field3, field4 bool, field5, field6 float32) {
@@ -495,6 +577,20 @@ func (player *Player) PacketWindowTransaction(windowId WindowId, txId TxId, acce
 }
 
 func (player *Player) PacketSignUpdate(position *BlockXyz, lines [4]string) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	// Validate that the player is actually somewhere near the sign.
+	signAbsPos := position.MidPointToAbsXyz()
+	if !signAbsPos.IsWithinDistanceOf(&player.position, MaxInteractDistance) {
+		log.Printf("Player/PacketSignUpdate: ignoring sign update at %v (too far away)", position)
+		return
+	}
+
+	shardClient, _, ok := player.chunkSubs.ShardClientForBlockXyz(position)
+	if ok {
+		shardClient.ReqSetSignText(*position, lines)
+	}
 }
 
 func (player *Player) PacketDisconnect(reason string) {
@@ -508,6 +604,34 @@ func (player *Player) PacketDisconnect(reason string) {
 	player.conn.Close()
 }
 
+// Kick disconnects the player from the server, giving them the reason. It is
+// for use by the game, which must have already forgotten the player.
+func (player *Player) Kick(reason string) {
+	log.Printf("Player %s kicked reason=%s", player.name, reason)
+
+	buf := new(bytes.Buffer)
+	proto.WriteDisconnect(buf, reason)
+
+	// The connection is closed by transmitLoop once the packet has been sent.
+	// If the queue is full, the client is not keeping up, so it is closed
+	// straight away rather than waiting on it.
+	if !player.tryTransmit(buf.Bytes()) || !player.tryTransmit(nil) {
+		player.conn.Close()
+	}
+	player.mainQueue <- nil
+}
+
+// tryTransmit queues the packet to be sent, without waiting. It returns false
+// if the queue is full.
+func (player *Player) tryTransmit(packet []byte) bool {
+	select {
+	case player.txQueue <- packet:
+		return true
+	default:
+	}
+	return false
+}
+
 func (player *Player) receiveLoop() {
 	for {
 		err := proto.ServerReadPacket(player.conn, player)
@@ -523,6 +647,8 @@ func (player *Player) receiveLoop() {
 // End of packet handling code
 
 func (player *Player) transmitLoop() {
+	defer player.conn.Close()
+
 	for {
 		bs, ok := <-player.txQueue
 
@@ -561,12 +687,19 @@ func (player *Player) mainLoop() {
 
 	player.sendChatMessage(fmt.Sprintf("%s has joined", player.name), false)
 
+	ticker := time.NewTicker(NanosecondsInSecond / TicksPerSecond)
+	defer ticker.Stop()
+
 	for {
-		f, ok := <-player.mainQueue
-		if !ok || f == nil {
-			return
+		select {
+		case f, ok := <-player.mainQueue:
+			if !ok || f == nil {
+				return
+			}
+			player.runQueuedCall(f)
+		case <-ticker.C:
+			player.runQueuedCall((*Player).tick)
 		}
-		player.runQueuedCall(f)
 	}
 }
 
@@ -662,7 +795,7 @@ func (player *Player) inventoryUnsubscribed(block *BlockXyz) {
 	player.closeCurrentWindow(true)
 }
 
-func (player *Player) placeHeldItem(target *BlockXyz, wasHeld *gamerules.Slot) {
+func (player *Player) placeHeldItem(target *BlockXyz, wasHeld *gamerules.Slot, againstFace Face) {
 	curHeld, _ := player.inventory.HeldItem()
 
 	// Currently held item has changed since chunk saw it.
@@ -678,7 +811,7 @@ func (player *Player) placeHeldItem(target *BlockXyz, wasHeld *gamerules.Slot) {
 
 		player.inventory.TakeOneHeldItem(&into)
 
-		shardClient.ReqPlaceItem(*target, into)
+		shardClient.ReqPlaceItem(*target, into, againstFace, player.look)
 	}
 }
 
@@ -725,8 +858,12 @@ func (player *Player) sendChatMessage(message string, sendToSelf bool) {
 	buf := new(bytes.Buffer)
 	proto.WriteChatMessage(buf, message)
 
-	packet := buf.Bytes()
+	player.multicastPacket(buf.Bytes(), sendToSelf)
+}
 
+// multicastPacket sends a packet to all players in the area, optionally
+// including the player themself.
+func (player *Player) multicastPacket(packet []byte, sendToSelf bool) {
 	if sendToSelf {
 		player.TransmitPacket(packet)
 	}
@@ -21,6 +21,10 @@ func (p *playerClient) GetEntityId() EntityId {
 	return p.player.EntityId
 }
 
+func (p *playerClient) Name() string {
+	return p.player.name
+}
+
 func (p *playerClient) TransmitPacket(packet []byte) {
 	p.player.TransmitPacket(packet)
 }
@@ -67,9 +71,9 @@ func (p *playerClient) InventoryUnsubscribed(block BlockXyz) {
 	})
 }
 
-func (p *playerClient) PlaceHeldItem(target BlockXyz, wasHeld gamerules.Slot) {
+func (p *playerClient) PlaceHeldItem(target BlockXyz, wasHeld gamerules.Slot, againstFace Face) {
 	p.player.Enqueue(func(_ *Player) {
-		p.player.placeHeldItem(&target, &wasHeld)
+		p.player.placeHeldItem(&target, &wasHeld, againstFace)
 	})
 }
 
@@ -121,3 +125,42 @@ func (p *playerClient) SetPositionLook(pos AbsXyz, look LookDegrees) {
 		player.setPositionLook(pos, look)
 	})
 }
+
+func (p *playerClient) SetEnvironment(env gamerules.PlayerEnvironment) {
+	p.player.Enqueue(func(player *Player) {
+		player.setEnvironment(&env)
+	})
+}
+
+func (p *playerClient) Kill() {
+	p.player.Enqueue(func(player *Player) {
+		player.kill()
+	})
+}
+
+func (p *playerClient) Attacked(damage Health, knockback AbsVelocity) {
+	p.player.Enqueue(func(player *Player) {
+		player.attacked(damage, &knockback)
+	})
+}
+
+func (p *playerClient) ReceiveWhisper(from, msg string) {
+	p.player.Enqueue(func(player *Player) {
+		player.receiveWhisper(from, msg)
+	})
+}
+
+// The private message state has its own lock, so it is used directly rather
+// than through the player's mainloop, which may have already stopped.
+
+func (p *playerClient) Correspondent() string {
+	return p.player.Correspondent()
+}
+
+func (p *playerClient) SetIgnoring(name string, ignore bool) bool {
+	return p.player.setIgnoring(name, ignore)
+}
+
+func (p *playerClient) Ignoring() []string {
+	return p.player.ignoringNames()
+}
@@ -7,6 +7,10 @@ import (
 	. "chunkymonkey/types"
 )
 
+// ViewDistance is the distance in chunks around each player within which they
+// receive updates.
+var ViewDistance = ChunkCoord(ChunkRadius)
+
 // shardRef holds a reference to a shard connection and context for the number
 // of subscribed chunks inside the shard.
 type shardRef struct {
@@ -38,7 +42,7 @@ func (sub *chunkSubscriptions) Init(player *Player) {
 	sub.curChunkLoc = player.position.ToChunkXz()
 	sub.shardClients = make(map[uint64]*shardRef)
 
-	initialChunkLocs := orderedChunkSquare(sub.curChunkLoc, ChunkRadius)
+	initialChunkLocs := orderedChunkSquare(sub.curChunkLoc, ViewDistance)
 	sub.subscribeToChunks(sub.curChunkLoc, initialChunkLocs)
 
 	sub.curShard = sub.shardClients[sub.curShardLoc.Key()].shard
@@ -72,6 +76,40 @@ func (sub *chunkSubscriptions) Move(newLoc *AbsXyz) (notify bool) {
 	return
 }
 
+// Respawn moves the player to newLoc as a new body. They are removed from the
+// chunk that they died in before being added to the chunk at newLoc, so that
+// other players see the dead body go. Chunk subscriptions are adjusted and the
+// result is returned as for Move.
+func (sub *chunkSubscriptions) Respawn(newLoc *AbsXyz) (notify bool) {
+	sub.curShard.ReqRemovePlayerData(sub.curChunkLoc, true)
+
+	newChunkLoc := newLoc.ToChunkXz()
+	if newChunkLoc.X != sub.curChunkLoc.X || newChunkLoc.Z != sub.curChunkLoc.Z {
+		addChunkLocs := squareDifference(newChunkLoc, sub.curChunkLoc, ViewDistance)
+		notify = sub.subscribeToChunks(newChunkLoc, addChunkLocs)
+
+		delChunkLocs := squareDifference(sub.curChunkLoc, newChunkLoc, ViewDistance)
+		sub.unsubscribeFromChunks(delChunkLocs)
+
+		sub.curChunkLoc = newChunkLoc
+
+		newShardLoc := newLoc.ToShardXz()
+		if newShardLoc.X != sub.curShardLoc.X || newShardLoc.Z != sub.curShardLoc.Z {
+			sub.moveToShard(newShardLoc)
+		}
+	}
+
+	sub.curShard.ReqAddPlayerData(
+		sub.curChunkLoc,
+		sub.player.name,
+		*newLoc,
+		*sub.player.look.ToLookBytes(),
+		sub.player.getHeldItemTypeId(),
+	)
+
+	return
+}
+
 // Close closes down all shard connections. Use when the player is
 // disconnected.
 func (sub *chunkSubscriptions) Close() {
@@ -95,6 +133,28 @@ func (sub *chunkSubscriptions) CurrentShardClient() (conn gamerules.IPlayerShard
 	return shardRef.shard, ok
 }
 
+// ShardClientsNear returns connections to each of the shards that contain
+// part of the area within the given horizontal distance of position. distance
+// must be smaller than the width of a shard.
+func (sub *chunkSubscriptions) ShardClientsNear(position *AbsXyz, distance AbsCoord) (conns []gamerules.IPlayerShardClient) {
+	seen := make(map[uint64]bool)
+	for _, dx := range []AbsCoord{-distance, distance} {
+		for _, dz := range []AbsCoord{-distance, distance} {
+			corner := AbsXyz{position.X + dx, position.Y, position.Z + dz}
+			shardLoc := corner.ToShardXz()
+			key := shardLoc.Key()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if ref, ok := sub.shardClients[key]; ok {
+				conns = append(conns, ref.shard)
+			}
+		}
+	}
+	return
+}
+
 // ShardClientForBlockXyz is a convenience function to get the correct shard
 // connection and the ChunkXz within that chunk for a given BlockXyz position.
 // Returns ok = false if there is no open connection for that shard. Note that
@@ -181,7 +241,7 @@ func (sub *chunkSubscriptions) unsubscribeFromChunks(chunkLocs []ChunkXz) {
 // moveToChunk subscribes to chunks that are newly in range, and unsubscribes
 // to those that have just left.
 func (sub *chunkSubscriptions) moveToChunk(newChunkLoc ChunkXz, newLoc *AbsXyz) (notify bool) {
-	addChunkLocs := squareDifference(newChunkLoc, sub.curChunkLoc, ChunkRadius)
+	addChunkLocs := squareDifference(newChunkLoc, sub.curChunkLoc, ViewDistance)
 	notify = sub.subscribeToChunks(newChunkLoc, addChunkLocs)
 
 	newShardLoc := newChunkLoc.ToShardXz()
@@ -200,7 +260,7 @@ func (sub *chunkSubscriptions) moveToChunk(newChunkLoc ChunkXz, newLoc *AbsXyz)
 		ref.shard.ReqRemovePlayerData(sub.curChunkLoc, false)
 	}
 
-	delChunkLocs := squareDifference(sub.curChunkLoc, newChunkLoc, ChunkRadius)
+	delChunkLocs := squareDifference(sub.curChunkLoc, newChunkLoc, ViewDistance)
 	sub.unsubscribeFromChunks(delChunkLocs)
 
 	sub.curChunkLoc = newChunkLoc
@@ -224,7 +284,8 @@ func (sub *chunkSubscriptions) moveToShard(newShardLoc ShardXz) {
 // AAA
 // ACCB
 // ACCB
-//  BBB
+//
+//	BBB
 //
 // Results will be:
 //
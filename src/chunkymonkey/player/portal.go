@@ -0,0 +1,81 @@
+package player
+
+import (
+	"bytes"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+const (
+	// The number of ticks that a player must stand in a portal before they are
+	// taken to the other dimension.
+	portalDelayTicks = 4 * TicksPerSecond
+
+	// Distances in the Nether are netherScale times those in the normal world.
+	netherScale = 8
+)
+
+// portalTick takes the player through a portal once they have stood in it for
+// long enough. It must be called with player.lock held.
+func (player *Player) portalTick() {
+	if !player.environment.InPortal {
+		player.portalTicks = 0
+		return
+	}
+
+	player.portalTicks++
+	if player.portalTicks < portalDelayTicks {
+		return
+	}
+	player.portalTicks = 0
+
+	dimension := DimensionNether
+	position := player.position
+	if player.dimension == DimensionNether {
+		dimension = DimensionNormal
+		position.X *= netherScale
+		position.Z *= netherScale
+	} else {
+		position.X /= netherScale
+		position.Z /= netherScale
+	}
+
+	if _, ok := player.shardConnecters[dimension]; !ok {
+		// The server does not run the other dimension.
+		return
+	}
+
+	player.changeDimension(dimension, position)
+
+	// The player arrives in a portal near the same place.
+	target := position.ToBlockXyz()
+	if shardClient, _, ok := player.chunkSubs.ShardClientForBlockXyz(target); ok {
+		shardClient.ReqArriveByPortal(*target, player.look)
+	}
+}
+
+// changeDimension moves the player to position in another dimension. The
+// client is sent the chunks around them there before they are put there. It
+// must be called with player.lock held.
+func (player *Player) changeDimension(dimension DimensionId, position AbsXyz) {
+	player.closeCurrentWindow(true)
+	player.chunkSubs.Close()
+
+	player.dimension = dimension
+	player.shardConnecter = player.shardConnecters[dimension]
+	player.position = position
+	player.environment = gamerules.PlayerEnvironment{}
+	player.fallDistance = 0
+	player.portalTicks = 0
+
+	// The client forgets the chunks of the old dimension, and waits for those
+	// of the new one.
+	player.spawnComplete = false
+	buf := new(bytes.Buffer)
+	proto.WriteRespawn(buf, dimension)
+	player.TransmitPacket(buf.Bytes())
+
+	player.chunkSubs.Init(player)
+}
@@ -0,0 +1,75 @@
+package player
+
+import (
+	"testing"
+
+	"gomock.googlecode.com/hg/gomock"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// newTestShardConnecter returns a shard connecter whose shards accept any
+// requests from the player other than ReqArriveByPortal.
+func newTestShardConnecter(mockCtrl *gomock.Controller) (*gamerules.MockIShardConnecter, *gamerules.MockIPlayerShardClient) {
+	shard := gamerules.NewMockIPlayerShardClient(mockCtrl)
+	shard.EXPECT().Disconnect().AnyTimes()
+	shard.EXPECT().ReqSubscribeChunk(gomock.Any(), gomock.Any()).AnyTimes()
+	shard.EXPECT().ReqAddPlayerData(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	shard.EXPECT().ReqRemovePlayerData(gomock.Any(), gomock.Any()).AnyTimes()
+
+	connecter := gamerules.NewMockIShardConnecter(mockCtrl)
+	connecter.EXPECT().PlayerShardConnect(gomock.Any(), gomock.Any(), gomock.Any()).Return(shard).AnyTimes()
+
+	return connecter, shard
+}
+
+func expectPosition(t *testing.T, player *Player, dimension DimensionId, position AbsXyz) {
+	if player.dimension != dimension {
+		t.Errorf("expected the player to be in dimension %d, got %d", dimension, player.dimension)
+	}
+	got := player.position
+	if got.X != position.X || got.Y != position.Y || got.Z != position.Z {
+		t.Errorf("expected the player at %#v, got %#v", position, got)
+	}
+}
+
+func TestPortalTick(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	normal, normalShard := newTestShardConnecter(mockCtrl)
+	nether, netherShard := newTestShardConnecter(mockCtrl)
+	shardConnecters := map[DimensionId]gamerules.IShardConnecter{
+		DimensionNormal: normal,
+		DimensionNether: nether,
+	}
+
+	player := NewPlayer(EntityId(1), shardConnecters, nil, "thePlayer", BlockXyz{0, 64, 0}, nil, nil)
+	player.position = AbsXyz{800.5, 64, -1600.5}
+	player.chunkSubs.Init(player)
+
+	// Nothing happens until the player has stood in the portal for long
+	// enough.
+	player.environment.InPortal = true
+	for i := 0; i < portalDelayTicks-1; i++ {
+		player.portalTick()
+	}
+	expectPosition(t, player, DimensionNormal, AbsXyz{800.5, 64, -1600.5})
+
+	// Distances in the Nether are an eighth of those in the normal world.
+	netherShard.EXPECT().ReqArriveByPortal(BlockXyz{100, 64, -201}, gomock.Any())
+	player.portalTick()
+	expectPosition(t, player, DimensionNether, AbsXyz{100.0625, 64, -200.0625})
+
+	// The environment is reset, so the player must stand in the portal again
+	// to go back.
+	if player.environment.InPortal {
+		t.Fatalf("expected the player's environment to be reset")
+	}
+	player.environment.InPortal = true
+	player.portalTicks = portalDelayTicks - 1
+	normalShard.EXPECT().ReqArriveByPortal(BlockXyz{800, 64, -1601}, gomock.Any())
+	player.portalTick()
+	expectPosition(t, player, DimensionNormal, AbsXyz{800.5, 64, -1600.5})
+}
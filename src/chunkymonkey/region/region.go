@@ -0,0 +1,151 @@
+package region
+
+import (
+	"strings"
+
+	. "chunkymonkey/types"
+)
+
+// The flags of a region say what players who are neither owners nor members of
+// the region may do within it.
+const (
+	FlagBuild = "build" // Digging and placing blocks.
+	FlagChest = "chest" // Opening chests and other blocks with inventory.
+	FlagPvp   = "pvp"   // Attacking other players.
+)
+
+// Flags lists all of the flags that a region can have.
+var Flags = []string{FlagBuild, FlagChest, FlagPvp}
+
+// IsFlag returns true if name is one of Flags.
+func IsFlag(name string) bool {
+	for _, flag := range Flags {
+		if flag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Region is a cuboid of the world within which only its owners and members may
+// do things that its flags do not allow.
+type Region struct {
+	Dimension DimensionId
+	Min, Max  BlockXyz // Opposite corners of the region, both inclusive.
+	Owners    []string
+	Members   []string
+	Flags     map[string]bool // Flags that are not set are not allowed.
+}
+
+// NewRegion creates a region with no owners or members that allows nothing,
+// lying between the given corners.
+func NewRegion(dimension DimensionId, corner1, corner2 *BlockXyz) *Region {
+	r := &Region{
+		Dimension: dimension,
+		Min:       *corner1,
+		Max:       *corner2,
+		Flags:     make(map[string]bool),
+	}
+
+	if r.Min.X > r.Max.X {
+		r.Min.X, r.Max.X = r.Max.X, r.Min.X
+	}
+	if r.Min.Y > r.Max.Y {
+		r.Min.Y, r.Max.Y = r.Max.Y, r.Min.Y
+	}
+	if r.Min.Z > r.Max.Z {
+		r.Min.Z, r.Max.Z = r.Max.Z, r.Min.Z
+	}
+
+	return r
+}
+
+// Contains returns true if the block is within the region.
+func (r *Region) Contains(dimension DimensionId, blockLoc *BlockXyz) bool {
+	return dimension == r.Dimension &&
+		blockLoc.X >= r.Min.X && blockLoc.X <= r.Max.X &&
+		blockLoc.Y >= r.Min.Y && blockLoc.Y <= r.Max.Y &&
+		blockLoc.Z >= r.Min.Z && blockLoc.Z <= r.Max.Z
+}
+
+// IsOwner returns true if the player owns the region.
+func (r *Region) IsOwner(username string) bool {
+	return containsName(r.Owners, username)
+}
+
+// IsMember returns true if the player is a member or an owner of the region.
+func (r *Region) IsMember(username string) bool {
+	return r.IsOwner(username) || containsName(r.Members, username)
+}
+
+// Allows returns true if the player may do what the flag is for within the
+// region.
+func (r *Region) Allows(username string, flag string) bool {
+	return r.Flags[flag] || r.IsMember(username)
+}
+
+// SetFlag sets whether players who are not members may do what the flag is
+// for within the region.
+func (r *Region) SetFlag(flag string, allow bool) {
+	if r.Flags == nil {
+		r.Flags = make(map[string]bool)
+	}
+	r.Flags[flag] = allow
+}
+
+func (r *Region) AddOwner(username string) {
+	r.Owners = addName(r.Owners, username)
+}
+
+func (r *Region) RemoveOwner(username string) {
+	r.Owners = removeName(r.Owners, username)
+}
+
+func (r *Region) AddMember(username string) {
+	r.Members = addName(r.Members, username)
+}
+
+func (r *Region) RemoveMember(username string) {
+	r.Members = removeName(r.Members, username)
+}
+
+// clone returns a copy of the region that shares nothing with it.
+func (r *Region) clone() *Region {
+	c := *r
+	c.Owners = append([]string(nil), r.Owners...)
+	c.Members = append([]string(nil), r.Members...)
+	c.Flags = make(map[string]bool)
+	for flag, allow := range r.Flags {
+		c.Flags[flag] = allow
+	}
+	return &c
+}
+
+// containsName returns true if name is in names. Player names are compared
+// without regard to case.
+func containsName(names []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, n := range names {
+		if strings.ToLower(n) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func addName(names []string, name string) []string {
+	if containsName(names, name) {
+		return names
+	}
+	return append(names, name)
+}
+
+func removeName(names []string, name string) []string {
+	name = strings.ToLower(name)
+	for i, n := range names {
+		if strings.ToLower(n) == name {
+			return append(names[:i], names[i+1:]...)
+		}
+	}
+	return names
+}
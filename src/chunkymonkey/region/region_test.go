@@ -0,0 +1,128 @@
+package region
+
+import (
+	"strings"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+const testRegionsJson = `{
+	"castle": {
+		"Dimension": 0,
+		"Min": {"X": 10, "Y": 0, "Z": 10},
+		"Max": {"X": 20, "Y": 127, "Z": 20},
+		"Owners": ["huin"],
+		"Members": ["agon"],
+		"Flags": {"chest": true}
+	},
+	"fortress": {
+		"Dimension": -1,
+		"Min": {"X": 0, "Y": 0, "Z": 0},
+		"Max": {"X": 5, "Y": 127, "Z": 5},
+		"Flags": {"pvp": true}
+	}
+}`
+
+func testLoadRegions() *Regions {
+	regions, err := LoadRegions(strings.NewReader(testRegionsJson))
+	if err != nil {
+		panic(err)
+	}
+	return regions
+}
+
+func TestNewRegion(t *testing.T) {
+	r := NewRegion(DimensionNormal, &BlockXyz{5, 70, -3}, &BlockXyz{-2, 60, 4})
+
+	if r.Min.X != -2 || r.Min.Y != 60 || r.Min.Z != -3 {
+		t.Errorf("Expected min corner (-2, 60, -3), got %v", r.Min)
+	}
+	if r.Max.X != 5 || r.Max.Y != 70 || r.Max.Z != 4 {
+		t.Errorf("Expected max corner (5, 70, 4), got %v", r.Max)
+	}
+}
+
+func TestRegions_Allows(t *testing.T) {
+	regions := testLoadRegions()
+
+	type Test struct {
+		username  string
+		dimension DimensionId
+		blockLoc  BlockXyz
+		flag      string
+		expected  bool
+	}
+
+	tests := []Test{
+		// Outside of any region.
+		{"griefy", DimensionNormal, BlockXyz{0, 64, 0}, FlagBuild, true},
+		// Owners and members may do anything.
+		{"huin", DimensionNormal, BlockXyz{15, 64, 15}, FlagBuild, true},
+		{"agon", DimensionNormal, BlockXyz{10, 0, 20}, FlagBuild, true},
+		// Names are not case sensitive.
+		{"Huin", DimensionNormal, BlockXyz{15, 64, 15}, FlagBuild, true},
+		// Others may only do what the flags allow.
+		{"griefy", DimensionNormal, BlockXyz{15, 64, 15}, FlagBuild, false},
+		{"griefy", DimensionNormal, BlockXyz{15, 64, 15}, FlagChest, true},
+		{"griefy", DimensionNormal, BlockXyz{15, 64, 15}, FlagPvp, false},
+		// Regions are only in their own dimension.
+		{"griefy", DimensionNether, BlockXyz{15, 64, 15}, FlagBuild, true},
+		{"griefy", DimensionNether, BlockXyz{2, 64, 2}, FlagBuild, false},
+		{"griefy", DimensionNormal, BlockXyz{2, 64, 2}, FlagBuild, true},
+		{"griefy", DimensionNether, BlockXyz{2, 64, 2}, FlagPvp, true},
+	}
+
+	for i := range tests {
+		test := &tests[i]
+		result := regions.Allows(test.username, test.dimension, &test.blockLoc, test.flag)
+		if test.expected != result {
+			t.Errorf("Expected Allows(%q, %d, %v, %q) to be %t", test.username, test.dimension, test.blockLoc, test.flag, test.expected)
+		}
+	}
+}
+
+func TestRegions_SetSpawn(t *testing.T) {
+	regions := testLoadRegions()
+	regions.SetSpawn(&BlockXyz{100, 64, 100})
+
+	if regions.Allows("griefy", DimensionNormal, &BlockXyz{100 + BlockCoord(SpawnProtectionRadius), 0, 100}, FlagBuild) {
+		t.Errorf("Expected the edge of spawn protection not to allow building")
+	}
+	if !regions.Allows("griefy", DimensionNormal, &BlockXyz{101 + BlockCoord(SpawnProtectionRadius), 0, 100}, FlagBuild) {
+		t.Errorf("Expected outside of spawn protection to allow building")
+	}
+	if !regions.Allows("griefy", DimensionNormal, &BlockXyz{100, 64, 100}, FlagChest) {
+		t.Errorf("Expected spawn protection to allow opening chests")
+	}
+	if !regions.Allows("griefy", DimensionNether, &BlockXyz{100, 64, 100}, FlagBuild) {
+		t.Errorf("Expected spawn protection not to be in the Nether")
+	}
+}
+
+func TestRegions_Update(t *testing.T) {
+	regions := testLoadRegions()
+
+	if ok, _ := regions.Update("nowhere", func(r *Region) {}); ok {
+		t.Errorf("Expected updating a missing region to fail")
+	}
+
+	ok, err := regions.Update("castle", func(r *Region) {
+		r.AddMember("griefy")
+		r.RemoveOwner("huin")
+	})
+	if !ok || err != nil {
+		t.Fatalf("Expected updating castle to succeed, got ok=%t err=%v", ok, err)
+	}
+
+	r, _ := regions.Region("castle")
+	if !r.IsMember("griefy") || r.IsMember("huin") {
+		t.Errorf("Expected griefy and not huin to be members, got owners %v and members %v", r.Owners, r.Members)
+	}
+
+	// Changing a copy must not change the region.
+	r.AddMember("newbie")
+	if regions.Allows("newbie", DimensionNormal, &BlockXyz{15, 64, 15}, FlagBuild) {
+		t.Errorf("Expected changing a copy of a region to have no effect")
+	}
+}
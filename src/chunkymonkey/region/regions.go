@@ -0,0 +1,194 @@
+package region
+
+import (
+	"io"
+	"io/ioutil"
+	"json"
+	"os"
+	"sort"
+	"sync"
+
+	. "chunkymonkey/types"
+)
+
+// SpawnProtectionRadius is how far from the spawn point, horizontally, blocks
+// are protected by SetSpawn. Zero turns off spawn protection.
+var SpawnProtectionRadius = 16
+
+// Regions is a set of named regions, stored in a JSON file. It is safe to use
+// from multiple goroutines.
+type Regions struct {
+	lock     sync.RWMutex
+	filename string // Empty if the regions are not saved.
+	regions  map[string]*Region
+
+	// Protects the spawn point. It is not saved with the other regions.
+	spawn *Region
+}
+
+// LoadRegionsFromFile loads the regions in filename. Changes to the regions are
+// saved back to the file.
+func LoadRegionsFromFile(filename string) (regions *Regions, err os.Error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	if regions, err = LoadRegions(file); err != nil {
+		return
+	}
+	regions.filename = filename
+
+	return
+}
+
+// LoadRegions reads regions from reader. Changes to the regions are not saved.
+func LoadRegions(reader io.Reader) (regions *Regions, err os.Error) {
+	regions = &Regions{
+		regions: make(map[string]*Region),
+	}
+
+	decoder := json.NewDecoder(reader)
+	if err = decoder.Decode(&regions.regions); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Reload replaces the regions with those in filename, and saves them there
+// from then on. The regions are not changed if the file fails to load.
+func (regions *Regions) Reload(filename string) os.Error {
+	loaded, err := LoadRegionsFromFile(filename)
+	if err != nil {
+		return err
+	}
+
+	regions.lock.Lock()
+	defer regions.lock.Unlock()
+
+	regions.filename = loaded.filename
+	regions.regions = loaded.regions
+
+	return nil
+}
+
+// save writes the regions to their file. It must be called with the lock held.
+func (regions *Regions) save() os.Error {
+	if regions.filename == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(regions.regions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(regions.filename, data, 0644)
+}
+
+// SetSpawn protects the blocks within SpawnProtectionRadius of the spawn point
+// in the normal dimension, from the bottom of the world to the top.
+func (regions *Regions) SetSpawn(spawn *BlockXyz) {
+	regions.lock.Lock()
+	defer regions.lock.Unlock()
+
+	if SpawnProtectionRadius <= 0 {
+		regions.spawn = nil
+		return
+	}
+
+	radius := BlockCoord(SpawnProtectionRadius)
+	regions.spawn = NewRegion(
+		DimensionNormal,
+		&BlockXyz{spawn.X - radius, 0, spawn.Z - radius},
+		&BlockXyz{spawn.X + radius, ChunkSizeY - 1, spawn.Z + radius})
+	regions.spawn.SetFlag(FlagChest, true)
+	regions.spawn.SetFlag(FlagPvp, true)
+}
+
+// Allows returns true if every region that contains the block allows the
+// player to do what the flag is for.
+func (regions *Regions) Allows(username string, dimension DimensionId, blockLoc *BlockXyz, flag string) bool {
+	regions.lock.RLock()
+	defer regions.lock.RUnlock()
+
+	if regions.spawn != nil && regions.spawn.Contains(dimension, blockLoc) && !regions.spawn.Allows(username, flag) {
+		return false
+	}
+
+	for _, r := range regions.regions {
+		if r.Contains(dimension, blockLoc) && !r.Allows(username, flag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Names returns the names of the regions in order.
+func (regions *Regions) Names() []string {
+	regions.lock.RLock()
+	defer regions.lock.RUnlock()
+
+	names := make([]string, 0, len(regions.regions))
+	for name := range regions.regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Region returns a copy of the named region.
+func (regions *Regions) Region(name string) (r *Region, ok bool) {
+	regions.lock.RLock()
+	defer regions.lock.RUnlock()
+
+	if r, ok = regions.regions[name]; ok {
+		r = r.clone()
+	}
+	return
+}
+
+// Define adds the region with the given name, replacing any region that
+// already has the name, and saves the regions.
+func (regions *Regions) Define(name string, r *Region) os.Error {
+	regions.lock.Lock()
+	defer regions.lock.Unlock()
+
+	regions.regions[name] = r.clone()
+	return regions.save()
+}
+
+// Remove removes the named region and saves the regions. It returns false if
+// there is no region with the name.
+func (regions *Regions) Remove(name string) (ok bool, err os.Error) {
+	regions.lock.Lock()
+	defer regions.lock.Unlock()
+
+	if _, ok = regions.regions[name]; !ok {
+		return
+	}
+	regions.regions[name] = nil, false
+	err = regions.save()
+
+	return
+}
+
+// Update calls fn to change the named region, and then saves the regions. It
+// returns false if there is no region with the name.
+func (regions *Regions) Update(name string, fn func(r *Region)) (ok bool, err os.Error) {
+	regions.lock.Lock()
+	defer regions.lock.Unlock()
+
+	r, ok := regions.regions[name]
+	if !ok {
+		return
+	}
+	fn(r)
+	err = regions.save()
+
+	return
+}
@@ -0,0 +1,456 @@
+package shardnet
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// The time that Save waits for shard servers to write their chunks.
+const saveTimeoutNs = 60e9
+
+// The time that a player's requests are dropped for after a shard server could
+// not be reached, before it is tried again.
+const redialIntervalNs = 5e9
+
+const msgShardServerLost = "Part of the world cannot be reached. Please reconnect later."
+
+// Connecter implements IShardConnecter for shards served by other processes.
+// It keeps a single connection open to each shard server, shared by all
+// players and shards that use it.
+type Connecter struct {
+	shardMap *ShardMap
+	conns    map[string]*clientConn
+	lock     sync.Mutex
+}
+
+func NewConnecter(shardMap *ShardMap) *Connecter {
+	return &Connecter{
+		shardMap: shardMap,
+		conns:    make(map[string]*clientConn),
+	}
+}
+
+func (connecter *Connecter) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
+	client := &remotePlayerShardClient{
+		connecter: connecter,
+		player:    player,
+		entityId:  entityId,
+		shardLoc:  shardLoc,
+	}
+	if !client.dial() {
+		// This is called from the player's own goroutine, which must not wait
+		// upon itself.
+		go player.EchoMessage(msgShardServerLost)
+	}
+
+	return client
+}
+
+func (connecter *Connecter) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+	cc := connecter.connTo(connecter.shardMap.Addr(shardLoc))
+	if cc == nil {
+		// (Return nil explicitly - interfaces containing nil pointer don't
+		// equal nil).
+		return nil
+	}
+
+	return &remoteShardShardClient{
+		conn:     cc,
+		shardLoc: shardLoc,
+	}
+}
+
+// Save asks each shard server to write its chunks, and waits until they have
+// done so or saveTimeoutNs has passed.
+func (connecter *Connecter) Save() {
+	var conns []*clientConn
+	for i := 0; i < connecter.shardMap.NumServers(); i++ {
+		if cc := connecter.connTo(connecter.shardMap.ServerAddr(i)); cc != nil {
+			cc.send(&envelope{Body: signalSave})
+			conns = append(conns, cc)
+		}
+	}
+
+	timeout := time.After(saveTimeoutNs)
+	for _, cc := range conns {
+		select {
+		case <-cc.saved:
+		case <-cc.done:
+			log.Printf("shardnet: lost connection to shard server %v while it was saving", cc)
+		case <-timeout:
+			log.Print("shardnet: timed out waiting for shard servers to save")
+			return
+		}
+	}
+}
+
+// connTo returns the connection to the shard server at addr, connecting to it
+// if not already connected or if the connection has been lost. It returns nil
+// if the server cannot be reached.
+func (connecter *Connecter) connTo(addr string) *clientConn {
+	connecter.lock.Lock()
+	defer connecter.lock.Unlock()
+
+	if cc, ok := connecter.conns[addr]; ok && !cc.closed() {
+		return cc
+	}
+
+	netConn, err := net.Dial("tcp", addr)
+	if err != nil {
+		log.Printf("shardnet: could not connect to shard server %s: %v", addr, err)
+		return nil
+	}
+
+	cc := &clientConn{
+		conn:    newConn(netConn),
+		players: make(map[EntityId]*playerRef),
+		saved:   make(chan bool, 1),
+	}
+	connecter.conns[addr] = cc
+
+	go func() {
+		cc.receiveLoop(cc.handle)
+
+		connecter.lock.Lock()
+		if connecter.conns[addr] == cc {
+			connecter.conns[addr] = nil, false
+		}
+		connecter.lock.Unlock()
+
+		log.Printf("shardnet: lost connection to shard server %s", addr)
+		cc.tellPlayersLost()
+	}()
+
+	return cc
+}
+
+// clientConn is a connection to a shard server. It passes on the requests
+// that shards make of players.
+type clientConn struct {
+	*conn
+	players map[EntityId]*playerRef
+	saved   chan bool // Sent to when the server has written its chunks.
+	lock    sync.Mutex
+}
+
+// playerRef counts the shards on a server that a player is connected to.
+type playerRef struct {
+	player gamerules.IPlayerClient
+	count  int
+}
+
+func (cc *clientConn) addPlayer(entityId EntityId, player gamerules.IPlayerClient) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	ref, ok := cc.players[entityId]
+	if !ok {
+		ref = &playerRef{player: player}
+		cc.players[entityId] = ref
+	}
+	ref.count++
+}
+
+func (cc *clientConn) removePlayer(entityId EntityId) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	if ref, ok := cc.players[entityId]; ok {
+		ref.count--
+		if ref.count <= 0 {
+			cc.players[entityId] = nil, false
+		}
+	}
+}
+
+func (cc *clientConn) player(entityId EntityId) (player gamerules.IPlayerClient, ok bool) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	ref, ok := cc.players[entityId]
+	if !ok {
+		return nil, false
+	}
+	return ref.player, true
+}
+
+// tellPlayersLost tells the players that were using the connection that it has
+// been lost. Their requests to the server are dropped from then on.
+func (cc *clientConn) tellPlayersLost() {
+	cc.lock.Lock()
+	players := make([]gamerules.IPlayerClient, 0, len(cc.players))
+	for _, ref := range cc.players {
+		players = append(players, ref.player)
+	}
+	cc.lock.Unlock()
+
+	for _, player := range players {
+		player.EchoMessage(msgShardServerLost)
+	}
+}
+
+// handle passes a request from a shard on to the player that it is for, or
+// records that the server has written its chunks.
+func (cc *clientConn) handle(msg *envelope) {
+	if msg.Body == signalSaved {
+		select {
+		case cc.saved <- true:
+		default:
+		}
+		return
+	}
+
+	player, ok := cc.player(msg.EntityId)
+	if !ok {
+		// The player has since disconnected from the shard.
+		return
+	}
+
+	switch body := msg.Body.(type) {
+	case *transmitPacket:
+		player.TransmitPacket(body.Packet)
+	case *inventorySubscribed:
+		player.InventorySubscribed(body.Block, body.InvTypeId, body.Slots)
+	case *inventorySlotUpdate:
+		player.InventorySlotUpdate(body.Block, body.Slot, body.SlotId)
+	case *inventoryProgressUpdate:
+		player.InventoryProgressUpdate(body.Block, body.PrgBarId, body.Value)
+	case *inventoryCursorUpdate:
+		player.InventoryCursorUpdate(body.Block, body.Cursor)
+	case *inventoryTxState:
+		player.InventoryTxState(body.Block, body.TxId, body.Accepted)
+	case *inventoryUnsubscribed:
+		player.InventoryUnsubscribed(body.Block)
+	case *placeHeldItem:
+		player.PlaceHeldItem(body.Target, body.WasHeld, body.AgainstFace)
+	case *offerItem:
+		player.OfferItem(body.FromChunk, body.EntityId, body.Item)
+	case *giveItemAtPosition:
+		player.GiveItemAtPosition(body.AtPosition, body.Item)
+	case *giveItem:
+		player.GiveItem(body.Item)
+	case *setPositionLook:
+		player.SetPositionLook(body.Position, body.Look)
+	case *echoMessage:
+		player.EchoMessage(body.Msg)
+	case *receiveWhisper:
+		player.ReceiveWhisper(body.From, body.Msg)
+	case *setEnvironment:
+		player.SetEnvironment(body.Env)
+	case *attacked:
+		player.Attacked(body.Damage, body.Knockback)
+	case signal:
+		switch body {
+		case signalNotifyChunkLoad:
+			player.NotifyChunkLoad()
+		case signalKill:
+			player.Kill()
+		}
+	default:
+		log.Printf("shardnet: unexpected message %T from %v for player %d", msg.Body, cc, msg.EntityId)
+	}
+}
+
+// remotePlayerShardClient implements IPlayerShardClient for a shard on a
+// shard server. If the connection to the server is lost, the next request
+// connects to it again. Requests are dropped while the server cannot be
+// reached, and the player is told so.
+type remotePlayerShardClient struct {
+	connecter *Connecter
+	conn      *clientConn
+	player    gamerules.IPlayerClient
+	entityId  EntityId
+	shardLoc  ShardXz
+	failed    bool  // Set once a request has been dropped.
+	redialAt  int64 // Time before which the server is not tried again.
+}
+
+// dial connects the player to the shard server, returning false if it cannot
+// be reached.
+func (client *remotePlayerShardClient) dial() bool {
+	client.conn = client.connecter.connTo(client.connecter.shardMap.Addr(client.shardLoc))
+	if client.conn == nil {
+		client.redialAt = time.Nanoseconds() + redialIntervalNs
+		return false
+	}
+
+	client.conn.addPlayer(client.entityId, client.player)
+	client.conn.send(&envelope{client.entityId, client.shardLoc, &reqConnectPlayer{client.player.Name()}})
+	client.failed = false
+	return true
+}
+
+func (client *remotePlayerShardClient) send(body interface{}) {
+	if client.conn == nil || client.conn.closed() {
+		// The player was told when the connection was lost, or when the server
+		// could not be reached.
+		if client.conn == nil && time.Nanoseconds() < client.redialAt {
+			client.dropped()
+			return
+		}
+		if !client.dial() {
+			client.dropped()
+			return
+		}
+	}
+
+	if !client.conn.send(&envelope{client.entityId, client.shardLoc, body}) {
+		client.dropped()
+	}
+}
+
+// dropped logs that a request has been dropped. The player was told when the
+// server was lost, so this is only logged the first time.
+func (client *remotePlayerShardClient) dropped() {
+	if !client.failed {
+		client.failed = true
+		log.Printf("shardnet: dropping requests from player %d to shard %v, as its server cannot be reached",
+			client.entityId, client.shardLoc)
+	}
+}
+
+func (client *remotePlayerShardClient) Disconnect() {
+	if client.conn != nil {
+		client.conn.send(&envelope{client.entityId, client.shardLoc, signalDisconnect})
+		client.conn.removePlayer(client.entityId)
+	}
+}
+
+func (client *remotePlayerShardClient) ReqSubscribeChunk(chunkLoc ChunkXz, notify bool) {
+	client.send(&reqSubscribeChunk{chunkLoc, notify})
+}
+
+func (client *remotePlayerShardClient) ReqUnsubscribeChunk(chunkLoc ChunkXz) {
+	client.send(&reqUnsubscribeChunk{chunkLoc})
+}
+
+func (client *remotePlayerShardClient) ReqMulticastPlayers(chunkLoc ChunkXz, exclude EntityId, packet []byte) {
+	client.send(&reqMulticastPlayers{chunkLoc, exclude, packet})
+}
+
+func (client *remotePlayerShardClient) ReqAddPlayerData(chunkLoc ChunkXz, name string, position AbsXyz, look LookBytes, held ItemTypeId) {
+	client.send(&reqAddPlayerData{chunkLoc, name, position, look, held})
+}
+
+func (client *remotePlayerShardClient) ReqRemovePlayerData(chunkLoc ChunkXz, isDisconnect bool) {
+	client.send(&reqRemovePlayerData{chunkLoc, isDisconnect})
+}
+
+func (client *remotePlayerShardClient) ReqSetPlayerPosition(chunkLoc ChunkXz, position AbsXyz) {
+	client.send(&reqSetPlayerPosition{chunkLoc, position})
+}
+
+func (client *remotePlayerShardClient) ReqSetPlayerLook(chunkLoc ChunkXz, look LookBytes) {
+	client.send(&reqSetPlayerLook{chunkLoc, look})
+}
+
+func (client *remotePlayerShardClient) ReqHitBlock(held gamerules.Slot, target BlockXyz, digStatus DigStatus, face Face) {
+	client.send(&reqHitBlock{held, target, digStatus, face})
+}
+
+func (client *remotePlayerShardClient) ReqInteractBlock(held gamerules.Slot, target BlockXyz, face Face) {
+	client.send(&reqInteractBlock{held, target, face})
+}
+
+func (client *remotePlayerShardClient) ReqPlaceItem(target BlockXyz, slot gamerules.Slot, againstFace Face, look LookDegrees) {
+	client.send(&reqPlaceItem{target, slot, againstFace, look})
+}
+
+func (client *remotePlayerShardClient) ReqTakeItem(chunkLoc ChunkXz, entityId EntityId) {
+	client.send(&reqTakeItem{chunkLoc, entityId})
+}
+
+func (client *remotePlayerShardClient) ReqDropItem(content gamerules.Slot, position AbsXyz, velocity AbsVelocity, pickupImmunity Ticks) {
+	client.send(&reqDropItem{content, position, velocity, pickupImmunity})
+}
+
+func (client *remotePlayerShardClient) ReqInventoryClick(block BlockXyz, click gamerules.Click) {
+	client.send(&reqInventoryClick{block, click})
+}
+
+func (client *remotePlayerShardClient) ReqInventoryUnsubscribed(block BlockXyz) {
+	client.send(&reqInventoryUnsubscribed{block})
+}
+
+func (client *remotePlayerShardClient) ReqSetSignText(target BlockXyz, lines [4]string) {
+	client.send(&reqSetSignText{target, lines})
+}
+
+func (client *remotePlayerShardClient) ReqAttackEntity(target EntityId, held gamerules.Slot, position AbsXyz, mayHurtPlayers bool) {
+	client.send(&reqAttackEntity{target, held, position, mayHurtPlayers})
+}
+
+func (client *remotePlayerShardClient) ReqArriveByPortal(target BlockXyz, look LookDegrees) {
+	client.send(&reqArriveByPortal{target, look})
+}
+
+// remoteShardShardClient implements IShardShardClient for a shard on a shard
+// server. Once its connection has been lost it drops requests, and the shard
+// using it should connect again.
+type remoteShardShardClient struct {
+	conn     *clientConn
+	shardLoc ShardXz
+}
+
+// Lost returns true once the connection to the shard server has been lost.
+func (client *remoteShardShardClient) Lost() bool {
+	return client.conn.closed()
+}
+
+func (client *remoteShardShardClient) send(body interface{}) {
+	client.conn.send(&envelope{ShardLoc: client.shardLoc, Body: body})
+}
+
+func (client *remoteShardShardClient) Disconnect() {
+}
+
+func (client *remoteShardShardClient) ReqSetActiveBlocks(blocks []BlockXyz) {
+	client.send(&reqSetActiveBlocks{blocks})
+}
+
+func (client *remoteShardShardClient) ReqTransferEntity(loc ChunkXz, entity gamerules.INonPlayerEntity) {
+	data, err := encodeEntity(entity)
+	if err != nil {
+		log.Printf("shardnet: dropping entity moving to %v: %v", loc, err)
+		return
+	}
+	client.send(&reqTransferEntity{loc, entity.GetEntityId(), data})
+}
+
+func (client *remoteShardShardClient) ReqQueryBlocks(requester ShardXz, blocks []BlockXyz) {
+	client.send(&reqQueryBlocks{requester, blocks})
+}
+
+func (client *remoteShardShardClient) ReqRemoteBlocks(blocks []gamerules.BlockState) {
+	client.send(&reqRemoteBlocks{blocks})
+}
+
+func (client *remoteShardShardClient) ReqUpdateLight(updates []gamerules.LightUpdate) {
+	client.send(&reqUpdateLight{updates})
+}
+
+func (client *remoteShardShardClient) ReqSetBlocks(blocks []gamerules.BlockState, tileEntities []gamerules.ITileEntity) {
+	var encoded [][]byte
+	for _, tileEntity := range tileEntities {
+		data, err := encodeTileEntity(tileEntity)
+		if err != nil {
+			log.Printf("shardnet: dropping tile entity at %v: %v", tileEntity.Block(), err)
+			continue
+		}
+		encoded = append(encoded, data)
+	}
+	client.send(&reqSetBlocks{blocks, encoded})
+}
+
+func (client *remoteShardShardClient) ReqQueryChunks(requester ShardXz, chunks []ChunkXz) {
+	client.send(&reqQueryChunks{requester, chunks})
+}
+
+func (client *remoteShardShardClient) ReqRemoteChunks(chunks []gamerules.ChunkBlocks) {
+	client.send(&reqRemoteChunks{chunks})
+}
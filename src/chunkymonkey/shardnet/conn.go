@@ -0,0 +1,109 @@
+package shardnet
+
+import (
+	"gob"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// The number of messages that can wait to be sent on a connection before
+// senders must wait.
+const outgoingQueueSize = 1024
+
+// conn sends and receives messages over a network connection. Messages are
+// sent by a goroutine of its own, so that shards and players do not wait upon
+// the network.
+type conn struct {
+	netConn  net.Conn
+	outgoing chan *envelope
+	done     chan bool
+	once     sync.Once
+}
+
+func newConn(netConn net.Conn) *conn {
+	c := &conn{
+		netConn:  netConn,
+		outgoing: make(chan *envelope, outgoingQueueSize),
+		done:     make(chan bool),
+	}
+
+	go c.sendLoop()
+
+	return c
+}
+
+func (c *conn) String() string {
+	return c.netConn.RemoteAddr().String()
+}
+
+// send queues a message to be sent. Messages sent after the connection has
+// closed are dropped, and false is returned.
+func (c *conn) send(msg *envelope) (ok bool) {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.outgoing <- msg:
+		return true
+	case <-c.done:
+	}
+	return false
+}
+
+// closed returns true once the connection has been closed.
+func (c *conn) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+	}
+	return false
+}
+
+func (c *conn) sendLoop() {
+	encoder := gob.NewEncoder(c.netConn)
+
+	for {
+		select {
+		case msg := <-c.outgoing:
+			if err := encoder.Encode(msg); err != nil {
+				log.Printf("shardnet: error sending to %v: %v", c, err)
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// receiveLoop passes each message received to handle until the connection
+// fails or is closed.
+func (c *conn) receiveLoop(handle func(msg *envelope)) {
+	decoder := gob.NewDecoder(c.netConn)
+
+	for {
+		var msg envelope
+		if err := decoder.Decode(&msg); err != nil {
+			if err != os.EOF {
+				log.Printf("shardnet: error receiving from %v: %v", c, err)
+			}
+			c.Close()
+			return
+		}
+		handle(&msg)
+	}
+}
+
+// Close closes the connection. It is safe to call more than once.
+func (c *conn) Close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.netConn.Close()
+	})
+}
@@ -0,0 +1,89 @@
+package shardnet
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"chunkymonkey/gamerules"
+	"nbt"
+)
+
+// encodeEntity encodes the entity in the same form as it is stored in chunk
+// files.
+func encodeEntity(entity gamerules.INonPlayerEntity) (data []byte, err os.Error) {
+	tag := entity.WriteNbt()
+	if tag == nil {
+		return nil, fmt.Errorf("entity %d of type %T cannot be encoded", entity.GetEntityId(), entity)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = nbt.Write(buf, tag); err != nil {
+		return
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeEntity creates an entity from data written by encodeEntity.
+func decodeEntity(data []byte) (entity gamerules.INonPlayerEntity, err os.Error) {
+	tag, err := nbt.Read(bytes.NewBuffer(data))
+	if err != nil {
+		return
+	}
+
+	typeName, ok := tag.Lookup("id").(*nbt.String)
+	if !ok {
+		return nil, os.NewError("missing or bad entity type ID in NBT")
+	}
+
+	if entity = gamerules.NewEntityByTypeName(typeName.Value); entity == nil {
+		return nil, fmt.Errorf("unhandled entity type %q", typeName.Value)
+	}
+
+	if err = entity.ReadNbt(tag); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// encodeTileEntity encodes the tile entity in the same form as it is stored in
+// chunk files.
+func encodeTileEntity(tileEntity gamerules.ITileEntity) (data []byte, err os.Error) {
+	tag := tileEntity.WriteNbt()
+	if tag == nil {
+		return nil, fmt.Errorf("tile entity of type %T cannot be encoded", tileEntity)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = nbt.Write(buf, tag); err != nil {
+		return
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeTileEntity creates a tile entity from data written by
+// encodeTileEntity.
+func decodeTileEntity(data []byte) (tileEntity gamerules.ITileEntity, err os.Error) {
+	tag, err := nbt.Read(bytes.NewBuffer(data))
+	if err != nil {
+		return
+	}
+
+	typeName, ok := tag.Lookup("id").(*nbt.String)
+	if !ok {
+		return nil, os.NewError("missing or bad tile entity type ID in NBT")
+	}
+
+	if tileEntity = gamerules.NewTileEntityByTypeName(typeName.Value); tileEntity == nil {
+		return nil, fmt.Errorf("unhandled tile entity type %q", typeName.Value)
+	}
+
+	if err = tileEntity.ReadNbt(tag); err != nil {
+		return nil, err
+	}
+
+	return
+}
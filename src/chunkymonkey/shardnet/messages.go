@@ -0,0 +1,317 @@
+package shardnet
+
+import (
+	"gob"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+// envelope is what is sent over a connection for each request. Body is one
+// of the message types below.
+type envelope struct {
+	// EntityId is the player that the message is from or to, if any.
+	EntityId EntityId
+	// ShardLoc is the shard that the message is for, if any.
+	ShardLoc ShardXz
+	Body     interface{}
+}
+
+// signal is the body of a message that carries no arguments.
+type signal byte
+
+const (
+	// From a player to a shard.
+	signalDisconnect = signal(iota)
+	// From a shard to a player.
+	signalNotifyChunkLoad
+	signalKill
+	// From a frontend to a shard server, and its reply once done.
+	signalSave
+	signalSaved
+)
+
+// reqConnectPlayer is sent ahead of a player's other requests on each
+// connection to a shard server, so that the server knows who the player is
+// before any of the player's requests reach a shard.
+type reqConnectPlayer struct {
+	Name string
+}
+
+// Requests from a player to a shard, one for each method of
+// IPlayerShardClient.
+
+type reqSubscribeChunk struct {
+	ChunkLoc ChunkXz
+	Notify   bool
+}
+
+type reqUnsubscribeChunk struct {
+	ChunkLoc ChunkXz
+}
+
+type reqMulticastPlayers struct {
+	ChunkLoc ChunkXz
+	Exclude  EntityId
+	Packet   []byte
+}
+
+type reqAddPlayerData struct {
+	ChunkLoc ChunkXz
+	Name     string
+	Position AbsXyz
+	Look     LookBytes
+	Held     ItemTypeId
+}
+
+type reqRemovePlayerData struct {
+	ChunkLoc     ChunkXz
+	IsDisconnect bool
+}
+
+type reqSetPlayerPosition struct {
+	ChunkLoc ChunkXz
+	Position AbsXyz
+}
+
+type reqSetPlayerLook struct {
+	ChunkLoc ChunkXz
+	Look     LookBytes
+}
+
+type reqHitBlock struct {
+	Held      gamerules.Slot
+	Target    BlockXyz
+	DigStatus DigStatus
+	Face      Face
+}
+
+type reqInteractBlock struct {
+	Held   gamerules.Slot
+	Target BlockXyz
+	Face   Face
+}
+
+type reqPlaceItem struct {
+	Target      BlockXyz
+	Slot        gamerules.Slot
+	AgainstFace Face
+	Look        LookDegrees
+}
+
+type reqTakeItem struct {
+	ChunkLoc ChunkXz
+	EntityId EntityId
+}
+
+type reqDropItem struct {
+	Content        gamerules.Slot
+	Position       AbsXyz
+	Velocity       AbsVelocity
+	PickupImmunity Ticks
+}
+
+type reqInventoryClick struct {
+	Block BlockXyz
+	Click gamerules.Click
+}
+
+type reqInventoryUnsubscribed struct {
+	Block BlockXyz
+}
+
+type reqSetSignText struct {
+	Target BlockXyz
+	Lines  [4]string
+}
+
+type reqAttackEntity struct {
+	Target         EntityId
+	Held           gamerules.Slot
+	Position       AbsXyz
+	MayHurtPlayers bool
+}
+
+type reqArriveByPortal struct {
+	Target BlockXyz
+	Look   LookDegrees
+}
+
+// Requests from a shard to a player, one for each method of IPlayerClient
+// that the shard uses.
+
+type transmitPacket struct {
+	Packet []byte
+}
+
+type inventorySubscribed struct {
+	Block     BlockXyz
+	InvTypeId InvTypeId
+	Slots     []proto.WindowSlot
+}
+
+type inventorySlotUpdate struct {
+	Block  BlockXyz
+	Slot   gamerules.Slot
+	SlotId SlotId
+}
+
+type inventoryProgressUpdate struct {
+	Block    BlockXyz
+	PrgBarId PrgBarId
+	Value    PrgBarValue
+}
+
+type inventoryCursorUpdate struct {
+	Block  BlockXyz
+	Cursor gamerules.Slot
+}
+
+type inventoryTxState struct {
+	Block    BlockXyz
+	TxId     TxId
+	Accepted bool
+}
+
+type inventoryUnsubscribed struct {
+	Block BlockXyz
+}
+
+type placeHeldItem struct {
+	Target      BlockXyz
+	WasHeld     gamerules.Slot
+	AgainstFace Face
+}
+
+type offerItem struct {
+	FromChunk ChunkXz
+	EntityId  EntityId
+	Item      gamerules.Slot
+}
+
+type giveItemAtPosition struct {
+	AtPosition AbsXyz
+	Item       gamerules.Slot
+}
+
+type giveItem struct {
+	Item gamerules.Slot
+}
+
+type setPositionLook struct {
+	Position AbsXyz
+	Look     LookDegrees
+}
+
+type echoMessage struct {
+	Msg string
+}
+
+type receiveWhisper struct {
+	From, Msg string
+}
+
+type setEnvironment struct {
+	Env gamerules.PlayerEnvironment
+}
+
+type attacked struct {
+	Damage    Health
+	Knockback AbsVelocity
+}
+
+// Requests from one shard to another, one for each method of
+// IShardShardClient.
+
+type reqSetActiveBlocks struct {
+	Blocks []BlockXyz
+}
+
+type reqTransferEntity struct {
+	Loc      ChunkXz
+	EntityId EntityId
+	// Nbt is the entity encoded as it would be in a chunk file.
+	Nbt []byte
+}
+
+type reqQueryBlocks struct {
+	Requester ShardXz
+	Blocks    []BlockXyz
+}
+
+type reqRemoteBlocks struct {
+	Blocks []gamerules.BlockState
+}
+
+type reqUpdateLight struct {
+	Updates []gamerules.LightUpdate
+}
+
+type reqSetBlocks struct {
+	Blocks []gamerules.BlockState
+	// TileEntities are encoded as they would be in a chunk file.
+	TileEntities [][]byte
+}
+
+type reqQueryChunks struct {
+	Requester ShardXz
+	Chunks    []ChunkXz
+}
+
+type reqRemoteChunks struct {
+	Chunks []gamerules.ChunkBlocks
+}
+
+func init() {
+	for _, body := range []interface{}{
+		signal(0),
+
+		&reqConnectPlayer{},
+		&reqSubscribeChunk{},
+		&reqUnsubscribeChunk{},
+		&reqMulticastPlayers{},
+		&reqAddPlayerData{},
+		&reqRemovePlayerData{},
+		&reqSetPlayerPosition{},
+		&reqSetPlayerLook{},
+		&reqHitBlock{},
+		&reqInteractBlock{},
+		&reqPlaceItem{},
+		&reqTakeItem{},
+		&reqDropItem{},
+		&reqInventoryClick{},
+		&reqInventoryUnsubscribed{},
+		&reqSetSignText{},
+		&reqAttackEntity{},
+		&reqArriveByPortal{},
+
+		&transmitPacket{},
+		&inventorySubscribed{},
+		&inventorySlotUpdate{},
+		&inventoryProgressUpdate{},
+		&inventoryCursorUpdate{},
+		&inventoryTxState{},
+		&inventoryUnsubscribed{},
+		&placeHeldItem{},
+		&offerItem{},
+		&giveItemAtPosition{},
+		&giveItem{},
+		&setPositionLook{},
+		&echoMessage{},
+		&receiveWhisper{},
+		&setEnvironment{},
+		&attacked{},
+
+		&reqSetActiveBlocks{},
+		&reqTransferEntity{},
+		&reqQueryBlocks{},
+		&reqRemoteBlocks{},
+		&reqUpdateLight{},
+		&reqSetBlocks{},
+		&reqQueryChunks{},
+		&reqRemoteChunks{},
+	} {
+		gob.Register(body)
+	}
+}
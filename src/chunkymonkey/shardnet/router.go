@@ -0,0 +1,42 @@
+package shardnet
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// Router implements IShardConnecter for a shard server. It connects to the
+// shards that the server is responsible for through local, and to all other
+// shards over the network.
+type Router struct {
+	shardMap *ShardMap
+	index    int
+	local    gamerules.IShardConnecter
+	remote   gamerules.IShardConnecter
+}
+
+// NewRouter creates a Router for the shard server with the given index in
+// shardMap.
+func NewRouter(shardMap *ShardMap, index int, local gamerules.IShardConnecter) *Router {
+	return &Router{
+		shardMap: shardMap,
+		index:    index,
+		local:    local,
+		remote:   NewConnecter(shardMap),
+	}
+}
+
+func (router *Router) connecterFor(shardLoc ShardXz) gamerules.IShardConnecter {
+	if router.shardMap.ServerIndex(shardLoc) == router.index {
+		return router.local
+	}
+	return router.remote
+}
+
+func (router *Router) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
+	return router.connecterFor(shardLoc).PlayerShardConnect(entityId, player, shardLoc)
+}
+
+func (router *Router) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+	return router.connecterFor(shardLoc).ShardShardConnect(shardLoc)
+}
@@ -0,0 +1,413 @@
+package shardnet
+
+import (
+	"log"
+	"net"
+	"sync"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+// Server serves shards to frontends and other shard servers over the network.
+type Server struct {
+	shardConnecter gamerules.IShardConnecter
+	save           func()
+}
+
+// NewServer creates a Server that passes requests on to the shards connected
+// to by shardConnecter. save is called to write the shards' chunks when a
+// frontend stops.
+func NewServer(shardConnecter gamerules.IShardConnecter, save func()) *Server {
+	return &Server{
+		shardConnecter: shardConnecter,
+		save:           save,
+	}
+}
+
+// Serve accepts connections on the listener until it fails.
+func (server *Server) Serve(listener net.Listener) {
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			log.Print("shardnet: accept: ", err)
+			return
+		}
+
+		sc := &serverConn{
+			conn:    newConn(netConn),
+			server:  server,
+			players: make(map[EntityId]*remotePlayer),
+		}
+		go sc.serve()
+	}
+}
+
+// serverConn is a connection from a frontend or another shard server.
+type serverConn struct {
+	*conn
+	server  *Server
+	players map[EntityId]*remotePlayer
+	lock    sync.Mutex
+}
+
+func (sc *serverConn) serve() {
+	log.Printf("shardnet: connection from %v", sc)
+	sc.receiveLoop(sc.handle)
+	log.Printf("shardnet: lost connection from %v", sc)
+
+	// Players can no longer be reached, so take them out of the shards.
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	for entityId, player := range sc.players {
+		for _, shardClient := range player.shardClients {
+			shardClient.Disconnect()
+		}
+		sc.players[entityId] = nil, false
+	}
+}
+
+// handle passes a request on to the shard that it is for.
+func (sc *serverConn) handle(msg *envelope) {
+	switch body := msg.Body.(type) {
+	case *reqSetActiveBlocks:
+		if shard := sc.shardShardClient(msg.ShardLoc); shard != nil {
+			shard.ReqSetActiveBlocks(body.Blocks)
+		}
+	case *reqTransferEntity:
+		if shard := sc.shardShardClient(msg.ShardLoc); shard != nil {
+			entity, err := decodeEntity(body.Nbt)
+			if err != nil {
+				log.Printf("shardnet: dropping entity moving to %v: %v", body.Loc, err)
+				return
+			}
+			entity.SetEntityId(body.EntityId)
+			shard.ReqTransferEntity(body.Loc, entity)
+		}
+	case *reqQueryBlocks:
+		if shard := sc.shardShardClient(msg.ShardLoc); shard != nil {
+			shard.ReqQueryBlocks(body.Requester, body.Blocks)
+		}
+	case *reqRemoteBlocks:
+		if shard := sc.shardShardClient(msg.ShardLoc); shard != nil {
+			shard.ReqRemoteBlocks(body.Blocks)
+		}
+	case *reqUpdateLight:
+		if shard := sc.shardShardClient(msg.ShardLoc); shard != nil {
+			shard.ReqUpdateLight(body.Updates)
+		}
+	case *reqSetBlocks:
+		if shard := sc.shardShardClient(msg.ShardLoc); shard != nil {
+			var tileEntities []gamerules.ITileEntity
+			for _, data := range body.TileEntities {
+				tileEntity, err := decodeTileEntity(data)
+				if err != nil {
+					log.Printf("shardnet: dropping tile entity: %v", err)
+					continue
+				}
+				tileEntities = append(tileEntities, tileEntity)
+			}
+			shard.ReqSetBlocks(body.Blocks, tileEntities)
+		}
+	case *reqQueryChunks:
+		if shard := sc.shardShardClient(msg.ShardLoc); shard != nil {
+			shard.ReqQueryChunks(body.Requester, body.Chunks)
+		}
+	case *reqRemoteChunks:
+		if shard := sc.shardShardClient(msg.ShardLoc); shard != nil {
+			shard.ReqRemoteChunks(body.Chunks)
+		}
+	case signal:
+		if body == signalSave {
+			// Saving waits on the shards, which may be waiting on this
+			// connection.
+			go sc.saveShards()
+			return
+		}
+		sc.handlePlayerRequest(msg)
+	default:
+		sc.handlePlayerRequest(msg)
+	}
+}
+
+func (sc *serverConn) handlePlayerRequest(msg *envelope) {
+	if msg.Body == signalDisconnect {
+		sc.disconnectPlayer(msg.EntityId, msg.ShardLoc)
+		return
+	}
+	if body, ok := msg.Body.(*reqConnectPlayer); ok {
+		sc.connectPlayer(msg.EntityId, body.Name)
+		return
+	}
+
+	player, shard := sc.playerShardClient(msg.EntityId, msg.ShardLoc)
+
+	switch body := msg.Body.(type) {
+	case *reqSubscribeChunk:
+		shard.ReqSubscribeChunk(body.ChunkLoc, body.Notify)
+	case *reqUnsubscribeChunk:
+		shard.ReqUnsubscribeChunk(body.ChunkLoc)
+	case *reqMulticastPlayers:
+		shard.ReqMulticastPlayers(body.ChunkLoc, body.Exclude, body.Packet)
+	case *reqAddPlayerData:
+		player.setName(body.Name)
+		player.setPosition(&body.Position)
+		shard.ReqAddPlayerData(body.ChunkLoc, body.Name, body.Position, body.Look, body.Held)
+	case *reqRemovePlayerData:
+		shard.ReqRemovePlayerData(body.ChunkLoc, body.IsDisconnect)
+	case *reqSetPlayerPosition:
+		player.setPosition(&body.Position)
+		shard.ReqSetPlayerPosition(body.ChunkLoc, body.Position)
+	case *reqSetPlayerLook:
+		player.setLook(body.Look.ToLookDegrees())
+		shard.ReqSetPlayerLook(body.ChunkLoc, body.Look)
+	case *reqHitBlock:
+		shard.ReqHitBlock(body.Held, body.Target, body.DigStatus, body.Face)
+	case *reqInteractBlock:
+		shard.ReqInteractBlock(body.Held, body.Target, body.Face)
+	case *reqPlaceItem:
+		shard.ReqPlaceItem(body.Target, body.Slot, body.AgainstFace, body.Look)
+	case *reqTakeItem:
+		shard.ReqTakeItem(body.ChunkLoc, body.EntityId)
+	case *reqDropItem:
+		shard.ReqDropItem(body.Content, body.Position, body.Velocity, body.PickupImmunity)
+	case *reqInventoryClick:
+		shard.ReqInventoryClick(body.Block, body.Click)
+	case *reqInventoryUnsubscribed:
+		shard.ReqInventoryUnsubscribed(body.Block)
+	case *reqSetSignText:
+		shard.ReqSetSignText(body.Target, body.Lines)
+	case *reqAttackEntity:
+		shard.ReqAttackEntity(body.Target, body.Held, body.Position, body.MayHurtPlayers)
+	case *reqArriveByPortal:
+		shard.ReqArriveByPortal(body.Target, body.Look)
+	default:
+		log.Printf("shardnet: unexpected message %T from %v", msg.Body, sc)
+	}
+}
+
+// saveShards writes the chunks of the server's shards, and tells the frontend
+// once they have been written.
+func (sc *serverConn) saveShards() {
+	log.Printf("shardnet: writing chunks for %v", sc)
+	sc.server.save()
+	sc.send(&envelope{Body: signalSaved})
+}
+
+// shardShardClient returns a client for the shard, or nil if it is not
+// running.
+func (sc *serverConn) shardShardClient(shardLoc ShardXz) gamerules.IShardShardClient {
+	return sc.server.shardConnecter.ShardShardConnect(shardLoc)
+}
+
+// connectPlayer records the name of a player connecting to the server, which
+// the shards use to check what the player may do.
+func (sc *serverConn) connectPlayer(entityId EntityId, name string) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	player := sc.player(entityId)
+	player.setName(name)
+	player.connects++
+}
+
+// player returns the player, making it if this is the first request from the
+// player. sc.lock must be held.
+func (sc *serverConn) player(entityId EntityId) *remotePlayer {
+	player, ok := sc.players[entityId]
+	if !ok {
+		player = &remotePlayer{
+			conn:         sc.conn,
+			entityId:     entityId,
+			shardClients: make(map[uint64]gamerules.IPlayerShardClient),
+		}
+		sc.players[entityId] = player
+	}
+	return player
+}
+
+// playerShardClient returns the player's connection to the shard, making it
+// if this is the first request from the player to that shard.
+func (sc *serverConn) playerShardClient(entityId EntityId, shardLoc ShardXz) (player *remotePlayer, shard gamerules.IPlayerShardClient) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	player = sc.player(entityId)
+
+	shardKey := shardLoc.Key()
+	shard, ok := player.shardClients[shardKey]
+	if !ok {
+		shard = sc.server.shardConnecter.PlayerShardConnect(entityId, player, shardLoc)
+		player.shardClients[shardKey] = shard
+	}
+
+	return
+}
+
+func (sc *serverConn) disconnectPlayer(entityId EntityId, shardLoc ShardXz) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	player, ok := sc.players[entityId]
+	if !ok {
+		return
+	}
+
+	shardKey := shardLoc.Key()
+	if shard, ok := player.shardClients[shardKey]; ok {
+		shard.Disconnect()
+		player.shardClients[shardKey] = nil, false
+	}
+
+	// The player is kept while any of its shard clients on the frontend are
+	// connected, so that the name is known to the shards that it has yet to
+	// make requests of.
+	player.connects--
+	if player.connects <= 0 && len(player.shardClients) == 0 {
+		sc.players[entityId] = nil, false
+	}
+}
+
+// remotePlayer implements IPlayerClient for a player on a frontend.
+type remotePlayer struct {
+	conn     *conn
+	entityId EntityId
+	// The player's connections to the shards on this server.
+	shardClients map[uint64]gamerules.IPlayerShardClient
+	// The number of the player's shard clients on the frontend that have
+	// connected to this server, less those that have disconnected.
+	connects int
+
+	// The name, position and look that the player last sent, for Name and
+	// PositionLook.
+	lock     sync.Mutex
+	name     string
+	position AbsXyz
+	look     LookDegrees
+}
+
+func (player *remotePlayer) send(body interface{}) {
+	player.conn.send(&envelope{EntityId: player.entityId, Body: body})
+}
+
+func (player *remotePlayer) setName(name string) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+	player.name = name
+}
+
+func (player *remotePlayer) setPosition(position *AbsXyz) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+	player.position = *position
+}
+
+func (player *remotePlayer) setLook(look *LookDegrees) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+	player.look = *look
+}
+
+func (player *remotePlayer) GetEntityId() EntityId {
+	return player.entityId
+}
+
+func (player *remotePlayer) Name() string {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+	return player.name
+}
+
+func (player *remotePlayer) TransmitPacket(packet []byte) {
+	player.send(&transmitPacket{packet})
+}
+
+func (player *remotePlayer) NotifyChunkLoad() {
+	player.send(signalNotifyChunkLoad)
+}
+
+func (player *remotePlayer) InventorySubscribed(block BlockXyz, invTypeId InvTypeId, slots []proto.WindowSlot) {
+	player.send(&inventorySubscribed{block, invTypeId, slots})
+}
+
+func (player *remotePlayer) InventorySlotUpdate(block BlockXyz, slot gamerules.Slot, slotId SlotId) {
+	player.send(&inventorySlotUpdate{block, slot, slotId})
+}
+
+func (player *remotePlayer) InventoryProgressUpdate(block BlockXyz, prgBarId PrgBarId, value PrgBarValue) {
+	player.send(&inventoryProgressUpdate{block, prgBarId, value})
+}
+
+func (player *remotePlayer) InventoryCursorUpdate(block BlockXyz, cursor gamerules.Slot) {
+	player.send(&inventoryCursorUpdate{block, cursor})
+}
+
+func (player *remotePlayer) InventoryTxState(block BlockXyz, txId TxId, accepted bool) {
+	player.send(&inventoryTxState{block, txId, accepted})
+}
+
+func (player *remotePlayer) InventoryUnsubscribed(block BlockXyz) {
+	player.send(&inventoryUnsubscribed{block})
+}
+
+func (player *remotePlayer) PlaceHeldItem(target BlockXyz, wasHeld gamerules.Slot, againstFace Face) {
+	player.send(&placeHeldItem{target, wasHeld, againstFace})
+}
+
+func (player *remotePlayer) OfferItem(fromChunk ChunkXz, entityId EntityId, item gamerules.Slot) {
+	player.send(&offerItem{fromChunk, entityId, item})
+}
+
+func (player *remotePlayer) GiveItemAtPosition(atPosition AbsXyz, item gamerules.Slot) {
+	player.send(&giveItemAtPosition{atPosition, item})
+}
+
+func (player *remotePlayer) GiveItem(item gamerules.Slot) {
+	player.send(&giveItem{item})
+}
+
+func (player *remotePlayer) PositionLook() (AbsXyz, LookDegrees) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+	return player.position, player.look
+}
+
+func (player *remotePlayer) SetPositionLook(position AbsXyz, look LookDegrees) {
+	player.send(&setPositionLook{position, look})
+}
+
+func (player *remotePlayer) EchoMessage(msg string) {
+	player.send(&echoMessage{msg})
+}
+
+func (player *remotePlayer) SetEnvironment(env gamerules.PlayerEnvironment) {
+	player.send(&setEnvironment{env})
+}
+
+func (player *remotePlayer) Kill() {
+	player.send(signalKill)
+}
+
+func (player *remotePlayer) Attacked(damage Health, knockback AbsVelocity) {
+	player.send(&attacked{damage, knockback})
+}
+
+func (player *remotePlayer) ReceiveWhisper(from, msg string) {
+	player.send(&receiveWhisper{from, msg})
+}
+
+// Shards do not run commands, so they have no use for the rest of the private
+// message state, which is kept by the frontend.
+
+func (player *remotePlayer) Correspondent() string {
+	return ""
+}
+
+func (player *remotePlayer) SetIgnoring(name string, ignore bool) bool {
+	return false
+}
+
+func (player *remotePlayer) Ignoring() []string {
+	return nil
+}
@@ -0,0 +1,71 @@
+package shardnet
+
+import (
+	. "chunkymonkey/types"
+)
+
+const (
+	// Shards are given to servers in squares of shardBlockSize by
+	// shardBlockSize, so that the shards in each region file of the world are
+	// all served by one process.
+	shardBlockSize = 2
+
+	// The number of EntityIds that each process may create.
+	entityIdsPerProcess = 1 << 24
+)
+
+// ShardMap says which shard server serves each shard.
+type ShardMap struct {
+	addrs []string
+}
+
+// NewShardMap creates a ShardMap that spreads shards across the shard servers
+// at the given network addresses. All frontends and shard servers must be
+// given the same addresses in the same order.
+func NewShardMap(addrs []string) *ShardMap {
+	return &ShardMap{addrs}
+}
+
+// NumServers returns the number of shard servers.
+func (m *ShardMap) NumServers() int {
+	return len(m.addrs)
+}
+
+// ServerAddr returns the network address of the shard server with the given
+// index.
+func (m *ShardMap) ServerAddr(index int) string {
+	return m.addrs[index]
+}
+
+// ServerIndex returns the index of the shard server that serves the shard.
+func (m *ShardMap) ServerIndex(loc ShardXz) int {
+	blockX := floorDiv(int(loc.X), shardBlockSize)
+	blockZ := floorDiv(int(loc.Z), shardBlockSize)
+
+	// Mix the coordinates so that neighbouring blocks of shards tend to go to
+	// different servers.
+	hash := uint32(blockX)*73856093 ^ uint32(blockZ)*19349663
+	return int(hash % uint32(len(m.addrs)))
+}
+
+// Addr returns the network address of the shard server that serves the shard.
+func (m *ShardMap) Addr(loc ShardXz) string {
+	return m.addrs[m.ServerIndex(loc)]
+}
+
+// EntityIdRange returns the EntityIds that a process may create, from first
+// up to but not including last. Shard servers use their index in the
+// ShardMap, and the frontend uses -1, so that no two processes create the
+// same EntityId.
+func EntityIdRange(index int) (first, last EntityId) {
+	first = EntityId(index+1) * entityIdsPerProcess
+	last = first + entityIdsPerProcess
+	return
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return (a - b + 1) / b
+	}
+	return a / b
+}
@@ -0,0 +1,383 @@
+package shardnet
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/permission"
+	. "chunkymonkey/types"
+)
+
+func init() {
+	if err := gamerules.LoadGameRules("blocks.json", "items.json", "recipes.json", "furnace.json", "spawning.json", "users.json", "groups.json", "regions.json"); err != nil {
+		panic(err)
+	}
+}
+
+const testTimeoutNs = 5e9
+
+// fakeShardConnecter records the players that connect to it, and the requests
+// that they make.
+type fakeShardConnecter struct {
+	players  chan gamerules.IPlayerClient
+	requests chan interface{}
+}
+
+func (c *fakeShardConnecter) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
+	c.players <- player
+	return &fakePlayerShardClient{requests: c.requests}
+}
+
+func (c *fakeShardConnecter) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+	return &fakeShardShardClient{requests: c.requests}
+}
+
+// fakePlayerShardClient only implements the methods used in tests.
+type fakePlayerShardClient struct {
+	gamerules.IPlayerShardClient
+	requests chan interface{}
+}
+
+func (c *fakePlayerShardClient) Disconnect() {
+}
+
+func (c *fakePlayerShardClient) ReqSubscribeChunk(chunkLoc ChunkXz, notify bool) {
+	c.requests <- &reqSubscribeChunk{chunkLoc, notify}
+}
+
+// fakeShardShardClient only implements the methods used in tests.
+type fakeShardShardClient struct {
+	gamerules.IShardShardClient
+	requests chan interface{}
+}
+
+func (c *fakeShardShardClient) ReqSetActiveBlocks(blocks []BlockXyz) {
+	c.requests <- &reqSetActiveBlocks{blocks}
+}
+
+// fakePlayerClient only implements the methods used in tests.
+type fakePlayerClient struct {
+	gamerules.IPlayerClient
+	name     string
+	packets  chan []byte
+	messages chan string
+}
+
+func (p *fakePlayerClient) Name() string {
+	return p.name
+}
+
+func (p *fakePlayerClient) TransmitPacket(packet []byte) {
+	p.packets <- packet
+}
+
+func (p *fakePlayerClient) EchoMessage(msg string) {
+	p.messages <- msg
+}
+
+func TestPlayerShardRoundTrip(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	shards := &fakeShardConnecter{
+		players:  make(chan gamerules.IPlayerClient, 1),
+		requests: make(chan interface{}, 1),
+	}
+	go NewServer(shards, func() {}).Serve(listener)
+
+	connecter := NewConnecter(NewShardMap([]string{listener.Addr().String()}))
+	frontendPlayer := &fakePlayerClient{packets: make(chan []byte, 1)}
+	client := connecter.PlayerShardConnect(5, frontendPlayer, ShardXz{0, 0})
+
+	// A request from the player reaches the shard.
+	client.ReqSubscribeChunk(ChunkXz{1, 2}, true)
+
+	var shardPlayer gamerules.IPlayerClient
+	select {
+	case shardPlayer = <-shards.players:
+	case <-time.After(testTimeoutNs):
+		t.Fatal("timed out waiting for player to connect to shard")
+	}
+	if shardPlayer.GetEntityId() != 5 {
+		t.Errorf("expected player with EntityId 5 on shard, got %d", shardPlayer.GetEntityId())
+	}
+
+	select {
+	case req := <-shards.requests:
+		sub, ok := req.(*reqSubscribeChunk)
+		if !ok || sub.ChunkLoc.X != 1 || sub.ChunkLoc.Z != 2 || !sub.Notify {
+			t.Errorf("expected subscribe request for chunk (1, 2), got %#v", req)
+		}
+	case <-time.After(testTimeoutNs):
+		t.Fatal("timed out waiting for request on shard")
+	}
+
+	// A request from the shard reaches the player.
+	shardPlayer.TransmitPacket([]byte{1, 2, 3})
+
+	select {
+	case packet := <-frontendPlayer.packets:
+		if !bytes.Equal(packet, []byte{1, 2, 3}) {
+			t.Errorf("expected packet [1 2 3], got %v", packet)
+		}
+	case <-time.After(testTimeoutNs):
+		t.Fatal("timed out waiting for packet on player")
+	}
+}
+
+func TestPlayerShardConnect_Permissions(t *testing.T) {
+	// Only the builder may build.
+	permissions, err := permission.LoadJsonPermission(
+		strings.NewReader(`{"builder": {"groups": ["builders"]}}`),
+		strings.NewReader(`{
+			"default": {"default": true, "permissions": []},
+			"builders": {"inheritance": ["default"], "permissions": ["world.build"]}
+		}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPermissions := gamerules.Permissions()
+	gamerules.SetPermissions(permissions)
+	defer gamerules.SetPermissions(oldPermissions)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	shards := &fakeShardConnecter{
+		players:  make(chan gamerules.IPlayerClient, 1),
+		requests: make(chan interface{}, 1),
+	}
+	go NewServer(shards, func() {}).Serve(listener)
+
+	connecter := NewConnecter(NewShardMap([]string{listener.Addr().String()}))
+	client := connecter.PlayerShardConnect(5, &fakePlayerClient{name: "builder"}, ShardXz{0, 0})
+
+	// The player has not been added to a chunk, so the server only knows the
+	// player's name from connecting.
+	client.ReqSubscribeChunk(ChunkXz{1, 2}, true)
+
+	var shardPlayer gamerules.IPlayerClient
+	select {
+	case shardPlayer = <-shards.players:
+	case <-time.After(testTimeoutNs):
+		t.Fatal("timed out waiting for player to connect to shard")
+	}
+
+	if name := shardPlayer.Name(); name != "builder" {
+		t.Errorf("expected player named \"builder\" on shard, got %q", name)
+	}
+	if !gamerules.MayBuild(shardPlayer.Name(), DimensionNormal, &BlockXyz{16, 64, 32}) {
+		t.Errorf("expected the builder to be allowed to build on the shard server")
+	}
+}
+
+func TestPlayerShardConnect_Unreachable(t *testing.T) {
+	// Find an address that nothing is listening on.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	connecter := NewConnecter(NewShardMap([]string{addr}))
+	player := &fakePlayerClient{messages: make(chan string, 1)}
+	client := connecter.PlayerShardConnect(5, player, ShardXz{0, 0})
+
+	select {
+	case msg := <-player.messages:
+		if msg != msgShardServerLost {
+			t.Errorf("expected message %q, got %q", msgShardServerLost, msg)
+		}
+	case <-time.After(testTimeoutNs):
+		t.Fatal("timed out waiting for the player to be told")
+	}
+
+	// Requests are dropped rather than failing.
+	client.ReqSubscribeChunk(ChunkXz{1, 2}, true)
+	client.Disconnect()
+}
+
+func TestReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+
+	shards := &fakeShardConnecter{
+		players:  make(chan gamerules.IPlayerClient, 1),
+		requests: make(chan interface{}, 1),
+	}
+	go NewServer(shards, func() {}).Serve(listener)
+
+	connecter := NewConnecter(NewShardMap([]string{addr}))
+	player := &fakePlayerClient{messages: make(chan string, 1)}
+	playerClient := connecter.PlayerShardConnect(5, player, ShardXz{0, 0})
+	shardClient := connecter.ShardShardConnect(ShardXz{0, 0})
+
+	playerClient.ReqSubscribeChunk(ChunkXz{1, 2}, true)
+	expectRequest(t, shards.requests)
+
+	// Stop the server, and lose the connection to it.
+	listener.Close()
+	connecter.connTo(addr).Close()
+
+	select {
+	case msg := <-player.messages:
+		if msg != msgShardServerLost {
+			t.Errorf("expected message %q, got %q", msgShardServerLost, msg)
+		}
+	case <-time.After(testTimeoutNs):
+		t.Fatal("timed out waiting for the player to be told")
+	}
+	if lost, ok := shardClient.(*remoteShardShardClient); !ok || !lost.Lost() {
+		t.Errorf("expected the shard client to have lost its connection")
+	}
+
+	// Start the server again at the same address.
+	restarted, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Close()
+
+	shards = &fakeShardConnecter{
+		players:  make(chan gamerules.IPlayerClient, 1),
+		requests: make(chan interface{}, 1),
+	}
+	go NewServer(shards, func() {}).Serve(restarted)
+
+	// The player's next request connects to the server again.
+	playerClient.ReqSubscribeChunk(ChunkXz{3, 4}, true)
+	if req := expectRequest(t, shards.requests); req != nil {
+		if sub, ok := req.(*reqSubscribeChunk); !ok || sub.ChunkLoc.X != 3 || sub.ChunkLoc.Z != 4 {
+			t.Errorf("expected subscribe request for chunk (3, 4), got %#v", req)
+		}
+	}
+
+	// A shard connecting again reaches the restarted server.
+	shardClient = connecter.ShardShardConnect(ShardXz{0, 0})
+	if shardClient == nil {
+		t.Fatal("expected to connect to the restarted server")
+	}
+	shardClient.ReqSetActiveBlocks([]BlockXyz{{1, 64, 2}})
+	if req := expectRequest(t, shards.requests); req != nil {
+		if _, ok := req.(*reqSetActiveBlocks); !ok {
+			t.Errorf("expected set active blocks request, got %#v", req)
+		}
+	}
+}
+
+// expectRequest returns the next request made of a shard, or nil if none is
+// made in time.
+func expectRequest(t *testing.T, requests chan interface{}) interface{} {
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(testTimeoutNs):
+		t.Error("timed out waiting for request on shard")
+	}
+	return nil
+}
+
+func TestConnecterSave(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	saves := make(chan bool, 1)
+	go NewServer(&fakeShardConnecter{}, func() { saves <- true }).Serve(listener)
+
+	connecter := NewConnecter(NewShardMap([]string{listener.Addr().String()}))
+
+	saved := make(chan bool)
+	go func() {
+		connecter.Save()
+		close(saved)
+	}()
+
+	select {
+	case <-saved:
+	case <-time.After(testTimeoutNs):
+		t.Fatal("timed out waiting for Save to return")
+	}
+
+	select {
+	case <-saves:
+	default:
+		t.Error("expected the shard server to have saved")
+	}
+}
+
+func TestShardMap_ServerIndex(t *testing.T) {
+	shardMap := NewShardMap([]string{"a:1", "b:1", "c:1"})
+
+	type Test struct {
+		a, b ShardXz
+	}
+
+	// Shards in the same square of shardBlockSize must go to the same server.
+	tests := []Test{
+		{ShardXz{0, 0}, ShardXz{1, 1}},
+		{ShardXz{2, 4}, ShardXz{3, 5}},
+		{ShardXz{-1, -1}, ShardXz{-2, -2}},
+		{ShardXz{-3, 0}, ShardXz{-4, 1}},
+	}
+
+	for _, test := range tests {
+		a := shardMap.ServerIndex(test.a)
+		b := shardMap.ServerIndex(test.b)
+		if a != b {
+			t.Errorf("expected shards %v and %v on the same server, got %d and %d", test.a, test.b, a, b)
+		}
+		if a < 0 || a >= shardMap.NumServers() {
+			t.Errorf("server index %d for shard %v out of range", a, test.a)
+		}
+	}
+}
+
+func TestEntityIdRange(t *testing.T) {
+	frontendFirst, frontendLast := EntityIdRange(-1)
+	serverFirst, _ := EntityIdRange(0)
+
+	if frontendFirst != 0 || frontendLast != serverFirst {
+		t.Errorf("expected ranges to be adjacent, got frontend [%d, %d) and server from %d",
+			frontendFirst, frontendLast, serverFirst)
+	}
+}
+
+func TestEncodeEntity(t *testing.T) {
+	position := AbsXyz{10.5, 64, -3.5}
+	pig := gamerules.NewMobByTypeName("Pig", &position, &LookDegrees{90, 0})
+
+	data, err := encodeEntity(pig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entity, err := decodeEntity(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mob, ok := entity.(gamerules.IMob)
+	if !ok || mob.GetMobType() != MobTypeIdPig {
+		t.Fatalf("expected a pig, got %#v", entity)
+	}
+	if result := mob.Position(); result.X != position.X || result.Y != position.Y || result.Z != position.Z {
+		t.Errorf("expected pig at %v, got %v", position, *result)
+	}
+}
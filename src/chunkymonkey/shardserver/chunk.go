@@ -13,6 +13,12 @@ import (
 	. "chunkymonkey/types"
 )
 
+// Messages for players who are not allowed to change the world.
+const (
+	msgMayNotBuild = "You are not allowed to build here."
+	msgMayNotUse   = "You are not allowed to open that here."
+)
+
 // A chunk is slice of the world map.
 type Chunk struct {
 	shard        *ChunkShard
@@ -22,6 +28,7 @@ type Chunk struct {
 	blockLight   []byte
 	skyLight     []byte
 	heightMap    []byte
+	populated    bool                                    // Has the generator's population pass run?
 	entities     map[EntityId]gamerules.INonPlayerEntity // Entities (mobs, items, etc)
 	blockExtra   map[BlockIndex]interface{}              // Used by IBlockAspect to store private specific data.
 	rand         *rand.Rand
@@ -30,10 +37,12 @@ type Chunk struct {
 	playersData  map[EntityId]*playerData               // Some player data for player(s) in the chunk.
 	onUnsub      map[EntityId][]gamerules.IUnsubscribed // Functions to be called when unsubscribed.
 	storeDirty   bool                                   // Is the chunk store copy of this chunk dirty?
+	idleTicks    Ticks                                  // How long the chunk has been idle for.
 
-	activeBlocks    map[BlockIndex]bool // Blocks that need to "tick".
-	newActiveBlocks map[BlockIndex]bool // Blocks added as active for next "tick".
-	tickAll         bool                // Whether or not all blocks should be allowed to "tick" once
+	activeBlocks    map[BlockIndex]bool  // Blocks that need to "tick".
+	newActiveBlocks map[BlockIndex]bool  // Blocks added as active for next "tick".
+	scheduledBlocks map[BlockIndex]Ticks // Blocks to make active at a later tick.
+	tickAll         bool                 // Whether or not all blocks should be allowed to "tick" once
 }
 
 func newChunkFromReader(reader chunkstore.IChunkReader, shard *ChunkShard) (chunk *Chunk) {
@@ -45,6 +54,7 @@ func newChunkFromReader(reader chunkstore.IChunkReader, shard *ChunkShard) (chun
 		skyLight:    reader.SkyLight(),
 		blockLight:  reader.BlockLight(),
 		heightMap:   reader.HeightMap(),
+		populated:   reader.TerrainPopulated(),
 		entities:    make(map[EntityId]gamerules.INonPlayerEntity),
 		blockExtra:  make(map[BlockIndex]interface{}),
 		rand:        rand.New(rand.NewSource(time.UTC().Seconds())),
@@ -55,6 +65,7 @@ func newChunkFromReader(reader chunkstore.IChunkReader, shard *ChunkShard) (chun
 
 		activeBlocks:    make(map[BlockIndex]bool),
 		newActiveBlocks: make(map[BlockIndex]bool),
+		scheduledBlocks: make(map[BlockIndex]Ticks),
 		tickAll:         true,
 	}
 
@@ -65,12 +76,22 @@ func newChunkFromReader(reader chunkstore.IChunkReader, shard *ChunkShard) (chun
 		chunk.entities[entityId] = entity
 	}
 
-	// TODO Load tile entities. Should rename
-	// Chunk.blockExtra->Chunk.TileEntities.
+	for _, tileEntity := range reader.TileEntities() {
+		chunk.addTileEntity(tileEntity)
+	}
 
 	return
 }
 
+// addTileEntity gives the block that the tile entity is for its tile entity.
+func (chunk *Chunk) addTileEntity(tileEntity gamerules.ITileEntity) {
+	blockLoc := tileEntity.Block()
+	if index, _, ok := chunk.getBlockIndexByBlockXyz(&blockLoc); ok {
+		tileEntity.SetChunk(chunk)
+		chunk.blockExtra[index] = tileEntity
+	}
+}
+
 func (chunk *Chunk) save(chunkStore chunkstore.IChunkStore) {
 	if chunk.storeDirty {
 		writer := chunkStore.Writer()
@@ -80,12 +101,47 @@ func (chunk *Chunk) save(chunkStore chunkstore.IChunkStore) {
 		writer.SetBlockLight(chunk.blockLight)
 		writer.SetSkyLight(chunk.skyLight)
 		writer.SetHeightMap(chunk.heightMap)
+		writer.SetTerrainPopulated(chunk.populated)
 		writer.SetEntities(chunk.entities)
+		writer.SetTileEntities(chunk.tileEntities())
 		chunkStore.WriteChunk(writer)
 		chunk.storeDirty = false
 	}
 }
 
+// isIdle returns true if nothing needs the chunk to stay loaded: no players
+// are in or subscribed to it, and no blocks in it need to tick.
+func (chunk *Chunk) isIdle() bool {
+	return len(chunk.subscribers) == 0 &&
+		len(chunk.playersData) == 0 &&
+		len(chunk.activeBlocks) == 0 &&
+		len(chunk.newActiveBlocks) == 0 &&
+		len(chunk.scheduledBlocks) == 0 &&
+		!chunk.tickAll
+}
+
+// unload saves the chunk, including the entities within it, and releases
+// their EntityIds. New EntityIds are given to them when the chunk is loaded
+// again. The chunk must not be used afterwards.
+func (chunk *Chunk) unload(chunkStore chunkstore.IChunkStore) {
+	chunk.save(chunkStore)
+
+	for entityId := range chunk.entities {
+		chunk.shard.entityMgr.RemoveEntityById(entityId)
+	}
+}
+
+// tileEntities returns the block extra data within the chunk that is to be
+// stored with it.
+func (chunk *Chunk) tileEntities() (tileEntities []gamerules.ITileEntity) {
+	for _, extra := range chunk.blockExtra {
+		if tileEntity, ok := extra.(gamerules.ITileEntity); ok {
+			tileEntities = append(tileEntities, tileEntity)
+		}
+	}
+	return
+}
+
 func (chunk *Chunk) String() string {
 	return fmt.Sprintf("Chunk[%d,%d]", chunk.loc.X, chunk.loc.Z)
 }
@@ -99,19 +155,40 @@ func (chunk *Chunk) setBlock(blockLoc *BlockXyz, subLoc *SubChunkXyz, index Bloc
 	// Invalidate currently stored chunk data.
 	chunk.storeDirty = true
 
+	oldBlockType := index.BlockId(chunk.blocks)
+
 	index.SetBlockId(chunk.blocks, blockType)
 	index.SetBlockData(chunk.blockData, blockData)
 
 	chunk.blockExtra[index] = nil, false
 
+	if lightChanges(oldBlockType, blockType) {
+		chunk.shard.relightBlock(chunk, blockLoc, subLoc, index)
+	}
+
 	// Tell players that the block changed.
 	packet := new(bytes.Buffer)
 	proto.WriteBlockChange(packet, blockLoc, blockType, blockData)
 	chunk.reqMulticastPlayers(-1, packet.Bytes())
 
+	// Let the block and its neighbours react to the change.
+	chunk.activateNeighbours(blockLoc)
+
 	return
 }
 
+// activateNeighbours flags the block at blockLoc and the blocks adjoining it as
+// active. The adjoining blocks may be in other chunks or shards.
+func (chunk *Chunk) activateNeighbours(blockLoc *BlockXyz) {
+	chunk.AddActiveBlock(blockLoc)
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		dx, dy, dz := face.Dxyz()
+		if neighbourLoc := blockLoc.AddXyz(dx, dy, dz); neighbourLoc != nil {
+			chunk.AddActiveBlock(neighbourLoc)
+		}
+	}
+}
+
 func (chunk *Chunk) blockId(index BlockIndex) BlockId {
 	return BlockId(index.BlockData(chunk.blocks))
 }
@@ -128,6 +205,113 @@ func (chunk *Chunk) SetBlockByIndex(blockIndex BlockIndex, blockId BlockId, bloc
 		blockData)
 }
 
+// chunkForBlock returns the chunk containing blockLoc and the index of the
+// block within it. The chunk must be this chunk, or another loaded chunk
+// within the same shard.
+func (chunk *Chunk) chunkForBlock(blockLoc *BlockXyz) (target *Chunk, index BlockIndex, ok bool) {
+	chunkLoc, subLoc := blockLoc.ToChunkLocal()
+
+	if chunk.isSameChunk(chunkLoc) {
+		target = chunk
+	} else if target = chunk.shard.loadedChunk(*chunkLoc); target == nil {
+		return
+	}
+
+	index, ok = subLoc.BlockIndex()
+	return
+}
+
+func (chunk *Chunk) BlockAt(blockLoc *BlockXyz) (blockType *gamerules.BlockType, blockData byte, ok bool) {
+	if shardLoc := blockLoc.ToChunkXz().ToShardXz(); !shardLoc.Equals(&chunk.shard.loc) {
+		return chunk.shard.remoteBlockAt(blockLoc)
+	}
+
+	target, index, ok := chunk.chunkForBlock(blockLoc)
+	if !ok {
+		return
+	}
+
+	return target.blockTypeAndData(index)
+}
+
+func (chunk *Chunk) SetBlockAt(blockLoc *BlockXyz, blockId BlockId, blockData byte) (ok bool) {
+	chunkLoc, subLoc := blockLoc.ToChunkLocal()
+	index, ok := subLoc.BlockIndex()
+	if !ok {
+		return
+	}
+
+	if shardLoc := chunkLoc.ToShardXz(); !shardLoc.Equals(&chunk.shard.loc) {
+		chunk.shard.setRemoteBlock(blockLoc, blockId, blockData)
+		return
+	}
+
+	target := chunk
+	if !chunk.isSameChunk(chunkLoc) {
+		if target = chunk.shard.chunkAt(*chunkLoc); target == nil {
+			return false
+		}
+	}
+
+	target.setBlock(blockLoc, subLoc, index, blockId, blockData)
+	return
+}
+
+func (chunk *Chunk) CurrentTick() Ticks {
+	return chunk.shard.ticks
+}
+
+func (chunk *Chunk) ScheduleBlockTick(blockIndex BlockIndex, delay Ticks) {
+	due := chunk.shard.ticks + delay
+	if existing, ok := chunk.scheduledBlocks[blockIndex]; !ok || due < existing {
+		chunk.scheduledBlocks[blockIndex] = due
+	}
+}
+
+func (chunk *Chunk) IsOccupied(blockLoc *BlockXyz) bool {
+	for _, player := range chunk.playersData {
+		if player.position.ToBlockXyz().Equals(*blockLoc) {
+			return true
+		}
+	}
+	for _, e := range chunk.entities {
+		if e.Position().ToBlockXyz().Equals(*blockLoc) {
+			return true
+		}
+	}
+	return false
+}
+
+func (chunk *Chunk) MulticastPlayers(packet []byte) {
+	chunk.reqMulticastPlayers(-1, packet)
+}
+
+func (chunk *Chunk) PlayersNear(position *AbsXyz, maxDistance AbsCoord) (players []gamerules.NearbyPlayer) {
+	for _, nearChunk := range chunk.shard.loadedChunksNear(position, maxDistance) {
+		for entityId, data := range nearChunk.playersData {
+			if !data.position.IsWithinDistanceOf(position, maxDistance) {
+				continue
+			}
+			if client, ok := nearChunk.subscribers[entityId]; ok {
+				players = append(players, gamerules.NearbyPlayer{entityId, data.position, client})
+			}
+		}
+	}
+	return
+}
+
+func (chunk *Chunk) MobsNear(position *AbsXyz, maxDistance AbsCoord) (mobs []gamerules.IMob) {
+	for _, nearChunk := range chunk.shard.loadedChunksNear(position, maxDistance) {
+		for _, e := range nearChunk.entities {
+			mob, ok := e.(gamerules.IMob)
+			if ok && mob.IsAlive() && mob.Position().IsWithinDistanceOf(position, maxDistance) {
+				mobs = append(mobs, mob)
+			}
+		}
+	}
+	return
+}
+
 func (chunk *Chunk) Rand() *rand.Rand {
 	return chunk.rand
 }
@@ -248,6 +432,14 @@ func (chunk *Chunk) reqHitBlock(player gamerules.IPlayerClient, held gamerules.S
 		return
 	}
 
+	if !gamerules.MayBuild(player.Name(), chunk.shard.dimension, target) {
+		if digStatus == DigBlockBroke {
+			player.EchoMessage(msgMayNotBuild)
+			chunk.resendBlock(player, target)
+		}
+		return
+	}
+
 	if blockType.Destructable && blockType.Aspect.Hit(blockInstance, player, digStatus) {
 		blockType.Aspect.Destroy(blockInstance)
 		chunk.setBlock(target, &blockInstance.SubLoc, blockInstance.Index, BlockIdAir, 0)
@@ -267,7 +459,21 @@ func (chunk *Chunk) reqInteractBlock(player gamerules.IPlayerClient, held gameru
 		return
 	}
 
-	if _, isBlockHeld := held.ItemTypeId.ToBlockId(); isBlockHeld && blockType.Attachable {
+	if held.ItemTypeId == gamerules.ItemIdFlintAndSteel {
+		// Flint and steel lights a portal frame that the face is inside of.
+		// TODO Set fire to the block otherwise, once fire has a behaviour.
+		dx, dy, dz := againstFace.Dxyz()
+		if destLoc := target.AddXyz(dx, dy, dz); destLoc != nil {
+			if gamerules.MayBuild(player.Name(), chunk.shard.dimension, destLoc) {
+				gamerules.LightPortal(chunk, destLoc)
+			} else {
+				player.EchoMessage(msgMayNotBuild)
+			}
+		}
+		return
+	}
+
+	if _, isPlaceable := chunk.placedBlockId(held.ItemTypeId); isPlaceable && blockType.Attachable {
 		// The player is interacting with a block that can be attached to.
 
 		// Work out the position to put the block at.
@@ -278,7 +484,9 @@ func (chunk *Chunk) reqInteractBlock(player gamerules.IPlayerClient, held gameru
 			return
 		}
 
-		player.PlaceHeldItem(*destLoc, held)
+		player.PlaceHeldItem(*destLoc, held, againstFace)
+	} else if blockType.HasInventory() && !gamerules.MayUse(player.Name(), chunk.shard.dimension, target) {
+		player.EchoMessage(msgMayNotUse)
 	} else {
 		// Player is otherwise interacting with the block.
 		blockType.Aspect.Interact(blockInstance, player)
@@ -290,7 +498,7 @@ func (chunk *Chunk) reqInteractBlock(player gamerules.IPlayerClient, held gameru
 // placeBlock attempts to place a block. This is called by PlayerBlockInteract
 // in the situation where the player interacts with an attachable block
 // (potentially in a different chunk to the one where the block gets placed).
-func (chunk *Chunk) reqPlaceItem(player gamerules.IPlayerClient, target *BlockXyz, slot *gamerules.Slot) {
+func (chunk *Chunk) reqPlaceItem(player gamerules.IPlayerClient, target *BlockXyz, slot *gamerules.Slot, againstFace Face, look *LookDegrees) {
 	// TODO defer a check for remaining items in slot, and do something with them
 	// (send to player or drop on the ground).
 
@@ -298,12 +506,33 @@ func (chunk *Chunk) reqPlaceItem(player gamerules.IPlayerClient, target *BlockXy
 	// items on farmland doesn't fit this current simplistic model). The block
 	// type for the block being placed against should probably contain this logic
 	// (i.e farmland block should know about the seed item).
-	heldBlockType, ok := slot.ItemTypeId.ToBlockId()
+	heldBlockType, ok := chunk.placedBlockId(slot.ItemTypeId)
 	if !ok || slot.Count < 1 {
 		// Not a placeable item.
 		return
 	}
 
+	if !gamerules.MayBuild(player.Name(), chunk.shard.dimension, target) {
+		// Give the item back, and undo the block that the client placed.
+		player.EchoMessage(msgMayNotBuild)
+		player.GiveItem(*slot)
+		chunk.resendBlock(player, target)
+		return
+	}
+
+	placedData := byte(slot.Data)
+	placedType, ok := gamerules.Blocks.Get(heldBlockType)
+	if !ok {
+		return
+	}
+	placeable, isPlaceable := placedType.Aspect.(gamerules.IPlaceableAspect)
+	if isPlaceable {
+		// The block decides how it is placed.
+		if heldBlockType, placedData, ok = placeable.PlaceAs(againstFace, look); !ok {
+			return
+		}
+	}
+
 	index, subLoc, ok := chunk.getBlockIndexByBlockXyz(target)
 	if !ok {
 		return
@@ -317,13 +546,65 @@ func (chunk *Chunk) reqPlaceItem(player gamerules.IPlayerClient, target *BlockXy
 	}
 
 	// Safe to replace block.
-	chunk.setBlock(target, subLoc, index, heldBlockType, byte(slot.Data))
+	chunk.setBlock(target, subLoc, index, heldBlockType, placedData)
 	// Allow this block to tick once
 	chunk.AddActiveBlockIndex(index)
 
+	if isPlaceable {
+		blockInstance, blockType, ok := chunk.blockInstanceAndType(target)
+		if ok {
+			if placeable, ok := blockType.Aspect.(gamerules.IPlaceableAspect); ok {
+				placeable.Placed(blockInstance, player)
+			}
+		}
+	}
+
 	slot.Decrement()
 }
 
+// resendBlock tells the player what the block at blockLoc within the chunk
+// really is, after the player was not allowed to change it.
+func (chunk *Chunk) resendBlock(player gamerules.IPlayerClient, blockLoc *BlockXyz) {
+	index, _, ok := chunk.getBlockIndexByBlockXyz(blockLoc)
+	if !ok {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	proto.WriteBlockChange(buf, blockLoc, index.BlockId(chunk.blocks), index.BlockData(chunk.blockData))
+	player.TransmitPacket(buf.Bytes())
+}
+
+// placedBlockId returns the ID of the block that an item creates when placed.
+// ok=false if the item cannot be placed.
+func (chunk *Chunk) placedBlockId(itemTypeId ItemTypeId) (blockId BlockId, ok bool) {
+	if blockId, ok = itemTypeId.ToBlockId(); ok {
+		return
+	}
+
+	if itemType, isItem := chunk.ItemType(itemTypeId); isItem && itemType.PlacesBlock != BlockIdAir {
+		return itemType.PlacesBlock, true
+	}
+
+	return
+}
+
+func (chunk *Chunk) reqSetSignText(player gamerules.IPlayerClient, target *BlockXyz, lines [4]string) {
+	blockInstance, blockType, ok := chunk.blockInstanceAndType(target)
+	if !ok {
+		return
+	}
+
+	if !gamerules.MayBuild(player.Name(), chunk.shard.dimension, target) {
+		player.EchoMessage(msgMayNotBuild)
+		return
+	}
+
+	if sign, ok := blockType.Aspect.(*gamerules.SignAspect); ok {
+		sign.SetText(blockInstance, player, lines)
+	}
+}
+
 func (chunk *Chunk) reqTakeItem(player gamerules.IPlayerClient, entityId EntityId) {
 	if entity, ok := chunk.entities[entityId]; ok {
 		if item, ok := entity.(*gamerules.Item); ok {
@@ -358,6 +639,11 @@ func (chunk *Chunk) reqInventoryClick(player gamerules.IPlayerClient, blockLoc *
 		return
 	}
 
+	if !gamerules.MayUse(player.Name(), chunk.shard.dimension, blockLoc) {
+		player.EchoMessage(msgMayNotUse)
+		return
+	}
+
 	blockType.Aspect.InventoryClick(blockInstance, player, click)
 }
 
@@ -419,6 +705,8 @@ func (chunk *Chunk) BlockQuery(blockLoc BlockXyz) (isSolid bool, isWithinChunk b
 
 func (chunk *Chunk) tick() {
 	chunk.spawnTick()
+	chunk.playerTick()
+	chunk.scheduledTick()
 	if chunk.tickAll {
 		chunk.tickAll = false
 		chunk.blockTickAll()
@@ -427,6 +715,28 @@ func (chunk *Chunk) tick() {
 	}
 }
 
+// playerTick tells players in the chunk when the blocks around them that can
+// harm them have changed.
+func (chunk *Chunk) playerTick() {
+	for _, data := range chunk.playersData {
+		chunk.updatePlayerEnvironment(data, false)
+	}
+}
+
+// updatePlayerEnvironment works out the environment of a player in the chunk,
+// and tells them about it if it has changed, or always if force is true.
+func (chunk *Chunk) updatePlayerEnvironment(data *playerData, force bool) {
+	env := gamerules.PlayerEnvironmentAt(chunk, &data.position, playerEyeY)
+	if !force && env.Equals(&data.environment) {
+		return
+	}
+	data.environment = env
+
+	if player, ok := chunk.subscribers[data.entityId]; ok {
+		player.SetEnvironment(env)
+	}
+}
+
 // spawnTick runs all spawns for a tick.
 func (chunk *Chunk) spawnTick() {
 	if len(chunk.entities) == 0 {
@@ -437,6 +747,11 @@ func (chunk *Chunk) spawnTick() {
 	outgoingEntities := []gamerules.INonPlayerEntity{}
 
 	for _, e := range chunk.entities {
+		if thinker, ok := e.(gamerules.IThinkingEntity); ok && thinker.Think(chunk) {
+			chunk.removeEntity(e)
+			continue
+		}
+
 		if e.Tick(chunk) {
 			if e.Position().Y <= 0 {
 				// Item or mob fell out of the world.
@@ -444,6 +759,13 @@ func (chunk *Chunk) spawnTick() {
 			} else {
 				outgoingEntities = append(outgoingEntities, e)
 			}
+		} else if blockEntity, ok := e.(gamerules.IBlockEntity); ok {
+			if blockEntity.BlockTick(chunk) {
+				chunk.removeEntity(e)
+			}
+		} else if attackable, ok := e.(gamerules.IAttackable); ok && attackable.Expired() {
+			// Mob has finished dying.
+			chunk.removeEntity(e)
 		}
 	}
 
@@ -469,6 +791,16 @@ func (chunk *Chunk) spawnTick() {
 	chunk.storeDirty = true
 }
 
+// scheduledTick makes active any blocks whose scheduled tick has come.
+func (chunk *Chunk) scheduledTick() {
+	for blockIndex, due := range chunk.scheduledBlocks {
+		if due <= chunk.shard.ticks {
+			chunk.newActiveBlocks[blockIndex] = true
+			chunk.scheduledBlocks[blockIndex] = 0, false
+		}
+	}
+}
+
 // blockTick runs any blocks that need to do something each tick.
 func (chunk *Chunk) blockTick() {
 	if len(chunk.activeBlocks) == 0 && len(chunk.newActiveBlocks) == 0 {
@@ -489,6 +821,7 @@ func (chunk *Chunk) blockTick() {
 		if !ok {
 			// Invalid block.
 			chunk.activeBlocks[blockIndex] = false, false
+			continue
 		}
 
 		blockInstance.SubLoc = blockIndex.ToSubChunkXyz()
@@ -537,6 +870,8 @@ func (chunk *Chunk) AddActiveBlock(blockXyz *BlockXyz) {
 		if index, ok := subLoc.BlockIndex(); ok {
 			chunk.newActiveBlocks[index] = true
 		}
+	} else {
+		chunk.shard.addActiveBlock(blockXyz)
 	}
 }
 
@@ -592,6 +927,17 @@ func (chunk *Chunk) reqSubscribeChunk(entityId EntityId, player gamerules.IPlaye
 		player.TransmitPacket(buf.Bytes())
 	}
 
+	// Send the state of tile entities that the player needs to see.
+	tileEntitiesPacket := new(bytes.Buffer)
+	for _, extra := range chunk.blockExtra {
+		if tileEntity, ok := extra.(gamerules.IClientTileEntity); ok {
+			tileEntity.SendUpdate(tileEntitiesPacket)
+		}
+	}
+	if tileEntitiesPacket.Len() > 0 {
+		player.TransmitPacket(tileEntitiesPacket.Bytes())
+	}
+
 	// Spawn existing players for new player.
 	if len(chunk.playersData) > 0 {
 		playersPacket := new(bytes.Buffer)
@@ -674,6 +1020,7 @@ func (chunk *Chunk) reqAddPlayerData(entityId EntityId, name string, pos AbsXyz,
 		heldItemId: held,
 	}
 	chunk.playersData[entityId] = newPlayerData
+	chunk.updatePlayerEnvironment(newPlayerData, true)
 
 	// Spawn new player for existing players.
 	newPlayerPacket := new(bytes.Buffer)
@@ -704,6 +1051,9 @@ func (chunk *Chunk) reqSetPlayerPosition(entityId EntityId, pos AbsXyz) {
 
 	data.position = pos
 
+	// Let any block that the player is now in (e.g a pressure plate) react.
+	chunk.AddActiveBlock(pos.ToBlockXyz())
+
 	// Update subscribers.
 	buf := new(bytes.Buffer)
 	data.sendPositionLook(buf)
@@ -746,6 +1096,57 @@ func (chunk *Chunk) reqSetPlayerLook(entityId EntityId, look LookBytes) {
 	chunk.reqMulticastPlayers(entityId, buf.Bytes())
 }
 
+// reqAttackEntity attacks the player or other entity with the given ID, if it
+// is in the chunk. It returns false if the entity was not found.
+func (chunk *Chunk) reqAttackEntity(attacker gamerules.IPlayerClient, target EntityId, held *gamerules.Slot, attackerPos *AbsXyz, mayHurtPlayers bool) (found bool) {
+	damage := gamerules.AttackDamage(held)
+
+	if data, ok := chunk.playersData[target]; ok {
+		if !mayHurtPlayers || !attackerPos.IsWithinDistanceOf(&data.position, MaxInteractDistance) {
+			return true
+		}
+		// The attacker must also be allowed to attack players where the target
+		// is, so that players cannot be attacked from outside a region that
+		// protects them.
+		if !gamerules.MayAttackPlayers(attacker.Name(), chunk.shard.dimension, data.position.ToBlockXyz()) {
+			return true
+		}
+		if player, ok := chunk.subscribers[target]; ok {
+			player.Attacked(damage, gamerules.Knockback(attackerPos, &data.position))
+		}
+		return true
+	}
+
+	entity, ok := chunk.entities[target]
+	if !ok {
+		return false
+	}
+
+	attackable, ok := entity.(gamerules.IAttackable)
+	if !ok || !attackerPos.IsWithinDistanceOf(entity.Position(), MaxInteractDistance) {
+		return true
+	}
+
+	knockback := gamerules.Knockback(attackerPos, entity.Position())
+	hurt, dead := attackable.Attacked(damage, &knockback)
+	if !hurt {
+		return true
+	}
+
+	status := EntityStatusHurt
+	if dead {
+		status = EntityStatusDead
+	}
+	buf := new(bytes.Buffer)
+	proto.WriteEntityStatus(buf, target, status)
+	entity.SendUpdate(buf)
+	chunk.reqMulticastPlayers(-1, buf.Bytes())
+
+	chunk.storeDirty = true
+
+	return true
+}
+
 func (chunk *Chunk) chunkPacket() []byte {
 	if chunk.cachedPacket == nil {
 		buf := new(bytes.Buffer)
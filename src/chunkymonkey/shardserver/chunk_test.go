@@ -0,0 +1,204 @@
+package shardserver
+
+import (
+	"strings"
+	"testing"
+
+	"gomock.googlecode.com/hg/gomock"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/permission"
+	"chunkymonkey/region"
+	. "chunkymonkey/types"
+)
+
+const (
+	testBuilder = "builder"
+	testVisitor = "visitor"
+
+	testBlockIdChest = BlockId(54)
+	testItemIdStone  = ItemTypeId(1)
+)
+
+const (
+	testPermUsersJson = `{
+		"builder": {
+			"groups": ["builders"]
+		}
+	}`
+	testPermGroupsJson = `{
+		"default": {
+			"default": true,
+			"permissions": []
+		},
+		"builders": {
+			"inheritance": ["default"],
+			"permissions": ["world.build", "world.use"]
+		}
+	}`
+)
+
+// setUpTestPermissions allows testBuilder to build and use blocks, and
+// nobody else. It returns a function that restores the permissions.
+func setUpTestPermissions(t *testing.T) (restore func()) {
+	permissions, err := permission.LoadJsonPermission(strings.NewReader(testPermUsersJson), strings.NewReader(testPermGroupsJson))
+	if err != nil {
+		t.Fatalf("Failed to load permissions: %v", err)
+	}
+
+	oldPermissions := gamerules.Permissions()
+	gamerules.SetPermissions(permissions)
+
+	return func() {
+		gamerules.SetPermissions(oldPermissions)
+	}
+}
+
+func newTestPlayer(mockCtrl *gomock.Controller, name string) *gamerules.MockIPlayerClient {
+	player := gamerules.NewMockIPlayerClient(mockCtrl)
+	player.EXPECT().Name().Return(name).AnyTimes()
+	player.EXPECT().GetEntityId().Return(EntityId(1)).AnyTimes()
+	return player
+}
+
+func TestReqHitBlock_Permissions(t *testing.T) {
+	defer setUpTestPermissions(t)()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ts := newTestShards(nil)
+	target := BlockXyz{8, testFloorY, 8}
+	chunk := ts.chunk(*target.ToChunkXz())
+
+	// The visitor is told, and sent the block back.
+	visitor := newTestPlayer(mockCtrl, testVisitor)
+	visitor.EXPECT().EchoMessage(msgMayNotBuild)
+	visitor.EXPECT().TransmitPacket(gomock.Any())
+	chunk.reqHitBlock(visitor, gamerules.Slot{}, DigBlockBroke, &target, FaceTop)
+	ts.expectBlock(t, target, testBlockIdStone, 0)
+
+	builder := newTestPlayer(mockCtrl, testBuilder)
+	chunk.reqHitBlock(builder, gamerules.Slot{}, DigBlockBroke, &target, FaceTop)
+	ts.expectBlock(t, target, BlockIdAir, 0)
+}
+
+func TestReqPlaceItem_Permissions(t *testing.T) {
+	defer setUpTestPermissions(t)()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ts := newTestShards(nil)
+	target := BlockXyz{8, testFloorY + 1, 8}
+	chunk := ts.chunk(*target.ToChunkXz())
+	look := LookDegrees{}
+
+	// The visitor is given the item back, and sent the block back.
+	slot := gamerules.Slot{testItemIdStone, 1, 0}
+	visitor := newTestPlayer(mockCtrl, testVisitor)
+	visitor.EXPECT().EchoMessage(msgMayNotBuild)
+	visitor.EXPECT().GiveItem(slot)
+	visitor.EXPECT().TransmitPacket(gomock.Any())
+	chunk.reqPlaceItem(visitor, &target, &slot, FaceTop, &look)
+	ts.expectBlock(t, target, BlockIdAir, 0)
+
+	builder := newTestPlayer(mockCtrl, testBuilder)
+	chunk.reqPlaceItem(builder, &target, &slot, FaceTop, &look)
+	ts.expectBlock(t, target, testBlockIdStone, 0)
+	if slot.Count != 0 {
+		t.Errorf("expected the placed item to be used, %d left", slot.Count)
+	}
+}
+
+func TestReqInteractBlock_Permissions(t *testing.T) {
+	defer setUpTestPermissions(t)()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ts := newTestShards(nil)
+	chestLoc := BlockXyz{8, testFloorY + 1, 8}
+	ts.setBlock(chestLoc, testBlockIdChest, 0)
+	chunk := ts.chunk(*chestLoc.ToChunkXz())
+
+	// The visitor may not open the chest.
+	visitor := newTestPlayer(mockCtrl, testVisitor)
+	visitor.EXPECT().EchoMessage(msgMayNotUse)
+	chunk.reqInteractBlock(visitor, gamerules.Slot{}, &chestLoc, FaceTop)
+
+	// Nor may they light a fire on the floor.
+	floorLoc := BlockXyz{9, testFloorY, 9}
+	visitor.EXPECT().EchoMessage(msgMayNotBuild)
+	chunk.reqInteractBlock(visitor, gamerules.Slot{gamerules.ItemIdFlintAndSteel, 1, 0}, &floorLoc, FaceTop)
+
+	// The builder is asked to place the held block against the floor.
+	builder := newTestPlayer(mockCtrl, testBuilder)
+	held := gamerules.Slot{testItemIdStone, 1, 0}
+	builder.EXPECT().PlaceHeldItem(BlockXyz{9, testFloorY + 1, 9}, held, Face(FaceTop))
+	chunk.reqInteractBlock(builder, held, &floorLoc, FaceTop)
+}
+
+func TestReqSetSignText_Permissions(t *testing.T) {
+	defer setUpTestPermissions(t)()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ts := newTestShards(nil)
+	target := BlockXyz{8, testFloorY, 8}
+	chunk := ts.chunk(*target.ToChunkXz())
+
+	visitor := newTestPlayer(mockCtrl, testVisitor)
+	visitor.EXPECT().EchoMessage(msgMayNotBuild)
+	chunk.reqSetSignText(visitor, &target, [4]string{"a", "b", "c", "d"})
+}
+
+func TestReqInventoryClick_Permissions(t *testing.T) {
+	defer setUpTestPermissions(t)()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ts := newTestShards(nil)
+	chestLoc := BlockXyz{8, testFloorY + 1, 8}
+	ts.setBlock(chestLoc, testBlockIdChest, 0)
+	chunk := ts.chunk(*chestLoc.ToChunkXz())
+
+	visitor := newTestPlayer(mockCtrl, testVisitor)
+	visitor.EXPECT().EchoMessage(msgMayNotUse)
+	chunk.reqInventoryClick(visitor, &chestLoc, &gamerules.Click{})
+}
+
+func TestReqAttackEntity_Regions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Nobody may attack players in the region.
+	regions, err := region.LoadRegions(strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	noPvp := region.NewRegion(DimensionNormal, &BlockXyz{8, 0, 0}, &BlockXyz{15, ChunkSizeY - 1, 15})
+	if err = regions.Define("nopvp", noPvp); err != nil {
+		t.Fatal(err)
+	}
+	oldRegions := gamerules.Regions
+	gamerules.Regions = regions
+	defer func() {
+		gamerules.Regions = oldRegions
+	}()
+
+	ts := newTestShards(nil)
+	chunk := ts.chunk(ChunkXz{0, 0})
+
+	targetId := EntityId(2)
+	target := gamerules.NewMockIPlayerClient(mockCtrl)
+	chunk.reqAddPlayerData(targetId, "target", AbsXyz{9.5, testFloorY + 1, 8.5}, LookBytes{}, 0)
+	chunk.subscribers[targetId] = target
+
+	// The attacker is outside the region, but the target is inside it.
+	attacker := newTestPlayer(mockCtrl, "attacker")
+	attackerPos := AbsXyz{6.5, testFloorY + 1, 8.5}
+	chunk.reqAttackEntity(attacker, targetId, &gamerules.Slot{}, &attackerPos, true)
+
+	// Once the target has left the region, it can be attacked.
+	chunk.playersData[targetId].position = AbsXyz{7.5, testFloorY + 1, 8.5}
+	target.EXPECT().Attacked(gomock.Any(), gomock.Any())
+	chunk.reqAttackEntity(attacker, targetId, &gamerules.Slot{}, &attackerPos, true)
+}
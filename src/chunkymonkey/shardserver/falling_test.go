@@ -0,0 +1,84 @@
+package shardserver
+
+import (
+	"testing"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+const (
+	testBlockIdSand  = BlockId(12)
+	testBlockIdTorch = BlockId(50)
+)
+
+// fallingTestTicks is long enough for a falling block to land from a few
+// blocks up.
+const fallingTestTicks = 100
+
+// objectsOf returns the objects in the chunk of the given type.
+func objectsOf(chunk *Chunk, objTypeId ObjTypeId) (objects []*gamerules.Object) {
+	for _, entity := range chunk.entities {
+		if object, ok := entity.(*gamerules.Object); ok && object.ObjTypeId == objTypeId {
+			objects = append(objects, object)
+		}
+	}
+	return
+}
+
+// itemsOf returns the items in the chunk of the given type.
+func itemsOf(chunk *Chunk, itemTypeId ItemTypeId) (items []*gamerules.Item) {
+	for _, entity := range chunk.entities {
+		if item, ok := entity.(*gamerules.Item); ok && item.ItemTypeId == itemTypeId {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+func TestFallingBlock(t *testing.T) {
+	ts := newTestShards(nil)
+
+	sandLoc := BlockXyz{8, testFloorY + 4, 8}
+	chunk := ts.chunk(*sandLoc.ToChunkXz())
+
+	// Sand with air underneath it becomes a falling object.
+	ts.setBlock(sandLoc, testBlockIdSand, 0)
+	ts.tick(1)
+
+	ts.expectBlock(t, sandLoc, BlockIdAir, 0)
+	if objects := objectsOf(chunk, ObjTypeIdFallingSand); len(objects) != 1 {
+		t.Fatalf("expected one falling object, got %d", len(objects))
+	}
+
+	// It lands on the floor as sand again.
+	ts.tick(fallingTestTicks)
+
+	ts.expectBlock(t, BlockXyz{8, testFloorY + 1, 8}, testBlockIdSand, 0)
+	if objects := objectsOf(chunk, ObjTypeIdFallingSand); len(objects) != 0 {
+		t.Errorf("expected the falling object to have landed, got %d", len(objects))
+	}
+	if items := itemsOf(chunk, ItemTypeId(testBlockIdSand)); len(items) != 0 {
+		t.Errorf("expected no sand items, got %d", len(items))
+	}
+}
+
+func TestFallingBlock_OntoTorch(t *testing.T) {
+	ts := newTestShards(nil)
+
+	// The sand falls past the torch, which it cannot replace.
+	torchLoc := BlockXyz{8, testFloorY + 1, 8}
+	sandLoc := BlockXyz{8, testFloorY + 4, 8}
+	chunk := ts.chunk(*sandLoc.ToChunkXz())
+	ts.setBlock(torchLoc, testBlockIdTorch, 5)
+	ts.setBlock(sandLoc, testBlockIdSand, 0)
+	ts.tick(fallingTestTicks)
+
+	ts.expectBlock(t, torchLoc, testBlockIdTorch, 5)
+	if objects := objectsOf(chunk, ObjTypeIdFallingSand); len(objects) != 0 {
+		t.Errorf("expected the falling object to have landed, got %d", len(objects))
+	}
+	if items := itemsOf(chunk, ItemTypeId(testBlockIdSand)); len(items) != 1 {
+		t.Errorf("expected the sand to drop as an item, got %d items", len(items))
+	}
+}
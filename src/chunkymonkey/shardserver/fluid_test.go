@@ -0,0 +1,115 @@
+package shardserver
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	testBlockIdCobblestone = BlockId(4)
+	testBlockIdLava        = BlockId(10)
+	testBlockIdObsidian    = BlockId(49)
+)
+
+// testFluidFalling is the flag in the block data of falling fluid.
+const testFluidFalling = 0x8
+
+// fluidTestTicks is long enough for water to spread as far as it can and
+// settle.
+const fluidTestTicks = 200
+
+// expectWaterLine checks that water has spread along the X axis from a source
+// at from, each block one level lower than the last, and no further.
+func expectWaterLine(t *testing.T, ts *testShards, from BlockXyz) {
+	loc := from
+	for level := byte(0); level <= 7; level++ {
+		ts.expectBlock(t, loc, testBlockIdStill, level)
+		loc.X++
+	}
+	ts.expectBlock(t, loc, BlockIdAir, 0)
+}
+
+func TestFluidSpread(t *testing.T) {
+	ts := newTestShards(nil)
+
+	source := BlockXyz{8, testFloorY + 1, 8}
+	ts.setBlock(source, testBlockIdWater, 0)
+	ts.tick(fluidTestTicks)
+
+	expectWaterLine(t, ts, source)
+
+	// Diagonal blocks are two steps from the source.
+	ts.expectBlock(t, BlockXyz{9, testFloorY + 1, 9}, testBlockIdStill, 2)
+}
+
+func TestFluidSpread_AcrossChunks(t *testing.T) {
+	ts := newTestShards(nil)
+
+	// Blocks are only known in loaded chunks, as they would be with a player
+	// nearby.
+	ts.chunk(ChunkXz{1, 0})
+
+	source := BlockXyz{ChunkSizeH - 4, testFloorY + 1, 8}
+	ts.setBlock(source, testBlockIdWater, 0)
+	ts.tick(fluidTestTicks)
+
+	expectWaterLine(t, ts, source)
+}
+
+func TestFluidSpread_AcrossShards(t *testing.T) {
+	ts := newTestShards(nil)
+
+	// The water flows from the first shard into the next one along the X axis.
+	// The other shard only answers queries about blocks in loaded chunks.
+	ts.chunk(ChunkXz{ShardSize, 0})
+
+	source := BlockXyz{ChunkSizeH*ShardSize - 4, testFloorY + 1, 8}
+	ts.setBlock(source, testBlockIdWater, 0)
+	ts.tick(fluidTestTicks)
+
+	expectWaterLine(t, ts, source)
+}
+
+func TestFluidSpread_Falls(t *testing.T) {
+	ts := newTestShards(nil)
+
+	// Dig a hole two blocks deep next to the source.
+	ts.setBlock(BlockXyz{9, testFloorY, 8}, BlockIdAir, 0)
+	ts.setBlock(BlockXyz{9, testFloorY - 1, 8}, BlockIdAir, 0)
+
+	source := BlockXyz{8, testFloorY + 1, 8}
+	ts.setBlock(source, testBlockIdWater, 0)
+	ts.tick(fluidTestTicks)
+
+	ts.expectBlock(t, source, testBlockIdStill, 0)
+	ts.expectBlock(t, BlockXyz{9, testFloorY, 8}, testBlockIdStill, testFluidFalling)
+	ts.expectBlock(t, BlockXyz{9, testFloorY - 1, 8}, testBlockIdStill, testFluidFalling)
+}
+
+func TestFluidSolidify_OverWater(t *testing.T) {
+	type Test struct {
+		lavaData byte
+		expected BlockId
+	}
+
+	tests := []Test{
+		// A lava source turns to obsidian, and flowing lava to cobblestone.
+		{0, testBlockIdObsidian},
+		{1, testBlockIdCobblestone},
+	}
+
+	for _, test := range tests {
+		ts := newTestShards(nil)
+
+		// Water in a pit in the floor, with lava on top of it.
+		water := BlockXyz{8, testFloorY, 8}
+		lava := BlockXyz{8, testFloorY + 1, 8}
+		ts.setBlock(water, testBlockIdStill, 0)
+		ts.setBlock(lava, testBlockIdLava, test.lavaData)
+		ts.tick(fluidTestTicks)
+
+		ts.expectBlock(t, lava, test.expected, 0)
+		ts.expectBlock(t, water, testBlockIdStill, 0)
+	}
+}
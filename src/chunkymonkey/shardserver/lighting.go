@@ -0,0 +1,389 @@
+package shardserver
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// Light levels range from 0 (dark) to 15.
+const lightMax = 15
+
+// Both kinds of light, for updates that need to process each in turn.
+var lightKinds = []bool{false, true}
+
+// lightNode is a block in a queue of blocks whose light is being updated. sky
+// is true for sky light, and false for block light.
+type lightNode struct {
+	chunk *Chunk
+	index BlockIndex
+	loc   BlockXyz
+	level byte
+}
+
+func (node *lightNode) set(level byte, sky bool) {
+	node.index.SetBlockData(node.chunk.light(sky), level)
+	node.level = level
+	node.chunk.lightChanged()
+}
+
+// destShardLight is a list of light updates for blocks within a single shard.
+type destShardLight struct {
+	loc     ShardXz
+	updates []gamerules.LightUpdate
+}
+
+// heightMapIndex returns the index into a chunk's heightMap for the column
+// containing subLoc.
+func heightMapIndex(subLoc *SubChunkXyz) int {
+	return int(subLoc.Z)<<ChunkHShift | int(subLoc.X)
+}
+
+// blockOpacity returns how much light the block type blocks. Unknown block
+// types are taken as fully opaque.
+func blockOpacity(blockTypeId BlockId) byte {
+	if blockType, ok := gamerules.Blocks.Get(blockTypeId); ok && blockType.Opacity > 0 {
+		return byte(blockType.Opacity)
+	} else if !ok {
+		return lightMax
+	}
+	return 0
+}
+
+// lightAttenuation returns how much light is reduced by entering a block.
+func lightAttenuation(blockTypeId BlockId) byte {
+	if opacity := blockOpacity(blockTypeId); opacity > 1 {
+		return opacity
+	}
+	return 1
+}
+
+// lightChanges returns true if replacing one block type with another changes
+// the light around it.
+func lightChanges(oldBlockTypeId, newBlockTypeId BlockId) bool {
+	if blockOpacity(oldBlockTypeId) != blockOpacity(newBlockTypeId) {
+		return true
+	}
+	return blockLuminance(oldBlockTypeId) != blockLuminance(newBlockTypeId)
+}
+
+// blockLuminance returns the light that the block type gives off.
+func blockLuminance(blockTypeId BlockId) byte {
+	if blockType, ok := gamerules.Blocks.Get(blockTypeId); ok && blockType.Luminance > 0 {
+		return byte(blockType.Luminance)
+	}
+	return 0
+}
+
+func (chunk *Chunk) light(sky bool) []byte {
+	if sky {
+		return chunk.skyLight
+	}
+	return chunk.blockLight
+}
+
+// lightChanged invalidates copies of the chunk that include its light.
+func (chunk *Chunk) lightChanged() {
+	chunk.cachedPacket = nil
+	chunk.storeDirty = true
+}
+
+// updateHeightMap updates the height of the column containing the block at
+// subLoc after the block has changed. The height is one above the highest
+// block that blocks any light.
+func (chunk *Chunk) updateHeightMap(subLoc *SubChunkXyz) (oldHeight, newHeight int) {
+	hmIndex := heightMapIndex(subLoc)
+	oldHeight = int(chunk.heightMap[hmIndex])
+	newHeight = oldHeight
+
+	y := int(subLoc.Y)
+	index, _ := subLoc.BlockIndex()
+
+	if blockOpacity(index.BlockId(chunk.blocks)) > 0 {
+		if y >= oldHeight {
+			newHeight = y + 1
+		}
+	} else if y == oldHeight-1 {
+		// The top of the column was removed, find the next block down that
+		// blocks light.
+		column := SubChunkXyz{subLoc.X, 0, subLoc.Z}
+		for newHeight = y; newHeight > 0; newHeight-- {
+			column.Y = SubChunkCoord(newHeight - 1)
+			below, _ := column.BlockIndex()
+			if blockOpacity(below.BlockId(chunk.blocks)) > 0 {
+				break
+			}
+		}
+	}
+
+	chunk.heightMap[hmIndex] = byte(newHeight)
+	return
+}
+
+// sourceLevel returns the light that a block has regardless of its
+// neighbours. For sky light this is full light if the block is open to the
+// sky, and for block light it is the light that the block gives off.
+func (node *lightNode) sourceLevel(sky bool) byte {
+	if sky {
+		subLoc := node.index.ToSubChunkXyz()
+		if int(subLoc.Y) >= int(node.chunk.heightMap[heightMapIndex(&subLoc)]) {
+			return lightMax
+		}
+		return 0
+	}
+
+	return blockLuminance(node.index.BlockId(node.chunk.blocks))
+}
+
+// lightNodeAt returns a lightNode for the block at loc, if it is within a
+// loaded chunk in the shard.
+func (shard *ChunkShard) lightNodeAt(loc *BlockXyz, sky bool) (node lightNode, ok bool) {
+	chunkLoc, subLoc := loc.ToChunkLocal()
+
+	chunk := shard.loadedChunk(*chunkLoc)
+	if chunk == nil {
+		return
+	}
+
+	index, ok := subLoc.BlockIndex()
+	if !ok {
+		return
+	}
+
+	node = lightNode{
+		chunk: chunk,
+		index: index,
+		loc:   *loc,
+		level: index.BlockData(chunk.light(sky)),
+	}
+
+	return
+}
+
+// sendLight queues up a light update for a block in another shard. level is
+// the light level of the neighbouring block within this shard. Blocks within
+// this shard in unloaded chunks are ignored.
+func (shard *ChunkShard) sendLight(loc *BlockXyz, sky bool, level byte, removed bool) {
+	shardLoc := loc.ToChunkXz().ToShardXz()
+	if shardLoc.Equals(&shard.loc) {
+		return
+	}
+
+	shardKey := shardLoc.Key()
+	destShard, ok := shard.newLightShards[shardKey]
+	if !ok {
+		destShard = &destShardLight{loc: shardLoc}
+		shard.newLightShards[shardKey] = destShard
+	}
+
+	destShard.updates = append(destShard.updates, gamerules.LightUpdate{
+		Loc:     *loc,
+		Sky:     sky,
+		Level:   level,
+		Removed: removed,
+	})
+}
+
+// transferLightUpdates sends the light updates queued by sendLight to the
+// shards that they are for.
+func (shard *ChunkShard) transferLightUpdates() {
+	if len(shard.newLightShards) == 0 {
+		return
+	}
+
+	for shardKey, destShard := range shard.newLightShards {
+		if client := shard.clientForShard(destShard.loc); client != nil {
+			client.ReqUpdateLight(destShard.updates)
+		}
+		shard.newLightShards[shardKey] = nil, false
+	}
+}
+
+// spreadLight spreads light outwards from the given nodes, brightening any
+// blocks that are darker than the light reaching them.
+func (shard *ChunkShard) spreadLight(queue []lightNode, sky bool) {
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if node.level <= 1 {
+			continue
+		}
+
+		for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+			dx, dy, dz := face.Dxyz()
+			loc := node.loc.AddXyz(dx, dy, dz)
+			if loc == nil {
+				continue
+			}
+
+			neighbour, ok := shard.lightNodeAt(loc, sky)
+			if !ok {
+				shard.sendLight(loc, sky, node.level, false)
+				continue
+			}
+
+			attenuation := lightAttenuation(neighbour.index.BlockId(neighbour.chunk.blocks))
+			if node.level <= attenuation {
+				continue
+			}
+			if level := node.level - attenuation; level > neighbour.level {
+				neighbour.set(level, sky)
+				queue = append(queue, neighbour)
+			}
+		}
+	}
+}
+
+// removeLight darkens the blocks that were lit by the given nodes. The nodes
+// must already have had their light set to zero, with node.level holding
+// their previous level. It returns the nodes that light must be spread from to
+// fill in the darkened area again.
+func (shard *ChunkShard) removeLight(queue []lightNode, sky bool) (relight []lightNode) {
+	for _, node := range queue {
+		if source := node.sourceLevel(sky); source > 0 {
+			node.set(source, sky)
+			relight = append(relight, node)
+		}
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+			dx, dy, dz := face.Dxyz()
+			loc := node.loc.AddXyz(dx, dy, dz)
+			if loc == nil {
+				continue
+			}
+
+			neighbour, ok := shard.lightNodeAt(loc, sky)
+			if !ok {
+				shard.sendLight(loc, sky, node.level, true)
+				continue
+			}
+
+			if neighbour.level == 0 {
+				continue
+			}
+
+			if neighbour.level < node.level {
+				// The neighbour may have been lit by the node, so darken it too.
+				oldLevel := neighbour.level
+				neighbour.set(0, sky)
+				if source := neighbour.sourceLevel(sky); source > 0 {
+					neighbour.set(source, sky)
+					relight = append(relight, neighbour)
+				}
+				neighbour.level = oldLevel
+				queue = append(queue, neighbour)
+			} else {
+				// The neighbour is lit by something else, and may light the
+				// darkened blocks.
+				relight = append(relight, neighbour)
+			}
+		}
+	}
+
+	return
+}
+
+// relightBlock updates the light around a block that has changed in how much
+// light it blocks or gives off.
+func (shard *ChunkShard) relightBlock(chunk *Chunk, loc *BlockXyz, subLoc *SubChunkXyz, index BlockIndex) {
+	oldHeight, newHeight := chunk.updateHeightMap(subLoc)
+
+	for _, sky := range lightKinds {
+		darkened := []lightNode{
+			lightNode{chunk, index, *loc, index.BlockData(chunk.light(sky))},
+		}
+		var opened []lightNode
+
+		if sky && newHeight != oldHeight {
+			// The blocks in the column between the old and new heights have
+			// changed between being open to the sky and not.
+			column := *loc
+			column.Y = BlockYCoord(oldHeight)
+			columnSubLoc := *subLoc
+			step := 1
+			if newHeight < oldHeight {
+				step = -1
+			}
+			for y := oldHeight; y != newHeight; y += step {
+				columnY := y
+				if step < 0 {
+					columnY = y - 1
+				}
+				if columnY == int(subLoc.Y) {
+					continue
+				}
+				column.Y = BlockYCoord(columnY)
+				columnSubLoc.Y = SubChunkCoord(columnY)
+				columnIndex, ok := columnSubLoc.BlockIndex()
+				if !ok {
+					continue
+				}
+				node := lightNode{chunk, columnIndex, column, columnIndex.BlockData(chunk.skyLight)}
+				if step > 0 {
+					darkened = append(darkened, node)
+				} else {
+					opened = append(opened, node)
+				}
+			}
+		}
+
+		for i := range darkened {
+			oldLevel := darkened[i].level
+			darkened[i].set(0, sky)
+			darkened[i].level = oldLevel
+		}
+
+		relight := shard.removeLight(darkened, sky)
+
+		for i := range opened {
+			opened[i].set(lightMax, sky)
+		}
+		relight = append(relight, opened...)
+
+		shard.spreadLight(relight, sky)
+	}
+}
+
+// reqUpdateLight applies changes in light that have spread from another
+// shard.
+func (shard *ChunkShard) reqUpdateLight(updates []gamerules.LightUpdate) {
+	for _, sky := range lightKinds {
+		var darkened, relight []lightNode
+
+		for _, update := range updates {
+			if update.Sky != sky {
+				continue
+			}
+
+			node, ok := shard.lightNodeAt(&update.Loc, sky)
+			if !ok {
+				continue
+			}
+
+			if update.Removed {
+				if node.level != 0 && node.level < update.Level {
+					oldLevel := node.level
+					node.set(0, sky)
+					node.level = oldLevel
+					darkened = append(darkened, node)
+				} else if node.level > 0 {
+					relight = append(relight, node)
+				}
+			} else {
+				attenuation := lightAttenuation(node.index.BlockId(node.chunk.blocks))
+				if update.Level > attenuation && update.Level-attenuation > node.level {
+					node.set(update.Level-attenuation, sky)
+					relight = append(relight, node)
+				}
+			}
+		}
+
+		relight = append(relight, shard.removeLight(darkened, sky)...)
+		shard.spreadLight(relight, sky)
+	}
+}
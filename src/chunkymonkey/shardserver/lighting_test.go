@@ -0,0 +1,101 @@
+package shardserver
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+const testBlockIdGlowstone = BlockId(89)
+
+// lightAt returns the sky or block light of a block, loading the chunk that it
+// is within if need be.
+func (ts *testShards) lightAt(loc BlockXyz, sky bool) byte {
+	chunkLoc, subLoc := loc.ToChunkLocal()
+	chunk := ts.chunk(*chunkLoc)
+	index, _ := subLoc.BlockIndex()
+	return index.BlockData(chunk.light(sky))
+}
+
+// expectLight checks the sky or block light of a block.
+func (ts *testShards) expectLight(t *testing.T, loc BlockXyz, sky bool, level byte) {
+	if got := ts.lightAt(loc, sky); got != level {
+		kind := "block"
+		if sky {
+			kind = "sky"
+		}
+		t.Errorf("%s light at %#v: expected %d, got %d", kind, loc, level, got)
+	}
+}
+
+// setRoof fills a square layer of blocks at y, from min to max along both the
+// X and Z axes.
+func (ts *testShards) setRoof(min, max BlockCoord, y BlockYCoord, blockId BlockId) {
+	for x := min; x <= max; x++ {
+		for z := min; z <= max; z++ {
+			ts.setBlock(BlockXyz{x, y, z}, blockId, 0)
+		}
+	}
+}
+
+func TestSkyLight_Overhang(t *testing.T) {
+	ts := newTestShards(nil)
+
+	// A roof from 4 to 12 along both axes, three blocks above the floor.
+	roofY := BlockYCoord(testFloorY + 4)
+	ts.setRoof(4, 12, roofY, testBlockIdStone)
+
+	// Light reaches under the roof from its sides, one level darker for each
+	// block that it travels.
+	ts.expectLight(t, BlockXyz{3, testFloorY + 1, 8}, true, 15)
+	ts.expectLight(t, BlockXyz{4, testFloorY + 1, 8}, true, 14)
+	ts.expectLight(t, BlockXyz{6, testFloorY + 2, 8}, true, 12)
+	ts.expectLight(t, BlockXyz{8, testFloorY + 1, 8}, true, 10)
+	ts.expectLight(t, BlockXyz{8, testFloorY + 3, 8}, true, 10)
+
+	// Above the roof is open to the sky, and the roof itself is dark.
+	ts.expectLight(t, BlockXyz{8, roofY + 1, 8}, true, 15)
+	ts.expectLight(t, BlockXyz{8, roofY, 8}, true, 0)
+}
+
+func TestBlockLight_Falloff(t *testing.T) {
+	ts := newTestShards(nil)
+
+	source := BlockXyz{8, testFloorY + 1, 8}
+	ts.setBlock(source, testBlockIdGlowstone, 0)
+
+	ts.expectLight(t, source, false, 15)
+	for dx := BlockCoord(1); dx < 8; dx++ {
+		ts.expectLight(t, BlockXyz{source.X + dx, source.Y, source.Z}, false, 15-byte(dx))
+	}
+	ts.expectLight(t, BlockXyz{source.X, source.Y + 5, source.Z}, false, 10)
+	ts.expectLight(t, BlockXyz{source.X + 1, source.Y + 1, source.Z + 1}, false, 12)
+
+	// Light does not pass through the floor.
+	ts.expectLight(t, BlockXyz{source.X, testFloorY - 1, source.Z}, false, 0)
+}
+
+func TestRelight_BlockRemoved(t *testing.T) {
+	ts := newTestShards(nil)
+
+	roofY := BlockYCoord(testFloorY + 4)
+	ts.setRoof(4, 12, roofY, testBlockIdStone)
+	source := BlockXyz{8, testFloorY + 1, 8}
+	ts.setBlock(source, testBlockIdGlowstone, 0)
+
+	// Removing the light source leaves the blocks that it lit dark.
+	ts.setBlock(source, BlockIdAir, 0)
+	ts.expectLight(t, source, false, 0)
+	ts.expectLight(t, BlockXyz{source.X + 3, source.Y, source.Z}, false, 0)
+
+	// Removing the roof lets the sky back in.
+	ts.setRoof(4, 12, roofY, BlockIdAir)
+	ts.expectLight(t, BlockXyz{8, testFloorY + 1, 8}, true, 15)
+	ts.expectLight(t, BlockXyz{8, roofY, 8}, true, 15)
+
+	// As does digging into the floor.
+	hole := BlockXyz{8, testFloorY, 8}
+	ts.setBlock(hole, BlockIdAir, 0)
+	ts.expectLight(t, hole, true, 15)
+	ts.expectLight(t, BlockXyz{8, testFloorY - 1, 8}, true, 0)
+}
@@ -26,6 +26,10 @@ func (conn *localPlayerShardClient) Disconnect() {
 	conn.shard.enqueueAllChunks(func(chunk *Chunk) {
 		chunk.reqUnsubscribeChunk(conn.entityId, false)
 	})
+
+	// The request above is still pending, so the shard cannot stop before it
+	// has been performed.
+	conn.shard.removePlayerClient()
 }
 
 func (conn *localPlayerShardClient) ReqSubscribeChunk(chunkLoc ChunkXz, notify bool) {
@@ -86,11 +90,11 @@ func (conn *localPlayerShardClient) ReqInteractBlock(held gamerules.Slot, target
 	})
 }
 
-func (conn *localPlayerShardClient) ReqPlaceItem(target BlockXyz, slot gamerules.Slot) {
+func (conn *localPlayerShardClient) ReqPlaceItem(target BlockXyz, slot gamerules.Slot, againstFace Face, look LookDegrees) {
 	chunkLoc, _ := target.ToChunkLocal()
 
 	conn.shard.enqueueOnChunk(*chunkLoc, func(chunk *Chunk) {
-		chunk.reqPlaceItem(conn.player, &target, &slot)
+		chunk.reqPlaceItem(conn.player, &target, &slot, againstFace, &look)
 	})
 }
 
@@ -120,3 +124,23 @@ func (conn *localPlayerShardClient) ReqInventoryUnsubscribed(block BlockXyz) {
 		chunk.reqInventoryUnsubscribed(conn.player, &block)
 	})
 }
+
+func (conn *localPlayerShardClient) ReqSetSignText(target BlockXyz, lines [4]string) {
+	chunkLoc := target.ToChunkXz()
+	conn.shard.enqueueOnChunk(*chunkLoc, func(chunk *Chunk) {
+		chunk.reqSetSignText(conn.player, &target, lines)
+	})
+}
+
+func (conn *localPlayerShardClient) ReqAttackEntity(target EntityId, held gamerules.Slot, position AbsXyz, mayHurtPlayers bool) {
+	conn.shard.enqueue(func() {
+		conn.shard.reqAttackEntity(conn.player, target, &held, &position, mayHurtPlayers)
+	})
+}
+
+func (conn *localPlayerShardClient) ReqArriveByPortal(target BlockXyz, look LookDegrees) {
+	chunkLoc := target.ToChunkXz()
+	conn.shard.enqueueOnChunk(*chunkLoc, func(chunk *Chunk) {
+		chunk.reqArriveByPortal(conn.player, &target, &look)
+	})
+}
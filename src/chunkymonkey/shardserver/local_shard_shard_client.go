@@ -6,13 +6,17 @@ import (
 )
 
 // localShardShardClient implements IShardShardClient for LocalShardManager.
+// It finds the shard afresh for each request, as the shard might have stopped
+// since the client was made.
 type localShardShardClient struct {
-	serverShard *ChunkShard
+	mgr *LocalShardManager
+	loc ShardXz
 }
 
-func newLocalShardShardClient(serverShard *ChunkShard) *localShardShardClient {
+func newLocalShardShardClient(mgr *LocalShardManager, loc ShardXz) *localShardShardClient {
 	return &localShardShardClient{
-		serverShard: serverShard,
+		mgr: mgr,
+		loc: loc,
 	}
 }
 
@@ -20,16 +24,54 @@ func (client *localShardShardClient) Disconnect() {
 }
 
 func (client *localShardShardClient) ReqSetActiveBlocks(blocks []BlockXyz) {
-	client.serverShard.enqueue(func() {
-		client.serverShard.reqSetBlocksActive(blocks)
+	client.mgr.enqueueOnShard(client.loc, false, func(shard *ChunkShard) {
+		shard.reqSetRemoteBlocksActive(blocks)
 	})
 }
 
 func (client *localShardShardClient) ReqTransferEntity(loc ChunkXz, entity gamerules.INonPlayerEntity) {
-	client.serverShard.enqueue(func() {
-		chunk := client.serverShard.chunkAt(loc)
+	// The shard is started if need be, so that the entity is not lost.
+	client.mgr.enqueueOnShard(client.loc, true, func(shard *ChunkShard) {
+		chunk := shard.chunkAt(loc)
 		if chunk != nil {
 			chunk.transferEntity(entity)
 		}
 	})
 }
+
+func (client *localShardShardClient) ReqQueryBlocks(requester ShardXz, blocks []BlockXyz) {
+	client.mgr.enqueueOnShard(client.loc, false, func(shard *ChunkShard) {
+		shard.reqQueryBlocks(requester, blocks)
+	})
+}
+
+func (client *localShardShardClient) ReqRemoteBlocks(blocks []gamerules.BlockState) {
+	client.mgr.enqueueOnShard(client.loc, false, func(shard *ChunkShard) {
+		shard.reqRemoteBlocks(blocks)
+	})
+}
+
+func (client *localShardShardClient) ReqUpdateLight(updates []gamerules.LightUpdate) {
+	client.mgr.enqueueOnShard(client.loc, false, func(shard *ChunkShard) {
+		shard.reqUpdateLight(updates)
+	})
+}
+
+func (client *localShardShardClient) ReqSetBlocks(blocks []gamerules.BlockState, tileEntities []gamerules.ITileEntity) {
+	// The shard is started if need be, so that the blocks are not lost.
+	client.mgr.enqueueOnShard(client.loc, true, func(shard *ChunkShard) {
+		shard.reqSetBlocks(blocks, tileEntities)
+	})
+}
+
+func (client *localShardShardClient) ReqQueryChunks(requester ShardXz, chunks []ChunkXz) {
+	client.mgr.enqueueOnShard(client.loc, false, func(shard *ChunkShard) {
+		shard.reqQueryChunks(requester, chunks)
+	})
+}
+
+func (client *localShardShardClient) ReqRemoteChunks(chunks []gamerules.ChunkBlocks) {
+	client.mgr.enqueueOnShard(client.loc, false, func(shard *ChunkShard) {
+		shard.reqRemoteChunks(chunks)
+	})
+}
@@ -2,6 +2,7 @@ package shardserver
 
 import (
 	"sync"
+	"time"
 
 	"chunkymonkey/chunkstore"
 	"chunkymonkey/entity"
@@ -15,21 +16,62 @@ import (
 type LocalShardManager struct {
 	entityMgr  *entity.EntityManager
 	chunkStore chunkstore.IChunkStore
+	populator  chunkstore.IPopulator
+	dimension  DimensionId
 	shards     map[uint64]*ChunkShard
 	lock       sync.Mutex
+
+	// shardConnecter is what shards use to reach other shards.
+	shardConnecter gamerules.IShardConnecter
+
+	// The world time when the manager was created, and the wall clock time at
+	// that moment, for starting new shards at the right time of day.
+	startTime Ticks
+	startNs   int64
 }
 
-func NewLocalShardManager(chunkStore chunkstore.IChunkStore, entityMgr *entity.EntityManager) *LocalShardManager {
-	return &LocalShardManager{
+// ChunkIdleTicks is how long a chunk must have had no subscribers and no
+// active blocks before it is saved and unloaded. Zero keeps chunks loaded.
+var ChunkIdleTicks = Ticks(TicksPerSecond * 60)
+
+// NewLocalShardManager creates a manager of the shards of a dimension, whose
+// chunks are kept in chunkStore. The chunks that have not been populated are
+// populated by populator, or left as they are if it is nil.
+func NewLocalShardManager(chunkStore chunkstore.IChunkStore, populator chunkstore.IPopulator, dimension DimensionId, entityMgr *entity.EntityManager, worldTime Ticks) *LocalShardManager {
+	mgr := &LocalShardManager{
 		entityMgr:  entityMgr,
 		chunkStore: chunkStore,
+		populator:  populator,
+		dimension:  dimension,
 		shards:     make(map[uint64]*ChunkShard),
+		startTime:  worldTime,
+		startNs:    time.Nanoseconds(),
 	}
+	mgr.shardConnecter = mgr
+	return mgr
+}
+
+// SetShardConnecter changes what shards use to reach other shards, for when
+// some shards are hosted in other processes. It must be called before any
+// shards are created.
+func (mgr *LocalShardManager) SetShardConnecter(shardConnecter gamerules.IShardConnecter) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+
+	mgr.shardConnecter = shardConnecter
+}
+
+// worldTime returns the current world time, as kept by the game.
+func (mgr *LocalShardManager) worldTime() Ticks {
+	elapsedNs := time.Nanoseconds() - mgr.startNs
+	return mgr.startTime + Ticks(elapsedNs*TicksPerSecond/NanosecondsInSecond)
 }
 
+// getShard returns the running shard at loc, creating it if create is true
+// and it is not running. It must be called with mgr.lock held.
 func (mgr *LocalShardManager) getShard(loc ShardXz, create bool) *ChunkShard {
 	shardKey := loc.Key()
-	if shard, ok := mgr.shards[shardKey]; ok {
+	if shard, ok := mgr.shards[shardKey]; ok && !shard.isStopped() {
 		// Shard already exists.
 		return shard
 	}
@@ -39,52 +81,116 @@ func (mgr *LocalShardManager) getShard(loc ShardXz, create bool) *ChunkShard {
 	}
 
 	// Create shard.
-	shard := NewChunkShard(mgr, mgr.chunkStore, mgr.entityMgr, loc)
+	shard := NewChunkShard(mgr, loc)
 	mgr.shards[shardKey] = shard
 	go shard.serve()
 
 	return shard
 }
 
-func (mgr *LocalShardManager) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
+// removeShard forgets a shard that has stopped, unless it has already been
+// replaced by a new one.
+func (mgr *LocalShardManager) removeShard(shard *ChunkShard) {
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
-	shard := mgr.getShard(shardLoc, true)
-	return newLocalPlayerShardClient(entityId, player, shard)
+	shardKey := shard.loc.Key()
+	if mgr.shards[shardKey] == shard {
+		mgr.shards[shardKey] = nil, false
+	}
 }
 
-func (mgr *LocalShardManager) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+// enqueueOnShard runs a function on the shard at loc. If the shard is not
+// running, it is started if create is true, otherwise the function is not
+// run.
+func (mgr *LocalShardManager) enqueueOnShard(loc ShardXz, create bool, fn func(shard *ChunkShard)) {
+	for {
+		mgr.lock.Lock()
+		shard := mgr.getShard(loc, create)
+		mgr.lock.Unlock()
+
+		if shard == nil {
+			return
+		}
+
+		if shard.enqueue(func() { fn(shard) }) {
+			return
+		}
+
+		// The shard stopped in the meantime, try again with a new one.
+	}
+}
+
+func (mgr *LocalShardManager) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
-	shard := mgr.getShard(shardLoc, false)
+	for {
+		shard := mgr.getShard(shardLoc, true)
+		if shard.addPlayerClient() {
+			return newLocalPlayerShardClient(entityId, player, shard)
+		}
+	}
 
-	if shard == nil {
-		return nil
+	panic("unreachable")
+}
+
+// ShardShardConnect returns a client for the shard whether or not it is
+// running, as shards are started and stopped as they are needed.
+func (mgr *LocalShardManager) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+	return newLocalShardShardClient(mgr, shardLoc)
+}
+
+// Save writes the changed chunks in all shards to the chunk store, and returns
+// once they have been written.
+func (mgr *LocalShardManager) Save() {
+	if !mgr.chunkStore.SupportsWrite() {
+		return
 	}
 
-	return newLocalShardShardClient(shard)
+	shards := mgr.allShards()
+	done := make(chan bool, len(shards))
+	for _, shard := range shards {
+		shard.enqueueSave(done)
+	}
+	for _ = range shards {
+		<-done
+	}
+
+	mgr.chunkStore.Flush()
 }
 
 // TODO remove Enqueue* methods
 
 // EnqueueAllChunks runs a given function on all loaded chunks.
 func (mgr *LocalShardManager) EnqueueAllChunks(fn func(chunk *Chunk)) {
+	// The lock is not held while enqueueing, as shards that are busy may be
+	// waiting on the manager themselves.
+	for _, shard := range mgr.allShards() {
+		// Stopped shards have no chunks loaded.
+		shard.enqueueAllChunks(fn)
+	}
+}
+
+// allShards returns the shards that the manager has started, some of which may
+// have since stopped.
+func (mgr *LocalShardManager) allShards() []*ChunkShard {
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
+	shards := make([]*ChunkShard, 0, len(mgr.shards))
 	for _, shard := range mgr.shards {
-		shard.enqueueAllChunks(fn)
+		shards = append(shards, shard)
 	}
+	return shards
 }
 
 // EnqueueOnChunk runs a function on the chunk at the given location. If the
 // chunk does not exist, it does nothing.
 func (mgr *LocalShardManager) EnqueueOnChunk(loc ChunkXz, fn func(chunk *Chunk)) {
-	mgr.lock.Lock()
-	defer mgr.lock.Unlock()
-
-	shard := mgr.getShard(loc.ToShardXz(), true)
-	shard.enqueueOnChunk(loc, fn)
+	mgr.enqueueOnShard(loc.ToShardXz(), true, func(shard *ChunkShard) {
+		if chunk := shard.chunkAt(loc); chunk != nil {
+			fn(chunk)
+		}
+	})
 }
@@ -0,0 +1,39 @@
+package shardserver
+
+import (
+	"testing"
+
+	"chunkymonkey/entity"
+	. "chunkymonkey/types"
+)
+
+func TestEnqueueOnShard_StoppedShard(t *testing.T) {
+	entityMgr := new(entity.EntityManager)
+	entityMgr.Init()
+	mgr := NewLocalShardManager(newTestChunkStore(), nil, DimensionNormal, entityMgr, 0)
+
+	// A shard that stopped before the manager forgot about it.
+	loc := ShardXz{0, 0}
+	stopped := NewChunkShard(mgr, loc)
+	stopped.stopped = true
+	mgr.shards[loc.Key()] = stopped
+
+	ran := make(chan *ChunkShard)
+	mgr.enqueueOnShard(loc, false, func(shard *ChunkShard) { ran <- shard })
+	if len(mgr.shards) != 1 || mgr.shards[loc.Key()] != stopped {
+		t.Fatalf("expected no shard to be started for a request that does not create one")
+	}
+
+	mgr.enqueueOnShard(loc, true, func(shard *ChunkShard) { ran <- shard })
+	if shard := <-ran; shard == stopped {
+		t.Errorf("expected the request to run on a new shard")
+	}
+
+	// The new shard runs in its own goroutine, and so may have stopped again
+	// by now, but the stopped one must have been replaced.
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	if mgr.shards[loc.Key()] == stopped {
+		t.Errorf("expected the new shard to replace the stopped one")
+	}
+}
@@ -13,6 +13,7 @@ const (
 	// Assumed values for size of player axis-aligned bounding box (AAB).
 	playerAabH = AbsCoord(0.75) // Each side of player.
 	playerAabY = AbsCoord(2.00) // From player's feet position upwards.
+	playerEyeY = AbsCoord(1.62) // From player's feet position upwards.
 )
 
 // playerData represents a Chunk's knowledge about a player. Only one Chunk has
@@ -24,6 +25,8 @@ type playerData struct {
 	position   AbsXyz
 	look       LookBytes
 	heldItemId ItemTypeId
+	// The environment that the player was last told about.
+	environment gamerules.PlayerEnvironment
 	// TODO Armor data.
 }
 
@@ -0,0 +1,122 @@
+package shardserver
+
+import (
+	"chunkymonkey/chunkstore"
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// populateChunks runs the population pass for each loaded chunk in the shard
+// that has not been populated, once the chunks at +X, +Z and +X+Z from it are
+// loaded too. The blocks of those chunks that are in other shards are queried,
+// and the pass runs on a later call once the replies have arrived.
+func (shard *ChunkShard) populateChunks() {
+	if shard.populator == nil {
+		return
+	}
+
+	queries := make(map[uint64]*destShardChunks)
+	for _, chunk := range shard.chunks {
+		if chunk != nil && !chunk.populated {
+			shard.populateChunk(chunk, queries)
+		}
+	}
+
+	// The blocks of chunks in other shards are only used on the call after
+	// they arrive, as they may have changed since.
+	shard.remoteChunks = make(map[uint64]*gamerules.ChunkBlocks)
+
+	for _, query := range queries {
+		if client := shard.clientForShard(query.loc); client != nil {
+			client.ReqQueryChunks(shard.loc, query.chunks)
+		}
+	}
+}
+
+// populateChunk runs the population pass for the chunk if the blocks of the
+// chunks next to it are known, and applies the changes that it makes. The
+// chunks in other shards that are not known are added to queries.
+func (shard *ChunkShard) populateChunk(chunk *Chunk, queries map[uint64]*destShardChunks) {
+	var blocks, blockData [4][]byte
+	known := true
+
+	for dx := 0; dx < 2; dx++ {
+		for dz := 0; dz < 2; dz++ {
+			loc := ChunkXz{chunk.loc.X + ChunkCoord(dx), chunk.loc.Z + ChunkCoord(dz)}
+			i := dx*2 + dz
+
+			if neighbour := shard.loadedChunk(loc); neighbour != nil {
+				blocks[i], blockData[i] = neighbour.blocks, neighbour.blockData
+			} else if remote, ok := shard.remoteChunks[loc.ChunkKey()]; ok {
+				blocks[i], blockData[i] = remote.Blocks, remote.BlockData
+			} else {
+				known = false
+				if shardLoc := loc.ToShardXz(); !shardLoc.Equals(&shard.loc) {
+					addDestShardChunk(queries, &loc)
+				}
+			}
+		}
+	}
+
+	if !known {
+		return
+	}
+
+	changes := shard.populator.Populate(chunk.loc, blocks, blockData)
+	chunk.populated = true
+	chunk.storeDirty = true
+
+	for i := range changes {
+		shard.applyBlockChange(&changes[i])
+	}
+}
+
+// applyBlockChange makes a change from a population pass, which may be to a
+// block in another shard.
+func (shard *ChunkShard) applyBlockChange(change *chunkstore.BlockChange) {
+	chunkLoc, subLoc := change.Loc.ToChunkLocal()
+
+	if shardLoc := chunkLoc.ToShardXz(); !shardLoc.Equals(&shard.loc) {
+		shard.setRemoteBlock(&change.Loc, change.BlockId, change.BlockData)
+		if change.TileEntity != nil {
+			shard.setRemoteTileEntity(change.TileEntity)
+		}
+		return
+	}
+
+	chunk := shard.chunkAt(*chunkLoc)
+	index, ok := subLoc.BlockIndex()
+	if chunk == nil || !ok {
+		return
+	}
+
+	chunk.setBlock(&change.Loc, subLoc, index, change.BlockId, change.BlockData)
+	if change.TileEntity != nil {
+		chunk.addTileEntity(change.TileEntity)
+	}
+}
+
+// destShardChunks is a list of chunks within a single shard.
+type destShardChunks struct {
+	loc    ShardXz
+	chunks []ChunkXz
+}
+
+// addDestShardChunk adds a chunk to the destShardChunks for the shard that it
+// is within, unless it is already there.
+func addDestShardChunk(destShards map[uint64]*destShardChunks, loc *ChunkXz) {
+	shardXz := loc.ToShardXz()
+	shardKey := shardXz.Key()
+	destShard, ok := destShards[shardKey]
+	if !ok {
+		destShard = &destShardChunks{loc: shardXz}
+		destShards[shardKey] = destShard
+	}
+
+	for _, chunkLoc := range destShard.chunks {
+		if chunkLoc.X == loc.X && chunkLoc.Z == loc.Z {
+			return
+		}
+	}
+	destShard.chunks = append(destShard.chunks, *loc)
+}
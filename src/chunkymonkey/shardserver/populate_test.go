@@ -0,0 +1,126 @@
+package shardserver
+
+import (
+	"testing"
+
+	"chunkymonkey/chunkstore"
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// testPopulator records the chunks that it populates. Each pass places a
+// block on the floor at the far corner of the area that it is given, which is
+// within the chunk at +X+Z.
+type testPopulator struct {
+	populated []ChunkXz
+}
+
+func (p *testPopulator) Populate(loc ChunkXz, blocks, blockData [4][]byte) []chunkstore.BlockChange {
+	p.populated = append(p.populated, loc)
+
+	for i := range blocks {
+		if blocks[i] == nil || blockData[i] == nil {
+			panic("missing neighbour blocks")
+		}
+	}
+
+	return []chunkstore.BlockChange{
+		{BlockState: gamerules.BlockState{Loc: testPopulatedBlock(loc), BlockId: testBlockIdStone}},
+	}
+}
+
+// testPopulatedBlock returns the block that testPopulator places when it
+// populates the chunk at loc.
+func testPopulatedBlock(loc ChunkXz) BlockXyz {
+	return BlockXyz{
+		BlockCoord(loc.X)*ChunkSizeH + 2*ChunkSizeH - 1,
+		testFloorY + 1,
+		BlockCoord(loc.Z)*ChunkSizeH + 2*ChunkSizeH - 1,
+	}
+}
+
+// populatedOnly returns true if the populator has populated loc and nothing
+// else.
+func populatedOnly(p *testPopulator, loc ChunkXz) bool {
+	return len(p.populated) == 1 && p.populated[0].X == loc.X && p.populated[0].Z == loc.Z
+}
+
+// storeUnpopulated puts a chunk that has not been populated into the store.
+func (ts *testShards) storeUnpopulated(loc ChunkXz) {
+	chunk := newTestChunk(loc)
+	chunk.populated = false
+	ts.store.chunks[loc.ChunkKey()] = chunk
+}
+
+func TestPopulate_WaitsForNeighbours(t *testing.T) {
+	populator := new(testPopulator)
+	ts := newTestShards(populator)
+
+	loc := ChunkXz{4, 4}
+	ts.storeUnpopulated(loc)
+	ts.chunk(loc)
+	ts.chunk(ChunkXz{5, 4})
+	ts.chunk(ChunkXz{4, 5})
+	ts.tick(2 * TicksPerSecond)
+
+	if len(populator.populated) != 0 {
+		t.Fatalf("expected no population before the neighbours are loaded, got %v", populator.populated)
+	}
+
+	ts.chunk(ChunkXz{5, 5})
+	ts.tick(TicksPerSecond)
+
+	if !populatedOnly(populator, loc) {
+		t.Fatalf("expected %v to be populated once, got %v", loc, populator.populated)
+	}
+	if !ts.chunk(loc).populated {
+		t.Errorf("expected the chunk to be marked as populated")
+	}
+	ts.expectBlock(t, testPopulatedBlock(loc), testBlockIdStone, 0)
+
+	// The pass is not run again.
+	ts.tick(2 * TicksPerSecond)
+	if len(populator.populated) != 1 {
+		t.Errorf("expected the chunk to be populated once, got %v", populator.populated)
+	}
+}
+
+func TestPopulate_AcrossShards(t *testing.T) {
+	populator := new(testPopulator)
+	ts := newTestShards(populator)
+
+	// The chunk is at the corner of its shard, so its neighbours are each in
+	// a different shard.
+	loc := ChunkXz{ShardSize - 1, ShardSize - 1}
+	ts.storeUnpopulated(loc)
+	ts.chunk(loc)
+	ts.chunk(ChunkXz{ShardSize, ShardSize - 1})
+	ts.chunk(ChunkXz{ShardSize - 1, ShardSize})
+	ts.chunk(ChunkXz{ShardSize, ShardSize})
+
+	// The first pass queries the neighbouring shards, and the second uses
+	// their replies.
+	ts.tick(2 * TicksPerSecond)
+
+	if !populatedOnly(populator, loc) {
+		t.Fatalf("expected %v to be populated once, got %v", loc, populator.populated)
+	}
+	ts.expectBlock(t, testPopulatedBlock(loc), testBlockIdStone, 0)
+}
+
+func TestPopulate_SkipsPopulatedChunks(t *testing.T) {
+	populator := new(testPopulator)
+	ts := newTestShards(populator)
+
+	// Chunks in the test store are populated unless stored otherwise.
+	for x := ChunkCoord(0); x < 2; x++ {
+		for z := ChunkCoord(0); z < 2; z++ {
+			ts.chunk(ChunkXz{x, z})
+		}
+	}
+	ts.tick(2 * TicksPerSecond)
+
+	if len(populator.populated) != 0 {
+		t.Errorf("expected populated chunks to be left alone, got %v", populator.populated)
+	}
+}
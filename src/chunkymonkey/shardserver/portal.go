@@ -0,0 +1,119 @@
+package shardserver
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+const (
+	// Players arriving in a dimension go to the nearest portal within
+	// portalSearchDistance blocks horizontally.
+	portalSearchDistance = 32
+
+	// The lowest and highest blocks that the inside of a built portal can
+	// start at, leaving room for its frame and the floor beneath it.
+	portalMinY = 2
+	portalMaxY = ChunkSizeY - 5
+)
+
+// reqArriveByPortal moves a player arriving from another dimension into the
+// nearest portal to target, building one within the chunk if there is none.
+func (chunk *Chunk) reqArriveByPortal(player gamerules.IPlayerClient, target *BlockXyz, look *LookDegrees) {
+	position, ok := chunk.shard.findPortal(target, portalSearchDistance)
+	if !ok {
+		origin := chunk.portalOrigin(target)
+		position = gamerules.BuildPortal(chunk, &origin)
+	}
+
+	player.SetPositionLook(position, *look)
+}
+
+// findPortal returns the position at the bottom of the portal block in loaded
+// chunks of the shard that is nearest to target, within the given horizontal
+// distance.
+func (shard *ChunkShard) findPortal(target *BlockXyz, distance AbsCoord) (position AbsXyz, ok bool) {
+	targetPos := target.MidPointToAbsXyz()
+	nearest := distance * distance
+
+	for _, chunk := range shard.loadedChunksNear(&targetPos, distance) {
+		for i, blockId := range chunk.blocks {
+			index := BlockIndex(i)
+			if BlockId(blockId) != gamerules.BlockIdPortal {
+				continue
+			}
+
+			// Only the bottom of each column of portal blocks is wanted.
+			subLoc := index.ToSubChunkXyz()
+			if subLoc.Y > 0 && BlockId(chunk.blocks[i-1]) == gamerules.BlockIdPortal {
+				continue
+			}
+
+			blockLoc := chunk.loc.ToBlockXyz(&subLoc)
+			blockPos := AbsXyz{
+				X: AbsCoord(blockLoc.X) + 0.5,
+				Y: AbsCoord(blockLoc.Y),
+				Z: AbsCoord(blockLoc.Z) + 0.5,
+			}
+			dx, dz := blockPos.X-targetPos.X, blockPos.Z-targetPos.Z
+			if d := dx*dx + dz*dz; d <= nearest {
+				nearest = d
+				position = blockPos
+				ok = true
+			}
+		}
+	}
+
+	return
+}
+
+// portalOrigin picks where to build a portal for a player arriving near
+// target. The portal and the space around it lie within the chunk. It is
+// built on the floor beneath target if there is one.
+func (chunk *Chunk) portalOrigin(target *BlockXyz) (origin BlockXyz) {
+	corner := chunk.loc.ChunkCornerBlockXY()
+
+	// The frame reaches one block beyond the inside of the portal along the X
+	// axis, and the space cleared around it one block either side along the Z
+	// axis.
+	origin.X = clampCoord(target.X, corner.X+1, corner.X+ChunkSizeH-3)
+	origin.Z = clampCoord(target.Z, corner.Z+1, corner.Z+ChunkSizeH-2)
+
+	y := int(target.Y)
+	if y < portalMinY {
+		y = portalMinY
+	} else if y > portalMaxY {
+		y = portalMaxY
+	}
+	origin.Y = BlockYCoord(y)
+
+	// Look down for air above a solid block.
+	subLoc := SubChunkXyz{
+		X: SubChunkCoord(origin.X - corner.X),
+		Z: SubChunkCoord(origin.Z - corner.Z),
+	}
+	for ; y >= portalMinY; y-- {
+		subLoc.Y = SubChunkCoord(y)
+		index, _ := subLoc.BlockIndex()
+		if index.BlockId(chunk.blocks) != BlockIdAir {
+			continue
+		}
+
+		subLoc.Y--
+		belowIndex, _ := subLoc.BlockIndex()
+		if belowType, ok := gamerules.Blocks.Get(belowIndex.BlockId(chunk.blocks)); ok && belowType.Solid {
+			origin.Y = BlockYCoord(y)
+			break
+		}
+	}
+
+	return
+}
+
+func clampCoord(v, min, max BlockCoord) BlockCoord {
+	if v < min {
+		return min
+	} else if v > max {
+		return max
+	}
+	return v
+}
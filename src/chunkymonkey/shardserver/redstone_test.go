@@ -0,0 +1,131 @@
+package shardserver
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	testBlockIdTnt        = BlockId(46)
+	testBlockIdWire       = BlockId(55)
+	testBlockIdLever      = BlockId(69)
+	testBlockIdIronDoor   = BlockId(71)
+	testBlockIdTorchUnlit = BlockId(75)
+	testBlockIdTorchLit   = BlockId(76)
+)
+
+const (
+	// A lever standing on the floor, off and on.
+	testLeverOff = 0x5
+	testLeverOn  = 0x5 | 0x8
+	// A torch standing on the block below it.
+	testTorchStanding = 0x5
+	// The halves of a closed door, and the flag set while it is open.
+	testDoorBottom = 0x0
+	testDoorTop    = 0x8
+	testDoorOpen   = 0x4
+)
+
+// redstoneTestTicks is long enough for redstone to settle after a change.
+const redstoneTestTicks = 40
+
+func TestRedstoneWire(t *testing.T) {
+	ts := newTestShards(nil)
+
+	lever := BlockXyz{8, testFloorY + 1, 8}
+	ts.setBlock(lever, testBlockIdLever, testLeverOff)
+	for x := BlockCoord(9); x <= 12; x++ {
+		ts.setBlock(BlockXyz{x, testFloorY + 1, 8}, testBlockIdWire, 0)
+	}
+
+	// The wire is powered fully next to the lever, and one level less for
+	// each block further along.
+	ts.setBlock(lever, testBlockIdLever, testLeverOn)
+	ts.tick(redstoneTestTicks)
+	for x := BlockCoord(9); x <= 12; x++ {
+		ts.expectBlock(t, BlockXyz{x, testFloorY + 1, 8}, testBlockIdWire, byte(24-x))
+	}
+
+	// All of the wire loses its power once the lever is off.
+	ts.setBlock(lever, testBlockIdLever, testLeverOff)
+	ts.tick(redstoneTestTicks)
+	for x := BlockCoord(9); x <= 12; x++ {
+		ts.expectBlock(t, BlockXyz{x, testFloorY + 1, 8}, testBlockIdWire, 0)
+	}
+}
+
+func TestRedstoneTorch(t *testing.T) {
+	ts := newTestShards(nil)
+
+	// The torch stands on a pillar, with a lever beside the pillar.
+	pillar := BlockXyz{8, testFloorY + 1, 8}
+	torch := BlockXyz{8, testFloorY + 2, 8}
+	lever := BlockXyz{7, testFloorY + 1, 8}
+	ts.setBlock(pillar, testBlockIdStone, 0)
+	ts.setBlock(torch, testBlockIdTorchLit, testTorchStanding)
+	ts.setBlock(lever, testBlockIdLever, testLeverOff)
+	ts.tick(redstoneTestTicks)
+	ts.expectBlock(t, torch, testBlockIdTorchLit, testTorchStanding)
+
+	// The torch goes out while the block that it stands on is powered.
+	ts.setBlock(lever, testBlockIdLever, testLeverOn)
+	ts.tick(redstoneTestTicks)
+	ts.expectBlock(t, torch, testBlockIdTorchUnlit, testTorchStanding)
+
+	ts.setBlock(lever, testBlockIdLever, testLeverOff)
+	ts.tick(redstoneTestTicks)
+	ts.expectBlock(t, torch, testBlockIdTorchLit, testTorchStanding)
+}
+
+func TestDoor_Powered(t *testing.T) {
+	ts := newTestShards(nil)
+
+	bottom := BlockXyz{8, testFloorY + 1, 8}
+	top := BlockXyz{8, testFloorY + 2, 8}
+	lever := BlockXyz{9, testFloorY + 1, 8}
+	ts.setBlock(bottom, testBlockIdIronDoor, testDoorBottom)
+	ts.setBlock(top, testBlockIdIronDoor, testDoorTop)
+	ts.setBlock(lever, testBlockIdLever, testLeverOff)
+	ts.tick(redstoneTestTicks)
+
+	// Both halves open while the door is powered.
+	ts.setBlock(lever, testBlockIdLever, testLeverOn)
+	ts.tick(redstoneTestTicks)
+	ts.expectBlock(t, bottom, testBlockIdIronDoor, testDoorBottom|testDoorOpen)
+	ts.expectBlock(t, top, testBlockIdIronDoor, testDoorTop|testDoorOpen)
+
+	// And close again once it is not.
+	ts.setBlock(lever, testBlockIdLever, testLeverOff)
+	ts.tick(redstoneTestTicks)
+	ts.expectBlock(t, bottom, testBlockIdIronDoor, testDoorBottom)
+	ts.expectBlock(t, top, testBlockIdIronDoor, testDoorTop)
+}
+
+func TestTnt(t *testing.T) {
+	ts := newTestShards(nil)
+
+	tnt := BlockXyz{8, testFloorY + 1, 8}
+	lever := BlockXyz{9, testFloorY + 1, 8}
+	chunk := ts.chunk(*tnt.ToChunkXz())
+	ts.setBlock(tnt, testBlockIdTnt, 0)
+	ts.setBlock(lever, testBlockIdLever, testLeverOff)
+	ts.tick(redstoneTestTicks)
+	ts.expectBlock(t, tnt, testBlockIdTnt, 0)
+
+	// Powered TNT is primed.
+	ts.setBlock(lever, testBlockIdLever, testLeverOn)
+	ts.tick(2)
+	ts.expectBlock(t, tnt, BlockIdAir, 0)
+	if objects := objectsOf(chunk, ObjTypeIdActivatedTnt); len(objects) != 1 {
+		t.Fatalf("expected one primed TNT, got %d", len(objects))
+	}
+
+	// It explodes once its fuse has run out, destroying the floor beneath it.
+	ts.tick(5 * TicksPerSecond)
+	if objects := objectsOf(chunk, ObjTypeIdActivatedTnt); len(objects) != 0 {
+		t.Errorf("expected the primed TNT to have exploded, got %d", len(objects))
+	}
+	ts.expectBlock(t, BlockXyz{8, testFloorY, 8}, BlockIdAir, 0)
+	ts.expectBlock(t, lever, BlockIdAir, 0)
+}
@@ -3,6 +3,8 @@ package shardserver
 import (
 	"fmt"
 	"log"
+	"rand"
+	"sync"
 	"time"
 
 	"chunkymonkey/chunkstore"
@@ -13,8 +15,9 @@ import (
 
 const chunksPerShard = ShardSize * ShardSize
 
-// TODO Allow configuration of this.
-const ticksBetweenSaves = TicksPerSecond * 60
+// TicksBetweenSaves is how often shards write their changed chunks to the
+// chunk store.
+var TicksBetweenSaves = Ticks(TicksPerSecond * 60)
 
 // chunkXzToChunkIndex assumes that locDelta is offset relative to the shard
 // origin.
@@ -25,39 +28,77 @@ func chunkXzToChunkIndex(locDelta *ChunkXz) int {
 // ChunkShard represents a square shard of chunks that share a master
 // goroutine.
 type ChunkShard struct {
+	mgr              *LocalShardManager
 	shardConnecter   gamerules.IShardConnecter
 	chunkStore       chunkstore.IChunkStore
+	populator        chunkstore.IPopulator
+	dimension        DimensionId
 	entityMgr        *entity.EntityManager
 	loc              ShardXz
 	originChunkLoc   ChunkXz // The lowest X and Z located chunk in the shard.
 	chunks           [chunksPerShard]*Chunk
 	requests         chan iShardRequest
+	ticks            Ticks
 	ticksSinceUpdate Ticks
 	ticksSinceSave   Ticks
+	ticksSinceSpawn  Ticks
 	saveChunks       bool
 
-	newActiveBlocks []BlockXyz
-	newActiveShards map[uint64]*destActiveShard
+	// The time of day in the world, kept in step with the game's clock.
+	worldTime Ticks
+	rand      *rand.Rand
+
+	newActiveShards map[uint64]*destShardBlocks
+
+	remoteBlocks   map[uint64]gamerules.BlockState // Known blocks in other shards.
+	newQueryShards map[uint64]*destShardBlocks     // Blocks to query from other shards.
+	pendingQueries map[uint64]bool                 // Blocks in newQueryShards.
+
+	newLightShards map[uint64]*destShardLight  // Light spreading into other shards.
+	newSetShards   map[uint64]*destShardStates // Blocks to set in other shards.
+
+	remoteChunks map[uint64]*gamerules.ChunkBlocks // Chunks in other shards, for population.
 
 	shardClients map[uint64]gamerules.IShardShardClient
 	selfClient   shardSelfClient
+
+	// lock guards the following fields, which are used to stop the shard once
+	// nothing needs it.
+	lock          sync.Mutex
+	stopped       bool
+	pending       int // Requests that have been enqueued but not yet received.
+	playerClients int // Connected localPlayerShardClients.
 }
 
-func NewChunkShard(shardConnecter gamerules.IShardConnecter, chunkStore chunkstore.IChunkStore, entityMgr *entity.EntityManager, loc ShardXz) (shard *ChunkShard) {
+func NewChunkShard(mgr *LocalShardManager, loc ShardXz) (shard *ChunkShard) {
 	shard = &ChunkShard{
-		shardConnecter:   shardConnecter,
-		chunkStore:       chunkStore,
-		entityMgr:        entityMgr,
+		mgr:              mgr,
+		shardConnecter:   mgr.shardConnecter,
+		chunkStore:       mgr.chunkStore,
+		populator:        mgr.populator,
+		dimension:        mgr.dimension,
+		entityMgr:        mgr.entityMgr,
 		loc:              loc,
 		originChunkLoc:   loc.ToChunkXz(),
 		requests:         make(chan iShardRequest, 256),
 		ticksSinceUpdate: 0,
-		saveChunks:       chunkStore.SupportsWrite(),
+		saveChunks:       mgr.chunkStore.SupportsWrite(),
+		worldTime:        mgr.worldTime(),
+		rand:             rand.New(rand.NewSource(time.UTC().Seconds())),
 
 		// Offset shard saves.
-		ticksSinceSave: (31 * Ticks(loc.Key())) % ticksBetweenSaves,
+		ticksSinceSave: (31 * Ticks(loc.Key())) % TicksBetweenSaves,
+
+		newActiveShards: make(map[uint64]*destShardBlocks),
+
+		remoteBlocks:   make(map[uint64]gamerules.BlockState),
+		newQueryShards: make(map[uint64]*destShardBlocks),
+		pendingQueries: make(map[uint64]bool),
 
-		newActiveShards: make(map[uint64]*destActiveShard),
+		newLightShards: make(map[uint64]*destShardLight),
+		newSetShards:   make(map[uint64]*destShardStates),
+
+		remoteChunks: make(map[uint64]*gamerules.ChunkBlocks),
 
 		shardClients: make(map[uint64]gamerules.IShardShardClient),
 	}
@@ -67,23 +108,86 @@ func NewChunkShard(shardConnecter gamerules.IShardConnecter, chunkStore chunksto
 	return
 }
 
-// serve services shard requests in the foreground.
+// serve services shard requests in the foreground, until the shard stops.
 func (shard *ChunkShard) serve() {
 	ticker := time.NewTicker(NanosecondsInSecond / TicksPerSecond)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			shard.tick()
+			if shard.ticksSinceUpdate == 0 && shard.stopIfUnused() {
+				log.Printf("%s: Stopped.", shard)
+				shard.mgr.removeShard(shard)
+				return
+			}
 
 		case request := <-shard.requests:
+			shard.lock.Lock()
+			shard.pending--
+			shard.lock.Unlock()
+
 			request.perform(shard)
 		}
 	}
 }
 
+// stopIfUnused stops the shard if it has no chunks loaded, no players
+// connected and no requests waiting. It returns true if the shard stopped.
+func (shard *ChunkShard) stopIfUnused() bool {
+	for _, chunk := range shard.chunks {
+		if chunk != nil {
+			return false
+		}
+	}
+
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	if shard.pending > 0 || shard.playerClients > 0 {
+		return false
+	}
+
+	shard.stopped = true
+
+	return true
+}
+
+func (shard *ChunkShard) isStopped() bool {
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	return shard.stopped
+}
+
+// addPlayerClient records that a player has connected to the shard. It
+// returns false if the shard has stopped.
+func (shard *ChunkShard) addPlayerClient() bool {
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	if shard.stopped {
+		return false
+	}
+
+	shard.playerClients++
+
+	return true
+}
+
+// removePlayerClient records that a player has disconnected from the shard.
+func (shard *ChunkShard) removePlayerClient() {
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	shard.playerClients--
+}
+
 // tick runs the shard for a single tick.
 func (shard *ChunkShard) tick() {
+	shard.ticks++
+	shard.worldTime++
 	shard.ticksSinceUpdate++
 
 	for _, chunk := range shard.chunks {
@@ -98,29 +202,97 @@ func (shard *ChunkShard) tick() {
 				chunk.sendUpdate()
 			}
 		}
+		shard.populateChunks()
+		shard.unloadIdleChunks(shard.ticksSinceUpdate)
 		shard.ticksSinceUpdate = 0
 	}
 
+	if spawning := gamerules.Spawning(); spawning != nil {
+		shard.ticksSinceSpawn++
+		if shard.ticksSinceSpawn >= spawning.AttemptTicks {
+			shard.spawnMobs(spawning)
+			shard.ticksSinceSpawn = 0
+		}
+	}
+
 	if shard.saveChunks && shard.chunkStore.SupportsWrite() {
 		shard.ticksSinceSave++
-		if shard.ticksSinceSave > ticksBetweenSaves {
+		if shard.ticksSinceSave > TicksBetweenSaves {
 			log.Printf("%s: Writing chunks.", shard)
 			// TODO Stagger the per-chunk saves over multiple ticks.
-			for _, chunk := range shard.chunks {
-				if chunk != nil {
-					chunk.save(shard.chunkStore)
-				}
-			}
+			shard.saveAllChunks()
 			shard.ticksSinceSave = 0
 		}
 	}
 
 	shard.transferActiveBlocks()
+	shard.transferBlockQueries()
+	shard.transferLightUpdates()
+	shard.transferBlockSets()
+}
+
+// saveAllChunks writes all changed chunks in the shard to the chunk store.
+func (shard *ChunkShard) saveAllChunks() {
+	for _, chunk := range shard.chunks {
+		if chunk != nil {
+			chunk.save(shard.chunkStore)
+		}
+	}
+}
+
+// unloadIdleChunks saves and unloads the chunks that have been idle for
+// ChunkIdleTicks. elapsed is the number of ticks since it was last called.
+// Chunks are only unloaded if they can be saved, as changes to them would
+// otherwise be lost.
+func (shard *ChunkShard) unloadIdleChunks(elapsed Ticks) {
+	if ChunkIdleTicks <= 0 || !shard.saveChunks || !shard.chunkStore.SupportsWrite() {
+		return
+	}
+
+	for index, chunk := range shard.chunks {
+		if chunk == nil {
+			continue
+		}
+
+		if !chunk.isIdle() {
+			chunk.idleTicks = 0
+			continue
+		}
+
+		chunk.idleTicks += elapsed
+		if chunk.idleTicks >= ChunkIdleTicks {
+			chunk.unload(shard.chunkStore)
+			shard.chunks[index] = nil
+			shard.forgetRemoteBlocksNear(&chunk.loc)
+		}
+	}
+}
+
+// forgetRemoteBlocksNear forgets the known blocks in other shards that are
+// next to the chunk, once it has been unloaded. Nothing else in the chunk needs
+// them, and they would otherwise be kept for as long as the shard runs.
+func (shard *ChunkShard) forgetRemoteBlocksNear(loc *ChunkXz) {
+	minX := BlockCoord(loc.X)*ChunkSizeH - 1
+	minZ := BlockCoord(loc.Z)*ChunkSizeH - 1
+	maxX := minX + ChunkSizeH + 1
+	maxZ := minZ + ChunkSizeH + 1
+
+	for key, state := range shard.remoteBlocks {
+		if state.Loc.X >= minX && state.Loc.X <= maxX && state.Loc.Z >= minZ && state.Loc.Z <= maxZ {
+			shard.remoteBlocks[key] = gamerules.BlockState{}, false
+		}
+	}
+}
+
+// iLostShardClient is implemented by an IShardShardClient whose connection to
+// its shard can be lost, such as one to a shard on another server.
+type iLostShardClient interface {
+	Lost() bool
 }
 
 // clientForShard is used to get a IShardShardClient for a given shard, reusing
-// IShardShardClient connections for use within the shard. Returns nil if the
-// shard does not exist.
+// IShardShardClient connections for use within the shard. A connection that
+// has been lost is replaced. Returns nil if the shard cannot be reached.
 func (shard *ChunkShard) clientForShard(shardLoc ShardXz) (client gamerules.IShardShardClient) {
 	var ok bool
 
@@ -130,7 +302,14 @@ func (shard *ChunkShard) clientForShard(shardLoc ShardXz) (client gamerules.ISha
 
 	shardKey := shardLoc.Key()
 
-	if client, ok = shard.shardClients[shardKey]; !ok {
+	if client, ok = shard.shardClients[shardKey]; ok {
+		if lost, isLost := client.(iLostShardClient); isLost && lost.Lost() {
+			shard.shardClients[shardKey] = nil, false
+			ok = false
+		}
+	}
+
+	if !ok {
 		client = shard.shardConnecter.ShardShardConnect(shardLoc)
 		if client != nil {
 			shard.shardClients[shardKey] = client
@@ -144,18 +323,12 @@ func (shard *ChunkShard) clientForShard(shardLoc ShardXz) (client gamerules.ISha
 // location. known=true if the returned blockTypeId is valid.
 func (shard *ChunkShard) blockQuery(chunkLoc ChunkXz, subLoc *SubChunkXyz) (blockTypeId BlockId, known bool) {
 
-	chunkIndex, _, _, ok := shard.chunkIndexAndRelLoc(chunkLoc)
-
-	if !ok {
-		// blockLoc is in another shard.
-		// TODO Have a good fast case to deal with this.
-		return
-	}
-
-	chunk := shard.chunks[chunkIndex]
+	chunk := shard.loadedChunk(chunkLoc)
 
 	if chunk == nil {
-		// Chunk not loaded. Don't bother to load it just for a block query.
+		// Chunk is in another shard or is not loaded. Don't bother to load it
+		// just for a block query.
+		// TODO Have a good fast case to deal with blocks in another shard.
 		return
 	}
 
@@ -169,7 +342,7 @@ func (shard *ChunkShard) blockQuery(chunkLoc ChunkXz, subLoc *SubChunkXyz) (bloc
 // transferActiveBlocks takes blocks marked as newly active by addActiveBlock,
 // and informs the chunk in the destination shards.
 func (shard *ChunkShard) transferActiveBlocks() {
-	if len(shard.newActiveBlocks) == 0 {
+	if len(shard.newActiveShards) == 0 {
 		return
 	}
 
@@ -182,6 +355,7 @@ func (shard *ChunkShard) transferActiveBlocks() {
 				client.ReqSetActiveBlocks(activeShard.blocks)
 			}
 		}
+		shard.newActiveShards[shardKey] = nil, false
 	}
 }
 
@@ -201,23 +375,257 @@ func (shard *ChunkShard) reqSetBlocksActive(blocks []BlockXyz) {
 	}
 }
 
+// reqSetRemoteBlocksActive is used when another shard sets blocks active,
+// which it does when blocks next to them have changed. Any known blocks in
+// other shards next to them are forgotten, so that they are queried again.
+func (shard *ChunkShard) reqSetRemoteBlocksActive(blocks []BlockXyz) {
+	if len(shard.remoteBlocks) > 0 {
+		for _, block := range blocks {
+			for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+				dx, dy, dz := face.Dxyz()
+				if neighbour := block.AddXyz(dx, dy, dz); neighbour != nil {
+					shard.remoteBlocks[neighbour.Key()] = gamerules.BlockState{}, false
+				}
+			}
+		}
+	}
+
+	shard.reqSetBlocksActive(blocks)
+}
+
 // addActiveBlock sets the given block to be active on the next tick. This
 // works even if the block is not within the shard - it will be made active
 // provided that the chunk that the block is within is loaded.
 func (shard *ChunkShard) addActiveBlock(block *BlockXyz) {
-	chunkXz := block.ToChunkXz()
-	shardXz := chunkXz.ToShardXz()
-	shardKey := shardXz.Key()
-	activeShard, ok := shard.newActiveShards[shardKey]
-	if ok {
-		activeShard = &destActiveShard{
-			loc:    shardXz,
-			blocks: []BlockXyz{*block},
+	addDestShardBlock(shard.newActiveShards, block)
+}
+
+// remoteBlockAt returns the type and data of a block in another shard, if it
+// is known. If it is not known, it is queried from the other shard at the end
+// of the tick.
+func (shard *ChunkShard) remoteBlockAt(block *BlockXyz) (blockType *gamerules.BlockType, blockData byte, ok bool) {
+	key := block.Key()
+
+	if state, known := shard.remoteBlocks[key]; known {
+		blockType, ok = gamerules.Blocks.Get(state.BlockId)
+		blockData = state.BlockData
+		return
+	}
+
+	if !shard.pendingQueries[key] {
+		shard.pendingQueries[key] = true
+		addDestShardBlock(shard.newQueryShards, block)
+	}
+
+	return
+}
+
+// transferBlockQueries sends the queries made by remoteBlockAt to the shards
+// that the blocks are within.
+func (shard *ChunkShard) transferBlockQueries() {
+	if len(shard.newQueryShards) == 0 {
+		return
+	}
+
+	for shardKey, queryShard := range shard.newQueryShards {
+		if client := shard.clientForShard(queryShard.loc); client != nil {
+			client.ReqQueryBlocks(shard.loc, queryShard.blocks)
 		}
-		shard.newActiveShards[shardKey] = activeShard
-	} else {
-		activeShard.blocks = append(activeShard.blocks, *block)
+		shard.newQueryShards[shardKey] = nil, false
 	}
+
+	shard.pendingQueries = make(map[uint64]bool)
+}
+
+// reqQueryBlocks replies to the requesting shard with the state of those of
+// the given blocks that are within loaded chunks.
+func (shard *ChunkShard) reqQueryBlocks(requester ShardXz, blocks []BlockXyz) {
+	states := make([]gamerules.BlockState, 0, len(blocks))
+
+	for _, block := range blocks {
+		chunkLoc, subLoc := block.ToChunkLocal()
+		chunk := shard.loadedChunk(*chunkLoc)
+		if chunk == nil {
+			continue
+		}
+		index, ok := subLoc.BlockIndex()
+		if !ok {
+			continue
+		}
+		states = append(states, gamerules.BlockState{
+			Loc:       block,
+			BlockId:   index.BlockId(chunk.blocks),
+			BlockData: index.BlockData(chunk.blockData),
+		})
+	}
+
+	if len(states) > 0 {
+		if client := shard.clientForShard(requester); client != nil {
+			client.ReqRemoteBlocks(states)
+		}
+	}
+}
+
+// reqRemoteBlocks records the state of blocks in other shards, and makes the
+// blocks next to them within this shard active so that they can make use of
+// it.
+func (shard *ChunkShard) reqRemoteBlocks(states []gamerules.BlockState) {
+	activate := make([]BlockXyz, 0, len(states))
+
+	for _, state := range states {
+		shard.remoteBlocks[state.Loc.Key()] = state
+
+		for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+			dx, dy, dz := face.Dxyz()
+			if neighbour := state.Loc.AddXyz(dx, dy, dz); neighbour != nil {
+				activate = append(activate, *neighbour)
+			}
+		}
+	}
+
+	shard.reqSetBlocksActive(activate)
+}
+
+// setRemoteBlock sets a block in another shard. The change is sent to that
+// shard at the end of the tick, and is taken to have been made in the
+// meantime.
+func (shard *ChunkShard) setRemoteBlock(block *BlockXyz, blockId BlockId, blockData byte) {
+	state := gamerules.BlockState{
+		Loc:       *block,
+		BlockId:   blockId,
+		BlockData: blockData,
+	}
+	shard.remoteBlocks[block.Key()] = state
+
+	destShard := shard.destShardStates(block)
+	destShard.blocks = append(destShard.blocks, state)
+}
+
+// setRemoteTileEntity gives a block in another shard a tile entity, after any
+// blocks set in that shard by setRemoteBlock.
+func (shard *ChunkShard) setRemoteTileEntity(tileEntity gamerules.ITileEntity) {
+	block := tileEntity.Block()
+	destShard := shard.destShardStates(&block)
+	destShard.tileEntities = append(destShard.tileEntities, tileEntity)
+}
+
+// destShardStates returns the blocks to be set in the shard that the given
+// block is within.
+func (shard *ChunkShard) destShardStates(block *BlockXyz) *destShardStates {
+	shardLoc := block.ToChunkXz().ToShardXz()
+	shardKey := shardLoc.Key()
+	destShard, ok := shard.newSetShards[shardKey]
+	if !ok {
+		destShard = &destShardStates{loc: shardLoc}
+		shard.newSetShards[shardKey] = destShard
+	}
+	return destShard
+}
+
+// transferBlockSets sends the blocks set by setRemoteBlock to the shards that
+// they are within.
+func (shard *ChunkShard) transferBlockSets() {
+	if len(shard.newSetShards) == 0 {
+		return
+	}
+
+	for shardKey, destShard := range shard.newSetShards {
+		if client := shard.clientForShard(destShard.loc); client != nil {
+			client.ReqSetBlocks(destShard.blocks, destShard.tileEntities)
+		}
+		shard.newSetShards[shardKey] = nil, false
+	}
+}
+
+// reqSetBlocks sets blocks within the shard on behalf of another shard, and
+// then adds the tile entities to them, loading the chunks that they are
+// within if need be.
+func (shard *ChunkShard) reqSetBlocks(states []gamerules.BlockState, tileEntities []gamerules.ITileEntity) {
+	for i := range states {
+		state := &states[i]
+		chunkLoc, subLoc := state.Loc.ToChunkLocal()
+		chunk := shard.chunkAt(*chunkLoc)
+		if chunk == nil {
+			continue
+		}
+		if index, ok := subLoc.BlockIndex(); ok {
+			chunk.setBlock(&state.Loc, subLoc, index, state.BlockId, state.BlockData)
+		}
+	}
+
+	for _, tileEntity := range tileEntities {
+		block := tileEntity.Block()
+		if chunk := shard.chunkAt(*block.ToChunkXz()); chunk != nil {
+			chunk.addTileEntity(tileEntity)
+			chunk.storeDirty = true
+		}
+	}
+}
+
+// reqQueryChunks replies to the requesting shard with the blocks of those of
+// the given chunks that are loaded.
+func (shard *ChunkShard) reqQueryChunks(requester ShardXz, locs []ChunkXz) {
+	chunks := make([]gamerules.ChunkBlocks, 0, len(locs))
+
+	for _, loc := range locs {
+		chunk := shard.loadedChunk(loc)
+		if chunk == nil {
+			continue
+		}
+		// The blocks are copied, as the chunk goes on changing while the
+		// requesting shard uses them.
+		chunks = append(chunks, gamerules.ChunkBlocks{
+			Loc:       loc,
+			Blocks:    cloneBytes(chunk.blocks),
+			BlockData: cloneBytes(chunk.blockData),
+		})
+	}
+
+	if len(chunks) > 0 {
+		if client := shard.clientForShard(requester); client != nil {
+			client.ReqRemoteChunks(chunks)
+		}
+	}
+}
+
+// reqRemoteChunks records the blocks of chunks in other shards, for the next
+// call to populateChunks.
+func (shard *ChunkShard) reqRemoteChunks(chunks []gamerules.ChunkBlocks) {
+	for i := range chunks {
+		shard.remoteChunks[chunks[i].Loc.ChunkKey()] = &chunks[i]
+	}
+}
+
+// reqAttackEntity attacks the entity with the given ID, if it is in a loaded
+// chunk in the shard that is within reach of the attacker.
+func (shard *ChunkShard) reqAttackEntity(attacker gamerules.IPlayerClient, target EntityId, held *gamerules.Slot, attackerPos *AbsXyz, mayHurtPlayers bool) {
+	for _, chunk := range shard.loadedChunksNear(attackerPos, MaxInteractDistance) {
+		if chunk.reqAttackEntity(attacker, target, held, attackerPos, mayHurtPlayers) {
+			return
+		}
+	}
+}
+
+// loadedChunksNear returns the loaded chunks in the shard that contain any
+// part of the area within the given horizontal distance of position.
+func (shard *ChunkShard) loadedChunksNear(position *AbsXyz, distance AbsCoord) (chunks []*Chunk) {
+	corner := *position
+	corner.X -= distance
+	corner.Z -= distance
+	minLoc := corner.ToChunkXz()
+	corner.X += 2 * distance
+	corner.Z += 2 * distance
+	maxLoc := corner.ToChunkXz()
+
+	for x := minLoc.X; x <= maxLoc.X; x++ {
+		for z := minLoc.Z; z <= maxLoc.Z; z++ {
+			if chunk := shard.loadedChunk(ChunkXz{x, z}); chunk != nil {
+				chunks = append(chunks, chunk)
+			}
+		}
+	}
+
+	return
 }
 
 func (shard *ChunkShard) String() string {
@@ -239,6 +647,17 @@ func (shard *ChunkShard) chunkIndexAndRelLoc(loc ChunkXz) (index int, x, z Chunk
 	return
 }
 
+// loadedChunk returns the Chunk at the given coordinates if it is within the
+// shard and already loaded, otherwise nil.
+func (shard *ChunkShard) loadedChunk(loc ChunkXz) *Chunk {
+	chunkIndex, _, _, ok := shard.chunkIndexAndRelLoc(loc)
+	if !ok {
+		return nil
+	}
+
+	return shard.chunks[chunkIndex]
+}
+
 // Get returns the Chunk at at given coordinates, loading it if it is not
 // already loaded.
 func (shard *ChunkShard) chunkAt(loc ChunkXz) *Chunk {
@@ -291,28 +710,86 @@ func (shard *ChunkShard) loadChunk(loc ChunkXz, locDelta ChunkXz) *Chunk {
 
 // enqueueAllChunks runs a given function on all loaded chunks in the shard.
 func (shard *ChunkShard) enqueueAllChunks(fn func(chunk *Chunk)) {
-	shard.requests <- &runOnAllChunks{fn}
+	shard.enqueueRequest(&runOnAllChunks{fn})
 }
 
 // enqueueOnChunk runs a function on the chunk at the given location. If the
 // chunk does not exist, it does nothing.
 func (shard *ChunkShard) enqueueOnChunk(loc ChunkXz, fn func(chunk *Chunk)) {
-	shard.requests <- &runOnChunk{loc, fn}
+	shard.enqueueRequest(&runOnChunk{loc, fn})
 }
 
-func (shard *ChunkShard) enqueue(fn func()) {
-	shard.requests <- &runGeneric{fn}
+// enqueueSave saves all chunks in the shard, and then sends to done. A shard
+// that has stopped has nothing to save, so it sends to done straight away.
+func (shard *ChunkShard) enqueueSave(done chan<- bool) {
+	ok := shard.enqueue(func() {
+		shard.saveAllChunks()
+		done <- true
+	})
+	if !ok {
+		done <- true
+	}
 }
 
-func (shard *ChunkShard) enqueueRequest(req iShardRequest) {
+func (shard *ChunkShard) enqueue(fn func()) bool {
+	return shard.enqueueRequest(&runGeneric{fn})
+}
+
+// enqueueRequest passes a request to the shard's goroutine. It returns false if
+// the shard has stopped, in which case the request will never be performed.
+func (shard *ChunkShard) enqueueRequest(req iShardRequest) bool {
+	shard.lock.Lock()
+	if shard.stopped {
+		shard.lock.Unlock()
+		return false
+	}
+	shard.pending++
+	shard.lock.Unlock()
+
 	shard.requests <- req
+
+	return true
 }
 
-type destActiveShard struct {
+// destShardBlocks is a list of blocks within a single shard, used to batch up
+// requests about blocks to other shards.
+type destShardBlocks struct {
 	loc    ShardXz
 	blocks []BlockXyz
 }
 
+// addDestShardBlock adds a block to the destShardBlocks for the shard that it
+// is within.
+func addDestShardBlock(destShards map[uint64]*destShardBlocks, block *BlockXyz) {
+	chunkXz := block.ToChunkXz()
+	shardXz := chunkXz.ToShardXz()
+	shardKey := shardXz.Key()
+	destShard, ok := destShards[shardKey]
+	if !ok {
+		destShard = &destShardBlocks{
+			loc:    shardXz,
+			blocks: []BlockXyz{*block},
+		}
+		destShards[shardKey] = destShard
+	} else {
+		destShard.blocks = append(destShard.blocks, *block)
+	}
+}
+
+// destShardStates is a list of the states of blocks within a single shard,
+// and of the tile entities to give them.
+type destShardStates struct {
+	loc          ShardXz
+	blocks       []gamerules.BlockState
+	tileEntities []gamerules.ITileEntity
+}
+
+func cloneBytes(in []byte) []byte {
+	out := make([]byte, len(in))
+	copy(out, in)
+	return out
+}
+
 // shardSelfClient implements IShardShardClient for a shard to efficiently talk
 // to itself.
 type shardSelfClient struct {
@@ -332,3 +809,27 @@ func (client *shardSelfClient) ReqTransferEntity(loc ChunkXz, entity gamerules.I
 		chunk.transferEntity(entity)
 	}
 }
+
+func (client *shardSelfClient) ReqQueryBlocks(requester ShardXz, blocks []BlockXyz) {
+	client.shard.reqQueryBlocks(requester, blocks)
+}
+
+func (client *shardSelfClient) ReqRemoteBlocks(blocks []gamerules.BlockState) {
+	client.shard.reqRemoteBlocks(blocks)
+}
+
+func (client *shardSelfClient) ReqUpdateLight(updates []gamerules.LightUpdate) {
+	client.shard.reqUpdateLight(updates)
+}
+
+func (client *shardSelfClient) ReqSetBlocks(blocks []gamerules.BlockState, tileEntities []gamerules.ITileEntity) {
+	client.shard.reqSetBlocks(blocks, tileEntities)
+}
+
+func (client *shardSelfClient) ReqQueryChunks(requester ShardXz, chunks []ChunkXz) {
+	client.shard.reqQueryChunks(requester, chunks)
+}
+
+func (client *shardSelfClient) ReqRemoteChunks(chunks []gamerules.ChunkBlocks) {
+	client.shard.reqRemoteChunks(chunks)
+}
@@ -0,0 +1,361 @@
+package shardserver
+
+import (
+	"testing"
+
+	"chunkymonkey/chunkstore"
+	"chunkymonkey/entity"
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+func init() {
+	if err := gamerules.LoadGameRules("blocks.json", "items.json", "recipes.json", "furnace.json", "spawning.json", "users.json", "groups.json", "regions.json"); err != nil {
+		panic(err)
+	}
+}
+
+const (
+	testBlockIdStone = BlockId(1)
+	testBlockIdWater = BlockId(8)
+	testBlockIdStill = BlockId(9)
+)
+
+// testFloorY is the height of the top of the stone floor in test chunks.
+// Everything above it is air.
+const testFloorY = 63
+
+// testChunk holds a chunk in memory. It implements chunkstore.IChunkReader and
+// chunkstore.IChunkWriter.
+type testChunk struct {
+	loc          ChunkXz
+	blocks       []byte
+	blockData    []byte
+	blockLight   []byte
+	skyLight     []byte
+	heightMap    []byte
+	populated    bool
+	entities     []gamerules.INonPlayerEntity
+	tileEntities []gamerules.ITileEntity
+}
+
+// newTestChunk returns a chunk with a stone floor up to testFloorY, and sky
+// light above it.
+func newTestChunk(loc ChunkXz) *testChunk {
+	chunk := &testChunk{
+		loc:        loc,
+		blocks:     make([]byte, ChunkSizeH*ChunkSizeH*ChunkSizeY),
+		blockData:  make([]byte, (ChunkSizeH*ChunkSizeH*ChunkSizeY)>>1),
+		blockLight: make([]byte, (ChunkSizeH*ChunkSizeH*ChunkSizeY)>>1),
+		skyLight:   make([]byte, (ChunkSizeH*ChunkSizeH*ChunkSizeY)>>1),
+		heightMap:  make([]byte, ChunkSizeH*ChunkSizeH),
+		populated:  true,
+	}
+
+	for x := 0; x < ChunkSizeH; x++ {
+		for z := 0; z < ChunkSizeH; z++ {
+			for y := 0; y < ChunkSizeY; y++ {
+				subLoc := SubChunkXyz{SubChunkCoord(x), SubChunkCoord(y), SubChunkCoord(z)}
+				index, _ := subLoc.BlockIndex()
+				if y <= testFloorY {
+					index.SetBlockId(chunk.blocks, testBlockIdStone)
+				} else {
+					index.SetBlockData(chunk.skyLight, 15)
+				}
+			}
+			chunk.heightMap[heightMapIndex(&SubChunkXyz{SubChunkCoord(x), 0, SubChunkCoord(z)})] = testFloorY + 1
+		}
+	}
+
+	return chunk
+}
+
+// clone returns a copy of the chunk, so that changes to a loaded chunk only
+// reach the store when it is written.
+func (c *testChunk) clone() *testChunk {
+	return &testChunk{
+		loc:          c.loc,
+		blocks:       cloneBytes(c.blocks),
+		blockData:    cloneBytes(c.blockData),
+		blockLight:   cloneBytes(c.blockLight),
+		skyLight:     cloneBytes(c.skyLight),
+		heightMap:    cloneBytes(c.heightMap),
+		populated:    c.populated,
+		entities:     c.entities,
+		tileEntities: c.tileEntities,
+	}
+}
+
+func (c *testChunk) ChunkLoc() ChunkXz                             { return c.loc }
+func (c *testChunk) Blocks() []byte                                { return c.blocks }
+func (c *testChunk) BlockData() []byte                             { return c.blockData }
+func (c *testChunk) BlockLight() []byte                            { return c.blockLight }
+func (c *testChunk) SkyLight() []byte                              { return c.skyLight }
+func (c *testChunk) HeightMap() []byte                             { return c.heightMap }
+func (c *testChunk) TerrainPopulated() bool                        { return c.populated }
+func (c *testChunk) Entities() []gamerules.INonPlayerEntity        { return c.entities }
+func (c *testChunk) TileEntities() []gamerules.ITileEntity         { return c.tileEntities }
+func (c *testChunk) RootTag() nbt.ITag                             { return nil }
+func (c *testChunk) SetChunkLoc(loc ChunkXz)                       { c.loc = loc }
+func (c *testChunk) SetBlocks(blocks []byte)                       { c.blocks = cloneBytes(blocks) }
+func (c *testChunk) SetBlockData(blockData []byte)                 { c.blockData = cloneBytes(blockData) }
+func (c *testChunk) SetBlockLight(blockLight []byte)               { c.blockLight = cloneBytes(blockLight) }
+func (c *testChunk) SetSkyLight(skyLight []byte)                   { c.skyLight = cloneBytes(skyLight) }
+func (c *testChunk) SetHeightMap(heightMap []byte)                 { c.heightMap = cloneBytes(heightMap) }
+func (c *testChunk) SetTerrainPopulated(populated bool)            { c.populated = populated }
+func (c *testChunk) SetTileEntities(tiles []gamerules.ITileEntity) { c.tileEntities = tiles }
+
+func (c *testChunk) SetEntities(entities map[EntityId]gamerules.INonPlayerEntity) {
+	c.entities = nil
+	for _, entity := range entities {
+		c.entities = append(c.entities, entity)
+	}
+}
+
+// testChunkStore keeps chunks in memory. It implements chunkstore.IChunkStore.
+// Chunks that have not been written read as newTestChunk.
+type testChunkStore struct {
+	chunks map[uint64]*testChunk
+	writes int
+}
+
+func newTestChunkStore() *testChunkStore {
+	return &testChunkStore{
+		chunks: make(map[uint64]*testChunk),
+	}
+}
+
+func (store *testChunkStore) Serve() {
+}
+
+func (store *testChunkStore) ReadChunk(loc ChunkXz) <-chan chunkstore.ChunkReadResult {
+	result := make(chan chunkstore.ChunkReadResult, 1)
+
+	chunk, ok := store.chunks[loc.ChunkKey()]
+	if !ok {
+		chunk = newTestChunk(loc)
+	}
+	result <- chunkstore.ChunkReadResult{chunk.clone(), nil}
+
+	return result
+}
+
+func (store *testChunkStore) SupportsWrite() bool {
+	return true
+}
+
+func (store *testChunkStore) Writer() chunkstore.IChunkWriter {
+	return &testChunk{}
+}
+
+func (store *testChunkStore) WriteChunk(writer chunkstore.IChunkWriter) {
+	chunk := writer.(*testChunk)
+	store.chunks[chunk.loc.ChunkKey()] = chunk
+	store.writes++
+}
+
+func (store *testChunkStore) Flush() {
+}
+
+// testShards runs shards in the test's goroutine rather than their own, so
+// that tests can tick them in step. It implements gamerules.IShardConnecter.
+type testShards struct {
+	mgr    *LocalShardManager
+	store  *testChunkStore
+	shards map[uint64]*ChunkShard
+}
+
+// newTestShards creates a testShards whose chunks are populated by populator,
+// or left as they are if it is nil.
+func newTestShards(populator chunkstore.IPopulator) *testShards {
+	ts := &testShards{
+		store:  newTestChunkStore(),
+		shards: make(map[uint64]*ChunkShard),
+	}
+
+	entityMgr := new(entity.EntityManager)
+	entityMgr.Init()
+	ts.mgr = NewLocalShardManager(ts.store, populator, DimensionNormal, entityMgr, 0)
+	ts.mgr.SetShardConnecter(ts)
+
+	return ts
+}
+
+// shard returns the shard at loc, creating it if need be.
+func (ts *testShards) shard(loc ShardXz) *ChunkShard {
+	shard, ok := ts.shards[loc.Key()]
+	if !ok {
+		shard = NewChunkShard(ts.mgr, loc)
+		ts.shards[loc.Key()] = shard
+	}
+	return shard
+}
+
+func (ts *testShards) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
+	return nil
+}
+
+func (ts *testShards) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+	return &testShardClient{ts, shardLoc}
+}
+
+// testShardClient makes requests upon a shard in testShards straight away,
+// as localShardShardClient would on the shard's own goroutine.
+type testShardClient struct {
+	ts  *testShards
+	loc ShardXz
+}
+
+func (client *testShardClient) Disconnect() {
+}
+
+func (client *testShardClient) ReqSetActiveBlocks(blocks []BlockXyz) {
+	client.ts.shard(client.loc).reqSetRemoteBlocksActive(blocks)
+}
+
+func (client *testShardClient) ReqTransferEntity(loc ChunkXz, entity gamerules.INonPlayerEntity) {
+	if chunk := client.ts.chunk(loc); chunk != nil {
+		chunk.transferEntity(entity)
+	}
+}
+
+func (client *testShardClient) ReqQueryBlocks(requester ShardXz, blocks []BlockXyz) {
+	client.ts.shard(client.loc).reqQueryBlocks(requester, blocks)
+}
+
+func (client *testShardClient) ReqRemoteBlocks(blocks []gamerules.BlockState) {
+	client.ts.shard(client.loc).reqRemoteBlocks(blocks)
+}
+
+func (client *testShardClient) ReqUpdateLight(updates []gamerules.LightUpdate) {
+	client.ts.shard(client.loc).reqUpdateLight(updates)
+}
+
+func (client *testShardClient) ReqSetBlocks(blocks []gamerules.BlockState, tileEntities []gamerules.ITileEntity) {
+	client.ts.shard(client.loc).reqSetBlocks(blocks, tileEntities)
+}
+
+func (client *testShardClient) ReqQueryChunks(requester ShardXz, chunks []ChunkXz) {
+	client.ts.shard(client.loc).reqQueryChunks(requester, chunks)
+}
+
+func (client *testShardClient) ReqRemoteChunks(chunks []gamerules.ChunkBlocks) {
+	client.ts.shard(client.loc).reqRemoteChunks(chunks)
+}
+
+// chunk returns the chunk at loc, loading it if need be.
+func (ts *testShards) chunk(loc ChunkXz) *Chunk {
+	return ts.shard(loc.ToShardXz()).chunkAt(loc)
+}
+
+// tick runs all of the shards for the given number of ticks.
+func (ts *testShards) tick(ticks int) {
+	for i := 0; i < ticks; i++ {
+		for _, shard := range ts.shards {
+			shard.tick()
+		}
+	}
+}
+
+func (ts *testShards) setBlock(loc BlockXyz, blockId BlockId, blockData byte) {
+	ts.chunk(*loc.ToChunkXz()).SetBlockAt(&loc, blockId, blockData)
+}
+
+// blockAt returns the type and data of a block, loading the chunk that it is
+// within if need be.
+func (ts *testShards) blockAt(loc BlockXyz) (blockId BlockId, blockData byte) {
+	chunkLoc, subLoc := loc.ToChunkLocal()
+	chunk := ts.chunk(*chunkLoc)
+	index, _ := subLoc.BlockIndex()
+	return index.BlockId(chunk.blocks), index.BlockData(chunk.blockData)
+}
+
+// expectBlock checks the type and data of a block.
+func (ts *testShards) expectBlock(t *testing.T, loc BlockXyz, blockId BlockId, blockData byte) {
+	if gotId, gotData := ts.blockAt(loc); gotId != blockId || gotData != blockData {
+		t.Errorf("block at %#v: expected %d/%d, got %d/%d", loc, blockId, blockData, gotId, gotData)
+	}
+}
+
+func TestUnloadIdleChunks(t *testing.T) {
+	defer func(idleTicks Ticks) { ChunkIdleTicks = idleTicks }(ChunkIdleTicks)
+	ChunkIdleTicks = 2 * TicksPerSecond
+
+	ts := newTestShards(nil)
+	loc := ChunkXz{1, 2}
+	shard := ts.shard(loc.ToShardXz())
+
+	item := gamerules.NewItem(testItemIdStone, 1, 0, &AbsXyz{24.5, testFloorY + 1, 40.5}, &AbsVelocity{}, 0)
+	ts.chunk(loc).AddEntity(item)
+
+	ts.tick(TicksPerSecond)
+	if shard.loadedChunk(loc) == nil {
+		t.Fatalf("expected the chunk to stay loaded until it has been idle for long enough")
+	}
+
+	ts.tick(2 * TicksPerSecond)
+	if shard.loadedChunk(loc) != nil {
+		t.Fatalf("expected the idle chunk to be unloaded")
+	}
+
+	stored, ok := ts.store.chunks[loc.ChunkKey()]
+	if !ok {
+		t.Fatalf("expected the chunk to be saved when it was unloaded")
+	}
+	if len(stored.entities) != 1 {
+		t.Errorf("expected the chunk to be saved with 1 entity, got %d", len(stored.entities))
+	}
+
+	// With nothing loaded, the shard stops, and refuses further requests.
+	if !shard.stopIfUnused() {
+		t.Fatalf("expected the shard to stop once its chunks were unloaded")
+	}
+	if shard.enqueue(func() {}) {
+		t.Errorf("expected a stopped shard to refuse requests")
+	}
+}
+
+func TestStopIfUnused_PendingRequest(t *testing.T) {
+	ts := newTestShards(nil)
+	shard := ts.shard(ShardXz{0, 0})
+
+	// The request has been enqueued, but the shard has not received it.
+	if !shard.enqueue(func() {}) {
+		t.Fatalf("expected a running shard to accept requests")
+	}
+	if shard.stopIfUnused() {
+		t.Fatalf("expected the shard not to stop with a request pending")
+	}
+
+	request := <-shard.requests
+	shard.pending--
+	request.perform(shard)
+
+	if !shard.stopIfUnused() {
+		t.Errorf("expected the shard to stop once the request was received")
+	}
+}
+
+func TestForgetRemoteBlocksNear(t *testing.T) {
+	ts := newTestShards(nil)
+	shard := ts.shard(ShardXz{0, 0})
+
+	// Both blocks are in the shard at -X, but only the first is next to the
+	// chunk.
+	near := BlockXyz{-1, testFloorY, 8}
+	far := BlockXyz{-1, testFloorY, 3 * ChunkSizeH}
+	shard.reqRemoteBlocks([]gamerules.BlockState{
+		{Loc: near, BlockId: testBlockIdStone},
+		{Loc: far, BlockId: testBlockIdStone},
+	})
+
+	shard.forgetRemoteBlocksNear(&ChunkXz{0, 0})
+
+	if _, ok := shard.remoteBlocks[near.Key()]; ok {
+		t.Errorf("expected the block next to the chunk to be forgotten")
+	}
+	if _, ok := shard.remoteBlocks[far.Key()]; !ok {
+		t.Errorf("expected the block away from the chunk to be kept")
+	}
+}
@@ -0,0 +1,296 @@
+package shardserver
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+const (
+	// Night lasts from nightStart until nightEnd in each day, during which sky
+	// light is reduced by nightSkyDarkening.
+	nightStart        = 13000
+	nightEnd          = 23000
+	nightSkyDarkening = 11
+
+	// The number of spots tried for each mob in a group after the first, and
+	// how far from the first mob they can be.
+	groupSpotTries = 4
+	groupSpread    = 4
+)
+
+// isNight returns true if worldTime falls at night.
+func isNight(worldTime Ticks) bool {
+	timeOfDay := worldTime % TicksPerDay
+	return timeOfDay >= nightStart && timeOfDay < nightEnd
+}
+
+// spawnMobs removes mobs that are far from all players, and spawns new mobs
+// near players, according to rules.
+func (shard *ChunkShard) spawnMobs(rules *gamerules.SpawnRules) {
+	players := shard.playerPositions()
+
+	counts := shard.despawnMobs(rules, players)
+
+	if len(players) == 0 {
+		return
+	}
+
+	night := isNight(shard.worldTime)
+
+	for i := range rules.Mobs {
+		rule := &rules.Mobs[i]
+
+		if counts[rule.MobType] >= rule.Cap {
+			continue
+		}
+		if (rule.Time == gamerules.SpawnTimeDay && night) || (rule.Time == gamerules.SpawnTimeNight && !night) {
+			continue
+		}
+		if shard.rand.Float64() >= rule.Chance {
+			continue
+		}
+
+		near := &players[shard.rand.Intn(len(players))]
+		counts[rule.MobType] += shard.spawnGroup(rules, rule, players, near)
+	}
+}
+
+// playerPositions returns the positions of all players in the shard.
+func (shard *ChunkShard) playerPositions() (positions []AbsXyz) {
+	for _, chunk := range shard.chunks {
+		if chunk == nil {
+			continue
+		}
+		for _, data := range chunk.playersData {
+			positions = append(positions, data.position)
+		}
+	}
+	return
+}
+
+// despawnMobs removes the mobs that despawn and are far from all players. It
+// returns the number of living mobs of each type left in the shard.
+func (shard *ChunkShard) despawnMobs(rules *gamerules.SpawnRules, players []AbsXyz) (counts map[EntityMobType]int) {
+	counts = make(map[EntityMobType]int)
+
+	for _, chunk := range shard.chunks {
+		if chunk == nil {
+			continue
+		}
+
+		for _, e := range chunk.entities {
+			mob, ok := e.(gamerules.IMob)
+			if !ok || !mob.IsAlive() {
+				continue
+			}
+
+			rule, ok := rules.Rule(mob.GetMobType())
+			if ok && rule.Despawns && shard.farFromPlayers(chunk, mob.Position(), players, rules.DespawnDistance) {
+				chunk.removeEntity(e)
+				continue
+			}
+
+			counts[mob.GetMobType()]++
+		}
+	}
+
+	return
+}
+
+// farFromPlayers returns true if no player is within distance of position in
+// chunk. The shard only knows about its own players, so if the area within
+// distance reaches into other shards, it instead relies on nobody being
+// subscribed to the chunk, which means that no player anywhere can see it.
+func (shard *ChunkShard) farFromPlayers(chunk *Chunk, position *AbsXyz, players []AbsXyz, distance AbsCoord) bool {
+	for i := range players {
+		if players[i].IsWithinDistanceOf(position, distance) {
+			return false
+		}
+	}
+
+	corner := *position
+	corner.X -= distance
+	corner.Z -= distance
+	minShardLoc := corner.ToShardXz()
+	corner.X += 2 * distance
+	corner.Z += 2 * distance
+	maxShardLoc := corner.ToShardXz()
+	if minShardLoc.X != shard.loc.X || minShardLoc.Z != shard.loc.Z ||
+		maxShardLoc.X != shard.loc.X || maxShardLoc.Z != shard.loc.Z {
+		return len(chunk.subscribers) == 0
+	}
+
+	return true
+}
+
+// spawnGroup tries to spawn a group of mobs in a random chunk near the player
+// at position near. It returns the number of mobs spawned.
+func (shard *ChunkShard) spawnGroup(rules *gamerules.SpawnRules, rule *gamerules.MobSpawnRule, players []AbsXyz, near *AbsXyz) (count int) {
+	radius := int(rules.ChunkRadius)
+	nearChunkLoc := near.ToChunkXz()
+	chunk := shard.loadedChunk(ChunkXz{
+		X: nearChunkLoc.X + ChunkCoord(shard.rand.Intn(2*radius+1)-radius),
+		Z: nearChunkLoc.Z + ChunkCoord(shard.rand.Intn(2*radius+1)-radius),
+	})
+	if chunk == nil {
+		return
+	}
+
+	first, ok := chunk.findSpawnSpot(rule, players, rules.MinPlayerDistance)
+	if !ok {
+		return
+	}
+
+	size := rule.MinGroup + shard.rand.Intn(rule.MaxGroup-rule.MinGroup+1)
+	shard.spawnMob(rule, first)
+	count++
+
+	for count < size {
+		spawned := false
+		for try := 0; try < groupSpotTries && !spawned; try++ {
+			loc := BlockXyz{
+				X: first.X + BlockCoord(shard.rand.Intn(2*groupSpread+1)-groupSpread),
+				Y: first.Y,
+				Z: first.Z + BlockCoord(shard.rand.Intn(2*groupSpread+1)-groupSpread),
+			}
+			if shard.isSpawnSpot(&loc, rule, players, rules.MinPlayerDistance) {
+				shard.spawnMob(rule, &loc)
+				spawned = true
+			}
+		}
+		if !spawned {
+			break
+		}
+		count++
+	}
+
+	return
+}
+
+// spawnMob creates a mob standing on the block below loc.
+func (shard *ChunkShard) spawnMob(rule *gamerules.MobSpawnRule, loc *BlockXyz) {
+	chunk := shard.loadedChunk(*loc.ToChunkXz())
+	if chunk == nil {
+		return
+	}
+
+	position := AbsXyz{AbsCoord(loc.X) + 0.5, AbsCoord(loc.Y), AbsCoord(loc.Z) + 0.5}
+	look := LookDegrees{AngleDegrees(shard.rand.Float64() * 360), 0}
+	if mob := gamerules.NewMobByTypeName(rule.Mob, &position, &look); mob != nil {
+		chunk.AddEntity(mob)
+	}
+}
+
+// findSpawnSpot picks a random column within the chunk, and looks down it
+// from the highest block for somewhere that the mob can spawn.
+func (chunk *Chunk) findSpawnSpot(rule *gamerules.MobSpawnRule, players []AbsXyz, minPlayerDistance AbsCoord) (loc *BlockXyz, ok bool) {
+	subLoc := SubChunkXyz{
+		X: SubChunkCoord(chunk.rand.Intn(ChunkSizeH)),
+		Z: SubChunkCoord(chunk.rand.Intn(ChunkSizeH)),
+	}
+	top := int(chunk.heightMap[heightMapIndex(&subLoc)])
+	if top > ChunkSizeY-2 {
+		// Leave room for the mob's head.
+		top = ChunkSizeY - 2
+	}
+
+	for y := top; y > 0; y-- {
+		subLoc.Y = SubChunkCoord(y)
+		loc = chunk.loc.ToBlockXyz(&subLoc)
+		if chunk.shard.isSpawnSpot(loc, rule, players, minPlayerDistance) {
+			return loc, true
+		}
+	}
+
+	return nil, false
+}
+
+// isSpawnSpot returns true if the mob can spawn with its feet in the block at
+// loc.
+func (shard *ChunkShard) isSpawnSpot(loc *BlockXyz, rule *gamerules.MobSpawnRule, players []AbsXyz, minPlayerDistance AbsCoord) bool {
+	below := loc.AddXyz(0, -1, 0)
+	above := loc.AddXyz(0, 1, 0)
+	if below == nil || above == nil {
+		return false
+	}
+
+	floorId, ok := shard.loadedBlockId(below)
+	if !ok || !rule.SpawnOn[floorId] {
+		return false
+	}
+
+	for _, spaceLoc := range []*BlockXyz{loc, above} {
+		spaceId, ok := shard.loadedBlockId(spaceLoc)
+		if !ok {
+			return false
+		}
+		spaceType, ok := gamerules.Blocks.Get(spaceId)
+		if !ok || spaceType.Solid {
+			return false
+		}
+		if _, isFluid := spaceType.Aspect.(*gamerules.FluidAspect); isFluid {
+			return false
+		}
+	}
+
+	light, ok := shard.lightAt(loc)
+	if !ok || light < rule.MinLight || light > rule.MaxLight {
+		return false
+	}
+
+	position := AbsXyz{AbsCoord(loc.X) + 0.5, AbsCoord(loc.Y), AbsCoord(loc.Z) + 0.5}
+	for i := range players {
+		if players[i].IsWithinDistanceOf(&position, minPlayerDistance) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// loadedBlockId returns the type of the block at loc, if it is in a loaded
+// chunk in the shard.
+func (shard *ChunkShard) loadedBlockId(loc *BlockXyz) (blockId BlockId, ok bool) {
+	chunkLoc, subLoc := loc.ToChunkLocal()
+	chunk := shard.loadedChunk(*chunkLoc)
+	if chunk == nil {
+		return
+	}
+
+	index, ok := subLoc.BlockIndex()
+	if !ok {
+		return
+	}
+
+	return index.BlockId(chunk.blocks), true
+}
+
+// lightAt returns the light level of the block at loc at the current time of
+// day, if it is in a loaded chunk in the shard.
+func (shard *ChunkShard) lightAt(loc *BlockXyz) (light byte, ok bool) {
+	chunkLoc, subLoc := loc.ToChunkLocal()
+	chunk := shard.loadedChunk(*chunkLoc)
+	if chunk == nil {
+		return
+	}
+
+	index, ok := subLoc.BlockIndex()
+	if !ok {
+		return
+	}
+
+	light = index.BlockData(chunk.blockLight)
+	skyLight := index.BlockData(chunk.skyLight)
+	if isNight(shard.worldTime) {
+		if skyLight > nightSkyDarkening {
+			skyLight -= nightSkyDarkening
+		} else {
+			skyLight = 0
+		}
+	}
+	if skyLight > light {
+		light = skyLight
+	}
+
+	return light, true
+}
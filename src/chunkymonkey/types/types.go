@@ -106,6 +106,11 @@ var MobNameByType = map[EntityMobType]string{}
 
 type EntityStatus byte
 
+const (
+	EntityStatusHurt = EntityStatus(2)
+	EntityStatusDead = EntityStatus(3)
+)
+
 type EntityAnimation byte
 
 const (
@@ -221,6 +226,25 @@ func (f Face) Dxyz() (dx BlockCoord, dy BlockYCoord, dz BlockCoord) {
 	return
 }
 
+// Opposite returns the face on the other side of a block.
+func (f Face) Opposite() Face {
+	switch f {
+	case FaceBottom:
+		return FaceTop
+	case FaceTop:
+		return FaceBottom
+	case FaceEast:
+		return FaceWest
+	case FaceWest:
+		return FaceEast
+	case FaceNorth:
+		return FaceSouth
+	case FaceSouth:
+		return FaceNorth
+	}
+	return FaceNull
+}
+
 // Action-related types and constants
 
 type DigStatus byte
@@ -359,6 +383,10 @@ func (d *AngleDegrees) ToAngleBytes() AngleBytes {
 	return AngleBytes(norm * DegreesToBytes)
 }
 
+func (b *AngleBytes) ToAngleDegrees() AngleDegrees {
+	return AngleDegrees(float64(*b) / DegreesToBytes)
+}
+
 type LookDegrees struct {
 	// Pitch is -ve when looking above the horizontal, and +ve below
 	Yaw, Pitch AngleDegrees
@@ -375,6 +403,13 @@ type LookBytes struct {
 	Yaw, Pitch AngleBytes
 }
 
+func (l *LookBytes) ToLookDegrees() *LookDegrees {
+	return &LookDegrees{
+		l.Yaw.ToAngleDegrees(),
+		l.Pitch.ToAngleDegrees(),
+	}
+}
+
 type OrientationDegrees struct {
 	Yaw, Pitch, Roll AngleDegrees
 }
@@ -669,6 +704,13 @@ func (b *BlockXyz) Equals(rhs BlockXyz) bool {
 	return b.X == rhs.X && b.Y == rhs.Y && b.Z == rhs.Z
 }
 
+// Key returns a value that uniquely identifies the block location, for use as
+// a map key. The X and Z coordinates only contribute their lower 28 bits, which
+// is well beyond the extent of any world.
+func (b *BlockXyz) Key() uint64 {
+	return uint64(uint32(b.X)&0xfffffff)<<36 | uint64(uint32(b.Z)&0xfffffff)<<8 | uint64(uint8(b.Y))
+}
+
 // Test if a block location is not appropriate to the situation, but block
 // location data passed (such as using an item not on a block).
 func (b *BlockXyz) IsNull() bool {
@@ -492,6 +492,45 @@ func TestAddXyz_Overflow(t *testing.T) {
 	}
 }
 
+func TestBlockXyz_Key(t *testing.T) {
+	type Test struct {
+		input    BlockXyz
+		expected uint64
+	}
+
+	var tests = []Test{
+		{BlockXyz{0, 0, 0}, 0},
+		{BlockXyz{0, 1, 0}, 0x0000000000000001},
+		{BlockXyz{0, 0, 1}, 0x0000000000000100},
+		{BlockXyz{1, 0, 0}, 0x0000001000000000},
+		{BlockXyz{0, 0, -1}, 0x0000000fffffff00},
+		{BlockXyz{-1, 0, 0}, 0xfffffff000000000},
+		{BlockXyz{10, 127, 11}, 0x000000a000000b7f},
+	}
+
+	for _, r := range tests {
+		result := r.input.Key()
+		if r.expected != result {
+			t.Errorf("BlockXyz%+v.Key() expected %#x got %#x",
+				r.input, r.expected, result)
+		}
+	}
+}
+
+func TestFace_Opposite(t *testing.T) {
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		opposite := face.Opposite()
+		if opposite.Opposite() != face {
+			t.Errorf("Face(%d).Opposite().Opposite() expected %d got %d", face, face, opposite.Opposite())
+		}
+		dx, dy, dz := face.Dxyz()
+		odx, ody, odz := opposite.Dxyz()
+		if dx != -odx || dy != -ody || dz != -odz {
+			t.Errorf("Face(%d) and its opposite Face(%d) are not in opposing directions", face, opposite)
+		}
+	}
+}
+
 func TestBlockXyz_ToAbsIntXyz(t *testing.T) {
 	type Test struct {
 		input    BlockXyz
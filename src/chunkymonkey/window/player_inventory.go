@@ -1,8 +1,8 @@
 package window
 
 import (
-	"io"
 	"fmt"
+	"io"
 	"os"
 
 	"chunkymonkey/gamerules"
@@ -101,6 +101,16 @@ func (w *PlayerInventory) TakeOneHeldItem(into *gamerules.Slot) {
 	w.holding.TakeOneItem(w.holdingIndex, into)
 }
 
+// TakeAllItems empties the player's inventory, including the armor and
+// crafting slots, and returns all items that were inside it.
+func (w *PlayerInventory) TakeAllItems() (items []gamerules.Slot) {
+	items = append(items, w.crafting.TakeAllItems()...)
+	items = append(items, w.armor.TakeAllItems()...)
+	items = append(items, w.main.TakeAllItems()...)
+	items = append(items, w.holding.TakeAllItems()...)
+	return
+}
+
 // Writes packets for other players to see the equipped items.
 func (w *PlayerInventory) SendFullEquipmentUpdate(writer io.Writer) (err os.Error) {
 	slot, _ := w.HeldItem()
@@ -1,5 +1,4 @@
-// Responsible for reading the overall world persistent state.
-// Eventually this should also be responsible for writing it as well.
+// Responsible for reading and writing the overall world persistent state.
 package worldstore
 
 import (
@@ -27,6 +26,14 @@ type WorldStore struct {
 	LevelData     nbt.ITag
 	ChunkStore    chunkstore.IChunkStore
 	SpawnPosition BlockXyz
+
+	// Populator populates the chunks of the normal world that the generator
+	// has made. It is nil if the generator's chunks need no population.
+	Populator chunkstore.IPopulator
+
+	// NetherChunkStore holds the chunks of the Nether, which are kept apart
+	// from those of the normal world.
+	NetherChunkStore chunkstore.IChunkStore
 }
 
 func LoadWorldStore(worldPath string) (world *WorldStore, err os.Error) {
@@ -56,15 +63,6 @@ func LoadWorldStore(worldPath string) (world *WorldStore, err os.Error) {
 		timeTicks = Ticks(timeTag.Value)
 	}
 
-	var chunkStores []chunkstore.IChunkStore
-	persistantChunkStore, err := chunkstore.ChunkStoreForLevel(worldPath, levelData, DimensionNormal)
-	if err != nil {
-		return nil, err
-	}
-
-	persistantChunkService := chunkstore.NewChunkService(persistantChunkStore)
-	chunkStores = append(chunkStores, persistantChunkService)
-
 	var seed int64
 	if seedNbt, ok := levelData.Lookup("Data/RandomSeed").(*nbt.Long); ok {
 		seed = seedNbt.Value
@@ -72,22 +70,67 @@ func LoadWorldStore(worldPath string) (world *WorldStore, err os.Error) {
 		seed = rand.NewSource(time.Seconds()).Int63()
 	}
 
-	chunkStores = append(chunkStores, chunkstore.NewChunkService(generation.NewTestGenerator(seed)))
+	var generatorName, generatorOptions string
+	if nameNbt, ok := levelData.Lookup("Data/generatorName").(*nbt.String); ok {
+		generatorName = nameNbt.Value
+	}
+	if optionsNbt, ok := levelData.Lookup("Data/generatorOptions").(*nbt.String); ok {
+		generatorOptions = optionsNbt.Value
+	}
+
+	generator, err := generation.NewGenerator(generatorName, generatorOptions, seed)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, store := range chunkStores {
-		go store.Serve()
+	chunkStore, err := loadChunkStore(worldPath, levelData, DimensionNormal, generator)
+	if err != nil {
+		return nil, err
+	}
+
+	netherChunkStore, err := loadChunkStore(worldPath, levelData, DimensionNether, generation.NewNetherGenerator(seed))
+	if err != nil {
+		return nil, err
 	}
 
 	world = &WorldStore{
-		WorldPath:     worldPath,
-		Seed:          seed,
-		Time:          timeTicks,
-		LevelData:     levelData,
-		ChunkStore:    chunkstore.NewChunkService(chunkstore.NewMultiStore(chunkStores, persistantChunkService)),
-		SpawnPosition: spawnPosition,
+		WorldPath:        worldPath,
+		Seed:             seed,
+		Time:             timeTicks,
+		LevelData:        levelData,
+		ChunkStore:       chunkStore,
+		SpawnPosition:    spawnPosition,
+		NetherChunkStore: netherChunkStore,
+	}
+
+	if populator, ok := generator.(chunkstore.IPopulator); ok {
+		world.Populator = populator
 	}
 
-	go world.ChunkStore.Serve()
+	return
+}
+
+// loadChunkStore returns a store of the chunks of the given dimension, which
+// reads the chunks saved in the world, and uses the generator for those that
+// have not been saved.
+func loadChunkStore(worldPath string, levelData nbt.ITag, dimension DimensionId, generator chunkstore.IChunkStoreForeground) (store chunkstore.IChunkStore, err os.Error) {
+	var chunkStores []chunkstore.IChunkStore
+	persistantChunkStore, err := chunkstore.ChunkStoreForLevel(worldPath, levelData, dimension)
+	if err != nil {
+		return
+	}
+
+	persistantChunkService := chunkstore.NewChunkService(persistantChunkStore)
+	chunkStores = append(chunkStores, persistantChunkService)
+
+	chunkStores = append(chunkStores, chunkstore.NewChunkService(generator))
+
+	for _, store := range chunkStores {
+		go store.Serve()
+	}
+
+	store = chunkstore.NewChunkService(chunkstore.NewMultiStore(chunkStores, persistantChunkService))
+	go store.Serve()
 
 	return
 }
@@ -111,8 +154,10 @@ func loadLevelData(worldPath string) (levelData nbt.ITag, err os.Error) {
 	return
 }
 
-// NOTE: ChunkStoreForDimension shouldn't really be used in the server just
-// yet.
+// ChunkStoreForDimension returns a store of only the chunks that have been
+// saved in the given dimension, without generating those that have not. It is
+// for tools that inspect worlds. The server uses ChunkStore and
+// NetherChunkStore.
 func (world *WorldStore) ChunkStoreForDimension(dimension DimensionId) (store chunkstore.IChunkStore, err os.Error) {
 	fgStore, err := chunkstore.ChunkStoreForLevel(world.WorldPath, world.LevelData, dimension)
 	if err != nil {
@@ -170,28 +215,97 @@ func (world *WorldStore) WritePlayerData(user string, data *nbt.Compound) (err o
 	return
 }
 
-// Creates a new world at 'worldPath'
-func CreateWorld(worldPath string) (err os.Error) {
+// WriteLevelData writes the level data back to level.dat, updating the time,
+// spawn position and last played time from the WorldStore. Tags in the level
+// data that are not understood here are written back unchanged.
+func (world *WorldStore) WriteLevelData() (err os.Error) {
+	data, ok := world.LevelData.Lookup("Data").(*nbt.Compound)
+	if !ok {
+		return BadType("Data")
+	}
+
+	data.Tags["Time"] = &nbt.Long{int64(world.Time)}
+	data.Tags["LastPlayed"] = &nbt.Long{time.Nanoseconds() / 1e6}
+	data.Tags["SpawnX"] = &nbt.Int{int32(world.SpawnPosition.X)}
+	data.Tags["SpawnY"] = &nbt.Int{int32(world.SpawnPosition.Y)}
+	data.Tags["SpawnZ"] = &nbt.Int{int32(world.SpawnPosition.Z)}
+
+	return writeLevelData(world.WorldPath, world.LevelData)
+}
+
+// writeLevelData writes level.dat in the world directory. It writes to a
+// temporary file first and then renames it over level.dat, so that level.dat
+// is never left partly written.
+func writeLevelData(worldPath string, levelData nbt.ITag) (err os.Error) {
+	filename := path.Join(worldPath, "level.dat")
+	tmpFilename := filename + ".tmp"
+
+	file, err := os.OpenFile(tmpFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return
+	}
+
+	gzipWriter, err := gzip.NewWriter(file)
+	if err == nil {
+		err = nbt.Write(gzipWriter, levelData)
+		if closeErr := gzipWriter.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmpFilename)
+		return
+	}
+
+	return os.Rename(tmpFilename, filename)
+}
+
+// Creates a new world at 'worldPath', whose chunks are made by the named
+// generator with the given options.
+func CreateWorld(worldPath string, generatorName string, generatorOptions string) (err os.Error) {
 	source := rand.NewSource(time.Nanoseconds())
 	seed := source.Int63()
 
+	// Check the generator name and options before the world is created with
+	// them.
+	generator, err := generation.NewGenerator(generatorName, generatorOptions, seed)
+	if err != nil {
+		return
+	}
+
+	// TODO: Figure this out from the other chunk generators.
+	spawn := BlockXyz{0, 75, 0}
+	if positioner, ok := generator.(generation.ISpawnPositioner); ok {
+		spawn = positioner.SpawnPosition()
+	}
+
 	data := &nbt.Compound{
 		map[string]nbt.ITag{
 			"Data": &nbt.Compound{
 				map[string]nbt.ITag{
-					"Time":        &nbt.Long{0},
-					"rainTime":    &nbt.Int{0},
-					"thunderTime": &nbt.Int{0},
-					"version":     &nbt.Int{19132}, // TODO: What should this be?
-					"thundering":  &nbt.Byte{0},
-					"raining":     &nbt.Byte{0},
-					"LevelName":   &nbt.String{"world"}, // TODO: Should be specifyable
-					"SpawnX":      &nbt.Int{0},          // TODO: Figure this out from chunk generator?
-					"SpawnY":      &nbt.Int{75},         // TODO: Figure this out from chunk generator?
-					"SpawnZ":      &nbt.Int{0},          // TODO: Figure this out from chunk generator?
-					"LastPlayed":  &nbt.Long{0},
-					"SizeOnDisk":  &nbt.Long{0}, // Needs to be accurate?
-					"RandomSeed":  &nbt.Long{seed},
+					"Time":          &nbt.Long{0},
+					"rainTime":      &nbt.Int{0},
+					"thunderTime":   &nbt.Int{0},
+					"version":       &nbt.Int{19132}, // TODO: What should this be?
+					"thundering":    &nbt.Byte{0},
+					"raining":       &nbt.Byte{0},
+					"LevelName":     &nbt.String{"world"}, // TODO: Should be specifyable
+					"SpawnX":        &nbt.Int{int32(spawn.X)},
+					"SpawnY":        &nbt.Int{int32(spawn.Y)},
+					"SpawnZ":        &nbt.Int{int32(spawn.Z)},
+					"LastPlayed":    &nbt.Long{0},
+					"SizeOnDisk":    &nbt.Long{0}, // Needs to be accurate?
+					"RandomSeed":    &nbt.Long{seed},
+					"generatorName": &nbt.String{generatorName},
+
+					"generatorOptions": &nbt.String{generatorOptions},
 				},
 			},
 		},
@@ -201,25 +315,9 @@ func CreateWorld(worldPath string) (err os.Error) {
 		return
 	}
 
-	filename := path.Join(worldPath, "level.dat")
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		return err
-	}
-
-	gzipWriter, err := gzip.NewWriter(file)
-	if err != nil {
-		return err
-	}
-
-	err = nbt.Write(gzipWriter, data)
-	gzipWriter.Close()
-	file.Close()
-
-	return nil
+	return writeLevelData(worldPath, data)
 }
 
-
 func absXyzFromNbt(tag nbt.ITag, path string) (pos AbsXyz, err os.Error) {
 	posList, posOk := tag.Lookup(path).(*nbt.List)
 	if !posOk {
@@ -0,0 +1,87 @@
+package worldstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+func TestWriteLevelData(t *testing.T) {
+	worldPath, err := ioutil.TempDir("", "worldstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(worldPath)
+
+	levelData := &nbt.Compound{
+		map[string]nbt.ITag{
+			"Data": &nbt.Compound{
+				map[string]nbt.ITag{
+					"Time":       &nbt.Long{10},
+					"LastPlayed": &nbt.Long{0},
+					"SpawnX":     &nbt.Int{1},
+					"SpawnY":     &nbt.Int{64},
+					"SpawnZ":     &nbt.Int{2},
+					"LevelName":  &nbt.String{"test"},
+					"RandomSeed": &nbt.Long{1234},
+				},
+			},
+		},
+	}
+	if err = writeLevelData(worldPath, levelData); err != nil {
+		t.Fatal(err)
+	}
+
+	readLevelData, err := loadLevelData(worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	world := &WorldStore{
+		WorldPath:     worldPath,
+		Time:          Ticks(5000),
+		LevelData:     readLevelData,
+		SpawnPosition: BlockXyz{-20, 70, 30},
+	}
+	before := time.Nanoseconds() / 1e6
+	if err = world.WriteLevelData(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := loadLevelData(worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tags that the WorldStore does not manage are kept.
+	if name, ok := result.Lookup("Data/LevelName").(*nbt.String); !ok || name.Value != "test" {
+		t.Errorf("expected LevelName \"test\", got %#v", result.Lookup("Data/LevelName"))
+	}
+	if seed, ok := result.Lookup("Data/RandomSeed").(*nbt.Long); !ok || seed.Value != 1234 {
+		t.Errorf("expected RandomSeed 1234, got %#v", result.Lookup("Data/RandomSeed"))
+	}
+
+	if timeTag, ok := result.Lookup("Data/Time").(*nbt.Long); !ok || timeTag.Value != 5000 {
+		t.Errorf("expected Time 5000, got %#v", result.Lookup("Data/Time"))
+	}
+	if lastPlayed, ok := result.Lookup("Data/LastPlayed").(*nbt.Long); !ok || lastPlayed.Value < before {
+		t.Errorf("expected LastPlayed of at least %d, got %#v", before, result.Lookup("Data/LastPlayed"))
+	}
+
+	spawn := map[string]int32{"SpawnX": -20, "SpawnY": 70, "SpawnZ": 30}
+	for name, value := range spawn {
+		if tag, ok := result.Lookup("Data/" + name).(*nbt.Int); !ok || tag.Value != value {
+			t.Errorf("expected %s %d, got %#v", name, value, result.Lookup("Data/"+name))
+		}
+	}
+
+	// The temporary file is not left behind.
+	if _, err := os.Stat(path.Join(worldPath, "level.dat.tmp")); err == nil {
+		t.Errorf("expected level.dat.tmp to have been renamed")
+	}
+}
@@ -8,47 +8,21 @@ import (
 	"log"
 	"net"
 	"os"
+	"os/signal"
 
 	"chunkymonkey"
+	"chunkymonkey/config"
 	"chunkymonkey/gamerules"
+	"chunkymonkey/player"
+	"chunkymonkey/region"
+	"chunkymonkey/shardserver"
+	. "chunkymonkey/types"
 	"chunkymonkey/worldstore"
 )
 
-var addr = flag.String(
-	"addr", ":25565",
-	"Serves on the given address:port.")
-
-var httpAddr = flag.String(
-	"http_addr", ":25566",
-	"Serves HTTP diagnostics on the given address:port.")
-
-var blockDefs = flag.String(
-	"blocks", "blocks.json",
-	"The JSON file containing block type definitions.")
-
-var itemDefs = flag.String(
-	"items", "items.json",
-	"The JSON file containing item type definitions.")
-
-var recipeDefs = flag.String(
-	"recipes", "recipes.json",
-	"The JSON file containing recipe definitions.")
-
-var furnaceDefs = flag.String(
-	"furnace", "furnace.json",
-	"The JSON file containing furnace fuel and reaction definitions.")
-
-var underMaintenaceMsg = flag.String(
-	"underMaintenanceMsg", "",
-	"If set, all logins will be denied and this message will be given as reason.")
-
-var userDefs = flag.String(
-	"users", "users.json",
-	"The JSON file container user permissions.")
-
-var groupDefs = flag.String(
-	"groups", "groups.json",
-	"The JSON file containing group permissions.")
+var configFile = flag.String(
+	"config", "config.json",
+	"The JSON file containing the server configuration.")
 
 func usage() {
 	os.Stderr.WriteString("usage: " + os.Args[0] + " [flags] <world>\n")
@@ -64,6 +38,25 @@ func startHttpServer(addr string) (err os.Error) {
 	return
 }
 
+// handleSignals stops the game when the process is interrupted or terminated,
+// so that the world is saved before exiting. The configuration is reloaded
+// when the process is hung up on.
+func handleSignals(game *chunkymonkey.Game) {
+	for sig := range signal.Incoming {
+		switch sig {
+		case os.SIGINT, os.SIGTERM:
+			log.Printf("Received %v", sig)
+			game.StopServer("The server is stopping.")
+			return
+		case os.SIGHUP:
+			log.Printf("Received %v", sig)
+			if err := game.ReloadConfig(); err != nil {
+				log.Print("Error reloading the configuration: ", err)
+			}
+		}
+	}
+}
+
 func main() {
 	var err os.Error
 
@@ -75,7 +68,19 @@ func main() {
 		os.Exit(1)
 	}
 
-	err = gamerules.LoadGameRules(*blockDefs, *itemDefs, *recipeDefs, *furnaceDefs, *userDefs, *groupDefs)
+	cfg, err := config.LoadConfigFromFile(*configFile)
+	if err != nil {
+		log.Print("Error loading configuration: ", err)
+		os.Exit(1)
+	}
+
+	shardserver.ChunkIdleTicks = Ticks(cfg.ChunkIdleSecs) * TicksPerSecond
+	shardserver.TicksBetweenSaves = Ticks(cfg.SaveIntervalSecs) * TicksPerSecond
+	region.SpawnProtectionRadius = cfg.SpawnProtection
+	player.ViewDistance = cfg.ViewDistance
+
+	files := &cfg.Files
+	err = gamerules.LoadGameRules(files.Blocks, files.Items, files.Recipes, files.Furnace, files.Spawning, files.Users, files.Groups, files.Regions)
 	if err != nil {
 		log.Print("Error loading game rules: ", err)
 		os.Exit(1)
@@ -86,7 +91,7 @@ func main() {
 	if err != nil {
 		log.Printf("Could not load world from directory %v: %v", worldPath, err)
 		log.Printf("Creating a new world in directory %v", worldPath)
-		err = worldstore.CreateWorld(worldPath)
+		err = worldstore.CreateWorld(worldPath, cfg.Generator, cfg.GeneratorOptions)
 	}
 	if err != nil {
 		log.Printf("Error creating new world: %v", err)
@@ -99,15 +104,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	game, err := chunkymonkey.NewGame(worldPath)
+	game, err := chunkymonkey.NewGame(worldPath, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	game.UnderMaintenanceMsg = *underMaintenaceMsg
-	err = startHttpServer(*httpAddr)
+	err = startHttpServer(cfg.HttpAddr)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	game.Serve(*addr)
+	go handleSignals(game)
+
+	game.Serve(cfg.Addr)
 }
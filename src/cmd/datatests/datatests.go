@@ -26,6 +26,10 @@ var furnaceDefs = flag.String(
 	"furnace", "furnace.json",
 	"The JSON file containing furnace fuel and reaction definitions.")
 
+var spawnDefs = flag.String(
+	"spawning", "spawning.json",
+	"The JSON file containing natural mob spawning rules.")
+
 var userDefs = flag.String(
 	"users", "users.json",
 	"The JSON file container user permissions.")
@@ -34,8 +38,12 @@ var groupDefs = flag.String(
 	"groups", "groups.json",
 	"The JSON file containing group permissions.")
 
+var regionDefs = flag.String(
+	"regions", "regions.json",
+	"The JSON file containing protected regions.")
+
 func main() {
-	err := gamerules.LoadGameRules(*blockDefs, *itemDefs, *recipeDefs, *furnaceDefs, *userDefs, *groupDefs)
+	err := gamerules.LoadGameRules(*blockDefs, *itemDefs, *recipeDefs, *furnaceDefs, *spawnDefs, *userDefs, *groupDefs, *regionDefs)
 
 	if err != nil {
 		fmt.Fprintf(os.Stdout, "Error loading definitions: %v\n", err)
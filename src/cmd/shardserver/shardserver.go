@@ -0,0 +1,129 @@
+// Standalone server that runs a share of the world's shards, for frontends
+// configured with ShardServers.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+
+	"chunkymonkey/config"
+	"chunkymonkey/entity"
+	"chunkymonkey/gamerules"
+	"chunkymonkey/region"
+	"chunkymonkey/shardnet"
+	"chunkymonkey/shardserver"
+	. "chunkymonkey/types"
+	"chunkymonkey/worldstore"
+)
+
+var configFile = flag.String(
+	"config", "config.json",
+	"The JSON file containing the server configuration, as given to the frontend.")
+
+var serverIndex = flag.Int(
+	"index", 0,
+	"The index of this server's address within ShardServers in the configuration.")
+
+func usage() {
+	os.Stderr.WriteString("usage: " + os.Args[0] + " [flags] <world>\n")
+	flag.PrintDefaults()
+}
+
+// handleSignals saves the shards' chunks and exits when the process is
+// interrupted or terminated. The game rules are reloaded when the process is
+// hung up on, as /reload on the frontend does not reach shard servers.
+func handleSignals(cfg *config.Config, shardMgr *shardserver.LocalShardManager) {
+	for sig := range signal.Incoming {
+		switch sig {
+		case os.SIGINT, os.SIGTERM:
+			log.Printf("Received %v, writing chunks", sig)
+			shardMgr.Save()
+			log.Print("Shard server stopped")
+			os.Exit(0)
+		case os.SIGHUP:
+			log.Printf("Received %v", sig)
+			if err := reloadRules(cfg); err != nil {
+				log.Print("Error reloading the game rules: ", err)
+			}
+		}
+	}
+}
+
+// reloadRules loads again the game rules in the files given by the
+// configuration. The other settings keep the values that they had when the
+// server started.
+func reloadRules(cfg *config.Config) os.Error {
+	cfg, err := cfg.Reload()
+	if err != nil {
+		return err
+	}
+
+	files := &cfg.Files
+	if err = gamerules.ReloadRules(files.Recipes, files.Furnace, files.Spawning, files.Users, files.Groups, files.Regions); err != nil {
+		return err
+	}
+
+	log.Print("Reloaded the game rules")
+	return nil
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadConfigFromFile(*configFile)
+	if err != nil {
+		log.Print("Error loading configuration: ", err)
+		os.Exit(1)
+	}
+
+	addrs := cfg.ShardServers
+	if *serverIndex < 0 || *serverIndex >= len(addrs) {
+		log.Printf("-index %d is not the index of an address in ShardServers", *serverIndex)
+		os.Exit(1)
+	}
+
+	shardserver.ChunkIdleTicks = Ticks(cfg.ChunkIdleSecs) * TicksPerSecond
+	shardserver.TicksBetweenSaves = Ticks(cfg.SaveIntervalSecs) * TicksPerSecond
+	region.SpawnProtectionRadius = cfg.SpawnProtection
+
+	files := &cfg.Files
+	err = gamerules.LoadGameRules(files.Blocks, files.Items, files.Recipes, files.Furnace, files.Spawning, files.Users, files.Groups, files.Regions)
+	if err != nil {
+		log.Print("Error loading game rules: ", err)
+		os.Exit(1)
+	}
+
+	// The world must already have been created by the frontend.
+	worldStore, err := worldstore.LoadWorldStore(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	gamerules.Regions.SetSpawn(&worldStore.SpawnPosition)
+
+	var entityMgr entity.EntityManager
+	entityMgr.InitRange(shardnet.EntityIdRange(*serverIndex))
+
+	shardMap := shardnet.NewShardMap(addrs)
+	shardMgr := shardserver.NewLocalShardManager(worldStore.ChunkStore, worldStore.Populator, DimensionNormal, &entityMgr, worldStore.Time)
+	router := shardnet.NewRouter(shardMap, *serverIndex, shardMgr)
+	shardMgr.SetShardConnecter(router)
+
+	go handleSignals(cfg, shardMgr)
+
+	listener, err := net.Listen("tcp", addrs[*serverIndex])
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Serving shards on ", addrs[*serverIndex])
+
+	shardnet.NewServer(router, func() { shardMgr.Save() }).Serve(listener)
+}
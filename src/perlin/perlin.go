@@ -14,6 +14,7 @@ type PerlinNoise struct {
 	seed   int64
 	permut [256]int
 	g2d    [256][2]float64 // Randomly generated 2D unit vectors.
+	g3d    [256][3]float64 // Randomly generated 3D unit vectors.
 }
 
 func NewPerlinNoise(seed int64) *PerlinNoise {
@@ -41,6 +42,13 @@ func NewPerlinNoise(seed int64) *PerlinNoise {
 		normVector(gen.g2d[i][:])
 	}
 
+	// Initialize gen.g3d.
+	source.Seed(seed)
+	for i := range perm {
+		randVector(gen.g3d[i][:], rnd)
+		normVector(gen.g3d[i][:])
+	}
+
 	return gen
 }
 
@@ -82,6 +90,43 @@ func (gen *PerlinNoise) At2d(x, y float64) float64 {
 	return a + sy*(b-a)
 }
 
+func (gen *PerlinNoise) grad3d(x, y, z int) *[3]float64 {
+	gradIndex := x&0xff + gen.permut[(y&0xff+gen.permut[z&0xff])&0xff]
+	return &gen.g3d[gradIndex&0xff]
+}
+
+// At3d returns the noise value at a given 3D point.
+func (gen *PerlinNoise) At3d(x, y, z float64) float64 {
+	x0 := floor(x)
+	y0 := floor(y)
+	z0 := floor(z)
+	ix, iy, iz := int(x0), int(y0), int(z0)
+
+	// dot returns the dot product of the gradient at the corner offset by
+	// (cx,cy,cz) from (x0,y0,z0), and the vector from that corner to the
+	// point.
+	dot := func(cx, cy, cz int) float64 {
+		grad := gen.grad3d(ix+cx, iy+cy, iz+cz)
+		return grad[0]*(x-x0-float64(cx)) + grad[1]*(y-y0-float64(cy)) + grad[2]*(z-z0-float64(cz))
+	}
+
+	dx := x - x0
+	sx := 3*dx*dx - 2*dx*dx*dx
+	dy := y - y0
+	sy := 3*dy*dy - 2*dy*dy*dy
+	dz := z - z0
+	sz := 3*dz*dz - 2*dz*dz*dz
+
+	// Interpolate along x for each of the four edges of the cube, then along
+	// y, then along z, using the same "ease" function as At2d.
+	a0 := lerp(sx, dot(0, 0, 0), dot(1, 0, 0))
+	b0 := lerp(sx, dot(0, 1, 0), dot(1, 1, 0))
+	a1 := lerp(sx, dot(0, 0, 1), dot(1, 0, 1))
+	b1 := lerp(sx, dot(0, 1, 1), dot(1, 1, 1))
+
+	return lerp(sz, lerp(sy, a0, b0), lerp(sy, a1, b1))
+}
+
 func (gen *PerlinNoise) MeanMagnitude() float64 {
 	return 0.5
 }
@@ -97,6 +142,10 @@ func floor(n float64) float64 {
 	return float64(int32(n) - 1)
 }
 
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
 // randVector generates a random vector whose components are each in the range
 // [-1, 1). The dimensionality of the vector is len(c).
 func randVector(c []float64, rnd *rand.Rand) {
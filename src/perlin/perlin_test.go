@@ -12,3 +12,33 @@ func Benchmark_Perlin_At2d(b *testing.B) {
 		n.At2d(0, 0)
 	}
 }
+
+func Benchmark_Perlin_At3d(b *testing.B) {
+	n := NewPerlinNoise(0)
+	b.ResetTimer()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		n.At3d(0, 0, 0)
+	}
+}
+
+func Test_Perlin_At3d(t *testing.T) {
+	n := NewPerlinNoise(0)
+
+	// Gradient noise is zero at the lattice points.
+	if v := n.At3d(3, -2, 7); v != 0 {
+		t.Errorf("expected 0 at a lattice point, got %v", v)
+	}
+
+	// Noise is continuous, so nearby points have nearby values.
+	a := n.At3d(3.5, -1.5, 7.5)
+	b := n.At3d(3.5001, -1.5, 7.5)
+	if d := a - b; d > 0.01 || d < -0.01 {
+		t.Errorf("expected nearby points to have nearby values, got %v and %v", a, b)
+	}
+
+	// Values depend on all three coordinates.
+	if a == n.At3d(3.5, -1.5, 8.5) {
+		t.Errorf("expected different values at different z, got %v for both", a)
+	}
+}